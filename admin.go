@@ -0,0 +1,234 @@
+package mailbus
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/quantonganh/mailbus/pkg/totp"
+)
+
+// MinPasswordLength is the minimum length of the password of an admin
+const MinPasswordLength = 10
+
+// Admin role
+const (
+	// RoleViewer reads subscribers, lists, campaigns and their reports
+	RoleViewer = "viewer"
+	// RoleEditor also changes them, sends campaigns, exports subscribers and manages the suppression list
+	RoleEditor = "editor"
+	// RoleOwner also manages the admins and reads the audit log
+	RoleOwner = "owner"
+)
+
+// Roles are all the roles of admins, from the least to the most powerful
+var Roles = []string{RoleViewer, RoleEditor, RoleOwner}
+
+// roleScopes are the scopes granted to each role, the same that gate API keys
+var roleScopes = map[string][]string{
+	RoleViewer: {ScopeSubscribersRead, ScopeListsRead, ScopeCampaignsRead},
+	RoleEditor: {
+		ScopeSubscribersRead, ScopeSubscribersWrite, ScopeSubscribersExport,
+		ScopeListsRead, ScopeListsWrite,
+		ScopeCampaignsRead, ScopeCampaignsWrite, ScopeCampaignsSend,
+		ScopeSuppressionsRead, ScopeSuppressionsWrite,
+	},
+	RoleOwner: Scopes,
+}
+
+// totpIssuer names the accounts of admins in authenticator apps
+const totpIssuer = "mailbus"
+
+var adminUsernamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._@-]*$`)
+
+// AdminService is the interface that wraps methods related to the people who log in to the admin UI
+type AdminService interface {
+	FindAll() ([]Admin, error)
+	FindByID(id int) (*Admin, error)
+	FindByUsername(username string) (*Admin, error)
+	Create(a *Admin) error
+	Update(a *Admin) error
+	Delete(id int) error
+}
+
+// Admin represents someone who logs in to the admin UI, only the bcrypt hash of their password is stored
+type Admin struct {
+	ID           int    `json:"id"`
+	Username     string `json:"username"`
+	Role         string `json:"role"`
+	PasswordHash string `json:"-"`
+	// TOTPSecret is the secret of the two-factor authentication of the admin, empty if they did not enable it
+	TOTPSecret string `json:"-"`
+	// TOTPStep is the step of the last code the admin logged in with, which cannot be used again
+	TOTPStep  int64     `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NewAdmin returns an admin with a role logging in with username and password
+func NewAdmin(username, password, role string) (*Admin, error) {
+	username = strings.TrimSpace(username)
+	if !adminUsernamePattern.MatchString(username) {
+		return nil, &Error{
+			Code:    ErrInvalid,
+			Message: "Username must start with a letter or a digit, followed by letters, digits, '.', '_', '@' or '-'.",
+			Op:      "NewAdmin",
+		}
+	}
+
+	a := &Admin{Username: username}
+	if err := a.SetRole(role); err != nil {
+		return nil, err
+	}
+	if err := a.SetPassword(password); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// SetRole changes the role of the admin
+func (a *Admin) SetRole(role string) error {
+	if _, ok := roleScopes[role]; !ok {
+		return &Error{
+			Code:    ErrInvalid,
+			Message: fmt.Sprintf("Unknown role %q, use one of %s.", role, strings.Join(Roles, ", ")),
+			Op:      "Admin.SetRole",
+		}
+	}
+	a.Role = role
+	return nil
+}
+
+// Allows reports whether the role of the admin grants a scope
+func (a *Admin) Allows(scope string) bool {
+	for _, s := range roleScopes[a.Role] {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOwner reports whether the admin manages the other admins
+func (a *Admin) IsOwner() bool {
+	return a.Role == RoleOwner
+}
+
+// TwoFactor reports whether the admin logs in with a code of their authenticator app on top of their password
+func (a *Admin) TwoFactor() bool {
+	return a.TOTPSecret != ""
+}
+
+// TOTPURL returns the otpauth URL authenticator apps import a secret of the admin from
+func (a *Admin) TOTPURL(secret string) string {
+	return totp.URL(totpIssuer, a.Username, secret)
+}
+
+// EnableTwoFactor turns two-factor authentication on with secret, once the admin
+// proved their authenticator app has it by typing its current code
+func (a *Admin) EnableTwoFactor(secret, code string, now time.Time) error {
+	step, ok := totp.Validate(secret, code, now)
+	if !ok {
+		return &Error{Code: ErrInvalid, Message: "Invalid code, please check the clock of your device.", Op: "Admin.EnableTwoFactor"}
+	}
+
+	a.TOTPSecret = secret
+	a.TOTPStep = step
+	return nil
+}
+
+// DisableTwoFactor turns two-factor authentication off, for admins who lost their device
+func (a *Admin) DisableTwoFactor() {
+	a.TOTPSecret = ""
+	a.TOTPStep = 0
+}
+
+// CheckCode reports whether code is the current code of the authenticator app of the admin. A code is only
+// accepted once: on success TOTPStep moves forward, and the admin must be saved for it to stick.
+func (a *Admin) CheckCode(code string, now time.Time) bool {
+	step, ok := totp.Validate(a.TOTPSecret, code, now)
+	if !ok || step <= a.TOTPStep {
+		return false
+	}
+
+	a.TOTPStep = step
+	return true
+}
+
+// SetPassword changes the password of the admin
+func (a *Admin) SetPassword(password string) error {
+	const op = "Admin.SetPassword"
+
+	if len(password) < MinPasswordLength {
+		return &Error{
+			Code:    ErrInvalid,
+			Message: fmt.Sprintf("Password must be at least %d characters long.", MinPasswordLength),
+			Op:      op,
+		}
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		// bcrypt only takes passwords of up to 72 bytes
+		return &Error{Code: ErrInvalid, Message: "Password is too long.", Op: op, Err: err}
+	}
+	a.PasswordHash = string(hash)
+	return nil
+}
+
+// CheckPassword reports whether password is the one of the admin
+func (a *Admin) CheckPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(a.PasswordHash), []byte(password)) == nil
+}
+
+// SessionService is the interface that wraps methods related to the sessions of the admin UI
+type SessionService interface {
+	FindByID(id string) (*Session, error)
+	Create(s *Session) error
+	Delete(id string) error
+	DeleteExpired(now time.Time) error
+}
+
+// Session represents an admin logged in to the admin UI. The browser holds the token of the session
+// in a cookie, the session is stored under the SHA-256 hash of the token, see SessionID.
+type Session struct {
+	ID        string `storm:"id"`
+	AdminID   int    `storm:"index"`
+	CreatedAt time.Time
+	ExpiresAt time.Time `storm:"index"`
+}
+
+// NewSession opens a session of an admin lasting ttl, and returns it along with its token
+func NewSession(adminID int, ttl time.Duration) (*Session, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", &Error{Code: ErrInternal, Op: "NewSession", Err: err}
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now()
+	return &Session{
+		ID:        SessionID(token),
+		AdminID:   adminID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}, token, nil
+}
+
+// SessionID returns the ID of the session of a token, so that a leaked database does not leak sessions
+func SessionID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Expired reports whether the session has expired at t
+func (s *Session) Expired(t time.Time) bool {
+	return !t.Before(s.ExpiresAt)
+}
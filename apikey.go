@@ -0,0 +1,152 @@
+package mailbus
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// API key scope
+const (
+	ScopeSubscribersRead   = "subscribers:read"
+	ScopeSubscribersWrite  = "subscribers:write"
+	ScopeSubscribersExport = "subscribers:export"
+	ScopeListsRead         = "lists:read"
+	ScopeListsWrite        = "lists:write"
+	ScopeCampaignsRead     = "campaigns:read"
+	ScopeCampaignsWrite    = "campaigns:write"
+	ScopeCampaignsSend     = "campaigns:send"
+	ScopeSuppressionsRead  = "suppressions:read"
+	ScopeSuppressionsWrite = "suppressions:write"
+	ScopeAuditRead         = "audit:read"
+)
+
+// Scopes are all the scopes an API key can be granted
+var Scopes = []string{
+	ScopeSubscribersRead, ScopeSubscribersWrite, ScopeSubscribersExport,
+	ScopeListsRead, ScopeListsWrite,
+	ScopeCampaignsRead, ScopeCampaignsWrite, ScopeCampaignsSend,
+	ScopeSuppressionsRead, ScopeSuppressionsWrite,
+	ScopeAuditRead,
+}
+
+// apiKeyPrefix starts every API key, so that leaked keys are easy to recognize
+const apiKeyPrefix = "mb_"
+
+// APIKeyService is the interface that wraps methods related to the API keys of the admin API
+type APIKeyService interface {
+	FindAll() ([]APIKey, error)
+	FindByPrefix(prefix string) (*APIKey, error)
+	Create(k *APIKey) error
+	Revoke(id int) error
+}
+
+// APIKey represents a key of the admin API. The key itself is shown once, when it is issued:
+// only its prefix, which finds it, and its SHA-256 hash, which verifies it, are stored.
+type APIKey struct {
+	ID     int      `json:"id"`
+	Name   string   `json:"name"`
+	Prefix string   `json:"prefix"`
+	Hash   string   `json:"-"`
+	Scopes []string `json:"scopes"`
+	// AdminID is the admin the key was issued to, whose role also limits what the key can do.
+	// Keys issued to nobody are only limited by their scopes.
+	AdminID   int       `json:"admin_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// RevokedAt is when the key was revoked, zero if it was not
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+// NewAPIKey issues a key named name granted scopes, and returns it along with the key itself
+func NewAPIKey(name string, scopes []string) (*APIKey, string, error) {
+	const op = "NewAPIKey"
+
+	if strings.TrimSpace(name) == "" {
+		return nil, "", &Error{Code: ErrInvalid, Message: "Name is required.", Op: op}
+	}
+	if len(scopes) == 0 {
+		return nil, "", &Error{Code: ErrInvalid, Message: "At least one scope is required.", Op: op}
+	}
+	for _, scope := range scopes {
+		if !validScope(scope) {
+			return nil, "", &Error{
+				Code:    ErrInvalid,
+				Message: fmt.Sprintf("Unknown scope %q, use one of %s.", scope, strings.Join(Scopes, ", ")),
+				Op:      op,
+			}
+		}
+	}
+
+	id := make([]byte, 6)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return nil, "", &Error{Code: ErrInternal, Op: op, Err: err}
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", &Error{Code: ErrInternal, Op: op, Err: err}
+	}
+
+	prefix := hex.EncodeToString(id)
+	key := apiKeyPrefix + prefix + "_" + base64.RawURLEncoding.EncodeToString(secret)
+	return &APIKey{
+		Name:      strings.TrimSpace(name),
+		Prefix:    prefix,
+		Hash:      hashAPIKey(key),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}, key, nil
+}
+
+// ParseAPIKey returns the prefix of a key, which finds it, and false if it does not look like a key
+func ParseAPIKey(key string) (string, bool) {
+	rest := strings.TrimPrefix(key, apiKeyPrefix)
+	if rest == key {
+		return "", false
+	}
+
+	i := strings.Index(rest, "_")
+	if i <= 0 || i == len(rest)-1 {
+		return "", false
+	}
+	return rest[:i], true
+}
+
+// Verify reports whether key is the one that was issued, the hashes are compared in constant time
+func (k *APIKey) Verify(key string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashAPIKey(key)), []byte(k.Hash)) == 1
+}
+
+// Revoked reports whether the key was revoked
+func (k *APIKey) Revoked() bool {
+	return !k.RevokedAt.IsZero()
+}
+
+// Allows reports whether the key was granted a scope
+func (k *APIKey) Allows(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// hashAPIKey hashes a key: keys are random and long, a fast hash is enough
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func validScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package mailbus
+
+import "time"
+
+// Audit action
+const (
+	AuditActionSubscriptionConfirm = "subscription.confirm"
+	AuditActionUnsubscribe         = "subscription.unsubscribe"
+	AuditActionSubscriberCreate    = "subscriber.create"
+	AuditActionSubscriberUpdate    = "subscriber.update"
+	AuditActionSubscriberDelete    = "subscriber.delete"
+	AuditActionSubscribersExport   = "subscribers.export"
+	AuditActionSubscribersImport   = "subscribers.import"
+	AuditActionListCreate          = "list.create"
+	AuditActionListUpdate          = "list.update"
+	AuditActionListDelete          = "list.delete"
+	AuditActionCampaignSchedule    = "campaign.schedule"
+	AuditActionCampaignCancel      = "campaign.cancel"
+	AuditActionCampaignDelete      = "campaign.delete"
+	AuditActionSuppressionCreate   = "suppression.create"
+	AuditActionSuppressionDelete   = "suppression.delete"
+	AuditActionAdminLogin          = "admin.login"
+	AuditActionAdminLoginFailed    = "admin.login_failed"
+	AuditActionAdminCreate         = "admin.create"
+	AuditActionAdminUpdate         = "admin.update"
+	AuditActionAdminDelete         = "admin.delete"
+	AuditActionAdminPassword       = "admin.password"
+	AuditActionTwoFactorEnable     = "admin.2fa_enable"
+	AuditActionTwoFactorDisable    = "admin.2fa_disable"
+	AuditActionAPIKeyIssue         = "apikey.issue"
+	AuditActionAPIKeyRevoke        = "apikey.revoke"
+)
+
+// AuditActions are all the actions recorded in the audit trail
+var AuditActions = []string{
+	AuditActionSubscriptionConfirm, AuditActionUnsubscribe,
+	AuditActionSubscriberCreate, AuditActionSubscriberUpdate, AuditActionSubscriberDelete,
+	AuditActionSubscribersExport, AuditActionSubscribersImport,
+	AuditActionListCreate, AuditActionListUpdate, AuditActionListDelete,
+	AuditActionCampaignSchedule, AuditActionCampaignCancel, AuditActionCampaignDelete,
+	AuditActionSuppressionCreate, AuditActionSuppressionDelete,
+	AuditActionAdminLogin, AuditActionAdminLoginFailed,
+	AuditActionAdminCreate, AuditActionAdminUpdate, AuditActionAdminDelete,
+	AuditActionAdminPassword, AuditActionTwoFactorEnable, AuditActionTwoFactorDisable,
+	AuditActionAPIKeyIssue, AuditActionAPIKeyRevoke,
+}
+
+// AuditService is the interface that wraps methods related to the audit trail.
+// The trail is append-only: entries can be recorded and searched, never changed.
+type AuditService interface {
+	Record(e *AuditEvent) error
+	Find(filter AuditFilter) ([]AuditEvent, error)
+}
+
+// AuditEvent represents a change made to the data, who made it and from where
+type AuditEvent struct {
+	ID        int       `storm:"id,increment" json:"id"`
+	Actor     string    `storm:"index" json:"actor"`
+	Action    string    `storm:"index" json:"action"`
+	Target    string    `json:"target"`
+	Details   string    `json:"details,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	CreatedAt time.Time `storm:"index" json:"created_at"`
+}
+
+// AuditFilter represents the criteria to search the audit trail, zero values match everything
+type AuditFilter struct {
+	Actor  string
+	Action string
+	Target string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
+
+// NewAuditEvent returns an event of an actor doing an action on a target
+func NewAuditEvent(actor, action, target, details string) *AuditEvent {
+	return &AuditEvent{
+		Actor:     actor,
+		Action:    action,
+		Target:    target,
+		Details:   details,
+		CreatedAt: time.Now(),
+	}
+}
@@ -0,0 +1,242 @@
+package bolt
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+	"github.com/go-errors/errors"
+
+	"github.com/quantonganh/mailbus"
+)
+
+// admin is how an admin is stored: storm encodes records to JSON, which leaves out the secrets of mailbus.Admin
+type admin struct {
+	ID           int    `storm:"id,increment"`
+	Username     string `storm:"unique"`
+	Role         string
+	PasswordHash string
+	TOTPSecret   string
+	TOTPStep     int64
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (a *admin) toAdmin() *mailbus.Admin {
+	return &mailbus.Admin{
+		ID:           a.ID,
+		Username:     a.Username,
+		Role:         a.role(),
+		PasswordHash: a.PasswordHash,
+		TOTPSecret:   a.TOTPSecret,
+		TOTPStep:     a.TOTPStep,
+		CreatedAt:    a.CreatedAt,
+		UpdatedAt:    a.UpdatedAt,
+	}
+}
+
+// role returns the role of the admin, admins saved before roles existed keep managing everything
+func (a *admin) role() string {
+	if a.Role == "" {
+		return mailbus.RoleOwner
+	}
+	return a.Role
+}
+
+type adminService struct {
+	db *DB
+}
+
+func NewAdminService(db *DB) mailbus.AdminService {
+	return &adminService{
+		db: db,
+	}
+}
+
+// FindAll returns every admin, by username
+func (as *adminService) FindAll() ([]mailbus.Admin, error) {
+	var records []admin
+	if err := as.db.stormDB.AllByIndex("Username", &records); err != nil {
+		return nil, errors.Errorf("failed to find admins: %v", err)
+	}
+
+	admins := make([]mailbus.Admin, 0, len(records))
+	for i := range records {
+		admins = append(admins, *records[i].toAdmin())
+	}
+	return admins, nil
+}
+
+// FindByID finds an admin by ID
+func (as *adminService) FindByID(id int) (*mailbus.Admin, error) {
+	return as.findOne("adminService.FindByID", "ID", id)
+}
+
+// FindByUsername finds an admin by username
+func (as *adminService) FindByUsername(username string) (*mailbus.Admin, error) {
+	return as.findOne("adminService.FindByUsername", "Username", username)
+}
+
+func (as *adminService) findOne(op, field string, value interface{}) (*mailbus.Admin, error) {
+	var record admin
+	if err := as.db.stormDB.One(field, value, &record); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil, &mailbus.Error{
+				Code:    mailbus.ErrNotFound,
+				Message: "Admin not found.",
+				Op:      op,
+			}
+		}
+		return nil, errors.Errorf("failed to find admin: %v", err)
+	}
+
+	return record.toAdmin(), nil
+}
+
+// Create saves a new admin
+func (as *adminService) Create(a *mailbus.Admin) error {
+	now := time.Now()
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = now
+	}
+	a.UpdatedAt = now
+
+	record := &admin{
+		Username:     a.Username,
+		Role:         a.Role,
+		PasswordHash: a.PasswordHash,
+		TOTPSecret:   a.TOTPSecret,
+		TOTPStep:     a.TOTPStep,
+		CreatedAt:    a.CreatedAt,
+		UpdatedAt:    a.UpdatedAt,
+	}
+	if err := as.db.stormDB.Save(record); err != nil {
+		if errors.Is(err, storm.ErrAlreadyExists) {
+			return &mailbus.Error{
+				Code:    mailbus.ErrConflict,
+				Message: fmt.Sprintf("Admin %q already exists.", a.Username),
+				Op:      "adminService.Create",
+			}
+		}
+		return errors.Errorf("failed to save: %v", err)
+	}
+	a.ID = record.ID
+
+	return nil
+}
+
+// Update saves the role, the password and the two-factor authentication of an admin
+func (as *adminService) Update(a *mailbus.Admin) error {
+	var record admin
+	if err := as.db.stormDB.One("ID", a.ID, &record); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return &mailbus.Error{
+				Code:    mailbus.ErrNotFound,
+				Message: "Admin not found.",
+				Op:      "adminService.Update",
+			}
+		}
+		return errors.Errorf("failed to find admin: %v", err)
+	}
+
+	a.UpdatedAt = time.Now()
+	record.Role = a.Role
+	record.PasswordHash = a.PasswordHash
+	record.TOTPSecret = a.TOTPSecret
+	record.TOTPStep = a.TOTPStep
+	record.UpdatedAt = a.UpdatedAt
+	if err := as.db.stormDB.Save(&record); err != nil {
+		return errors.Errorf("failed to save: %v", err)
+	}
+
+	return nil
+}
+
+// Delete deletes an admin, logging them out
+func (as *adminService) Delete(id int) error {
+	tx, err := as.db.stormDB.Begin(true)
+	if err != nil {
+		return errors.Errorf("failed to start a transaction: %v", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var record admin
+	if err := tx.One("ID", id, &record); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return &mailbus.Error{
+				Code:    mailbus.ErrNotFound,
+				Message: "Admin not found.",
+				Op:      "adminService.Delete",
+			}
+		}
+		return errors.Errorf("failed to find admin: %v", err)
+	}
+
+	if err := tx.Select(q.Eq("AdminID", id)).Delete(&mailbus.Session{}); err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return errors.Errorf("failed to delete sessions: %v", err)
+	}
+	if err := tx.DeleteStruct(&record); err != nil {
+		return errors.Errorf("failed to delete admin: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+type sessionService struct {
+	db *DB
+}
+
+func NewSessionService(db *DB) mailbus.SessionService {
+	return &sessionService{
+		db: db,
+	}
+}
+
+// FindByID finds a session by ID, expired sessions included
+func (ss *sessionService) FindByID(id string) (*mailbus.Session, error) {
+	var s mailbus.Session
+	if err := ss.db.stormDB.One("ID", id, &s); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil, &mailbus.Error{
+				Code:    mailbus.ErrNotFound,
+				Message: "Session not found.",
+				Op:      "sessionService.FindByID",
+			}
+		}
+		return nil, errors.Errorf("failed to find session: %v", err)
+	}
+
+	return &s, nil
+}
+
+// Create saves a new session
+func (ss *sessionService) Create(s *mailbus.Session) error {
+	if err := ss.db.stormDB.Save(s); err != nil {
+		return errors.Errorf("failed to save: %v", err)
+	}
+
+	return nil
+}
+
+// Delete deletes a session, it is not an error if there is none
+func (ss *sessionService) Delete(id string) error {
+	err := ss.db.stormDB.DeleteStruct(&mailbus.Session{ID: id})
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return errors.Errorf("failed to delete session: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteExpired deletes the sessions that have expired at now
+func (ss *sessionService) DeleteExpired(now time.Time) error {
+	err := ss.db.stormDB.Select(q.Lte("ExpiresAt", now)).Delete(&mailbus.Session{})
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return errors.Errorf("failed to delete expired sessions: %v", err)
+	}
+
+	return nil
+}
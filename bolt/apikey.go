@@ -0,0 +1,123 @@
+package bolt
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/go-errors/errors"
+
+	"github.com/quantonganh/mailbus"
+)
+
+// apiKey is how an API key is stored: storm encodes records to JSON, which leaves out the hash of mailbus.APIKey
+type apiKey struct {
+	ID        int `storm:"id,increment"`
+	Name      string
+	Prefix    string `storm:"unique"`
+	Hash      string
+	Scopes    []string
+	AdminID   int
+	CreatedAt time.Time
+	RevokedAt time.Time
+}
+
+func (k *apiKey) toAPIKey() mailbus.APIKey {
+	return mailbus.APIKey{
+		ID:        k.ID,
+		Name:      k.Name,
+		Prefix:    k.Prefix,
+		Hash:      k.Hash,
+		Scopes:    k.Scopes,
+		AdminID:   k.AdminID,
+		CreatedAt: k.CreatedAt,
+		RevokedAt: k.RevokedAt,
+	}
+}
+
+type apiKeyService struct {
+	db *DB
+}
+
+func NewAPIKeyService(db *DB) mailbus.APIKeyService {
+	return &apiKeyService{
+		db: db,
+	}
+}
+
+// FindAll returns every API key, revoked ones included, oldest first
+func (ks *apiKeyService) FindAll() ([]mailbus.APIKey, error) {
+	var records []apiKey
+	if err := ks.db.stormDB.All(&records); err != nil {
+		return nil, errors.Errorf("failed to find API keys: %v", err)
+	}
+
+	keys := make([]mailbus.APIKey, 0, len(records))
+	for i := range records {
+		keys = append(keys, records[i].toAPIKey())
+	}
+	return keys, nil
+}
+
+// FindByPrefix finds an API key by its prefix
+func (ks *apiKeyService) FindByPrefix(prefix string) (*mailbus.APIKey, error) {
+	var record apiKey
+	if err := ks.db.stormDB.One("Prefix", prefix, &record); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil, &mailbus.Error{
+				Code:    mailbus.ErrNotFound,
+				Message: "API key not found.",
+				Op:      "apiKeyService.FindByPrefix",
+			}
+		}
+		return nil, errors.Errorf("failed to find API key: %v", err)
+	}
+
+	k := record.toAPIKey()
+	return &k, nil
+}
+
+// Create saves a new API key
+func (ks *apiKeyService) Create(k *mailbus.APIKey) error {
+	if k.CreatedAt.IsZero() {
+		k.CreatedAt = time.Now()
+	}
+
+	record := &apiKey{
+		Name:      k.Name,
+		Prefix:    k.Prefix,
+		Hash:      k.Hash,
+		Scopes:    k.Scopes,
+		AdminID:   k.AdminID,
+		CreatedAt: k.CreatedAt,
+	}
+	if err := ks.db.stormDB.Save(record); err != nil {
+		return errors.Errorf("failed to save: %v", err)
+	}
+	k.ID = record.ID
+
+	return nil
+}
+
+// Revoke revokes an API key, it is kept so that what it did can still be traced to it
+func (ks *apiKeyService) Revoke(id int) error {
+	var k apiKey
+	err := ks.db.stormDB.One("ID", id, &k)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return errors.Errorf("failed to find API key: %v", err)
+	}
+	if err != nil || !k.RevokedAt.IsZero() {
+		return &mailbus.Error{
+			Code:    mailbus.ErrNotFound,
+			Message: fmt.Sprintf("API key %d not found, or already revoked.", id),
+			Op:      "apiKeyService.Revoke",
+		}
+	}
+
+	k.RevokedAt = time.Now()
+	if err := ks.db.stormDB.Save(&k); err != nil {
+		return errors.Errorf("failed to save: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package bolt
+
+import (
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+	"github.com/go-errors/errors"
+
+	"github.com/quantonganh/mailbus"
+)
+
+type auditService struct {
+	db *DB
+}
+
+func NewAuditService(db *DB) mailbus.AuditService {
+	return &auditService{
+		db: db,
+	}
+}
+
+// Record appends an event to the audit log
+func (as *auditService) Record(e *mailbus.AuditEvent) error {
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+
+	// events are only ever inserted, never saved over
+	e.ID = 0
+	if err := as.db.stormDB.Save(e); err != nil {
+		return errors.Errorf("failed to save: %v", err)
+	}
+
+	return nil
+}
+
+// Find finds audit events matching the filter, newest first
+func (as *auditService) Find(filter mailbus.AuditFilter) ([]mailbus.AuditEvent, error) {
+	var matchers []q.Matcher
+	if filter.Actor != "" {
+		matchers = append(matchers, q.Eq("Actor", filter.Actor))
+	}
+	if filter.Action != "" {
+		matchers = append(matchers, q.Eq("Action", filter.Action))
+	}
+	if filter.Target != "" {
+		matchers = append(matchers, q.Eq("Target", filter.Target))
+	}
+	if !filter.Since.IsZero() {
+		matchers = append(matchers, q.Gte("CreatedAt", filter.Since))
+	}
+	if !filter.Until.IsZero() {
+		matchers = append(matchers, q.Lt("CreatedAt", filter.Until))
+	}
+
+	query := as.db.stormDB.Select(matchers...).OrderBy("ID").Reverse()
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var events []mailbus.AuditEvent
+	if err := query.Find(&events); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, errors.Errorf("failed to find audit events: %v", err)
+	}
+
+	return events, nil
+}
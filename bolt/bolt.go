@@ -2,8 +2,16 @@ package bolt
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
 
 	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+	"go.etcd.io/bbolt"
+
+	"github.com/quantonganh/mailbus"
 )
 
 // DB represents a database
@@ -25,14 +33,157 @@ func NewDB(path string) *DB {
 	return db
 }
 
-// Open opens new database connection
+// openTimeout is how long Open waits for the lock of a database another process has open
+const openTimeout = time.Second
+
+// Open opens new database connection. A bolt database is opened by one process at a time,
+// so Open fails when the server, or another command, has it open.
 func (db *DB) Open() error {
-	stormDB, err := storm.Open(db.path)
+	stormDB, err := storm.Open(db.path, storm.BoltOptions(0600, &bbolt.Options{Timeout: openTimeout}))
+	if errors.Is(err, bbolt.ErrTimeout) {
+		return fmt.Errorf("database %s is in use by another process, stop the mailbus server first: %w", db.path, err)
+	}
 	if err != nil {
 		return err
 	}
 	db.stormDB = stormDB
 
+	if err := db.init(); err != nil {
+		return err
+	}
+	return db.migrate()
+}
+
+// init creates the default list on a fresh database
+func (db *DB) init() error {
+	var l mailbus.List
+	err := db.stormDB.One("Name", mailbus.DefaultList, &l)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, storm.ErrNotFound) {
+		return err
+	}
+
+	return db.stormDB.Save(&mailbus.List{
+		Name:      mailbus.DefaultList,
+		Title:     "Default",
+		CreatedAt: time.Now(),
+	})
+}
+
+const (
+	migrationBucket = "migrations"
+	migrationKey    = "version"
+)
+
+// migrations upgrade the records saved by older versions, in order.
+// Like the sqlite migrations, each of them runs once: the number applied is kept in the database.
+var migrations = []func(tx storm.Node) error{
+	backfillLists,
+	lowerEmails,
+	spendSettledTokens,
+}
+
+// migrate runs the migrations that have not been applied yet, each in a transaction of its own
+func (db *DB) migrate() error {
+	var version int
+	if err := db.stormDB.Get(migrationBucket, migrationKey, &version); err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return fmt.Errorf("failed to read migration version: %w", err)
+	}
+
+	for ; version < len(migrations); version++ {
+		tx, err := db.stormDB.Begin(true)
+		if err != nil {
+			return fmt.Errorf("failed to start a transaction: %w", err)
+		}
+		if err := migrations[version](tx); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("failed to run migration %d: %w", version+1, err)
+		}
+		if err := tx.Set(migrationBucket, migrationKey, version+1); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("failed to save migration version: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %d: %w", version+1, err)
+		}
+	}
+
+	return nil
+}
+
+// backfillLists moves the subscribers saved before there were lists to the default list
+func backfillLists(tx storm.Node) error {
+	var subscribers []mailbus.Subscriber
+	if err := tx.Select(q.Eq("List", "")).Find(&subscribers); err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return err
+	}
+
+	for i := range subscribers {
+		subscribers[i].List = mailbus.DefaultList
+		if err := tx.Save(&subscribers[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lowerEmails stores the addresses lower-cased, as they are looked up. A subscriber whose address differs
+// only by case from another one of the same list is a duplicate, it is deleted along with its tokens.
+func lowerEmails(tx storm.Node) error {
+	var subscribers []mailbus.Subscriber
+	if err := tx.All(&subscribers); err != nil {
+		return err
+	}
+
+	// the subscribers whose address is lower-cased already are kept first
+	sort.SliceStable(subscribers, func(i, j int) bool {
+		return subscribers[i].Email == mailbus.NormalizeEmail(subscribers[i].Email) &&
+			subscribers[j].Email != mailbus.NormalizeEmail(subscribers[j].Email)
+	})
+
+	kept := make(map[[2]string]bool, len(subscribers))
+	for i := range subscribers {
+		s := &subscribers[i]
+		email := mailbus.NormalizeEmail(s.Email)
+		key := [2]string{s.List, email}
+		if kept[key] {
+			if err := tx.Select(q.Eq("SubscriberID", s.ID)).Delete(new(subscriptionToken)); err != nil && !errors.Is(err, storm.ErrNotFound) {
+				return err
+			}
+			if err := tx.DeleteStruct(s); err != nil {
+				return err
+			}
+			continue
+		}
+
+		kept[key] = true
+		if s.Email != email {
+			s.Email = email
+			if err := tx.Save(s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// spendSettledTokens marks the tokens of the subscriptions that are no longer pending as used,
+// confirming spends them since then
+func spendSettledTokens(tx storm.Node) error {
+	var subscribers []mailbus.Subscriber
+	err := tx.Select(q.Not(q.Eq("Status", mailbus.StatusPendingConfirmation))).Find(&subscribers)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return err
+	}
+
+	now := time.Now()
+	for _, s := range subscribers {
+		if err := spendTokens(tx, s.ID, now); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,210 @@
+package bolt
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+
+	"github.com/quantonganh/mailbus"
+)
+
+func openDB(t *testing.T) *DB {
+	db := NewDB(filepath.Join(t.TempDir(), "mailbus.db"))
+	require.NoError(t, db.Open())
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	return db
+}
+
+func TestMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mailbus.db")
+
+	// a database created before there were lists
+	stormDB, err := storm.Open(path)
+	require.NoError(t, err)
+	require.NoError(t, stormDB.Save(&mailbus.Subscriber{Email: "Alice@Example.com", Status: mailbus.StatusActive}))
+	require.NoError(t, stormDB.Save(&mailbus.Subscriber{Email: "alice@example.com", Status: mailbus.StatusActive}))
+	require.NoError(t, stormDB.Close())
+
+	db := NewDB(path)
+	require.NoError(t, db.Open())
+	defer db.Close()
+
+	var version int
+	require.NoError(t, db.stormDB.Get(migrationBucket, migrationKey, &version))
+	assert.Equal(t, len(migrations), version)
+
+	subscriber, err := NewSubscriptionService(db).FindByEmail(mailbus.DefaultList, "alice@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, mailbus.StatusActive, subscriber.Status)
+
+	// the addresses that differed only by case are merged
+	subscribers, err := NewSubscriptionService(db).FindByStatus(mailbus.DefaultList, mailbus.StatusActive)
+	require.NoError(t, err)
+	require.Len(t, subscribers, 1)
+	assert.Equal(t, "alice@example.com", subscribers[0].Email)
+
+	// opening the database again runs no migration twice
+	require.NoError(t, db.stormDB.Save(&mailbus.Subscriber{Email: "bob@example.com", Status: mailbus.StatusActive}))
+	require.NoError(t, db.migrate())
+	_, err = NewSubscriptionService(db).FindByEmail(mailbus.DefaultList, "bob@example.com")
+	assert.Equal(t, mailbus.ErrNotFound, mailbus.ErrorCode(err))
+}
+
+func TestOpenInUse(t *testing.T) {
+	db := openDB(t)
+
+	err := NewDB(db.path).Open()
+	assert.ErrorIs(t, err, bbolt.ErrTimeout)
+	assert.Contains(t, err.Error(), "in use")
+}
+
+func TestFindByStatus(t *testing.T) {
+	db := openDB(t)
+	ls, ss := NewListService(db), NewSubscriptionService(db)
+	for _, name := range []string{"go", "rust"} {
+		require.NoError(t, ls.Create(&mailbus.List{Name: name}))
+	}
+
+	for _, s := range []*mailbus.Subscription{
+		mailbus.NewSubscription("go", "alice@example.com", mailbus.StatusActive, ""),
+		mailbus.NewSubscription("rust", "alice@example.com", mailbus.StatusActive, ""),
+		mailbus.NewSubscription("go", "bob@example.com", mailbus.StatusPendingConfirmation, "token"),
+		mailbus.NewSubscription("rust", "carol@example.com", mailbus.StatusActive, ""),
+	} {
+		require.NoError(t, ss.Insert(s))
+	}
+
+	subscribers, err := ss.FindByStatus("go", mailbus.StatusActive)
+	require.NoError(t, err)
+	require.Len(t, subscribers, 1)
+	assert.Equal(t, "alice@example.com", subscribers[0].Email)
+	assert.Equal(t, "go", subscribers[0].List)
+
+	subscribers, err = ss.FindByStatus("rust", mailbus.StatusActive)
+	require.NoError(t, err)
+	assert.Len(t, subscribers, 2)
+
+	// a list is subscribed to once, whatever the case of the address
+	err = ss.Insert(mailbus.NewSubscription("go", "Alice@Example.com", mailbus.StatusActive, ""))
+	assert.Equal(t, mailbus.ErrConflict, mailbus.ErrorCode(err))
+	subscriber, err := ss.FindByEmail("go", "ALICE@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", subscriber.Email)
+}
+
+func TestConfirm(t *testing.T) {
+	db := openDB(t)
+	ss := NewSubscriptionService(db)
+	require.NoError(t, ss.Insert(mailbus.NewSubscription(mailbus.DefaultList, "alice@example.com", mailbus.StatusPendingConfirmation, "token")))
+
+	subscriber, err := ss.Confirm("token")
+	require.NoError(t, err)
+	assert.Equal(t, mailbus.StatusActive, subscriber.Status)
+
+	// an old link does not bring back whoever unsubscribed since
+	require.NoError(t, ss.Unsubscribe(mailbus.DefaultList, "alice@example.com"))
+	_, err = ss.Confirm("token")
+	assert.Equal(t, mailbus.ErrConflict, mailbus.ErrorCode(err))
+	subscriber, err = ss.FindByEmail(mailbus.DefaultList, "alice@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, mailbus.StatusUnsubscribed, subscriber.Status)
+
+	_, err = ss.Confirm("unknown")
+	assert.Equal(t, mailbus.ErrNotFound, mailbus.ErrorCode(err))
+}
+
+func TestUnsubscribe(t *testing.T) {
+	db := openDB(t)
+	ss := NewSubscriptionService(db)
+	require.NoError(t, ss.Insert(mailbus.NewSubscription(mailbus.DefaultList, "alice@example.com", mailbus.StatusActive, "")))
+
+	require.NoError(t, ss.Unsubscribe(mailbus.DefaultList, "Alice@Example.com"))
+	// unsubscribing twice is fine, an address that is not subscribed is not found
+	require.NoError(t, ss.Unsubscribe(mailbus.DefaultList, "alice@example.com"))
+	err := ss.Unsubscribe(mailbus.DefaultList, "bob@example.com")
+	assert.Equal(t, mailbus.ErrNotFound, mailbus.ErrorCode(err))
+	assert.Equal(t, "bob@example.com is not subscribed to default.", mailbus.ErrorMessage(err))
+}
+
+func TestDeliveryUniqueness(t *testing.T) {
+	db := openDB(t)
+	cs, ds := NewCampaignService(db), NewDeliveryService(db)
+
+	c := mailbus.NewCampaign(mailbus.DefaultList, "Issue #1", "<p>Hello</p>")
+	require.NoError(t, cs.Create(c))
+
+	d := &mailbus.Delivery{CampaignID: c.ID, SubscriberID: 1, Email: "Alice@Example.com", Status: mailbus.DeliveryStatusSending}
+	require.NoError(t, ds.Create(d))
+	err := ds.Create(&mailbus.Delivery{CampaignID: c.ID, SubscriberID: 1, Email: "alice@example.com", Status: mailbus.DeliveryStatusSending})
+	assert.Equal(t, mailbus.ErrConflict, mailbus.ErrorCode(err))
+	require.NoError(t, ds.Create(&mailbus.Delivery{CampaignID: c.ID, SubscriberID: 2, Email: "bob@example.com", Status: mailbus.DeliveryStatusSending}))
+
+	deliveries, err := ds.Find(mailbus.DeliveryFilter{Email: "alice@example.com"})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, d.ID, deliveries[0].ID)
+}
+
+func TestCampaignStats(t *testing.T) {
+	db := openDB(t)
+	cs, ds, ts, bs := NewCampaignService(db), NewDeliveryService(db), NewTrackingService(db), NewBounceService(db)
+
+	c := mailbus.NewCampaign(mailbus.DefaultList, "Issue #1", "<p>Hello</p>")
+	require.NoError(t, cs.Create(c))
+
+	deliveries := []mailbus.Delivery{
+		{Status: mailbus.DeliveryStatusSent, SMTPCode: 250},
+		{Status: mailbus.DeliveryStatusSent, SMTPCode: 250},
+		{Status: mailbus.DeliveryStatusFailed, SMTPCode: 550},
+		{Status: mailbus.DeliveryStatusFailed},
+		{Status: mailbus.DeliveryStatusSuppressed},
+		{Status: mailbus.DeliveryStatusDeferred, SMTPCode: 451},
+		{Status: mailbus.DeliveryStatusSent, SMTPCode: 250},
+	}
+	for i := range deliveries {
+		deliveries[i].CampaignID, deliveries[i].SubscriberID = c.ID, i+1
+		require.NoError(t, ds.Create(&deliveries[i]))
+	}
+
+	// the rejection is recorded as a bounce too, and the last delivery bounced once it was sent
+	require.NoError(t, bs.Create(deliveries[2].Bounce()))
+	b := mailbus.NewBounce("grace@example.com", "5.1.1", "smtp; 550 5.1.1 User unknown", "dsn")
+	b.DeliveryID = deliveries[6].ID
+	require.NoError(t, bs.Create(b))
+
+	at := time.Date(2026, 10, 17, 8, 30, 0, 0, time.UTC)
+	for _, e := range []mailbus.TrackingEvent{
+		{Type: mailbus.TrackingEventOpen, SubscriberID: 1, CreatedAt: at},
+		{Type: mailbus.TrackingEventOpen, SubscriberID: 1, CreatedAt: at.Add(time.Hour)},
+		{Type: mailbus.TrackingEventOpen, SubscriberID: 2, Machine: true, CreatedAt: at},
+		{Type: mailbus.TrackingEventClick, SubscriberID: 1, URL: "https://example.com", CreatedAt: at},
+	} {
+		e.CampaignID = c.ID
+		require.NoError(t, ts.Record(&e))
+	}
+
+	stats, err := NewReportService(db).CampaignStats(c.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 7, stats.Recipients)
+	assert.Equal(t, 2, stats.Delivered)
+	assert.Equal(t, 3, stats.Failed)
+	assert.Equal(t, 2, stats.Bounced)
+	assert.Equal(t, 1, stats.Suppressed)
+	assert.Equal(t, 1, stats.Pending)
+	assert.Equal(t, 1, stats.Opened)
+	assert.Equal(t, 2, stats.Opens)
+	assert.Equal(t, 1, stats.MachineOpens)
+	assert.Equal(t, 1, stats.Clicked)
+	assert.Equal(t, 0.5, stats.Rates.Open)
+	assert.Equal(t, []mailbus.HourlyStats{
+		{Hour: at.Truncate(time.Hour), Opens: 1, Clicks: 1},
+		{Hour: at.Truncate(time.Hour).Add(time.Hour), Opens: 1},
+	}, stats.Hourly)
+}
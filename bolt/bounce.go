@@ -0,0 +1,58 @@
+package bolt
+
+import (
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+	"github.com/go-errors/errors"
+
+	"github.com/quantonganh/mailbus"
+)
+
+type bounceService struct {
+	db *DB
+}
+
+func NewBounceService(db *DB) mailbus.BounceService {
+	return &bounceService{
+		db: db,
+	}
+}
+
+// Create records a bounce
+func (bs *bounceService) Create(b *mailbus.Bounce) error {
+	if b.CreatedAt.IsZero() {
+		b.CreatedAt = time.Now()
+	}
+
+	if err := bs.db.stormDB.Save(b); err != nil {
+		return errors.Errorf("failed to save: %v", err)
+	}
+
+	return nil
+}
+
+// Find finds the bounces of an address, newest first
+func (bs *bounceService) Find(email string) ([]mailbus.Bounce, error) {
+	var bounces []mailbus.Bounce
+	if err := bs.db.stormDB.Select(q.Eq("Email", email)).OrderBy("ID").Reverse().Find(&bounces); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, errors.Errorf("failed to find bounces: %v", err)
+	}
+
+	return bounces, nil
+}
+
+// Count counts the bounces of the given type of an address since a point in time
+func (bs *bounceService) Count(email, bounceType string, since time.Time) (int, error) {
+	n, err := bs.db.stormDB.Select(q.Eq("Email", email), q.Eq("Type", bounceType), q.Gte("CreatedAt", since)).
+		Count(&mailbus.Bounce{})
+	if err != nil {
+		return 0, errors.Errorf("failed to count bounces: %v", err)
+	}
+
+	return n, nil
+}
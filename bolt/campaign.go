@@ -0,0 +1,122 @@
+package bolt
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+	"github.com/go-errors/errors"
+
+	"github.com/quantonganh/mailbus"
+)
+
+type campaignService struct {
+	db *DB
+}
+
+func NewCampaignService(db *DB) mailbus.CampaignService {
+	return &campaignService{
+		db: db,
+	}
+}
+
+// FindByID finds a campaign by ID
+func (cs *campaignService) FindByID(id int) (*mailbus.Campaign, error) {
+	var c mailbus.Campaign
+	if err := cs.db.stormDB.One("ID", id, &c); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil, &mailbus.Error{
+				Code:    mailbus.ErrNotFound,
+				Message: fmt.Sprintf("Campaign %d not found.", id),
+				Op:      "campaignService.FindByID",
+			}
+		}
+		return nil, errors.Errorf("failed to find campaign: %v", err)
+	}
+
+	return &c, nil
+}
+
+// Find finds campaigns matching the filter, newest first
+func (cs *campaignService) Find(filter mailbus.CampaignFilter) ([]mailbus.Campaign, error) {
+	var matchers []q.Matcher
+	if filter.List != "" {
+		matchers = append(matchers, q.Eq("List", filter.List))
+	}
+	if filter.Status != "" {
+		matchers = append(matchers, q.Eq("Status", filter.Status))
+	}
+	if !filter.Since.IsZero() {
+		matchers = append(matchers, q.Gte("CreatedAt", filter.Since))
+	}
+	if !filter.Until.IsZero() {
+		matchers = append(matchers, q.Lt("CreatedAt", filter.Until))
+	}
+
+	var campaigns []mailbus.Campaign
+	if err := cs.db.stormDB.Select(matchers...).OrderBy("ID").Reverse().Find(&campaigns); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, errors.Errorf("failed to find campaigns: %v", err)
+	}
+
+	return campaigns, nil
+}
+
+// FindDue finds scheduled campaigns whose time has come
+func (cs *campaignService) FindDue(now time.Time) ([]mailbus.Campaign, error) {
+	var campaigns []mailbus.Campaign
+	err := cs.db.stormDB.Select(q.Eq("Status", mailbus.CampaignStatusScheduled), q.Lte("ScheduledAt", now)).
+		OrderBy("ScheduledAt").
+		Find(&campaigns)
+	if err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, errors.Errorf("failed to find due campaigns: %v", err)
+	}
+
+	return campaigns, nil
+}
+
+// Create inserts a new campaign
+func (cs *campaignService) Create(c *mailbus.Campaign) error {
+	var l mailbus.List
+	if err := cs.db.stormDB.One("Name", c.List, &l); err != nil {
+		return &mailbus.Error{
+			Code:    mailbus.ErrNotFound,
+			Message: fmt.Sprintf("List %q not found.", c.List),
+			Err:     err,
+		}
+	}
+
+	now := time.Now()
+	c.CreatedAt = now
+	c.UpdatedAt = now
+	if err := cs.db.stormDB.Save(c); err != nil {
+		return errors.Errorf("failed to save: %v", err)
+	}
+
+	return nil
+}
+
+// Update saves all fields of an existing campaign
+func (cs *campaignService) Update(c *mailbus.Campaign) error {
+	c.UpdatedAt = time.Now()
+	if err := cs.db.stormDB.Save(c); err != nil {
+		return errors.Errorf("failed to save: %v", err)
+	}
+
+	return nil
+}
+
+// Delete deletes a campaign
+func (cs *campaignService) Delete(id int) error {
+	if err := cs.db.stormDB.DeleteStruct(&mailbus.Campaign{ID: id}); err != nil {
+		return errors.Errorf("failed to delete campaign: %v", err)
+	}
+
+	return nil
+}
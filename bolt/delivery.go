@@ -0,0 +1,124 @@
+package bolt
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+	"github.com/go-errors/errors"
+
+	"github.com/quantonganh/mailbus"
+)
+
+type deliveryService struct {
+	db *DB
+}
+
+func NewDeliveryService(db *DB) mailbus.DeliveryService {
+	return &deliveryService{
+		db: db,
+	}
+}
+
+// FindByID finds a delivery by ID
+func (ds *deliveryService) FindByID(id int) (*mailbus.Delivery, error) {
+	var d mailbus.Delivery
+	if err := ds.db.stormDB.One("ID", id, &d); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil, &mailbus.Error{
+				Code:    mailbus.ErrNotFound,
+				Message: fmt.Sprintf("Delivery %d not found.", id),
+				Op:      "deliveryService.FindByID",
+			}
+		}
+		return nil, errors.Errorf("failed to find delivery: %v", err)
+	}
+
+	return &d, nil
+}
+
+// Find finds deliveries matching the filter, newest first
+func (ds *deliveryService) Find(filter mailbus.DeliveryFilter) ([]mailbus.Delivery, error) {
+	var matchers []q.Matcher
+	if filter.CampaignID != 0 {
+		matchers = append(matchers, q.Eq("CampaignID", filter.CampaignID))
+	}
+	if filter.SubscriberID != 0 {
+		matchers = append(matchers, q.Eq("SubscriberID", filter.SubscriberID))
+	}
+	if filter.Email != "" {
+		matchers = append(matchers, q.NewFieldMatcher("Email", equalFoldMatcher(filter.Email)))
+	}
+	if filter.Status != "" {
+		matchers = append(matchers, q.Eq("Status", filter.Status))
+	}
+	if !filter.SentSince.IsZero() {
+		matchers = append(matchers, q.Gte("SentAt", filter.SentSince))
+	}
+
+	var deliveries []mailbus.Delivery
+	if err := ds.db.stormDB.Select(matchers...).OrderBy("ID").Reverse().Find(&deliveries); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, errors.Errorf("failed to find deliveries: %v", err)
+	}
+
+	return deliveries, nil
+}
+
+// FindDue finds deferred deliveries whose next attempt is due
+func (ds *deliveryService) FindDue(now time.Time) ([]mailbus.Delivery, error) {
+	var deliveries []mailbus.Delivery
+	err := ds.db.stormDB.Select(q.Eq("Status", mailbus.DeliveryStatusDeferred), q.Lte("NextAttemptAt", now)).
+		OrderBy("NextAttemptAt").
+		Find(&deliveries)
+	if err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, errors.Errorf("failed to find due deliveries: %v", err)
+	}
+
+	return deliveries, nil
+}
+
+// Create inserts a new delivery, there can only be one per campaign and subscriber
+func (ds *deliveryService) Create(d *mailbus.Delivery) error {
+	tx, err := ds.db.stormDB.Begin(true)
+	if err != nil {
+		return errors.Errorf("failed to start a transaction: %v", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var existing mailbus.Delivery
+	err = tx.Select(q.Eq("CampaignID", d.CampaignID), q.Eq("SubscriberID", d.SubscriberID)).First(&existing)
+	if err == nil {
+		return &mailbus.Error{
+			Code:    mailbus.ErrConflict,
+			Message: fmt.Sprintf("Campaign %d has already been delivered to subscriber %d.", d.CampaignID, d.SubscriberID),
+			Op:      "deliveryService.Create",
+		}
+	}
+	if !errors.Is(err, storm.ErrNotFound) {
+		return errors.Errorf("failed to find delivery: %v", err)
+	}
+
+	if err := tx.Save(d); err != nil {
+		return errors.Errorf("failed to save: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// Update saves the state of a delivery
+func (ds *deliveryService) Update(d *mailbus.Delivery) error {
+	if err := ds.db.stormDB.Save(d); err != nil {
+		return errors.Errorf("failed to save: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package bolt
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+	"github.com/go-errors/errors"
+
+	"github.com/quantonganh/mailbus"
+)
+
+type listService struct {
+	db *DB
+}
+
+func NewListService(db *DB) mailbus.ListService {
+	return &listService{
+		db: db,
+	}
+}
+
+// FindAll returns all lists ordered by name
+func (ls *listService) FindAll() ([]mailbus.List, error) {
+	var lists []mailbus.List
+	if err := ls.db.stormDB.AllByIndex("Name", &lists); err != nil {
+		return nil, errors.Errorf("failed to find lists: %v", err)
+	}
+
+	return lists, nil
+}
+
+// FindByName finds a list by its name
+func (ls *listService) FindByName(name string) (*mailbus.List, error) {
+	var l mailbus.List
+	if err := ls.db.stormDB.One("Name", name, &l); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil, &mailbus.Error{
+				Code:    mailbus.ErrNotFound,
+				Message: fmt.Sprintf("List %q not found.", name),
+				Op:      "listService.FindByName",
+			}
+		}
+		return nil, errors.Errorf("failed to find list: %v", err)
+	}
+
+	return &l, nil
+}
+
+// Create creates a new list
+func (ls *listService) Create(l *mailbus.List) error {
+	if l.CreatedAt.IsZero() {
+		l.CreatedAt = time.Now()
+	}
+
+	if err := ls.db.stormDB.Save(l); err != nil {
+		if errors.Is(err, storm.ErrAlreadyExists) {
+			return &mailbus.Error{
+				Code:    mailbus.ErrConflict,
+				Message: fmt.Sprintf("List %q already exists.", l.Name),
+				Op:      "listService.Create",
+			}
+		}
+		return errors.Errorf("failed to save: %v", err)
+	}
+
+	return nil
+}
+
+// Update saves the title, the description and the tracking settings of a list, its name cannot change
+func (ls *listService) Update(l *mailbus.List) error {
+	existing, err := ls.FindByName(l.Name)
+	if err != nil {
+		return err
+	}
+
+	existing.Title = l.Title
+	existing.Description = l.Description
+	existing.TrackOpens = l.TrackOpens
+	existing.TrackClicks = l.TrackClicks
+	if err := ls.db.stormDB.Save(existing); err != nil {
+		return errors.Errorf("failed to save: %v", err)
+	}
+	*l = *existing
+
+	return nil
+}
+
+// Delete deletes a list and all of its memberships
+func (ls *listService) Delete(name string) error {
+	l, err := ls.FindByName(name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := ls.db.stormDB.Begin(true)
+	if err != nil {
+		return errors.Errorf("failed to start a transaction: %v", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var subscribers []mailbus.Subscriber
+	if err := tx.Find("List", name, &subscribers); err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return errors.Errorf("failed to find subscribers: %v", err)
+	}
+	for i := range subscribers {
+		err := tx.Select(q.Eq("SubscriberID", subscribers[i].ID)).Delete(&subscriptionToken{})
+		if err != nil && !errors.Is(err, storm.ErrNotFound) {
+			return errors.Errorf("failed to delete tokens: %v", err)
+		}
+		if err := tx.DeleteStruct(&subscribers[i]); err != nil {
+			return errors.Errorf("failed to delete subscriber: %v", err)
+		}
+	}
+
+	if err := tx.DeleteStruct(l); err != nil {
+		return errors.Errorf("failed to delete list: %v", err)
+	}
+
+	return tx.Commit()
+}
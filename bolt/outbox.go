@@ -0,0 +1,59 @@
+package bolt
+
+import (
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+	"github.com/go-errors/errors"
+
+	"github.com/quantonganh/mailbus"
+)
+
+type outboxService struct {
+	db *DB
+}
+
+func NewOutboxService(db *DB) mailbus.OutboxService {
+	return &outboxService{
+		db: db,
+	}
+}
+
+// FindDue finds the pending messages whose next attempt is due, oldest first
+func (o *outboxService) FindDue(now time.Time) ([]mailbus.OutboxMessage, error) {
+	var messages []mailbus.OutboxMessage
+	err := o.db.stormDB.Select(q.Eq("Status", mailbus.OutboxStatusPending), q.Lte("NextAttemptAt", now)).
+		OrderBy("NextAttemptAt").
+		Find(&messages)
+	if err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, errors.Errorf("failed to find due outbox messages: %v", err)
+	}
+
+	return messages, nil
+}
+
+// Update saves the state of a message
+func (o *outboxService) Update(m *mailbus.OutboxMessage) error {
+	if err := o.db.stormDB.Save(m); err != nil {
+		return errors.Errorf("failed to save: %v", err)
+	}
+
+	return nil
+}
+
+// saveOutboxMessage writes a message, if any, to the outbox as part of a transaction
+func saveOutboxMessage(tx storm.Node, m *mailbus.OutboxMessage) error {
+	if m == nil {
+		return nil
+	}
+
+	if err := tx.Save(m); err != nil {
+		return errors.Errorf("failed to save outbox message: %v", err)
+	}
+
+	return nil
+}
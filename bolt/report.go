@@ -0,0 +1,82 @@
+package bolt
+
+import (
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+	"github.com/go-errors/errors"
+
+	"github.com/quantonganh/mailbus"
+)
+
+type reportService struct {
+	db *DB
+}
+
+func NewReportService(db *DB) mailbus.ReportService {
+	return &reportService{
+		db: db,
+	}
+}
+
+// CampaignStats aggregates the deliveries and the tracking events of a campaign
+func (rs *reportService) CampaignStats(campaignID int) (*mailbus.CampaignStats, error) {
+	stats := mailbus.NewCampaignStats(campaignID)
+
+	var deliveries []mailbus.Delivery
+	if err := rs.db.stormDB.Select(q.Eq("CampaignID", campaignID)).Find(&deliveries); err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, errors.Errorf("failed to find deliveries: %v", err)
+	}
+	// the deliveries that bounced after they were sent are known by their bounces
+	ids := make([]int, len(deliveries))
+	for i := range deliveries {
+		ids[i] = deliveries[i].ID
+	}
+	var bounces []mailbus.Bounce
+	if err := rs.db.stormDB.Select(q.In("DeliveryID", ids)).Find(&bounces); err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, errors.Errorf("failed to find bounces: %v", err)
+	}
+	bounced := make(map[int]bool, len(bounces))
+	for _, b := range bounces {
+		bounced[b.DeliveryID] = true
+	}
+
+	for i := range deliveries {
+		n := 0
+		if deliveries[i].Bounce() != nil || bounced[deliveries[i].ID] {
+			n = 1
+		}
+		stats.CountDeliveries(deliveries[i].Status, 1, n)
+	}
+
+	var events []mailbus.TrackingEvent
+	if err := rs.db.stormDB.Select(q.Eq("CampaignID", campaignID)).Find(&events); err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, errors.Errorf("failed to find tracking events: %v", err)
+	}
+
+	type group struct {
+		eventType string
+		machine   bool
+	}
+	var (
+		totals      = make(map[group]int)
+		subscribers = make(map[group]map[int]bool)
+	)
+	for _, e := range events {
+		g := group{e.Type, e.Machine}
+		totals[g]++
+		if subscribers[g] == nil {
+			subscribers[g] = make(map[int]bool)
+		}
+		subscribers[g][e.SubscriberID] = true
+
+		if !e.Machine {
+			stats.CountHourly(e.Type, e.CreatedAt, 1)
+		}
+	}
+	for g, total := range totals {
+		stats.CountEvents(g.eventType, g.machine, total, len(subscribers[g]))
+	}
+
+	stats.ComputeRates()
+	return stats, nil
+}
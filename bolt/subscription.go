@@ -1,10 +1,26 @@
 package bolt
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
 	"github.com/go-errors/errors"
+
 	"github.com/quantonganh/mailbus"
 )
 
+// subscriptionToken maps a confirmation token to the subscription it was issued for
+type subscriptionToken struct {
+	Token        string `storm:"id"`
+	SubscriberID int    `storm:"index"`
+	IssuedAt     time.Time
+	ExpiresAt    time.Time
+	UsedAt       time.Time
+}
+
 type subscriptionService struct {
 	db *DB
 }
@@ -15,81 +31,422 @@ func NewSubscriptionService(db *DB) mailbus.SubscriptionService {
 	}
 }
 
-// FindByEmail finds a subscription by email
-func (ss *subscriptionService) FindByEmail(email string) (*mailbus.Subscriber, error) {
-	var s mailbus.Subscriber
-	if err := ss.db.stormDB.One("Email", email, &s); err != nil {
+// FindByEmail finds the subscription of an email to a list
+func (ss *subscriptionService) FindByEmail(list, email string) (*mailbus.Subscriber, error) {
+	// the index of the addresses is used, selecting on both fields would read every subscriber
+	var subscribers []mailbus.Subscriber
+	if err := ss.db.stormDB.Find("Email", mailbus.NormalizeEmail(email), &subscribers); err != nil && !errors.Is(err, storm.ErrNotFound) {
 		return nil, err
 	}
+	for i := range subscribers {
+		if subscribers[i].List == list {
+			return &subscribers[i], nil
+		}
+	}
 
-	return &s, nil
+	return nil, &mailbus.Error{
+		Code: mailbus.ErrNotFound,
+		Op:   "subscriptionService.FindByEmail",
+		Err:  storm.ErrNotFound,
+	}
 }
 
 // Insert inserts new subscription into stormDB
 func (ss *subscriptionService) Insert(s *mailbus.Subscription) error {
-	if err := ss.db.stormDB.Save(s); err != nil {
+	var l mailbus.List
+	if err := ss.db.stormDB.One("Name", s.List, &l); err != nil {
+		return errors.Errorf("failed to find list %s: %v", s.List, err)
+	}
+
+	tx, err := ss.db.stormDB.Begin(true)
+	if err != nil {
+		return errors.Errorf("failed to start a transaction: %v", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// the addresses are indexed on their own, so a list is subscribed to once by checking its subscribers
+	email := mailbus.NormalizeEmail(s.Email)
+	var subscribers []mailbus.Subscriber
+	if err := tx.Find("Email", email, &subscribers); err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return errors.Errorf("failed to find subscriber: %v", err)
+	}
+	for _, subscriber := range subscribers {
+		if subscriber.List == s.List {
+			return &mailbus.Error{
+				Code:    mailbus.ErrConflict,
+				Message: fmt.Sprintf("%s is already subscribed to list %q.", s.Email, s.List),
+				Op:      "subscriptionService.Insert",
+			}
+		}
+	}
+
+	subscriber := &mailbus.Subscriber{
+		Email:              email,
+		List:               s.List,
+		Status:             s.Status,
+		SubscribedAt:       startedAt(s),
+		ConfirmationSentAt: s.IssuedAt,
+	}
+	if err := tx.Save(subscriber); err != nil {
 		return errors.Errorf("failed to save: %v", err)
 	}
 
-	return nil
+	if err := saveToken(tx, s, subscriber.ID); err != nil {
+		return err
+	}
+
+	if err := saveOutboxMessage(tx, s.Message); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-// Update updates subscription status and new token
-func (ss *subscriptionService) Update(email, token string) error {
-	s, err := ss.FindByEmail(email)
+// Update sets a subscription back to pending confirmation with a new token. Subscriptions that were not pending
+// start over, the ones that were keep the time they became pending.
+func (ss *subscriptionService) Update(s *mailbus.Subscription) error {
+	subscriber, err := ss.FindByEmail(s.List, s.Email)
 	if err != nil {
 		return err
 	}
 
-	s.Status = mailbus.StatusPendingConfirmation
-	// s.Token = token
-	if err := ss.db.stormDB.Save(s); err != nil {
+	tx, err := ss.db.stormDB.Begin(true)
+	if err != nil {
+		return errors.Errorf("failed to start a transaction: %v", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if subscriber.Status != mailbus.StatusPendingConfirmation {
+		subscriber.Status = mailbus.StatusPendingConfirmation
+		subscriber.SubscribedAt = startedAt(s)
+		subscriber.RemindedAt = time.Time{}
+	}
+	subscriber.ConfirmationSentAt = s.IssuedAt
+	if s.Reminder {
+		subscriber.RemindedAt = s.IssuedAt
+	}
+	if err := tx.Save(subscriber); err != nil {
 		return errors.Errorf("failed to save: %v", err)
 	}
 
+	if err := saveToken(tx, s, subscriber.ID); err != nil {
+		return err
+	}
+
+	if err := saveOutboxMessage(tx, s.Message); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// startedAt returns when a subscription starts: when its first token is issued
+func startedAt(s *mailbus.Subscription) time.Time {
+	if s.IssuedAt.IsZero() {
+		return time.Now()
+	}
+	return s.IssuedAt
+}
+
+// saveToken saves the confirmation token of a subscription, signed tokens are not stored,
+// nor are the missing tokens of subscriptions that need no confirmation
+func saveToken(tx storm.Node, s *mailbus.Subscription, subscriberID int) error {
+	if s.Signed || s.Token == "" {
+		return nil
+	}
+
+	t := &subscriptionToken{
+		Token:        s.Token,
+		SubscriberID: subscriberID,
+		IssuedAt:     s.IssuedAt,
+		ExpiresAt:    s.ExpiresAt,
+	}
+	if err := tx.Save(t); err != nil {
+		return errors.Errorf("failed to save token: %v", err)
+	}
 	return nil
 }
 
 // FindByToken finds subscription by token
 func (ss *subscriptionService) FindByToken(token string) (*mailbus.Subscriber, error) {
-	var s mailbus.Subscriber
-	if err := ss.db.stormDB.One("Token", token, &s); err != nil {
+	var t subscriptionToken
+	if err := ss.db.stormDB.One("Token", token, &t); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil, &mailbus.Error{
+				Code: mailbus.ErrNotFound,
+				Op:   "subscriptionService.FindByToken",
+				Err:  err,
+			}
+		}
 		return nil, errors.Errorf("failed to find by token: %v", err)
 	}
+	if !t.ExpiresAt.IsZero() && time.Now().After(t.ExpiresAt) {
+		return nil, mailbus.TokenExpiredError("subscriptionService.FindByToken")
+	}
+
+	var s mailbus.Subscriber
+	if err := ss.db.stormDB.One("ID", t.SubscriberID, &s); err != nil {
+		return nil, errors.Errorf("failed to find subscriber %d: %v", t.SubscriberID, err)
+	}
 
 	return &s, nil
 }
 
-// FindByStatus finds subscription by status
-func (ss *subscriptionService) FindByStatus(status string) ([]mailbus.Subscriber, error) {
+// FindByStatus finds the subscriptions to a list by status
+func (ss *subscriptionService) FindByStatus(list, status string) ([]mailbus.Subscriber, error) {
 	var subscribes []mailbus.Subscriber
-	if err := ss.db.stormDB.Find("Status", status, &subscribes); err != nil {
+	if err := ss.db.stormDB.Select(q.Eq("List", list), q.Eq("Status", status)).Find(&subscribes); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil, nil
+		}
 		return nil, errors.Errorf("failed to find by status: %v", err)
 	}
 
 	return subscribes, nil
 }
 
-// Subscribe subscribes to newsletter
-func (ss *subscriptionService) Confirm(token string) (string, error) {
-	s, err := ss.FindByToken(token)
+// Find searches the subscriptions to every list, ordered by address and list
+func (ss *subscriptionService) Find(filter mailbus.SubscriberFilter) ([]mailbus.Subscriber, error) {
+	var matchers []q.Matcher
+	if filter.List != "" {
+		matchers = append(matchers, q.Eq("List", filter.List))
+	}
+	if filter.Status != "" {
+		matchers = append(matchers, q.Eq("Status", filter.Status))
+	}
+	if filter.Query != "" {
+		query := strings.ToLower(filter.Query)
+		matchers = append(matchers, q.NewFieldMatcher("Email", containsMatcher(query)))
+	}
+	if !filter.Since.IsZero() {
+		matchers = append(matchers, q.Gte("SubscribedAt", filter.Since))
+	}
+	if !filter.Until.IsZero() {
+		matchers = append(matchers, q.Lt("SubscribedAt", filter.Until))
+	}
+
+	query := ss.db.stormDB.Select(matchers...).OrderBy("Email", "List")
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query = query.Skip(filter.Offset)
+	}
+
+	var subscribers []mailbus.Subscriber
+	if err := query.Find(&subscribers); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, errors.Errorf("failed to find subscriptions: %v", err)
+	}
+
+	return subscribers, nil
+}
+
+// containsMatcher matches the strings that contain it, regardless of case
+type containsMatcher string
+
+func (m containsMatcher) MatchField(v interface{}) (bool, error) {
+	s, ok := v.(string)
+	return ok && strings.Contains(strings.ToLower(s), string(m)), nil
+}
+
+// equalFoldMatcher matches the strings equal to it, regardless of case
+type equalFoldMatcher string
+
+func (m equalFoldMatcher) MatchField(v interface{}) (bool, error) {
+	s, ok := v.(string)
+	return ok && strings.EqualFold(s, string(m)), nil
+}
+
+// FindPending finds the subscriptions to any list pending confirmation since before since
+func (ss *subscriptionService) FindPending(since time.Time) ([]mailbus.Subscriber, error) {
+	var subscribers []mailbus.Subscriber
+	err := ss.db.stormDB.Select(q.Eq("Status", mailbus.StatusPendingConfirmation), q.Lt("SubscribedAt", since)).
+		OrderBy("SubscribedAt").Find(&subscribers)
 	if err != nil {
-		return "", err
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, errors.Errorf("failed to find pending subscriptions: %v", err)
+	}
+
+	return subscribers, nil
+}
+
+// Purge deletes the subscriptions pending confirmation since before since, and their tokens
+func (ss *subscriptionService) Purge(since time.Time) (int, error) {
+	subscribers, err := ss.FindPending(since)
+	if err != nil || len(subscribers) == 0 {
+		return 0, err
+	}
+
+	tx, err := ss.db.stormDB.Begin(true)
+	if err != nil {
+		return 0, errors.Errorf("failed to start a transaction: %v", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	for i := range subscribers {
+		var tokens []subscriptionToken
+		if err := tx.Find("SubscriberID", subscribers[i].ID, &tokens); err != nil && !errors.Is(err, storm.ErrNotFound) {
+			return 0, errors.Errorf("failed to find tokens: %v", err)
+		}
+		for j := range tokens {
+			if err := tx.DeleteStruct(&tokens[j]); err != nil {
+				return 0, errors.Errorf("failed to delete token: %v", err)
+			}
+		}
+
+		if err := tx.DeleteStruct(&subscribers[i]); err != nil {
+			return 0, errors.Errorf("failed to delete: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(subscribers), nil
+}
+
+// Confirm activates the subscription the token was issued for. A token is used once: confirming spends it
+// along with the other tokens of the subscription, and the subscriptions that are no longer pending are left alone.
+func (ss *subscriptionService) Confirm(token string) (*mailbus.Subscriber, error) {
+	const op = "subscriptionService.Confirm"
+
+	tx, err := ss.db.stormDB.Begin(true)
+	if err != nil {
+		return nil, errors.Errorf("failed to start a transaction: %v", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var t subscriptionToken
+	if err := tx.One("Token", token, &t); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil, &mailbus.Error{
+				Code: mailbus.ErrNotFound,
+				Op:   op,
+				Err:  err,
+			}
+		}
+		return nil, errors.Errorf("failed to find by token: %v", err)
+	}
+
+	var s mailbus.Subscriber
+	if err := tx.One("ID", t.SubscriberID, &s); err != nil {
+		return nil, errors.Errorf("failed to find subscriber %d: %v", t.SubscriberID, err)
+	}
+
+	if !t.UsedAt.IsZero() || s.Status != mailbus.StatusPendingConfirmation {
+		return nil, mailbus.TokenUsedError(op)
+	}
+	if !t.ExpiresAt.IsZero() && time.Now().After(t.ExpiresAt) {
+		return nil, mailbus.TokenExpiredError(op)
+	}
+
+	s.Status = mailbus.StatusActive
+	if err := tx.Save(&s); err != nil {
+		return nil, errors.Errorf("failed to save: %v", err)
+	}
+	if err := spendTokens(tx, s.ID, time.Now()); err != nil {
+		return nil, errors.Errorf("failed to spend tokens: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// spendTokens marks the tokens of a subscriber that are not used yet as used at the given time
+func spendTokens(tx storm.Node, subscriberID int, usedAt time.Time) error {
+	var tokens []subscriptionToken
+	err := tx.Find("SubscriberID", subscriberID, &tokens)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return err
+	}
+
+	for i := range tokens {
+		if !tokens[i].UsedAt.IsZero() {
+			continue
+		}
+		tokens[i].UsedAt = usedAt
+		if err := tx.Save(&tokens[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete deletes the subscription of an email to a list and its tokens, the address stays in other lists
+func (ss *subscriptionService) Delete(list, email string) error {
+	s, err := ss.FindByEmail(list, email)
+	if err != nil {
+		if mailbus.ErrorCode(err) == mailbus.ErrNotFound {
+			return &mailbus.Error{
+				Code:    mailbus.ErrNotFound,
+				Message: fmt.Sprintf("%s is not subscribed to %s.", email, list),
+				Op:      "subscriptionService.Delete",
+			}
+		}
+		return err
+	}
+
+	tx, err := ss.db.stormDB.Begin(true)
+	if err != nil {
+		return errors.Errorf("failed to start a transaction: %v", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	err = tx.Select(q.Eq("SubscriberID", s.ID)).Delete(&subscriptionToken{})
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return errors.Errorf("failed to delete tokens: %v", err)
+	}
+	if err := tx.DeleteStruct(s); err != nil {
+		return errors.Errorf("failed to delete: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// Activate activates the subscription of an email to a list
+func (ss *subscriptionService) Activate(list, email string) (*mailbus.Subscriber, error) {
+	s, err := ss.FindByEmail(list, email)
+	if err != nil {
+		return nil, err
 	}
 
 	s.Status = mailbus.StatusActive
 	if err := ss.db.stormDB.Save(s); err != nil {
-		return "", err
+		return nil, errors.Errorf("failed to save: %v", err)
 	}
 
-	return s.Email, nil
+	return s, nil
 }
 
-// Unsubscribe unsubscribes from newsletter
-func (ss *subscriptionService) Unsubscribe(email string) error {
-	s, err := ss.FindByEmail(email)
+// Unsubscribe unsubscribes an email from a list
+func (ss *subscriptionService) Unsubscribe(list, email string) error {
+	s, err := ss.FindByEmail(list, email)
 	if err != nil {
-		return errors.Errorf("failed to find by email: %v", err)
+		if mailbus.ErrorCode(err) == mailbus.ErrNotFound {
+			return &mailbus.Error{
+				Code:    mailbus.ErrNotFound,
+				Message: fmt.Sprintf("%s is not subscribed to %s.", email, list),
+				Op:      "subscriptionService.Unsubscribe",
+			}
+		}
+		return err
 	}
 
 	s.Status = mailbus.StatusUnsubscribed
@@ -99,3 +456,27 @@ func (ss *subscriptionService) Unsubscribe(email string) error {
 
 	return nil
 }
+
+// MarkBounced marks the subscriptions of an email to every list as bounced
+func (ss *subscriptionService) MarkBounced(email string) error {
+	var subscribers []mailbus.Subscriber
+	err := ss.db.stormDB.Select(
+		q.Eq("Email", mailbus.NormalizeEmail(email)),
+		q.In("Status", []string{mailbus.StatusActive, mailbus.StatusPendingConfirmation}),
+	).Find(&subscribers)
+	if err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil
+		}
+		return errors.Errorf("failed to find by email: %v", err)
+	}
+
+	for i := range subscribers {
+		subscribers[i].Status = mailbus.StatusBounced
+		if err := ss.db.stormDB.Save(&subscribers[i]); err != nil {
+			return errors.Errorf("failed to save: %v", err)
+		}
+	}
+
+	return nil
+}
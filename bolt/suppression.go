@@ -0,0 +1,87 @@
+package bolt
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+	"github.com/go-errors/errors"
+
+	"github.com/quantonganh/mailbus"
+)
+
+type suppressionService struct {
+	db *DB
+}
+
+func NewSuppressionService(db *DB) mailbus.SuppressionService {
+	return &suppressionService{
+		db: db,
+	}
+}
+
+// FindAll returns the whole suppression list, newest first
+func (ss *suppressionService) FindAll() ([]mailbus.Suppression, error) {
+	var suppressions []mailbus.Suppression
+	if err := ss.db.stormDB.All(&suppressions, storm.Reverse()); err != nil {
+		return nil, errors.Errorf("failed to find suppressions: %v", err)
+	}
+
+	return suppressions, nil
+}
+
+// Match returns the entry that suppresses an address, either the address itself or its domain,
+// or nil if the address can be mailed
+func (ss *suppressionService) Match(email string) (*mailbus.Suppression, error) {
+	var s mailbus.Suppression
+	if err := ss.db.stormDB.Select(q.In("Value", mailbus.SuppressionKeys(email))).First(&s); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, errors.Errorf("failed to match suppressions: %v", err)
+	}
+
+	return &s, nil
+}
+
+// Create adds an entry to the suppression list
+func (ss *suppressionService) Create(s *mailbus.Suppression) error {
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = time.Now()
+	}
+
+	if err := ss.db.stormDB.Save(s); err != nil {
+		if errors.Is(err, storm.ErrAlreadyExists) {
+			return &mailbus.Error{
+				Code:    mailbus.ErrConflict,
+				Message: fmt.Sprintf("%s is already suppressed.", s.Value),
+				Op:      "suppressionService.Create",
+			}
+		}
+		return errors.Errorf("failed to save: %v", err)
+	}
+
+	return nil
+}
+
+// Delete removes an address or a domain from the suppression list
+func (ss *suppressionService) Delete(value string) error {
+	var s mailbus.Suppression
+	if err := ss.db.stormDB.One("Value", value, &s); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return &mailbus.Error{
+				Code:    mailbus.ErrNotFound,
+				Message: fmt.Sprintf("%s is not suppressed.", value),
+				Op:      "suppressionService.Delete",
+			}
+		}
+		return errors.Errorf("failed to find suppression: %v", err)
+	}
+
+	if err := ss.db.stormDB.DeleteStruct(&s); err != nil {
+		return errors.Errorf("failed to delete suppression: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package bolt
+
+import (
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+	"github.com/go-errors/errors"
+
+	"github.com/quantonganh/mailbus"
+)
+
+type trackingService struct {
+	db *DB
+}
+
+func NewTrackingService(db *DB) mailbus.TrackingService {
+	return &trackingService{
+		db: db,
+	}
+}
+
+// Record saves a tracking event
+func (ts *trackingService) Record(e *mailbus.TrackingEvent) error {
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+
+	e.ID = 0
+	if err := ts.db.stormDB.Save(e); err != nil {
+		return errors.Errorf("failed to save: %v", err)
+	}
+
+	return nil
+}
+
+// Find finds tracking events matching the filter, oldest first
+func (ts *trackingService) Find(filter mailbus.TrackingFilter) ([]mailbus.TrackingEvent, error) {
+	var matchers []q.Matcher
+	if filter.CampaignID != 0 {
+		matchers = append(matchers, q.Eq("CampaignID", filter.CampaignID))
+	}
+	if filter.DeliveryID != 0 {
+		matchers = append(matchers, q.Eq("DeliveryID", filter.DeliveryID))
+	}
+	if filter.Type != "" {
+		matchers = append(matchers, q.Eq("Type", filter.Type))
+	}
+
+	var events []mailbus.TrackingEvent
+	if err := ts.db.stormDB.Select(matchers...).OrderBy("ID").Find(&events); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, errors.Errorf("failed to find tracking events: %v", err)
+	}
+
+	return events, nil
+}
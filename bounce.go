@@ -0,0 +1,83 @@
+package mailbus
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Bounce type
+const (
+	BounceTypeHard = "hard"
+	BounceTypeSoft = "soft"
+)
+
+// BounceService is the interface that wraps methods related to bounces
+type BounceService interface {
+	Create(b *Bounce) error
+	Find(email string) ([]Bounce, error)
+	Count(email, bounceType string, since time.Time) (int, error)
+}
+
+// Bounce represents a message that could not be delivered to an address
+type Bounce struct {
+	ID         int       `storm:"id,increment" json:"id"`
+	Email      string    `storm:"index" json:"email"`
+	DeliveryID int       `storm:"index" json:"delivery_id,omitempty"` // the delivery that bounced, 0 if unknown
+	Type       string    `storm:"index" json:"type"`
+	Status     string    `json:"status"`
+	Diagnostic string    `json:"diagnostic"`
+	Source     string    `json:"source"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// BouncePolicy decides after how many bounces an address stops being mailed
+type BouncePolicy struct {
+	HardThreshold int
+	SoftThreshold int
+	Window        time.Duration
+}
+
+// Threshold returns the number of bounces of the given type after which an address is bounced
+func (p BouncePolicy) Threshold(bounceType string) int {
+	if bounceType == BounceTypeHard {
+		return p.HardThreshold
+	}
+	return p.SoftThreshold
+}
+
+var enhancedStatusCode = regexp.MustCompile(`^([245])\.\d{1,3}\.\d{1,3}`)
+
+// NewBounce returns a bounce of an address for an RFC 3463 status code such as 5.1.1
+func NewBounce(email, status, diagnostic, source string) *Bounce {
+	bounceType := BounceTypeSoft
+	if len(status) > 0 && status[0] == '5' {
+		bounceType = BounceTypeHard
+	}
+
+	return &Bounce{
+		Email:      email,
+		Type:       bounceType,
+		Status:     status,
+		Diagnostic: diagnostic,
+		Source:     source,
+		CreatedAt:  time.Now(),
+	}
+}
+
+// Bounce returns the bounce caused by a failed delivery, or nil if the failure was not a rejection
+func (d *Delivery) Bounce() *Bounce {
+	if d.Status != DeliveryStatusFailed || d.SMTPCode < 400 {
+		return nil
+	}
+
+	// prefer the enhanced status code the server put at the start of its reply
+	status := enhancedStatusCode.FindString(d.SMTPMessage)
+	if status == "" {
+		status = fmt.Sprintf("%d.0.0", d.SMTPCode/100)
+	}
+
+	b := NewBounce(d.Email, status, fmt.Sprintf("smtp; %d %s", d.SMTPCode, d.SMTPMessage), "smtp")
+	b.DeliveryID = d.ID
+	return b
+}
@@ -0,0 +1,79 @@
+package bounce
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotFeedbackReport is returned when a message is not an abuse feedback report
+var ErrNotFeedbackReport = errors.New("not a feedback report")
+
+// Complaint represents an RFC 5965 abuse feedback report about a message we sent
+type Complaint struct {
+	Email        string
+	FeedbackType string
+}
+
+// ParseFeedbackReport parses an RFC 5965 feedback report, such as the ones sent by feedback loops,
+// and returns the recipient who complained
+func ParseFeedbackReport(r io.Reader) (*Complaint, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read message")
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" || !strings.EqualFold(params["report-type"], "feedback-report") {
+		return nil, ErrNotFeedbackReport
+	}
+
+	var c Complaint
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read multipart report")
+		}
+
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		switch partType {
+		case "message/feedback-report":
+			fields, err := textproto.NewReader(bufio.NewReader(io.MultiReader(part, strings.NewReader("\r\n\r\n")))).ReadMIMEHeader()
+			if err != nil && err != io.EOF {
+				return nil, errors.Wrap(err, "failed to parse feedback report")
+			}
+			c.FeedbackType = strings.ToLower(strings.TrimSpace(fields.Get("Feedback-Type")))
+			if rcpt := addressField(fields.Get("Original-Rcpt-To")); rcpt != "" {
+				c.Email = rcpt
+			}
+		case "message/rfc822", "text/rfc822-headers":
+			// feedback loops often redact Original-Rcpt-To, the original message still says who it was sent to
+			if c.Email != "" {
+				continue
+			}
+			original, err := mail.ReadMessage(bufio.NewReader(io.MultiReader(part, strings.NewReader("\r\n\r\n"))))
+			if err != nil {
+				continue
+			}
+			if addr, err := mail.ParseAddress(original.Header.Get("To")); err == nil {
+				c.Email = strings.ToLower(addr.Address)
+			}
+		}
+	}
+
+	if c.Email == "" {
+		return nil, errors.New("feedback report does not identify the recipient")
+	}
+
+	return &c, nil
+}
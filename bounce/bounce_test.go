@@ -0,0 +1,245 @@
+package bounce
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	testifymock "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/mailbus"
+	"github.com/quantonganh/mailbus/mock"
+)
+
+const dsn = `From: Mail Delivery System <MAILER-DAEMON@mx.example.com>
+To: newsletter@example.com
+Subject: Undelivered Mail Returned to Sender
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=delivery-status;
+	boundary="BOUNDARY"
+
+--BOUNDARY
+Content-Type: text/plain
+
+This is the mail system at host mx.example.com.
+
+--BOUNDARY
+Content-Type: message/delivery-status
+
+Reporting-MTA: dns; mx.example.com
+Arrival-Date: Sat, 17 Oct 2026 07:00:01 +0000
+
+Final-Recipient: rfc822; Alice@Example.com
+Original-Recipient: rfc822;alice@example.com
+Action: failed
+Status: 5.1.1
+Diagnostic-Code: smtp; 550 5.1.1 <alice@example.com>: Recipient address rejected
+
+Final-Recipient: rfc822; bob@example.com
+Action: delayed
+Status: 4.4.1 (connection timed out)
+
+--BOUNDARY--
+`
+
+func TestParseDSN(t *testing.T) {
+	recipients, err := ParseDSN(strings.NewReader(dsn))
+	require.NoError(t, err)
+	require.Len(t, recipients, 2)
+
+	assert.Equal(t, Recipient{
+		Email:      "alice@example.com",
+		Action:     "failed",
+		Status:     "5.1.1",
+		Diagnostic: "smtp; 550 5.1.1 <alice@example.com>: Recipient address rejected",
+	}, recipients[0])
+	assert.Equal(t, "bob@example.com", recipients[1].Email)
+	assert.Equal(t, "4.4.1", recipients[1].Status)
+
+	_, err = ParseDSN(strings.NewReader("Subject: hello\r\n\r\nnot a bounce\r\n"))
+	assert.ErrorIs(t, err, ErrNotDSN)
+}
+
+func TestProcessMaildir(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"new", "cur", "tmp"} {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, sub), 0755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new", "1.mx"), []byte(dsn), 0644))
+
+	deliveryService := new(mock.DeliveryService)
+	deliveryService.On("Find", testifymock.Anything).Return([]mailbus.Delivery{
+		{ID: 2, Status: mailbus.DeliveryStatusFailed},
+		{ID: 1, Status: mailbus.DeliveryStatusSent},
+	}, nil)
+
+	// a failure is taken to be the one of the last message accepted, a delay is not the failure of any
+	bounceService := new(mock.BounceService)
+	bounceService.On("Create", testifymock.MatchedBy(func(b *mailbus.Bounce) bool {
+		return b.Email == "alice@example.com" && b.DeliveryID == 1 || b.Email == "bob@example.com" && b.DeliveryID == 0
+	})).Return(nil).Twice()
+	bounceService.On("Count", "alice@example.com", mailbus.BounceTypeHard, testifymock.Anything).Return(1, nil)
+	bounceService.On("Count", "bob@example.com", mailbus.BounceTypeSoft, testifymock.Anything).Return(1, nil)
+
+	subscriptionService := new(mock.SubscriptionService)
+	subscriptionService.On("MarkBounced", "alice@example.com").Return(nil)
+
+	suppressionService := new(mock.SuppressionService)
+	suppressionService.On("Create", testifymock.MatchedBy(func(s *mailbus.Suppression) bool {
+		return s.Value == "alice@example.com" && s.Source == mailbus.SuppressionSourceBounce
+	})).Return(nil)
+
+	p := &Processor{
+		BounceService:       bounceService,
+		SubscriptionService: subscriptionService,
+		DeliveryService:     deliveryService,
+		SuppressionService:  suppressionService,
+		Policy: mailbus.BouncePolicy{
+			HardThreshold: 1,
+			SoftThreshold: 3,
+		},
+	}
+	require.NoError(t, p.ProcessMaildir(dir))
+
+	bounceService.AssertExpectations(t)
+	subscriptionService.AssertExpectations(t)
+	subscriptionService.AssertNotCalled(t, "MarkBounced", "bob@example.com")
+	suppressionService.AssertExpectations(t)
+	_, err := os.Stat(filepath.Join(dir, "cur", "1.mx:2,S"))
+	assert.NoError(t, err)
+}
+
+const feedbackReport = `From: Feedback Loop <fbl@isp.example.net>
+To: abuse@example.com
+Subject: Abuse report
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=feedback-report;
+	boundary="BOUNDARY"
+
+--BOUNDARY
+Content-Type: text/plain
+
+This is an email abuse report.
+
+--BOUNDARY
+Content-Type: message/feedback-report
+
+Feedback-Type: abuse
+User-Agent: FBL/1.0
+Version: 1
+
+--BOUNDARY
+Content-Type: message/rfc822
+
+From: Mailbus <newsletter@example.com>
+To: Carol@Example.net
+Subject: Issue #1
+
+Hello
+--BOUNDARY--
+`
+
+func TestProcessFeedbackReport(t *testing.T) {
+	complaint, err := ParseFeedbackReport(strings.NewReader(feedbackReport))
+	require.NoError(t, err)
+	assert.Equal(t, &Complaint{Email: "carol@example.net", FeedbackType: "abuse"}, complaint)
+
+	deliveryService := new(mock.DeliveryService)
+	deliveryService.On("Find", mailbus.DeliveryFilter{Email: "carol@example.net"}).Return([]mailbus.Delivery{{ID: 1}}, nil)
+
+	suppressionService := new(mock.SuppressionService)
+	suppressionService.On("Create", testifymock.MatchedBy(func(s *mailbus.Suppression) bool {
+		return s.Value == "carol@example.net" && s.Source == mailbus.SuppressionSourceComplaint
+	})).Return(&mailbus.Error{Code: mailbus.ErrConflict})
+
+	p := &Processor{
+		DeliveryService:    deliveryService,
+		SuppressionService: suppressionService,
+	}
+	require.NoError(t, p.Process(strings.NewReader(feedbackReport)))
+	suppressionService.AssertExpectations(t)
+}
+
+func TestProcessMaildirQuarantine(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"new", "cur", "tmp"} {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, sub), 0755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new", "1.mx"), []byte(dsn), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new", "2.mx"), []byte(feedbackReport), 0644))
+
+	// the DSN fails to be processed, the feedback report after it still is
+	deliveryService := new(mock.DeliveryService)
+	deliveryService.On("Find", mailbus.DeliveryFilter{Email: "alice@example.com"}).Return(nil, errors.New("database is locked"))
+	deliveryService.On("Find", mailbus.DeliveryFilter{Email: "carol@example.net"}).Return([]mailbus.Delivery{{ID: 1}}, nil)
+
+	suppressionService := new(mock.SuppressionService)
+	suppressionService.On("Create", testifymock.AnythingOfType("*mailbus.Suppression")).Return(nil).Once()
+
+	p := &Processor{
+		DeliveryService:    deliveryService,
+		SuppressionService: suppressionService,
+	}
+	require.NoError(t, p.ProcessMaildir(dir))
+	suppressionService.AssertExpectations(t)
+
+	_, err := os.Stat(filepath.Join(dir, "quarantine", "1.mx"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "cur", "2.mx:2,S"))
+	assert.NoError(t, err)
+	entries, err := os.ReadDir(filepath.Join(dir, "new"))
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestProcessMbox(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bounces")
+	first := "From MAILER-DAEMON Sat Oct 17 07:00:01 2026\n" + dsn + "\n"
+	mbox := first +
+		"From fbl@isp.example.net Sat Oct 17 07:00:02 2026\n" + feedbackReport + "\n" +
+		"From fbl@isp.example.net Sat Oct 17 07:00:03 2026\n" + strings.Replace(feedbackReport, "Carol", "Dave", 1) + "\n"
+	require.NoError(t, os.WriteFile(path+".processing", []byte(mbox), 0644))
+	// a previous run stopped after the DSN, which must not be counted twice
+	require.NoError(t, os.WriteFile(path+".processing.offset", []byte(strconv.Itoa(len(first))), 0644))
+
+	deliveryService := new(mock.DeliveryService)
+	deliveryService.On("Find", mailbus.DeliveryFilter{Email: "carol@example.net"}).Return(nil, errors.New("database is locked"))
+	deliveryService.On("Find", mailbus.DeliveryFilter{Email: "dave@example.net"}).Return([]mailbus.Delivery{{ID: 2}}, nil)
+
+	suppressionService := new(mock.SuppressionService)
+	suppressionService.On("Create", testifymock.MatchedBy(func(s *mailbus.Suppression) bool {
+		return s.Value == "dave@example.net"
+	})).Return(nil).Once()
+
+	bounceService := new(mock.BounceService)
+	p := &Processor{
+		BounceService:      bounceService,
+		DeliveryService:    deliveryService,
+		SuppressionService: suppressionService,
+	}
+	require.NoError(t, p.ProcessMbox(path))
+	bounceService.AssertNotCalled(t, "Create", testifymock.Anything)
+	suppressionService.AssertExpectations(t)
+
+	for _, name := range []string{path + ".processing", path + ".processing.offset"} {
+		_, err := os.Stat(name)
+		assert.True(t, os.IsNotExist(err), name)
+	}
+
+	// the report that failed is kept aside, in an mbox of its own
+	var quarantined []string
+	f, err := os.Open(path + ".quarantine")
+	require.NoError(t, err)
+	defer f.Close()
+	require.NoError(t, splitMbox(f, func(msg []byte, n int, end int64) error {
+		quarantined = append(quarantined, string(msg))
+		return nil
+	}))
+	require.Len(t, quarantined, 1)
+	assert.Equal(t, feedbackReport+"\n", quarantined[0])
+}
@@ -0,0 +1,124 @@
+package bounce
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotDSN is returned when a message is not a delivery status notification
+var ErrNotDSN = errors.New("not a delivery status notification")
+
+// Recipient represents the per-recipient fields of a delivery status notification
+type Recipient struct {
+	Email      string
+	Action     string
+	Status     string
+	Diagnostic string
+}
+
+// Failed reports whether the notification is about a failed or delayed delivery
+func (r Recipient) Failed() bool {
+	return r.Action == "failed" || r.Action == "delayed"
+}
+
+// ParseDSN parses an RFC 3464 delivery status notification
+// and returns the recipients it reports on
+func ParseDSN(r io.Reader) ([]Recipient, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read message")
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" || !strings.EqualFold(params["report-type"], "delivery-status") {
+		return nil, ErrNotDSN
+	}
+
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, ErrNotDSN
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read multipart report")
+		}
+
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if partType == "message/delivery-status" || partType == "message/global-delivery-status" {
+			return parseDeliveryStatus(part)
+		}
+	}
+}
+
+// parseDeliveryStatus parses the body of a message/delivery-status part:
+// a block of per-message fields followed by one block per recipient
+func parseDeliveryStatus(r io.Reader) ([]Recipient, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read delivery status")
+	}
+
+	// the blocks use the header syntax, so make sure every one of them is terminated by a blank line
+	body = bytes.ReplaceAll(body, []byte("\r\n"), []byte("\n"))
+	tr := textproto.NewReader(bufio.NewReader(bytes.NewReader(append(body, '\n', '\n'))))
+
+	var recipients []Recipient
+	for {
+		// only per-recipient blocks have a Final-Recipient field
+		fields, err := tr.ReadMIMEHeader()
+		if rcpt, ok := newRecipient(fields); ok {
+			recipients = append(recipients, rcpt)
+		}
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, errors.Wrap(err, "failed to parse delivery status")
+		}
+	}
+
+	return recipients, nil
+}
+
+func newRecipient(fields textproto.MIMEHeader) (Recipient, bool) {
+	email := addressField(fields.Get("Final-Recipient"))
+	if email == "" {
+		email = addressField(fields.Get("Original-Recipient"))
+	}
+	if email == "" {
+		return Recipient{}, false
+	}
+
+	return Recipient{
+		Email:      email,
+		Action:     strings.ToLower(strings.TrimSpace(fields.Get("Action"))),
+		Status:     firstWord(fields.Get("Status")),
+		Diagnostic: strings.TrimSpace(fields.Get("Diagnostic-Code")),
+	}, true
+}
+
+// firstWord strips comments from a field such as "5.1.1 (bad destination mailbox)"
+func firstWord(v string) string {
+	if f := strings.Fields(v); len(f) > 0 {
+		return f[0]
+	}
+	return ""
+}
+
+// addressField extracts the address from a field such as "rfc822; alice@example.com"
+func addressField(v string) string {
+	if i := strings.Index(v, ";"); i >= 0 {
+		v = v[i+1:]
+	}
+	v = strings.Trim(strings.TrimSpace(v), "<>")
+	return strings.ToLower(v)
+}
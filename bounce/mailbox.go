@@ -0,0 +1,236 @@
+package bounce
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/pkg/errors"
+)
+
+// quarantineDir is the folder of a Maildir the messages that failed to be processed are moved to
+const quarantineDir = "quarantine"
+
+// ProcessMaildir processes the new messages of a Maildir and moves them to cur/ once they have been read.
+// Messages that fail to be processed are reported and moved to quarantine/, so that they are not retried forever.
+func (p *Processor) ProcessMaildir(dir string) error {
+	entries, err := os.ReadDir(filepath.Join(dir, "new"))
+	if err != nil {
+		return errors.Wrap(err, "failed to read maildir")
+	}
+
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+
+		name := e.Name()
+		path := filepath.Join(dir, "new", name)
+		if err := p.processFile(path); err != nil {
+			report(err, path)
+			if err := os.MkdirAll(filepath.Join(dir, quarantineDir), 0700); err != nil {
+				return errors.Wrap(err, "failed to create quarantine folder")
+			}
+			if err := os.Rename(path, filepath.Join(dir, quarantineDir, name)); err != nil {
+				return errors.Wrapf(err, "failed to move %s to quarantine", path)
+			}
+			continue
+		}
+
+		if !strings.Contains(name, ":2,") {
+			name += ":2,S"
+		}
+		if err := os.Rename(path, filepath.Join(dir, "cur", name)); err != nil {
+			return errors.Wrapf(err, "failed to move %s to cur", path)
+		}
+	}
+
+	return nil
+}
+
+func (p *Processor) processFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s", path)
+	}
+	defer f.Close()
+
+	return p.processMessage(f, path)
+}
+
+// processMessage processes a single message, messages that are neither DSNs nor feedback reports are skipped
+func (p *Processor) processMessage(r io.Reader, name string) error {
+	err := p.Process(r)
+	if errors.Is(err, ErrNotDSN) {
+		log.Printf("skipping %s: %v", name, err)
+		return nil
+	}
+	return err
+}
+
+// report logs and reports a message that failed to be processed and is put in quarantine
+func report(err error, name string) {
+	log.Printf("quarantining %s: %v", name, err)
+	sentry.CaptureException(errors.Wrapf(err, "failed to process %s", name))
+}
+
+// ProcessMbox processes the messages of an mbox file. The file is moved aside first
+// so that the MTA starts a new one, and removed once all of its messages have been processed.
+// The offset of the next message is saved after every message, so that a run that stops halfway
+// resumes where it stopped rather than counting the same bounces twice. Messages that fail
+// to be processed are reported and appended to the mbox with the .quarantine suffix.
+func (p *Processor) ProcessMbox(path string) error {
+	processing := path + ".processing"
+	if _, err := os.Stat(processing); os.IsNotExist(err) {
+		if err := os.Rename(path, processing); err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return errors.Wrapf(err, "failed to move %s aside", path)
+		}
+	}
+
+	f, err := os.Open(processing)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s", processing)
+	}
+	defer f.Close()
+
+	progress := processing + ".offset"
+	offset, err := readOffset(progress)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return errors.Wrapf(err, "failed to seek %s", processing)
+	}
+
+	if err := splitMbox(f, func(msg []byte, n int, end int64) error {
+		name := fmt.Sprintf("%s#%d", processing, n)
+		if err := p.processMessage(bytes.NewReader(msg), name); err != nil {
+			report(err, name)
+			if err := appendMbox(path+".quarantine", msg); err != nil {
+				return err
+			}
+		}
+		return writeOffset(progress, offset+end)
+	}); err != nil {
+		return err
+	}
+
+	// forget the offset first: should the mbox outlive it, its messages are processed again rather than skipped
+	if err := os.Remove(progress); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to remove %s", progress)
+	}
+	return os.Remove(processing)
+}
+
+// readOffset reads the offset saved by writeOffset, 0 when there is none
+func readOffset(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to read %s", path)
+	}
+
+	offset, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid offset in %s", path)
+	}
+	return offset, nil
+}
+
+// writeOffset saves an offset, replacing the previous one at once
+func writeOffset(path string, offset int64) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(offset, 10)), 0600); err != nil {
+		return errors.Wrapf(err, "failed to write %s", tmp)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return errors.Wrapf(err, "failed to save offset to %s", path)
+	}
+	return nil
+}
+
+// appendMbox appends a message to an mbox file, quoting its body lines that start with "From "
+func appendMbox(path string, msg []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s", path)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From MAILER-DAEMON %s\n", time.Now().UTC().Format(time.ANSIC))
+	for _, line := range bytes.SplitAfter(msg, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("From ")) {
+			buf.WriteByte('>')
+		}
+		buf.Write(line)
+	}
+	// end with the blank line that separates messages, which split messages still have
+	for !bytes.HasSuffix(buf.Bytes(), []byte("\n\n")) {
+		buf.WriteByte('\n')
+	}
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "failed to write to %s", path)
+	}
+	return f.Close()
+}
+
+// splitMbox calls fn with every message of an mbox, without its "From " separator line,
+// and the offset right after the message, where the next one starts
+func splitMbox(r io.Reader, fn func(msg []byte, n int, end int64) error) error {
+	br := bufio.NewReader(r)
+
+	var (
+		msg    bytes.Buffer
+		n      int
+		offset int64
+	)
+	flush := func(end int64) error {
+		if msg.Len() == 0 {
+			return nil
+		}
+		n++
+		err := fn(msg.Bytes(), n, end)
+		msg.Reset()
+		return err
+	}
+
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			start := offset
+			offset += int64(len(line))
+			if bytes.HasPrefix(line, []byte("From ")) {
+				if err := flush(start); err != nil {
+					return err
+				}
+			} else {
+				// undo the quoting of body lines that started with "From "
+				if bytes.HasPrefix(line, []byte(">From ")) {
+					line = line[1:]
+				}
+				msg.Write(line)
+			}
+		}
+		if err == io.EOF {
+			return flush(offset)
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to read mbox")
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package bounce
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/pkg/errors"
+
+	"github.com/quantonganh/mailbus"
+)
+
+// Processor records bounces and marks addresses as bounced once they reach the policy threshold.
+// Addresses that hard bounce or complain are also added to the suppression list.
+type Processor struct {
+	BounceService       mailbus.BounceService
+	SubscriptionService mailbus.SubscriptionService
+	DeliveryService     mailbus.DeliveryService
+	SuppressionService  mailbus.SuppressionService
+	Policy              mailbus.BouncePolicy
+
+	// Maildir and Mbox are the mailboxes DSNs are delivered to, if any
+	Maildir string
+	Mbox    string
+}
+
+// Process parses a delivery status notification, or an abuse feedback report,
+// and handles the failures or the complaint it reports
+func (p *Processor) Process(r io.Reader) error {
+	msg, err := io.ReadAll(r)
+	if err != nil {
+		return errors.Wrap(err, "failed to read message")
+	}
+
+	recipients, err := ParseDSN(bytes.NewReader(msg))
+	if errors.Is(err, ErrNotDSN) {
+		complaint, cerr := ParseFeedbackReport(bytes.NewReader(msg))
+		if errors.Is(cerr, ErrNotFeedbackReport) {
+			return err
+		}
+		if cerr != nil {
+			return cerr
+		}
+		return p.HandleComplaint(complaint)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, rcpt := range recipients {
+		if !rcpt.Failed() {
+			continue
+		}
+
+		// anyone can send us a DSN, so only trust it for addresses we have actually mailed
+		deliveries, err := p.deliveries(rcpt.Email)
+		if err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			log.Printf("ignoring DSN for %s: no delivery to this address", rcpt.Email)
+			continue
+		}
+
+		status := rcpt.Status
+		if status == "" {
+			status = "5.0.0"
+		}
+		b := mailbus.NewBounce(rcpt.Email, status, rcpt.Diagnostic, "dsn")
+		if rcpt.Action == "delayed" {
+			b.Type = mailbus.BounceTypeSoft
+		} else if d := lastSent(deliveries); d != nil {
+			// a DSN names neither the campaign nor the message: the failure is taken to be the one
+			// of the last message the mail server accepted for the address
+			b.DeliveryID = d.ID
+		}
+
+		if err := p.Handle(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Handle records a bounce and marks the address as bounced when it reaches the threshold for its type
+func (p *Processor) Handle(b *mailbus.Bounce) error {
+	if err := p.BounceService.Create(b); err != nil {
+		return err
+	}
+
+	threshold := p.Policy.Threshold(b.Type)
+	if threshold <= 0 {
+		return nil
+	}
+
+	var since time.Time
+	if p.Policy.Window > 0 {
+		since = time.Now().Add(-p.Policy.Window)
+	}
+
+	n, err := p.BounceService.Count(b.Email, b.Type, since)
+	if err != nil {
+		return err
+	}
+	if n < threshold {
+		return nil
+	}
+
+	log.Printf("marking %s as bounced after %d %s bounces", b.Email, n, b.Type)
+	if err := p.SubscriptionService.MarkBounced(b.Email); err != nil {
+		return err
+	}
+
+	if b.Type != mailbus.BounceTypeHard {
+		return nil
+	}
+	return p.suppress(b.Email, mailbus.SuppressionSourceBounce, fmt.Sprintf("%s %s", b.Status, b.Diagnostic))
+}
+
+// HandleComplaint suppresses an address whose owner reported one of our messages as spam
+func (p *Processor) HandleComplaint(c *Complaint) error {
+	deliveries, err := p.deliveries(c.Email)
+	if err != nil {
+		return err
+	}
+	if len(deliveries) == 0 {
+		log.Printf("ignoring complaint for %s: no delivery to this address", c.Email)
+		return nil
+	}
+
+	feedbackType := c.FeedbackType
+	if feedbackType == "" {
+		feedbackType = "abuse"
+	}
+	log.Printf("suppressing %s after a %s complaint", c.Email, feedbackType)
+	return p.suppress(c.Email, mailbus.SuppressionSourceComplaint, feedbackType+" report")
+}
+
+// deliveries finds the messages we have tried to deliver to an address, whatever its case, newest first
+func (p *Processor) deliveries(email string) ([]mailbus.Delivery, error) {
+	return p.DeliveryService.Find(mailbus.DeliveryFilter{Email: strings.ToLower(email)})
+}
+
+// lastSent returns the newest of the deliveries the mail server accepted, nil if it accepted none
+func lastSent(deliveries []mailbus.Delivery) *mailbus.Delivery {
+	for i := range deliveries {
+		if deliveries[i].Status == mailbus.DeliveryStatusSent {
+			return &deliveries[i]
+		}
+	}
+	return nil
+}
+
+// suppress adds an address to the suppression list, addresses that are already suppressed are left alone
+func (p *Processor) suppress(email, source, reason string) error {
+	if p.SuppressionService == nil {
+		return nil
+	}
+
+	s, err := mailbus.NewSuppression(email, source, strings.TrimSpace(reason))
+	if err != nil {
+		return err
+	}
+
+	err = p.SuppressionService.Create(s)
+	if mailbus.ErrorCode(err) == mailbus.ErrConflict {
+		return nil
+	}
+	return err
+}
+
+// Watch processes the configured mailboxes every interval until ctx is cancelled
+func (p *Processor) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if p.Maildir != "" {
+			if err := p.ProcessMaildir(p.Maildir); err != nil {
+				sentry.CaptureException(errors.Wrap(err, "failed to process maildir"))
+			}
+		}
+		if p.Mbox != "" {
+			if err := p.ProcessMbox(p.Mbox); err != nil {
+				sentry.CaptureException(errors.Wrap(err, "failed to process mbox"))
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package bounce
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"net"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	connTimeout    = 5 * time.Minute
+	maxMessageSize = 10 << 20
+)
+
+// Server is an inbound SMTP server that DSNs can be delivered to
+type Server struct {
+	ln        net.Listener
+	Addr      string
+	Processor *Processor
+}
+
+// Open starts accepting connections
+func (s *Server) Open() (err error) {
+	s.ln, err = net.Listen("tcp", s.Addr)
+	if err != nil {
+		return errors.Errorf("failed to listen to %s: %v", s.Addr, err)
+	}
+
+	go s.serve()
+
+	return nil
+}
+
+// Close stops accepting connections
+func (s *Server) Close() error {
+	if s.ln == nil {
+		return nil
+	}
+	return s.ln.Close()
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(connTimeout))
+
+	tp := textproto.NewConn(conn)
+	reply := func(code int, msg string) {
+		_ = tp.PrintfLine("%d %s", code, msg)
+	}
+
+	reply(220, "mailbus bounce processor ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO":
+			reply(250, "mailbus")
+		case "MAIL", "RCPT", "RSET", "NOOP":
+			reply(250, "OK")
+		case "DATA":
+			reply(354, "End data with <CR><LF>.<CR><LF>")
+			if err := s.receive(tp.DotReader()); err != nil {
+				log.Printf("failed to process DSN: %v", err)
+				reply(451, "4.3.0 failed to process message")
+				continue
+			}
+			reply(250, "2.0.0 OK")
+		case "QUIT":
+			reply(221, "2.0.0 Bye")
+			return
+		default:
+			reply(502, "5.5.2 command not recognized")
+		}
+	}
+}
+
+func (s *Server) receive(r io.Reader) error {
+	lr := &io.LimitedReader{R: r, N: maxMessageSize}
+	err := s.Processor.processMessage(bufio.NewReader(lr), "SMTP message")
+
+	// drain the rest of the message so that the next command can be read
+	_, _ = io.Copy(io.Discard, r)
+
+	return err
+}
@@ -0,0 +1,123 @@
+package mailbus
+
+import (
+	"fmt"
+	"time"
+)
+
+// Campaign status
+const (
+	CampaignStatusDraft     = "draft"
+	CampaignStatusScheduled = "scheduled"
+	CampaignStatusSending   = "sending"
+	CampaignStatusSent      = "sent"
+	CampaignStatusFailed    = "failed"
+	CampaignStatusCancelled = "cancelled"
+)
+
+// CampaignService is the interface that wraps methods related to campaigns
+type CampaignService interface {
+	FindByID(id int) (*Campaign, error)
+	Find(filter CampaignFilter) ([]Campaign, error)
+	FindDue(now time.Time) ([]Campaign, error)
+	Create(c *Campaign) error
+	Update(c *Campaign) error
+	Delete(id int) error
+}
+
+// Campaign represents a newsletter issue sent to a list
+type Campaign struct {
+	ID          int       `storm:"id,increment" json:"id"`
+	List        string    `storm:"index" json:"list"`
+	Subject     string    `json:"subject"`
+	Body        string    `json:"body"`
+	Status      string    `storm:"index" json:"status"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+	SentAt      time.Time `json:"sent_at"`
+	Error       string    `json:"error,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// CampaignFilter represents the criteria used to find campaigns
+type CampaignFilter struct {
+	List   string
+	Status string
+	// Since and Until bound when the campaigns were created, Until excluded
+	Since time.Time
+	Until time.Time
+}
+
+// NewCampaign returns a new draft campaign
+func NewCampaign(list, subject, body string) *Campaign {
+	return &Campaign{
+		List:    list,
+		Subject: subject,
+		Body:    body,
+		Status:  CampaignStatusDraft,
+	}
+}
+
+// Editable reports whether the content of the campaign can still be changed
+func (c *Campaign) Editable() bool {
+	return c.Status == CampaignStatusDraft || c.Status == CampaignStatusScheduled
+}
+
+// Schedule schedules the campaign to be sent at the given time
+func (c *Campaign) Schedule(at time.Time) error {
+	if !c.Editable() {
+		return c.transitionError(CampaignStatusScheduled)
+	}
+
+	c.Status = CampaignStatusScheduled
+	c.ScheduledAt = at
+	return nil
+}
+
+// Cancel cancels a campaign that has not been sent yet
+func (c *Campaign) Cancel() error {
+	if !c.Editable() {
+		return c.transitionError(CampaignStatusCancelled)
+	}
+
+	c.Status = CampaignStatusCancelled
+	return nil
+}
+
+// Start marks a scheduled campaign as being sent
+func (c *Campaign) Start() error {
+	if c.Status != CampaignStatusScheduled {
+		return c.transitionError(CampaignStatusSending)
+	}
+
+	c.Status = CampaignStatusSending
+	return nil
+}
+
+// Finish marks a campaign as sent, or as failed if err is not nil
+func (c *Campaign) Finish(err error) {
+	if err != nil {
+		c.Status = CampaignStatusFailed
+		c.Error = err.Error()
+		return
+	}
+
+	c.Status = CampaignStatusSent
+	c.SentAt = time.Now()
+	c.Error = ""
+}
+
+func (c *Campaign) transitionError(status string) error {
+	return &Error{
+		Code:    ErrConflict,
+		Message: fmt.Sprintf("Campaign %d is %s and cannot be %s.", c.ID, c.Status, status),
+	}
+}
+
+// CampaignRequest represents a request to create or edit a campaign
+type CampaignRequest struct {
+	List        string    `json:"list"`
+	Subject     string    `json:"subject"`
+	Body        string    `json:"body"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+}
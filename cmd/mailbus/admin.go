@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/quantonganh/mailbus"
+)
+
+var adminsUsage = `usage: mailbus admins <command> [arguments]
+
+commands:
+  list                      list the admins who log in to the admin UI
+  add [-role R] USERNAME    add an admin, the password is asked for. The first admin
+                            is an owner unless told otherwise, the next ones viewers
+  passwd USERNAME           change the password of an admin
+  role USERNAME ROLE        change the role of an admin
+  reset-2fa USERNAME        turn off the two-factor authentication of an admin who lost their device
+  remove USERNAME           remove an admin, logging them out
+
+roles: ` + strings.Join(mailbus.Roles, ", ") + `
+
+The password is read from stdin when it is not a terminal.`
+
+// adminsCommand manages the admins who log in to the admin UI
+func adminsCommand(_ *mailbus.Config, svc *services, args []string) error {
+	if len(args) == 0 {
+		return errors.New(adminsUsage)
+	}
+
+	fs := flag.NewFlagSet("admins "+args[0], flag.ContinueOnError)
+	role := fs.String("role", "", "role of the admin")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		return listAdmins(svc.admin, os.Stdout)
+	case "add":
+		if fs.NArg() != 1 {
+			return errors.New(adminsUsage)
+		}
+		if *role == "" {
+			admins, err := svc.admin.FindAll()
+			if err != nil {
+				return err
+			}
+			*role = mailbus.RoleViewer
+			if len(admins) == 0 {
+				*role = mailbus.RoleOwner
+			}
+		}
+		password, err := readPassword()
+		if err != nil {
+			return err
+		}
+		a, err := mailbus.NewAdmin(fs.Arg(0), password, *role)
+		if err != nil {
+			return errors.New(mailbus.ErrorMessage(err))
+		}
+		if err := svc.admin.Create(a); err != nil {
+			return errors.New(mailbus.ErrorMessage(err))
+		}
+		audit(svc, mailbus.AuditActionAdminCreate, a.Username, "role="+a.Role)
+		fmt.Printf("added admin %s as %s\n", a.Username, a.Role)
+		return nil
+	case "passwd":
+		if fs.NArg() != 1 {
+			return errors.New(adminsUsage)
+		}
+		a, err := svc.admin.FindByUsername(fs.Arg(0))
+		if err != nil {
+			return errors.New(mailbus.ErrorMessage(err))
+		}
+		password, err := readPassword()
+		if err != nil {
+			return err
+		}
+		if err := a.SetPassword(password); err != nil {
+			return errors.New(mailbus.ErrorMessage(err))
+		}
+		if err := svc.admin.Update(a); err != nil {
+			return err
+		}
+		audit(svc, mailbus.AuditActionAdminPassword, a.Username, "")
+		fmt.Printf("changed the password of %s\n", a.Username)
+		return nil
+	case "role":
+		if fs.NArg() != 2 {
+			return errors.New(adminsUsage)
+		}
+		a, err := svc.admin.FindByUsername(fs.Arg(0))
+		if err != nil {
+			return errors.New(mailbus.ErrorMessage(err))
+		}
+		if err := a.SetRole(fs.Arg(1)); err != nil {
+			return errors.New(mailbus.ErrorMessage(err))
+		}
+		if err := svc.admin.Update(a); err != nil {
+			return err
+		}
+		audit(svc, mailbus.AuditActionAdminUpdate, a.Username, "role="+a.Role)
+		fmt.Printf("%s is now %s\n", a.Username, a.Role)
+		return nil
+	case "reset-2fa":
+		if fs.NArg() != 1 {
+			return errors.New(adminsUsage)
+		}
+		a, err := svc.admin.FindByUsername(fs.Arg(0))
+		if err != nil {
+			return errors.New(mailbus.ErrorMessage(err))
+		}
+		a.DisableTwoFactor()
+		if err := svc.admin.Update(a); err != nil {
+			return err
+		}
+		audit(svc, mailbus.AuditActionTwoFactorDisable, a.Username, "")
+		fmt.Printf("turned off the two-factor authentication of %s\n", a.Username)
+		return nil
+	case "remove":
+		if fs.NArg() != 1 {
+			return errors.New(adminsUsage)
+		}
+		a, err := svc.admin.FindByUsername(fs.Arg(0))
+		if err != nil {
+			return errors.New(mailbus.ErrorMessage(err))
+		}
+		if err := svc.admin.Delete(a.ID); err != nil {
+			return err
+		}
+		audit(svc, mailbus.AuditActionAdminDelete, a.Username, "")
+		fmt.Printf("removed admin %s\n", a.Username)
+		return nil
+	default:
+		return errors.New(adminsUsage)
+	}
+}
+
+func listAdmins(as mailbus.AdminService, w io.Writer) error {
+	admins, err := as.FindAll()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tUSERNAME\tROLE\t2FA\tCREATED")
+	for _, a := range admins {
+		twoFactor := "no"
+		if a.TwoFactor() {
+			twoFactor = "yes"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", a.ID, a.Username, a.Role, twoFactor, a.CreatedAt.Format("2006-01-02 15:04"))
+	}
+	return tw.Flush()
+}
+
+// readPassword asks for a password twice without echoing it, or reads it from the first line of stdin
+func readPassword() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprint(os.Stderr, "Password again: ")
+	again, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if string(password) != string(again) {
+		return "", errors.New("passwords do not match")
+	}
+
+	return string(password), nil
+}
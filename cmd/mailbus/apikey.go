@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/quantonganh/mailbus"
+)
+
+var apiKeysUsage = `usage: mailbus apikeys <command> [arguments]
+
+commands:
+  list                                          list API keys, revoked ones included
+  issue -name N -scopes S[,S...] [-admin A]     issue a key granted scopes, and print it once. A key issued
+                                                to an admin can do no more than their role, and stops
+                                                working when they are removed
+  revoke ID...                                  revoke keys
+
+scopes: ` + strings.Join(mailbus.Scopes, ", ")
+
+// apiKeysCommand manages the keys of the admin API
+func apiKeysCommand(_ *mailbus.Config, svc *services, args []string) error {
+	if len(args) == 0 {
+		return errors.New(apiKeysUsage)
+	}
+
+	fs := flag.NewFlagSet("apikeys "+args[0], flag.ContinueOnError)
+	name := fs.String("name", "", "what the key is for")
+	scopes := fs.String("scopes", "", "comma-separated scopes granted to the key")
+	username := fs.String("admin", "", "username of the admin the key is issued to")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		return listAPIKeys(svc.apiKey, svc.admin, os.Stdout)
+	case "issue":
+		var granted []string
+		for _, scope := range strings.Split(*scopes, ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				granted = append(granted, scope)
+			}
+		}
+
+		k, key, err := mailbus.NewAPIKey(*name, granted)
+		if err != nil {
+			return errors.New(mailbus.ErrorMessage(err))
+		}
+		if *username != "" {
+			a, err := svc.admin.FindByUsername(*username)
+			if err != nil {
+				return errors.New(mailbus.ErrorMessage(err))
+			}
+			for _, scope := range k.Scopes {
+				if !a.Allows(scope) {
+					return fmt.Errorf("the role of %s, %s, does not grant the %s scope", a.Username, a.Role, scope)
+				}
+			}
+			k.AdminID = a.ID
+		}
+		if err := svc.apiKey.Create(k); err != nil {
+			return err
+		}
+		audit(svc, mailbus.AuditActionAPIKeyIssue, "api-key:"+k.Prefix, k.Name+": "+strings.Join(k.Scopes, ","))
+		fmt.Printf("issued API key %d, it will not be shown again:\n%s\n", k.ID, key)
+		return nil
+	case "revoke":
+		if fs.NArg() == 0 {
+			return errors.New(apiKeysUsage)
+		}
+		keys, err := svc.apiKey.FindAll()
+		if err != nil {
+			return err
+		}
+		for _, arg := range fs.Args() {
+			id, err := strconv.Atoi(arg)
+			if err != nil {
+				return fmt.Errorf("invalid API key ID %q", arg)
+			}
+			if err := svc.apiKey.Revoke(id); err != nil {
+				return err
+			}
+			for _, k := range keys {
+				if k.ID == id {
+					audit(svc, mailbus.AuditActionAPIKeyRevoke, "api-key:"+k.Prefix, k.Name)
+				}
+			}
+			fmt.Printf("revoked API key %d\n", id)
+		}
+		return nil
+	default:
+		return errors.New(apiKeysUsage)
+	}
+}
+
+func listAPIKeys(ks mailbus.APIKeyService, as mailbus.AdminService, w io.Writer) error {
+	keys, err := ks.FindAll()
+	if err != nil {
+		return err
+	}
+	admins, err := as.FindAll()
+	if err != nil {
+		return err
+	}
+	usernames := make(map[int]string, len(admins))
+	for _, a := range admins {
+		usernames[a.ID] = a.Username
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tPREFIX\tSCOPES\tADMIN\tCREATED\tREVOKED")
+	for _, k := range keys {
+		admin := "-"
+		if k.AdminID != 0 {
+			if admin = usernames[k.AdminID]; admin == "" {
+				admin = "(removed)"
+			}
+		}
+		revoked := "-"
+		if k.Revoked() {
+			revoked = k.RevokedAt.Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(tw, "%d\t%s\tmb_%s\t%s\t%s\t%s\t%s\n", k.ID, k.Name, k.Prefix, strings.Join(k.Scopes, ","), admin,
+			k.CreatedAt.Format("2006-01-02 15:04"), revoked)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/quantonganh/mailbus"
+)
+
+const campaignsUsage = `usage: mailbus campaigns <command> [arguments]
+
+commands:
+  list [-list L] [-status S] [-since DATE] [-until DATE] [-format F]
+                          list the campaigns, of a list, in a status or created between two dates
+  send [-at TIME] ID      schedule a campaign to be sent by the running server, now or at TIME
+  cancel ID               cancel a campaign that has not been sent yet
+
+statuses: draft, scheduled, sending, sent, failed, cancelled
+
+Dates are YYYY-MM-DD or RFC 3339, TIME is "YYYY-MM-DD HH:MM" in local time or RFC 3339.
+With -dry-run send and cancel only print what they would do. -format is table or json.`
+
+// campaignsCommand sends and cancels the campaigns written in the admin UI or with the API
+func campaignsCommand(_ *mailbus.Config, svc *services, args []string) error {
+	if len(args) == 0 {
+		return errors.New(campaignsUsage)
+	}
+
+	var filter mailbus.CampaignFilter
+	fs := flag.NewFlagSet("campaigns "+args[0], flag.ContinueOnError)
+	fs.StringVar(&filter.List, "list", "", "name of the list")
+	fs.StringVar(&filter.Status, "status", "", "status of the campaigns")
+	since := fs.String("since", "", "created on or after this date")
+	until := fs.String("until", "", "created before the end of this date")
+	at := fs.String("at", "", "when the campaign is sent")
+	format := fs.String("format", formatTable, "output format, table or json")
+	dryRun := fs.Bool("dry-run", false, "print what would be done without doing it")
+	ids, err := parseArgs(fs, args[1:])
+	if err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	if args[0] == "list" {
+		if len(ids) != 0 {
+			return errors.New(campaignsUsage)
+		}
+		if filter.Since, err = parseDate("since", *since, false); err != nil {
+			return err
+		}
+		if filter.Until, err = parseDate("until", *until, true); err != nil {
+			return err
+		}
+		return listCampaigns(svc.campaign, *format, filter)
+	}
+
+	if len(ids) != 1 {
+		return errors.New(campaignsUsage)
+	}
+	id, err := strconv.Atoi(ids[0])
+	if err != nil {
+		return fmt.Errorf("invalid campaign ID %q", ids[0])
+	}
+	c, err := svc.campaign.FindByID(id)
+	if err != nil {
+		return errors.New(mailbus.ErrorMessage(err))
+	}
+
+	switch args[0] {
+	case "send":
+		when, err := parseTime(*at)
+		if err != nil {
+			return err
+		}
+		if err := c.Schedule(when); err != nil {
+			return errors.New(mailbus.ErrorMessage(err))
+		}
+		if *dryRun {
+			recipients, err := svc.subscription.FindByStatus(c.List, mailbus.StatusActive)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("would schedule campaign %d at %s, to the %d active subscribers of %s\n",
+				c.ID, formatTime(c.ScheduledAt), len(recipients), c.List)
+			return nil
+		}
+		if err := svc.campaign.Update(c); err != nil {
+			return err
+		}
+		audit(svc, mailbus.AuditActionCampaignSchedule, strconv.Itoa(c.ID),
+			fmt.Sprintf("%q to %s at %s", c.Subject, c.List, c.ScheduledAt.UTC().Format(time.RFC3339)))
+		fmt.Printf("scheduled campaign %d at %s, the running server sends it\n", c.ID, formatTime(c.ScheduledAt))
+		return nil
+	case "cancel":
+		if err := c.Cancel(); err != nil {
+			return errors.New(mailbus.ErrorMessage(err))
+		}
+		if *dryRun {
+			fmt.Printf("would cancel campaign %d\n", c.ID)
+			return nil
+		}
+		if err := svc.campaign.Update(c); err != nil {
+			return err
+		}
+		audit(svc, mailbus.AuditActionCampaignCancel, strconv.Itoa(c.ID), fmt.Sprintf("%q to %s", c.Subject, c.List))
+		fmt.Printf("cancelled campaign %d\n", c.ID)
+		return nil
+	default:
+		return errors.New(campaignsUsage)
+	}
+}
+
+func listCampaigns(cs mailbus.CampaignService, format string, filter mailbus.CampaignFilter) error {
+	campaigns, err := cs.Find(filter)
+	if err != nil {
+		return err
+	}
+	if campaigns == nil {
+		campaigns = []mailbus.Campaign{}
+	}
+
+	return writeOutput(os.Stdout, format, campaigns, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, "ID\tLIST\tSTATUS\tSUBJECT\tSCHEDULED\tSENT\tCREATED")
+		for _, c := range campaigns {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", c.ID, c.List, c.Status, c.Subject,
+				formatTime(c.ScheduledAt), formatTime(c.SentAt), formatTime(c.CreatedAt))
+		}
+	})
+}
+
+// parseTime parses when a campaign is sent, now if v is empty
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Now(), nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04", v, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid -at %q, use \"YYYY-MM-DD HH:MM\" or RFC 3339", v)
+	}
+	return t, nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/quantonganh/mailbus"
+)
+
+// command is a subcommand that is run instead of starting the server
+type command struct {
+	// database tells whether the command operates on the configured database, which is then opened for it
+	database bool
+	run      func(config *mailbus.Config, svc *services, args []string) error
+}
+
+var commands = map[string]command{
+	"admins":       {database: true, run: adminsCommand},
+	"apikeys":      {database: true, run: apiKeysCommand},
+	"campaigns":    {database: true, run: campaignsCommand},
+	"dkim":         {run: dkimCommand},
+	"import":       {database: true, run: importCommand},
+	"lists":        {database: true, run: listsCommand},
+	"subscribers":  {database: true, run: subscribersCommand},
+	"suppressions": {database: true, run: suppressionsCommand},
+}
+
+// cliActor is who the changes made with the commands are recorded as in the audit trail
+const cliActor = "cli"
+
+// audit records a change made with a command in the audit trail. The change has already been made,
+// so a failure to record it is reported without failing the command.
+func audit(svc *services, action, target, details string) {
+	if err := svc.audit.Record(mailbus.NewAuditEvent(cliActor, action, target, details)); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to record audit event: %v\n", err)
+	}
+}
+
+// Output formats of the commands listing things
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// checkFormat refuses unknown output formats before anything is done
+func checkFormat(format string) error {
+	if format != formatTable && format != formatJSON {
+		return fmt.Errorf("unknown format %q, use %s or %s", format, formatTable, formatJSON)
+	}
+	return nil
+}
+
+// writeOutput writes v as indented JSON, or as the table written by table
+func writeOutput(w io.Writer, format string, v interface{}, table func(tw *tabwriter.Writer)) error {
+	if format == formatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	table(tw)
+	return tw.Flush()
+}
+
+// parseArgs parses the flags of a command, which may also follow its arguments: flag stops at the first argument,
+// and "remove alice@example.com -list go" would otherwise remove "-list" and "go".
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// parseDate parses a date flag, either a day or a time in RFC 3339 format. A day is taken in local time,
+// and as its end when end is set, so that "-until 2026-01-31" includes the 31st.
+func parseDate(name, v string, end bool) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid -%s %q, use YYYY-MM-DD or RFC 3339", name, v)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// formatTime formats the times shown in tables, which are empty for zero times
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// needsConfig tells whether a command line cannot run without a config file: the server and the commands
+// operating on the database cannot, the others run with the defaults and the environment
+func needsConfig(args []string) bool {
+	if len(args) == 0 {
+		return true
+	}
+	cmd, ok := commands[args[0]]
+	return ok && cmd.database
+}
+
+// runCommand runs the subcommand named by the first argument
+func runCommand(config *mailbus.Config, args []string) error {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown command %q, available commands: %s", args[0], strings.Join(names, ", "))
+	}
+
+	if !cmd.database {
+		return cmd.run(config, nil, args[1:])
+	}
+
+	db, svc, err := newDatabaseService(DatabaseType(config.DB.Type), config.DB.Path)
+	if err != nil {
+		return err
+	}
+	if err := db.Open(); err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return cmd.run(config, svc, args[1:])
+}
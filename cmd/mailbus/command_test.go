@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/mailbus"
+)
+
+// databaseTypes are the databases the commands are tested against
+var databaseTypes = []DatabaseType{SQLiteDB, BoltDB}
+
+func newTestConfig(t *testing.T, dbType DatabaseType) *mailbus.Config {
+	config := new(mailbus.Config)
+	config.DB.Type = string(dbType)
+	config.DB.Path = filepath.Join(t.TempDir(), "mailbus.db")
+	config.HTTP.Addr = ":8080"
+	return config
+}
+
+// cli runs a command and returns what it printed, on stdout and stderr
+func cli(t *testing.T, config *mailbus.Config, args ...string) (string, error) {
+	f, err := os.CreateTemp(t.TempDir(), "output")
+	require.NoError(t, err)
+	defer f.Close()
+
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = f, f
+	err = runCommand(config, args)
+	os.Stdout, os.Stderr = stdout, stderr
+
+	output, rerr := os.ReadFile(f.Name())
+	require.NoError(t, rerr)
+	return string(output), err
+}
+
+// subscribers lists the subscribers matching the filters, as the subscribers list command prints them
+func subscribers(t *testing.T, config *mailbus.Config, filters ...string) []string {
+	output, err := cli(t, config, append([]string{"subscribers", "list", "-format", "json"}, filters...)...)
+	require.NoError(t, err, output)
+
+	var found []mailbus.Subscriber
+	require.NoError(t, json.Unmarshal([]byte(output), &found), output)
+	emails := make([]string, 0, len(found))
+	for _, s := range found {
+		emails = append(emails, s.Email+" "+s.List+" "+s.Status)
+	}
+	return emails
+}
+
+func TestSubscribersCommand(t *testing.T) {
+	for _, dbType := range databaseTypes {
+		t.Run(string(dbType), func(t *testing.T) {
+			config := newTestConfig(t, dbType)
+			_, err := cli(t, config, "lists", "create", "go")
+			require.NoError(t, err)
+
+			output, err := cli(t, config, "subscribers", "add", "-list", "go", "alice@example.com", "bob@example.com", "not an address")
+			assert.EqualError(t, err, "1 of the addresses given are invalid or could not be added")
+			assert.Contains(t, output, "added alice@example.com to go as active")
+			assert.Contains(t, output, "invalid email address not an address")
+
+			output, err = cli(t, config, "subscribers", "add", "-list", "go", "-status", "pending_confirmation", "carol@example.com")
+			require.NoError(t, err, output)
+			_, err = cli(t, config, "subscribers", "add", "alice@example.com")
+			require.NoError(t, err)
+			output, err = cli(t, config, "subscribers", "add", "-list", "go", "Alice@Example.com")
+			require.NoError(t, err, output)
+			assert.Contains(t, output, "skipped alice@example.com: already subscribed to go")
+
+			assert.Equal(t, []string{"alice@example.com go active", "bob@example.com go active"},
+				subscribers(t, config, "-list", "go", "-status", "active"))
+			assert.Equal(t, []string{"alice@example.com default active", "alice@example.com go active"},
+				subscribers(t, config, "-q", "ALICE"))
+			assert.Empty(t, subscribers(t, config, "-until", time.Now().AddDate(0, 0, -1).Format("2006-01-02")))
+			assert.Len(t, subscribers(t, config, "-since", time.Now().Format("2006-01-02")), 4)
+
+			// a dry run changes nothing
+			output, err = cli(t, config, "subscribers", "unsubscribe", "-list", "go", "-status", "active", "-dry-run")
+			require.NoError(t, err, output)
+			assert.Contains(t, output, "would unsubscribe alice@example.com (go)")
+			assert.Contains(t, output, "dry run: 2 would be changed, 0 skipped")
+			assert.Len(t, subscribers(t, config, "-list", "go", "-status", "active"), 2)
+
+			output, err = cli(t, config, "subscribers", "unsubscribe", "alice@example.com", "-list", "go")
+			require.NoError(t, err, output)
+			assert.Contains(t, output, "unsubscribed alice@example.com (go)")
+			output, err = cli(t, config, "subscribers", "unsubscribe", "-list", "go", "alice@example.com")
+			require.NoError(t, err, output)
+			assert.Contains(t, output, "skipped alice@example.com (go): already unsubscribed")
+			_, err = cli(t, config, "subscribers", "unsubscribe", "-list", "go", "dave@example.com")
+			assert.EqualError(t, err, "1 of the addresses given are not subscribed")
+
+			// resending confirmations picks the pending subscribers
+			output, err = cli(t, config, "subscribers", "resend-confirmation", "-list", "go")
+			require.NoError(t, err, output)
+			assert.Contains(t, output, "resent the confirmation to carol@example.com (go)")
+			assert.NotContains(t, output, "bob@example.com")
+
+			_, err = cli(t, config, "subscribers", "remove")
+			assert.Error(t, err)
+			output, err = cli(t, config, "subscribers", "remove", "-list", "go", "-status", "unsubscribed")
+			require.NoError(t, err, output)
+			assert.Contains(t, output, "removed alice@example.com (go)")
+			assert.Equal(t, []string{"bob@example.com go active", "carol@example.com go pending_confirmation"},
+				subscribers(t, config, "-list", "go"))
+		})
+	}
+}
+
+func TestListsCommand(t *testing.T) {
+	for _, dbType := range databaseTypes {
+		t.Run(string(dbType), func(t *testing.T) {
+			config := newTestConfig(t, dbType)
+
+			output, err := cli(t, config, "lists", "create", "-title", "Go", "go", "-dry-run")
+			require.NoError(t, err, output)
+			assert.Equal(t, "would create list go\n", output)
+			_, err = cli(t, config, "lists", "show", "go")
+			assert.Error(t, err)
+
+			_, err = cli(t, config, "lists", "create", "-title", "Go", "-track-opens", "-track-clicks", "go")
+			require.NoError(t, err)
+			_, err = cli(t, config, "lists", "update", "-description", "Posts about Go", "-track-opens=false", "go")
+			require.NoError(t, err)
+			_, err = cli(t, config, "subscribers", "add", "-list", "go", "alice@example.com")
+			require.NoError(t, err)
+
+			output, err = cli(t, config, "lists", "show", "-format", "json", "go")
+			require.NoError(t, err, output)
+			var stats listStats
+			require.NoError(t, json.Unmarshal([]byte(output), &stats))
+			assert.Equal(t, "Go", stats.Title)
+			assert.Equal(t, "Posts about Go", stats.Description)
+			assert.False(t, stats.TrackOpens)
+			assert.True(t, stats.TrackClicks)
+			assert.Equal(t, 1, stats.Subscribers[mailbus.StatusActive])
+
+			output, err = cli(t, config, "lists", "delete", "-dry-run", "go")
+			require.NoError(t, err, output)
+			assert.Equal(t, "would delete list go and its 1 subscribers\n", output)
+			_, err = cli(t, config, "lists", "delete", "go")
+			require.NoError(t, err)
+			_, err = cli(t, config, "lists", "show", "go")
+			assert.Error(t, err)
+
+			_, err = cli(t, config, "lists", "delete", mailbus.DefaultList)
+			assert.EqualError(t, err, "the default list cannot be deleted")
+		})
+	}
+}
+
+func TestCampaignsCommand(t *testing.T) {
+	for _, dbType := range databaseTypes {
+		t.Run(string(dbType), func(t *testing.T) {
+			config := newTestConfig(t, dbType)
+			db, svc, err := newDatabaseService(dbType, config.DB.Path)
+			require.NoError(t, err)
+			require.NoError(t, db.Open())
+			c := mailbus.NewCampaign(mailbus.DefaultList, "Issue #1", "<p>Hello</p>")
+			require.NoError(t, svc.campaign.Create(c))
+			require.NoError(t, db.Close())
+			id := strconv.Itoa(c.ID)
+
+			campaigns := func(filters ...string) []mailbus.Campaign {
+				output, err := cli(t, config, append([]string{"campaigns", "list", "-format", "json"}, filters...)...)
+				require.NoError(t, err, output)
+				var found []mailbus.Campaign
+				require.NoError(t, json.Unmarshal([]byte(output), &found), output)
+				return found
+			}
+
+			at := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+			output, err := cli(t, config, "campaigns", "send", "-dry-run", "-at", at.Format(time.RFC3339), id)
+			require.NoError(t, err, output)
+			assert.Contains(t, output, "would schedule campaign "+id)
+			assert.Len(t, campaigns("-status", mailbus.CampaignStatusDraft), 1)
+
+			_, err = cli(t, config, "campaigns", "send", "-at", at.Format(time.RFC3339), id)
+			require.NoError(t, err)
+			scheduled := campaigns("-status", mailbus.CampaignStatusScheduled, "-list", mailbus.DefaultList)
+			require.Len(t, scheduled, 1)
+			assert.True(t, at.Equal(scheduled[0].ScheduledAt))
+			assert.Empty(t, campaigns("-until", time.Now().AddDate(0, 0, -1).Format("2006-01-02")))
+
+			_, err = cli(t, config, "campaigns", "cancel", id)
+			require.NoError(t, err)
+			assert.Len(t, campaigns("-status", mailbus.CampaignStatusCancelled), 1)
+			_, err = cli(t, config, "campaigns", "send", id)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestImportCommand(t *testing.T) {
+	for _, dbType := range databaseTypes {
+		t.Run(string(dbType), func(t *testing.T) {
+			config := newTestConfig(t, dbType)
+			path := filepath.Join(t.TempDir(), "subscribers.csv")
+			require.NoError(t, os.WriteFile(path, []byte("E-mail,Name\nAlice@Example.com,Alice\nbob@example.com,Bob\nnot an address,Carol\n"), 0644))
+
+			output, err := cli(t, config, "import", "-consent", "confirmed", "-map", "email=E-mail", "-dry-run", path)
+			assert.EqualError(t, err, "1 invalid rows")
+			assert.Contains(t, output, "3 rows: 2 would be accepted (dry run), 0 skipped, 1 invalid")
+			assert.Empty(t, subscribers(t, config))
+
+			_, err = cli(t, config, "import", "-consent", "confirmed", "-map", "email=E-mail", path)
+			assert.Error(t, err)
+			assert.Equal(t, []string{"alice@example.com default active", "bob@example.com default active"}, subscribers(t, config))
+
+			// importing again skips the subscribers already there
+			output, _ = cli(t, config, "import", "-consent", "confirmed", "-map", "email=E-mail", path)
+			assert.Contains(t, output, "3 rows: 0 accepted, 2 skipped, 1 invalid")
+		})
+	}
+}
+
+func TestNeedsConfig(t *testing.T) {
+	assert.True(t, needsConfig(nil))
+	assert.True(t, needsConfig([]string{"subscribers", "list"}))
+	assert.False(t, needsConfig([]string{"dkim", "keygen"}))
+	// unknown commands are reported as such rather than as a missing config
+	assert.False(t, needsConfig([]string{"unknown"}))
+}
+
+func TestBoltDatabaseInUse(t *testing.T) {
+	config := newTestConfig(t, BoltDB)
+	db, _, err := newDatabaseService(BoltDB, config.DB.Path)
+	require.NoError(t, err)
+	require.NoError(t, db.Open())
+	defer db.Close()
+
+	_, err = cli(t, config, "lists", "list")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "in use")
+}
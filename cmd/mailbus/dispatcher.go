@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+
+	"github.com/quantonganh/mailbus"
+	"github.com/quantonganh/mailbus/bounce"
+	"github.com/quantonganh/mailbus/ratelimit"
+)
+
+// dispatcher sends scheduled campaigns once they are due, and retries deferred deliveries
+type dispatcher struct {
+	campaignService     mailbus.CampaignService
+	subscriptionService mailbus.SubscriptionService
+	deliveryService     mailbus.DeliveryService
+	newsletterService   mailbus.NewsletterService
+	bounceProcessor     *bounce.Processor
+	limiter             *ratelimit.Limiter
+	retryPolicy         mailbus.RetryPolicy
+	interval            time.Duration
+	concurrency         int // number of messages sent at the same time
+}
+
+// Run checks for due campaigns and deliveries every interval until ctx is cancelled
+func (d *dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	d.resume(ctx)
+
+	for {
+		now := time.Now()
+		d.dispatchDue(ctx, now)
+		d.retryDue(ctx, now)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (d *dispatcher) dispatchDue(ctx context.Context, now time.Time) {
+	campaigns, err := d.campaignService.FindDue(now)
+	if err != nil {
+		sentry.CaptureException(err)
+		return
+	}
+
+	for i := range campaigns {
+		if err := d.send(ctx, &campaigns[i]); err != nil && ctx.Err() == nil {
+			sentry.CaptureException(err)
+		}
+	}
+}
+
+// send starts a due campaign and sends it to the active subscribers of its list
+func (d *dispatcher) send(ctx context.Context, c *mailbus.Campaign) error {
+	if err := c.Start(); err != nil {
+		return err
+	}
+	if err := d.campaignService.Update(c); err != nil {
+		return err
+	}
+
+	return d.deliver(ctx, c)
+}
+
+// resume continues the campaigns that were being sent when the dispatcher last stopped
+func (d *dispatcher) resume(ctx context.Context) {
+	campaigns, err := d.campaignService.Find(mailbus.CampaignFilter{Status: mailbus.CampaignStatusSending})
+	if err != nil {
+		sentry.CaptureException(err)
+		return
+	}
+
+	for i := range campaigns {
+		log.Printf("resuming campaign %d", campaigns[i].ID)
+		if err := d.deliver(ctx, &campaigns[i]); err != nil && ctx.Err() == nil {
+			sentry.CaptureException(err)
+		}
+	}
+}
+
+// deliver sends a campaign to the active subscribers of its list that it has not been sent to yet, then finishes it.
+// The delivery log is the checkpoint: a delivery is created before its message is sent, and there is only one
+// per campaign and subscriber, so a campaign can be delivered again after a restart without sending anything twice.
+func (d *dispatcher) deliver(ctx context.Context, c *mailbus.Campaign) error {
+	deliveries, err := d.deliveryService.Find(mailbus.DeliveryFilter{CampaignID: c.ID})
+	if err != nil {
+		return err
+	}
+
+	done := make(map[int]bool)
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		done[delivery.SubscriberID] = true
+		if delivery.Status == mailbus.DeliveryStatusSending {
+			delivery.Interrupt()
+			if err := d.deliveryService.Update(delivery); err != nil {
+				return err
+			}
+		}
+	}
+
+	subscribers, err := d.subscriptionService.FindByStatus(c.List, mailbus.StatusActive)
+	if err == nil {
+		var pending []mailbus.Subscriber
+		for _, s := range subscribers {
+			if !done[s.ID] {
+				pending = append(pending, s)
+			}
+		}
+
+		var unlogged int32
+		d.parallel(len(pending), func(i int) {
+			s := pending[i]
+			// the subscriber is left pending if the dispatcher stops in the meantime
+			if err := d.limiter.Wait(ctx, s.Email); err != nil {
+				return
+			}
+
+			delivery := mailbus.NewDelivery(c.ID, s)
+			delivery.Start()
+			if err := d.deliveryService.Create(delivery); err != nil {
+				if mailbus.ErrorCode(err) != mailbus.ErrConflict {
+					atomic.AddInt32(&unlogged, 1)
+					sentry.CaptureException(err)
+				}
+				return
+			}
+			if err := d.attempt(c, s, delivery); err != nil {
+				sentry.CaptureException(err)
+			}
+		})
+
+		// shutting down: the campaign is left sending and resumed on the next start
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		err = d.summarize(c, int(unlogged))
+	}
+
+	c.Finish(err)
+	if err := d.campaignService.Update(c); err != nil {
+		return err
+	}
+
+	return err
+}
+
+// summarize logs how many deliveries of a campaign were sent, deferred, suppressed or failed,
+// and returns an error if they all failed. Deliveries that could not be logged count as failed.
+func (d *dispatcher) summarize(c *mailbus.Campaign, unlogged int) error {
+	deliveries, err := d.deliveryService.Find(mailbus.DeliveryFilter{CampaignID: c.ID})
+	if err != nil {
+		return err
+	}
+
+	sum := summary{mailbus.DeliveryStatusFailed: unlogged}
+	for _, delivery := range deliveries {
+		sum[delivery.Status]++
+	}
+	log.Printf("campaign %d: %s", c.ID, sum)
+
+	if failed := sum[mailbus.DeliveryStatusFailed]; failed > 0 && failed == len(deliveries)+unlogged {
+		return fmt.Errorf("all %d deliveries failed", failed)
+	}
+	return nil
+}
+
+// summary counts deliveries by status
+type summary map[string]int
+
+func (s summary) String() string {
+	return fmt.Sprintf("%d sent, %d deferred, %d suppressed, %d failed",
+		s[mailbus.DeliveryStatusSent], s[mailbus.DeliveryStatusDeferred],
+		s[mailbus.DeliveryStatusSuppressed], s[mailbus.DeliveryStatusFailed])
+}
+
+// parallel calls fn for every index in [0, n), over at most d.concurrency goroutines, and waits for them to return
+func (d *dispatcher) parallel(n int, fn func(i int)) {
+	workers := d.concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// retryDue attempts deferred deliveries again once their backoff has elapsed
+func (d *dispatcher) retryDue(ctx context.Context, now time.Time) {
+	deliveries, err := d.deliveryService.FindDue(now)
+	if err != nil {
+		sentry.CaptureException(err)
+		return
+	}
+
+	campaigns := make(map[int]*mailbus.Campaign)
+	for _, delivery := range deliveries {
+		if _, ok := campaigns[delivery.CampaignID]; ok {
+			continue
+		}
+		c, err := d.campaignService.FindByID(delivery.CampaignID)
+		if err != nil {
+			sentry.CaptureException(err)
+		}
+		// a campaign that cannot be found is looked up once, its deliveries are skipped
+		campaigns[delivery.CampaignID] = c
+	}
+
+	d.parallel(len(deliveries), func(i int) {
+		delivery := &deliveries[i]
+		c := campaigns[delivery.CampaignID]
+		if c == nil {
+			return
+		}
+
+		if err := d.retry(ctx, c, delivery); err != nil && ctx.Err() == nil {
+			sentry.CaptureException(err)
+		}
+	})
+}
+
+func (d *dispatcher) retry(ctx context.Context, c *mailbus.Campaign, delivery *mailbus.Delivery) error {
+	s, err := d.subscriptionService.FindByEmail(c.List, delivery.Email)
+	if err != nil && mailbus.ErrorCode(err) != mailbus.ErrNotFound {
+		return err
+	}
+
+	// the subscriber may have left the list since the first attempt
+	if s == nil || s.Status != mailbus.StatusActive {
+		delivery.Status = mailbus.DeliveryStatusFailed
+		delivery.NextAttemptAt = time.Time{}
+		delivery.SMTPMessage = "subscriber is no longer active"
+		return d.deliveryService.Update(delivery)
+	}
+
+	// the delivery stays deferred if the dispatcher stops in the meantime
+	if err := d.limiter.Wait(ctx, s.Email); err != nil {
+		return err
+	}
+
+	delivery.Start()
+	if err := d.deliveryService.Update(delivery); err != nil {
+		return err
+	}
+
+	return d.attempt(c, *s, delivery)
+}
+
+// attempt sends a campaign to a subscriber and records the outcome in the delivery log,
+// the delivery has been saved as sending beforehand
+func (d *dispatcher) attempt(c *mailbus.Campaign, s mailbus.Subscriber, delivery *mailbus.Delivery) error {
+	reply, sendErr := d.newsletterService.SendNewsletter(c, s)
+	delivery.Record(reply, sendErr, d.retryPolicy)
+	if err := d.deliveryService.Update(delivery); err != nil {
+		return err
+	}
+
+	if b := delivery.Bounce(); b != nil {
+		if err := d.bounceProcessor.Handle(b); err != nil {
+			return err
+		}
+	}
+
+	return sendErr
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/quantonganh/mailbus"
+	"github.com/quantonganh/mailbus/dkim"
+)
+
+const dkimUsage = `usage: mailbus dkim keygen [-algorithm rsa|ed25519] [-bits N] [-domain D] [-selector S] [-out FILE]
+
+Generates a DKIM key pair, writes the private key to FILE (dkim.privatekey by default)
+and prints the TXT record to publish at <selector>._domainkey.<domain>.`
+
+// dkimCommand generates DKIM keys
+func dkimCommand(config *mailbus.Config, _ *services, args []string) error {
+	if len(args) == 0 || args[0] != "keygen" {
+		return errors.New(dkimUsage)
+	}
+
+	fs := flag.NewFlagSet("dkim keygen", flag.ContinueOnError)
+	algorithm := fs.String("algorithm", dkim.AlgorithmRSA, "key algorithm, rsa or ed25519")
+	bits := fs.Int("bits", 2048, "size of RSA keys")
+	domain := fs.String("domain", config.DKIM.Domain, "signing domain")
+	selector := fs.String("selector", config.DKIM.Selector, "selector")
+	out := fs.String("out", config.DKIM.PrivateKey, "path of the private key")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *domain == "" || *selector == "" || *out == "" {
+		return errors.New(dkimUsage)
+	}
+
+	key, err := dkim.GenerateKey(*algorithm, *bits)
+	if err != nil {
+		return err
+	}
+
+	data, err := dkim.MarshalPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	// never overwrite a key that may already be published
+	f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	record, err := dkim.TXTRecord(key)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "private key written to %s, publish this record:\n", *out)
+	fmt.Printf("%s._domainkey.%s. IN TXT %s\n", *selector, *domain, quoteTXT(record))
+	return nil
+}
+
+// quoteTXT quotes a TXT record value, splitting it in strings of at most 255 characters
+func quoteTXT(v string) string {
+	var parts []string
+	for len(v) > 255 {
+		parts = append(parts, `"`+v[:255]+`"`)
+		v = v[255:]
+	}
+	parts = append(parts, `"`+v+`"`)
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/quantonganh/mailbus"
+)
+
+const importUsage = `usage: mailbus import -consent C [-list L] [-map FIELD=COLUMN,...] [-type T] [-url U] [-dry-run] [-format F] FILE
+
+Imports subscribers from FILE, or stdin if FILE is -: a CSV file whose first line names the columns,
+or a JSONL file holding a JSON object per line. Rows are read one at a time.
+
+  -consent C    confirmed to add the subscribers as active, who agreed to receive emails elsewhere,
+                or double_opt_in to add them pending and send them a confirmation email
+  -list L       list of the rows without a list column, the default list if omitted
+  -map M        columns, or JSON keys, of the email and list fields, such as "email=E-mail Address,list=Topic".
+                By default they are read from the email and list columns
+  -type T       csv or jsonl, guessed from the extension of FILE if omitted
+  -url U        where the links of the confirmation emails lead, by default the server on this machine
+  -dry-run      check the rows without adding anyone
+  -format F     table or json, the format of the report
+
+Addresses are lower-cased and rid of display names. Rows that are invalid, repeat an earlier row, are suppressed,
+or are of people already subscribed are skipped, except pending subscribers who are activated by confirmed imports.`
+
+// importCommand imports subscribers from a CSV or JSONL file
+func importCommand(config *mailbus.Config, svc *services, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	consent := fs.String("consent", "", "confirmed or double_opt_in")
+	list := fs.String("list", "", "list of the rows without one")
+	mapping := fs.String("map", "", "columns of the fields, FIELD=COLUMN,...")
+	typ := fs.String("type", "", "csv or jsonl")
+	url := fs.String("url", "", "URL of the server the confirmation links lead to")
+	dryRun := fs.Bool("dry-run", false, "check the rows without adding anyone")
+	format := fs.String("format", formatTable, "output format, table or json")
+	files, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(files) != 1 || *consent == "" {
+		return errors.New(importUsage)
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	opts := mailbus.ImportOptions{
+		Format:  *typ,
+		List:    *list,
+		Consent: *consent,
+		URL:     serverURL(config, *url),
+		DryRun:  *dryRun,
+	}
+	if opts.Mapping, err = mailbus.ParseImportMapping(*mapping); err != nil {
+		return errors.New(mailbus.ErrorMessage(err))
+	}
+	if opts.Format == "" {
+		if opts.Format = importFormat(files[0]); opts.Format == "" {
+			return errors.New("cannot tell the type of the file from its extension, set -type csv or -type jsonl")
+		}
+	}
+	if opts.List != "" {
+		if _, err := svc.list.FindByName(opts.List); err != nil {
+			return errors.New(mailbus.ErrorMessage(err))
+		}
+	}
+
+	var r io.Reader = os.Stdin
+	if files[0] != "-" {
+		f, err := os.Open(files[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	confirmer, err := newConfirmer(config, svc)
+	if err != nil {
+		return err
+	}
+	importer := &mailbus.Importer{
+		ListService:         svc.list,
+		SubscriptionService: svc.subscription,
+		SuppressionService:  svc.suppression,
+		Confirmer:           confirmer,
+	}
+
+	report, err := importer.Import(r, opts)
+	if report == nil {
+		return errors.New(mailbus.ErrorMessage(err))
+	}
+	if !opts.DryRun {
+		audit(svc, mailbus.AuditActionSubscribersImport, opts.List, "consent="+opts.Consent+" "+report.Summary())
+	}
+	if werr := writeImportReport(*format, report); werr != nil {
+		return werr
+	}
+	if err != nil {
+		return err
+	}
+
+	if report.Invalid > 0 {
+		return fmt.Errorf("%d invalid rows", report.Invalid)
+	}
+	return nil
+}
+
+// importFormat returns the format of a file from its extension
+func importFormat(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return mailbus.ImportFormatCSV
+	case ".jsonl", ".ndjson":
+		return mailbus.ImportFormatJSONL
+	}
+	return ""
+}
+
+func writeImportReport(format string, report *mailbus.ImportReport) error {
+	return writeOutput(os.Stdout, format, report, func(tw *tabwriter.Writer) {
+		if len(report.Issues) > 0 {
+			fmt.Fprintln(tw, "LINE\tEMAIL\tLIST\tRESULT\tREASON")
+			for _, issue := range report.Issues {
+				fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", issue.Line, issue.Email, issue.List, issue.Result, issue.Reason)
+			}
+			if report.Truncated {
+				fmt.Fprintf(tw, "...\tonly the first %d skipped and invalid rows are listed\n", mailbus.MaxImportIssues)
+			}
+			fmt.Fprintln(tw)
+		}
+
+		accepted := "accepted"
+		if report.DryRun {
+			accepted = "would be accepted (dry run)"
+		}
+		fmt.Fprintf(tw, "%d rows: %d %s, %d skipped, %d invalid\n", report.Rows, report.Accepted, accepted, report.Skipped, report.Invalid)
+	})
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/quantonganh/mailbus"
+)
+
+const listsUsage = `usage: mailbus lists <command> [arguments]
+
+commands:
+  list [-format F]                                   list the lists
+  show [-format F] NAME                              show a list and how many subscribers it has by status
+  create [-title T] [-description D] [-track-opens] [-track-clicks] NAME
+                                                     create a list
+  update [-title T] [-description D] [-track-opens=B] [-track-clicks=B] NAME
+                                                     change the title, the description or the tracking of a list
+  delete NAME                                        delete a list along with its subscribers
+
+With -dry-run the commands changing lists only print what they would do. -format is table or json.`
+
+// statuses are the statuses subscribers are counted by
+var statuses = []string{mailbus.StatusActive, mailbus.StatusPendingConfirmation, mailbus.StatusUnsubscribed, mailbus.StatusBounced}
+
+// listStats is a list along with how many subscribers it has in each status
+type listStats struct {
+	mailbus.List
+	Subscribers map[string]int `json:"subscribers"`
+}
+
+// listsCommand manages the lists people subscribe to
+func listsCommand(_ *mailbus.Config, svc *services, args []string) error {
+	if len(args) == 0 {
+		return errors.New(listsUsage)
+	}
+
+	fs := flag.NewFlagSet("lists "+args[0], flag.ContinueOnError)
+	title := fs.String("title", "", "title of the list")
+	description := fs.String("description", "", "description of the list")
+	trackOpens := fs.Bool("track-opens", false, "add a tracking pixel to the newsletters of the list")
+	trackClicks := fs.Bool("track-clicks", false, "redirect the links of the newsletters of the list through the server")
+	format := fs.String("format", formatTable, "output format, table or json")
+	dryRun := fs.Bool("dry-run", false, "print what would be done without doing it")
+	names, err := parseArgs(fs, args[1:])
+	if err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	if args[0] == "list" {
+		if len(names) != 0 {
+			return errors.New(listsUsage)
+		}
+		lists, err := svc.list.FindAll()
+		if err != nil {
+			return err
+		}
+		if lists == nil {
+			lists = []mailbus.List{}
+		}
+		return writeOutput(os.Stdout, *format, lists, func(tw *tabwriter.Writer) {
+			fmt.Fprintln(tw, "NAME\tTITLE\tCREATED")
+			for _, l := range lists {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", l.Name, l.Title, formatTime(l.CreatedAt))
+			}
+		})
+	}
+
+	if len(names) != 1 {
+		return errors.New(listsUsage)
+	}
+	name := names[0]
+
+	switch args[0] {
+	case "show":
+		l, err := svc.list.FindByName(name)
+		if err != nil {
+			return errors.New(mailbus.ErrorMessage(err))
+		}
+		stats := listStats{List: *l, Subscribers: map[string]int{}}
+		for _, status := range statuses {
+			subscribers, err := svc.subscription.FindByStatus(l.Name, status)
+			if err != nil {
+				return err
+			}
+			stats.Subscribers[status] = len(subscribers)
+		}
+		return writeOutput(os.Stdout, *format, stats, func(tw *tabwriter.Writer) {
+			fmt.Fprintf(tw, "Name:\t%s\n", l.Name)
+			fmt.Fprintf(tw, "Title:\t%s\n", l.Title)
+			fmt.Fprintf(tw, "Description:\t%s\n", l.Description)
+			fmt.Fprintf(tw, "Track opens:\t%t\n", l.TrackOpens)
+			fmt.Fprintf(tw, "Track clicks:\t%t\n", l.TrackClicks)
+			fmt.Fprintf(tw, "Created:\t%s\n", formatTime(l.CreatedAt))
+			for _, status := range statuses {
+				fmt.Fprintf(tw, "%s:\t%d\n", status, stats.Subscribers[status])
+			}
+		})
+	case "create":
+		l, err := mailbus.NewList(name, *title, *description)
+		if err != nil {
+			return errors.New(mailbus.ErrorMessage(err))
+		}
+		l.TrackOpens, l.TrackClicks = *trackOpens, *trackClicks
+		if *dryRun {
+			if _, err := svc.list.FindByName(name); err == nil {
+				return fmt.Errorf("list %s already exists", name)
+			}
+			fmt.Printf("would create list %s\n", l.Name)
+			return nil
+		}
+		if err := svc.list.Create(l); err != nil {
+			return errors.New(mailbus.ErrorMessage(err))
+		}
+		audit(svc, mailbus.AuditActionListCreate, l.Name, l.Title)
+		fmt.Printf("created list %s\n", l.Name)
+		return nil
+	case "update":
+		l, err := svc.list.FindByName(name)
+		if err != nil {
+			return errors.New(mailbus.ErrorMessage(err))
+		}
+		if *title != "" {
+			l.Title = *title
+		}
+		if *description != "" {
+			l.Description = *description
+		}
+		// the tracking flags change only when they are given, -track-opens=false turns tracking off
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "track-opens":
+				l.TrackOpens = *trackOpens
+			case "track-clicks":
+				l.TrackClicks = *trackClicks
+			}
+		})
+		if *dryRun {
+			fmt.Printf("would update list %s\n", l.Name)
+			return nil
+		}
+		if err := svc.list.Update(l); err != nil {
+			return errors.New(mailbus.ErrorMessage(err))
+		}
+		audit(svc, mailbus.AuditActionListUpdate, l.Name, l.Title)
+		fmt.Printf("updated list %s\n", l.Name)
+		return nil
+	case "delete":
+		l, err := svc.list.FindByName(name)
+		if err != nil {
+			return errors.New(mailbus.ErrorMessage(err))
+		}
+		if l.Name == mailbus.DefaultList {
+			return errors.New("the default list cannot be deleted")
+		}
+		if *dryRun {
+			subscribers, err := svc.subscription.Find(mailbus.SubscriberFilter{List: l.Name})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("would delete list %s and its %d subscribers\n", l.Name, len(subscribers))
+			return nil
+		}
+		if err := svc.list.Delete(l.Name); err != nil {
+			return err
+		}
+		audit(svc, mailbus.AuditActionListDelete, l.Name, "")
+		fmt.Printf("deleted list %s\n", l.Name)
+		return nil
+	default:
+		return errors.New(listsUsage)
+	}
+}
@@ -2,11 +2,14 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/getsentry/sentry-go"
@@ -14,10 +17,16 @@ import (
 
 	"github.com/quantonganh/mailbus"
 	"github.com/quantonganh/mailbus/bolt"
+	"github.com/quantonganh/mailbus/bounce"
+	"github.com/quantonganh/mailbus/dkim"
 	"github.com/quantonganh/mailbus/gmail"
 	"github.com/quantonganh/mailbus/http"
+	"github.com/quantonganh/mailbus/pkg/hash"
+	"github.com/quantonganh/mailbus/pkg/token"
 	"github.com/quantonganh/mailbus/rabbitmq"
+	"github.com/quantonganh/mailbus/ratelimit"
 	"github.com/quantonganh/mailbus/sqlite"
+	"github.com/quantonganh/mailbus/transport"
 )
 
 type DatabaseType string
@@ -33,16 +42,54 @@ func main() {
 	viper.AddConfigPath("./config")
 	viper.AutomaticEnv()
 	if err := viper.ReadInConfig(); err != nil {
-		log.Fatal(err)
+		var notFound viper.ConfigFileNotFoundError
+		if !errors.As(err, &notFound) || needsConfig(os.Args[1:]) {
+			log.Fatal(err)
+		}
 	}
 
 	viper.SetDefault("http.addr", ":8080")
+	viper.SetDefault("admin.sessionttl", 12*time.Hour)
+	viper.SetDefault("newsletter.dispatcher.interval", time.Minute)
+	viper.SetDefault("newsletter.dispatcher.concurrency", 4)
+	viper.SetDefault("newsletter.outbox.interval", 5*time.Second)
+	viper.SetDefault("newsletter.confirmation.tokenttl", 72*time.Hour)
+	viper.SetDefault("newsletter.confirmation.cooldown", 10*time.Minute)
+	viper.SetDefault("newsletter.confirmation.purgeafter", 30*24*time.Hour)
+	viper.SetDefault("newsletter.confirmation.interval", time.Hour)
+	viper.SetDefault("smtp.pool.size", 4)
+	viper.SetDefault("transport.driver", mailbus.TransportSMTP)
+	viper.SetDefault("transport.sendmail.path", "/usr/sbin/sendmail")
+	viper.SetDefault("smtp.retry.maxattempts", 5)
+	viper.SetDefault("smtp.retry.initialinterval", 5*time.Minute)
+	viper.SetDefault("smtp.retry.maxinterval", 6*time.Hour)
+	viper.SetDefault("tracking.opens.mindelay", 10*time.Second)
+	// Apple Mail Privacy Protection fetches images with a bare user agent
+	viper.SetDefault("tracking.opens.proxyuseragents", []string{"Mozilla/5.0"})
+	viper.SetDefault("tracking.opens.useragents", []string{
+		"bot", "crawler", "spider", "curl", "wget", "python-requests", "go-http-client",
+		"barracuda", "mimecast", "proofpoint",
+	})
+	// Apple Mail Privacy Protection fetches images from Apple's network
+	viper.SetDefault("tracking.opens.networks", []string{"17.0.0.0/8"})
+	viper.SetDefault("bounce.hardthreshold", 1)
+	viper.SetDefault("bounce.softthreshold", 3)
+	viper.SetDefault("bounce.window", 30*24*time.Hour)
+	viper.SetDefault("bounce.interval", time.Minute)
 
 	var config *mailbus.Config
 	if err := viper.Unmarshal(&config); err != nil {
 		log.Fatal(err)
 	}
 
+	if args := os.Args[1:]; len(args) > 0 {
+		if err := runCommand(config, args); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if err := sentry.Init(sentry.ClientOptions{
 		Dsn: config.Sentry.DSN,
 	}); err != nil {
@@ -79,13 +126,33 @@ func main() {
 }
 
 type app struct {
-	config     *mailbus.Config
-	db         mailbus.Database
-	httpServer *http.Server
+	config       *mailbus.Config
+	db           mailbus.Database
+	services     *services
+	httpServer   *http.Server
+	bounceServer *bounce.Server
+	transport    mailbus.Transport
+}
+
+// services holds the storage-backed services of the configured database
+type services struct {
+	list         mailbus.ListService
+	subscription mailbus.SubscriptionService
+	campaign     mailbus.CampaignService
+	delivery     mailbus.DeliveryService
+	bounce       mailbus.BounceService
+	suppression  mailbus.SuppressionService
+	audit        mailbus.AuditService
+	outbox       mailbus.OutboxService
+	tracking     mailbus.TrackingService
+	report       mailbus.ReportService
+	apiKey       mailbus.APIKeyService
+	admin        mailbus.AdminService
+	session      mailbus.SessionService
 }
 
 func newApp(config *mailbus.Config) (*app, error) {
-	db, subscriptionSvc, err := newDatabaseService(DatabaseType(config.DB.Type), config.DB.Path)
+	db, svc, err := newDatabaseService(DatabaseType(config.DB.Type), config.DB.Path)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -94,7 +161,25 @@ func newApp(config *mailbus.Config) (*app, error) {
 	if err != nil {
 		return nil, err
 	}
-	httpServer.SubscriptionService = subscriptionSvc
+	httpServer.ListService = svc.list
+	httpServer.SubscriptionService = svc.subscription
+	httpServer.CampaignService = svc.campaign
+	httpServer.DeliveryService = svc.delivery
+	httpServer.SuppressionService = svc.suppression
+	httpServer.AuditService = svc.audit
+	httpServer.TrackingService = svc.tracking
+	httpServer.ReportService = svc.report
+	httpServer.APIKeyService = svc.apiKey
+	httpServer.AdminService = svc.admin
+	httpServer.SessionService = svc.session
+	httpServer.SessionTTL = config.Admin.SessionTTL
+	httpServer.ConfirmationPolicy = newConfirmationPolicy(config)
+	if httpServer.TokenSigner, err = newTokenSigner(config); err != nil {
+		return nil, err
+	}
+	if httpServer.OpenHeuristics, err = newOpenHeuristics(config); err != nil {
+		return nil, err
+	}
 
 	mqService, err := rabbitmq.NewQueueService(config.AMQP.URL)
 	if err != nil {
@@ -105,15 +190,16 @@ func newApp(config *mailbus.Config) (*app, error) {
 	return &app{
 		config:     config,
 		db:         db,
+		services:   svc,
 		httpServer: httpServer,
 	}, nil
 }
 
-func newDatabaseService(dbType DatabaseType, path string) (mailbus.Database, mailbus.SubscriptionService, error) {
+func newDatabaseService(dbType DatabaseType, path string) (mailbus.Database, *services, error) {
 	var (
-		db              mailbus.Database
-		subscriptionSvc mailbus.SubscriptionService
-		err             error
+		db  mailbus.Database
+		svc = &services{}
+		err error
 	)
 
 	if dbType == "" {
@@ -129,7 +215,19 @@ func newDatabaseService(dbType DatabaseType, path string) (mailbus.Database, mai
 		db = bolt.NewDB(path)
 		boltDB, ok := db.(*bolt.DB)
 		if ok {
-			subscriptionSvc = bolt.NewSubscriptionService(boltDB)
+			svc.list = bolt.NewListService(boltDB)
+			svc.subscription = bolt.NewSubscriptionService(boltDB)
+			svc.campaign = bolt.NewCampaignService(boltDB)
+			svc.delivery = bolt.NewDeliveryService(boltDB)
+			svc.bounce = bolt.NewBounceService(boltDB)
+			svc.suppression = bolt.NewSuppressionService(boltDB)
+			svc.audit = bolt.NewAuditService(boltDB)
+			svc.outbox = bolt.NewOutboxService(boltDB)
+			svc.tracking = bolt.NewTrackingService(boltDB)
+			svc.report = bolt.NewReportService(boltDB)
+			svc.apiKey = bolt.NewAPIKeyService(boltDB)
+			svc.admin = bolt.NewAdminService(boltDB)
+			svc.session = bolt.NewSessionService(boltDB)
 		} else {
 			err = fmt.Errorf("failed to create BoltDB")
 		}
@@ -137,7 +235,19 @@ func newDatabaseService(dbType DatabaseType, path string) (mailbus.Database, mai
 		db = sqlite.NewDB(path)
 		sqliteDB, ok := db.(*sqlite.DB)
 		if ok {
-			subscriptionSvc = sqlite.NewSubscriptionService(sqliteDB)
+			svc.list = sqlite.NewListService(sqliteDB)
+			svc.subscription = sqlite.NewSubscriptionService(sqliteDB)
+			svc.campaign = sqlite.NewCampaignService(sqliteDB)
+			svc.delivery = sqlite.NewDeliveryService(sqliteDB)
+			svc.bounce = sqlite.NewBounceService(sqliteDB)
+			svc.suppression = sqlite.NewSuppressionService(sqliteDB)
+			svc.audit = sqlite.NewAuditService(sqliteDB)
+			svc.outbox = sqlite.NewOutboxService(sqliteDB)
+			svc.tracking = sqlite.NewTrackingService(sqliteDB)
+			svc.report = sqlite.NewReportService(sqliteDB)
+			svc.apiKey = sqlite.NewAPIKeyService(sqliteDB)
+			svc.admin = sqlite.NewAdminService(sqliteDB)
+			svc.session = sqlite.NewSessionService(sqliteDB)
 		} else {
 			err = fmt.Errorf("failed to create SQLiteDB")
 		}
@@ -146,7 +256,166 @@ func newDatabaseService(dbType DatabaseType, path string) (mailbus.Database, mai
 
 	}
 
-	return db, subscriptionSvc, err
+	return db, svc, err
+}
+
+func newTransport(config *mailbus.Config) (mailbus.Transport, error) {
+	switch config.Transport.Driver {
+	case mailbus.TransportSMTP, "":
+		return transport.NewSMTPTransport(config.SMTP.Host, config.SMTP.Port, config.SMTP.Username, config.SMTP.Password,
+			config.SMTP.Pool.Size), nil
+	case mailbus.TransportSendmail:
+		return transport.NewSendmailTransport(config.Transport.Sendmail.Path), nil
+	case mailbus.TransportFile:
+		return transport.NewFileTransport(config.Transport.File.Dir, config.Transport.File.Maildir)
+	case mailbus.TransportHTTP:
+		return transport.NewHTTPTransport(config.Transport.HTTP.URL, config.Transport.HTTP.Headers,
+			config.Transport.HTTP.Timeout), nil
+	default:
+		return nil, fmt.Errorf("unsupported transport driver: %s", config.Transport.Driver)
+	}
+}
+
+// newOpenHeuristics returns the heuristics telling the opens made by machines
+func newOpenHeuristics(config *mailbus.Config) (mailbus.OpenHeuristics, error) {
+	opens := config.Tracking.Opens
+
+	h := mailbus.OpenHeuristics{
+		MinDelay:        opens.MinDelay,
+		ProxyUserAgents: opens.ProxyUserAgents,
+		UserAgents:      opens.UserAgents,
+	}
+	for _, cidr := range opens.Networks {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return h, fmt.Errorf("invalid tracking network %q: %w", cidr, err)
+		}
+		h.Networks = append(h.Networks, n)
+	}
+
+	return h, nil
+}
+
+// newConfirmationPolicy returns how confirmation tokens and pending subscriptions are handled
+func newConfirmationPolicy(config *mailbus.Config) mailbus.ConfirmationPolicy {
+	return mailbus.ConfirmationPolicy{
+		TokenTTL:    config.Newsletter.Confirmation.TokenTTL,
+		Cooldown:    config.Newsletter.Confirmation.Cooldown,
+		RemindAfter: config.Newsletter.Confirmation.RemindAfter,
+		PurgeAfter:  config.Newsletter.Confirmation.PurgeAfter,
+	}
+}
+
+// newTokenSigner returns the signer of confirmation tokens, nil if tokens are stored
+func newTokenSigner(config *mailbus.Config) (*token.Signer, error) {
+	switch config.Newsletter.Confirmation.Tokens {
+	case "", mailbus.TokensStored:
+		return nil, nil
+	case mailbus.TokensSigned:
+	default:
+		return nil, fmt.Errorf("unsupported confirmation tokens %q, use %s or %s",
+			config.Newsletter.Confirmation.Tokens, mailbus.TokensStored, mailbus.TokensSigned)
+	}
+
+	var keys []token.Key
+	for _, k := range config.Newsletter.Confirmation.Keys {
+		key := token.Key{
+			ID:        k.ID,
+			Algorithm: k.Algorithm,
+			Secret:    []byte(k.Secret),
+		}
+		if k.PrivateKey != "" {
+			data, err := os.ReadFile(k.PrivateKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read private key of confirmation key %q: %w", k.ID, err)
+			}
+			if key.PrivateKey, err = token.ParseEd25519Key(data); err != nil {
+				return nil, fmt.Errorf("invalid private key of confirmation key %q: %w", k.ID, err)
+			}
+		}
+		keys = append(keys, key)
+	}
+
+	return token.NewSigner(keys...)
+}
+
+// newKeyring returns the keyring of the HMAC keys that sign links. The secret of old configs verifies
+// the links that were signed with it, and signs until keys are added.
+func newKeyring(config *mailbus.Config) (*hash.Keyring, error) {
+	var keys []hash.Key
+	for _, k := range config.Newsletter.HMAC.Keys {
+		if k.ID == "" {
+			return nil, fmt.Errorf("HMAC keys must have an ID")
+		}
+		if !k.Retired {
+			keys = append(keys, hash.Key{ID: k.ID, Secret: k.Secret})
+		}
+	}
+	if secret := config.Newsletter.HMAC.Secret; secret != "" {
+		keys = append(keys, hash.Key{Secret: secret})
+	}
+
+	return hash.NewKeyring(keys...)
+}
+
+// newLimiter returns the rate limiter of newsletters. The deliveries sent in the last 24 hours, the longest
+// rolling cap, are counted again so that a restart does not grant a new quota.
+func newLimiter(config *mailbus.Config, deliveryService mailbus.DeliveryService) (*ratelimit.Limiter, error) {
+	rl := config.RateLimit
+
+	l := ratelimit.New(ratelimit.Quota{Rate: rl.Rate, Burst: rl.Burst, Hourly: rl.Hourly, Daily: rl.Daily})
+	for _, d := range rl.Domains {
+		l.Limit(ratelimit.Quota{Rate: d.Rate, Burst: d.Burst, Hourly: d.Hourly, Daily: d.Daily}, d.Names...)
+	}
+
+	sent, err := deliveryService.Find(mailbus.DeliveryFilter{
+		Status:    mailbus.DeliveryStatusSent,
+		SentSince: time.Now().Add(-24 * time.Hour),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the deliveries sent in the last 24 hours: %w", err)
+	}
+	sort.Slice(sent, func(i, j int) bool {
+		return sent[i].SentAt.Before(sent[j].SentAt)
+	})
+	for _, d := range sent {
+		l.Record(d.Email, d.SentAt)
+	}
+
+	return l, nil
+}
+
+// createLists creates the lists declared in the config that do not exist yet. The tracking enabled
+// in the config is turned on for the lists that exist, it can be turned off from the admin API or UI.
+func (a *app) createLists() error {
+	for _, l := range a.config.Newsletter.Lists {
+		existing, err := a.services.list.FindByName(l.Name)
+		if err == nil {
+			if l.TrackOpens && !existing.TrackOpens || l.TrackClicks && !existing.TrackClicks {
+				existing.TrackOpens = existing.TrackOpens || l.TrackOpens
+				existing.TrackClicks = existing.TrackClicks || l.TrackClicks
+				if err := a.services.list.Update(existing); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if mailbus.ErrorCode(err) != mailbus.ErrNotFound {
+			return err
+		}
+
+		if err := a.services.list.Create(&mailbus.List{
+			Name:        l.Name,
+			Title:       l.Title,
+			Description: l.Description,
+			TrackOpens:  l.TrackOpens,
+			TrackClicks: l.TrackClicks,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (a *app) Run(ctx context.Context) error {
@@ -154,47 +423,130 @@ func (a *app) Run(ctx context.Context) error {
 		return err
 	}
 
+	if err := a.createLists(); err != nil {
+		return err
+	}
+
 	a.httpServer.Addr = a.config.HTTP.Addr
 
 	if err := a.httpServer.Open(); err != nil {
 		return err
 	}
 
-	a.httpServer.NewsletterService = gmail.NewNewsletterService(a.config, a.httpServer.URL())
-
-	nextSaturday := getNextSaturday(time.Now())
-	durationUntilNextSaturday := time.Until(nextSaturday)
+	var (
+		signer *dkim.Signer
+		err    error
+	)
+	if a.config.DKIM.Domain != "" {
+		signer, err = dkim.NewSigner(a.config.DKIM.Domain, a.config.DKIM.Selector, a.config.DKIM.PrivateKey)
+		if err != nil {
+			return err
+		}
+	}
 
-	for {
-		select {
-		case <-time.After(durationUntilNextSaturday):
-			ticker := time.NewTicker(7 * 24 * time.Hour)
-			defer ticker.Stop()
+	a.transport, err = newTransport(a.config)
+	if err != nil {
+		return err
+	}
 
-			tick := ticker.C
+	keyring, err := newKeyring(a.config)
+	if err != nil {
+		return err
+	}
 
-			for {
-				select {
-				case <-tick:
-					if err := a.httpServer.ConsumeAndSendNewsletter(ctx); err != nil {
-						return err
-					}
-				case <-ctx.Done():
-					return nil
-				}
-			}
-		case <-ctx.Done():
-			return nil
+	a.httpServer.NewsletterService = gmail.NewNewsletterService(a.config, a.httpServer.URL(), a.services.list, a.services.suppression, signer, keyring, a.transport)
+
+	bounceProcessor := &bounce.Processor{
+		BounceService:       a.services.bounce,
+		SubscriptionService: a.services.subscription,
+		DeliveryService:     a.services.delivery,
+		SuppressionService:  a.services.suppression,
+		Policy: mailbus.BouncePolicy{
+			HardThreshold: a.config.Bounce.HardThreshold,
+			SoftThreshold: a.config.Bounce.SoftThreshold,
+			Window:        a.config.Bounce.Window,
+		},
+		Maildir: a.config.Bounce.Maildir,
+		Mbox:    a.config.Bounce.Mbox,
+	}
+	if bounceProcessor.Maildir != "" || bounceProcessor.Mbox != "" {
+		go bounceProcessor.Watch(ctx, a.config.Bounce.Interval)
+	}
+	if a.config.Bounce.SMTP.Addr != "" {
+		a.bounceServer = &bounce.Server{
+			Addr:      a.config.Bounce.SMTP.Addr,
+			Processor: bounceProcessor,
+		}
+		if err := a.bounceServer.Open(); err != nil {
+			return err
 		}
 	}
+
+	limiter, err := newLimiter(a.config, a.services.delivery)
+	if err != nil {
+		return err
+	}
+
+	d := &dispatcher{
+		campaignService:     a.services.campaign,
+		subscriptionService: a.services.subscription,
+		deliveryService:     a.services.delivery,
+		newsletterService:   a.httpServer.NewsletterService,
+		bounceProcessor:     bounceProcessor,
+		limiter:             limiter,
+		retryPolicy: mailbus.RetryPolicy{
+			MaxAttempts:     a.config.SMTP.Retry.MaxAttempts,
+			InitialInterval: a.config.SMTP.Retry.InitialInterval,
+			MaxInterval:     a.config.SMTP.Retry.MaxInterval,
+		},
+		interval:    a.config.Newsletter.Dispatcher.Interval,
+		concurrency: a.config.Newsletter.Dispatcher.Concurrency,
+	}
+	go d.Run(ctx)
+
+	r := &relay{
+		outboxService:     a.services.outbox,
+		newsletterService: a.httpServer.NewsletterService,
+		retryPolicy:       d.retryPolicy,
+		interval:          a.config.Newsletter.Outbox.Interval,
+	}
+	go r.Run(ctx)
+
+	rp := &reaper{
+		subscriptionService: a.services.subscription,
+		confirmer: &mailbus.Confirmer{
+			SubscriptionService: a.services.subscription,
+			Policy:              a.httpServer.ConfirmationPolicy,
+			Signer:              a.httpServer.TokenSigner,
+			NewToken:            a.httpServer.NewsletterService.GenerateNewUUID,
+		},
+		serverURL: a.httpServer.URL(),
+		interval:  a.config.Newsletter.Confirmation.Interval,
+	}
+	go rp.Run(ctx)
+
+	// newsletter requests pushed onto the queue go out with the next weekly issue
+	errc := make(chan error, 1)
+	go func() {
+		errc <- a.httpServer.ConsumeNewsletterRequests(ctx, getNextSaturday)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		return nil
+	}
 }
 
+// getNextSaturday returns the first Saturday at 07:00 after now
 func getNextSaturday(now time.Time) time.Time {
-	if now.Weekday() != time.Saturday {
-		now = now.Add(24 * time.Hour)
+	days := (int(time.Saturday) - int(now.Weekday()) + 7) % 7
+	next := time.Date(now.Year(), now.Month(), now.Day()+days, 7, 0, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 7)
 	}
-
-	return time.Date(now.Year(), now.Month(), now.Day(), 7, 0, 0, 0, now.Location())
+	return next
 }
 
 func (a *app) Close() error {
@@ -204,6 +556,18 @@ func (a *app) Close() error {
 		}
 	}
 
+	if a.bounceServer != nil {
+		if err := a.bounceServer.Close(); err != nil {
+			return err
+		}
+	}
+
+	if a.transport != nil {
+		if err := a.transport.Close(); err != nil {
+			return err
+		}
+	}
+
 	if a.db != nil {
 		if err := a.db.Close(); err != nil {
 			return err
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/mailbus"
+)
+
+func TestGetNextSaturday(t *testing.T) {
+	saturday := time.Date(2026, 10, 17, 7, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC), saturday},
+		{time.Date(2026, 10, 17, 6, 59, 0, 0, time.UTC), saturday},
+		{saturday, saturday.AddDate(0, 0, 7)},
+		{time.Date(2026, 10, 17, 20, 0, 0, 0, time.UTC), saturday.AddDate(0, 0, 7)},
+	} {
+		assert.Equal(t, tt.want, getNextSaturday(tt.now), tt.now.String())
+	}
+}
+
+func TestNewLimiter(t *testing.T) {
+	for _, dbType := range databaseTypes {
+		t.Run(string(dbType), func(t *testing.T) {
+			config := newTestConfig(t, dbType)
+			config.RateLimit.Daily = 2
+			db, svc, err := newDatabaseService(dbType, config.DB.Path)
+			require.NoError(t, err)
+			require.NoError(t, db.Open())
+			defer db.Close()
+
+			// only what was sent in the last 24 hours counts against the daily cap
+			for i, d := range []mailbus.Delivery{
+				{Email: "alice@example.com", Status: mailbus.DeliveryStatusSent, SentAt: time.Now().Add(-time.Hour)},
+				{Email: "bob@example.com", Status: mailbus.DeliveryStatusSent, SentAt: time.Now().Add(-25 * time.Hour)},
+				{Email: "carol@example.com", Status: mailbus.DeliveryStatusQueued},
+			} {
+				d.CampaignID, d.SubscriberID, d.QueuedAt = 1, i+1, time.Now().Add(-26*time.Hour)
+				require.NoError(t, svc.delivery.Create(&d))
+			}
+
+			l, err := newLimiter(config, svc.delivery)
+			require.NoError(t, err)
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			require.NoError(t, l.Wait(ctx, "dave@example.com"))
+			assert.ErrorIs(t, l.Wait(ctx, "erin@example.com"), context.DeadlineExceeded)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+
+	"github.com/quantonganh/mailbus"
+)
+
+// reaper takes care of the subscriptions that are never confirmed: it reminds them once, then deletes them
+type reaper struct {
+	subscriptionService mailbus.SubscriptionService
+	confirmer           *mailbus.Confirmer
+	serverURL           string
+	interval            time.Duration
+}
+
+// Run reminds and purges pending subscriptions every interval until ctx is cancelled
+func (r *reaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.reap(time.Now())
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *reaper) reap(now time.Time) {
+	policy := r.confirmer.Policy
+	if policy.RemindAfter > 0 {
+		r.remind(now)
+	}
+
+	if policy.PurgeAfter > 0 {
+		n, err := r.subscriptionService.Purge(now.Add(-policy.PurgeAfter))
+		if err != nil {
+			sentry.CaptureException(err)
+			return
+		}
+		if n > 0 {
+			log.Printf("purged %d subscriptions pending confirmation for more than %s", n, policy.PurgeAfter)
+		}
+	}
+}
+
+// remind sends a new confirmation email, with a fresh token, to the subscriptions that are due their reminder
+func (r *reaper) remind(now time.Time) {
+	subscribers, err := r.subscriptionService.FindPending(now.Add(-r.confirmer.Policy.RemindAfter))
+	if err != nil {
+		sentry.CaptureException(err)
+		return
+	}
+
+	for i := range subscribers {
+		s := &subscribers[i]
+		if !r.confirmer.Policy.Remindable(s, now) {
+			continue
+		}
+
+		subscription, err := r.confirmer.NewSubscription(s.List, s.Email)
+		if err != nil {
+			sentry.CaptureException(err)
+			continue
+		}
+		subscription.Reminder = true
+		subscription.Message = mailbus.NewConfirmationMessage(s.Email, r.serverURL, subscription.Token)
+		if err := r.subscriptionService.Update(subscription); err != nil {
+			sentry.CaptureException(err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+
+	"github.com/quantonganh/mailbus"
+)
+
+// relay sends the messages of the outbox, they were saved in the same transaction as the change they are about
+type relay struct {
+	outboxService     mailbus.OutboxService
+	newsletterService mailbus.NewsletterService
+	retryPolicy       mailbus.RetryPolicy
+	interval          time.Duration
+}
+
+// Run sends due outbox messages every interval until ctx is cancelled
+func (r *relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.relayDue(time.Now())
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *relay) relayDue(now time.Time) {
+	messages, err := r.outboxService.FindDue(now)
+	if err != nil {
+		sentry.CaptureException(err)
+		return
+	}
+
+	for i := range messages {
+		m := &messages[i]
+		sendErr := r.send(m)
+		m.Record(sendErr, r.retryPolicy)
+		if err := r.outboxService.Update(m); err != nil {
+			sentry.CaptureException(err)
+			continue
+		}
+		if sendErr != nil {
+			sentry.CaptureException(sendErr)
+		}
+	}
+}
+
+// send sends a message according to its kind
+func (r *relay) send(m *mailbus.OutboxMessage) error {
+	switch m.Kind {
+	case mailbus.OutboxKindConfirmation:
+		var p mailbus.ConfirmationPayload
+		if err := json.Unmarshal([]byte(m.Payload), &p); err != nil {
+			return &mailbus.Error{
+				Code: mailbus.ErrInvalid,
+				Op:   "relay.send",
+				Err:  fmt.Errorf("invalid payload of outbox message %d: %w", m.ID, err),
+			}
+		}
+		return r.newsletterService.SendConfirmationEmail(m.Recipient, p.URL, p.Token)
+	default:
+		return &mailbus.Error{
+			Code: mailbus.ErrInvalid,
+			Op:   "relay.send",
+			Err:  fmt.Errorf("unknown kind of outbox message %d: %q", m.ID, m.Kind),
+		}
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/mail"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/quantonganh/mailbus"
+)
+
+const subscribersUsage = `usage: mailbus subscribers <command> [arguments]
+
+commands:
+  list [filters] [-limit N] [-format F]   list the subscribers matching the filters
+  show [-format F] EMAIL                  show the lists EMAIL is subscribed to
+  add [-list L] [-status S] [-url U] EMAIL...
+                                          add subscribers, active unless -status is pending_confirmation,
+                                          which sends them a confirmation email
+  remove [-list L] EMAIL... | filters     remove subscribers, as if they had never subscribed
+  unsubscribe [-list L] EMAIL... | filters
+                                          unsubscribe subscribers
+  resend-confirmation [-list L] [-url U] EMAIL... | filters
+                                          send a new confirmation email to pending subscribers
+
+filters:
+  -list L        subscribers of the list L
+  -status S      subscribers who are active, pending_confirmation, unsubscribed or bounced
+  -q Q           subscribers whose address contains Q
+  -since DATE    subscribers who subscribed on or after DATE, YYYY-MM-DD or RFC 3339
+  -until DATE    subscribers who subscribed before DATE, or on the day DATE
+
+The commands changing subscribers act on the addresses given in -list, the default list if it is
+omitted, or on every subscriber matching the filters. With -dry-run they only print what they would do.
+Confirmation emails are queued, the running server sends them; their links lead to -url, by default
+the server on this machine. -format is table or json.`
+
+// subscriberChange is a change made by a command to the subscribers it selected
+type subscriberChange struct {
+	// verb and done describe the change, as in "would remove" and "removed"
+	verb, done string
+	// check returns why a subscriber is skipped, left as it is
+	check func(s *mailbus.Subscriber) error
+	apply func(s *mailbus.Subscriber) error
+}
+
+// subscribersCommand manages the subscribers of the lists
+func subscribersCommand(config *mailbus.Config, svc *services, args []string) error {
+	if len(args) == 0 {
+		return errors.New(subscribersUsage)
+	}
+
+	var filter mailbus.SubscriberFilter
+	fs := flag.NewFlagSet("subscribers "+args[0], flag.ContinueOnError)
+	fs.StringVar(&filter.List, "list", "", "name of the list")
+	fs.StringVar(&filter.Status, "status", "", "status of the subscribers")
+	fs.StringVar(&filter.Query, "q", "", "part of the addresses")
+	since := fs.String("since", "", "subscribed on or after this date")
+	until := fs.String("until", "", "subscribed before the end of this date")
+	fs.IntVar(&filter.Limit, "limit", 0, "maximum number of subscribers listed")
+	format := fs.String("format", formatTable, "output format, table or json")
+	url := fs.String("url", "", "URL of the server the confirmation links lead to")
+	dryRun := fs.Bool("dry-run", false, "print what would be done without doing it")
+	emails, err := parseArgs(fs, args[1:])
+	if err != nil {
+		return err
+	}
+
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	if filter.Since, err = parseDate("since", *since, false); err != nil {
+		return err
+	}
+	if filter.Until, err = parseDate("until", *until, true); err != nil {
+		return err
+	}
+	if filter.Status != "" && !validStatus(filter.Status) {
+		return fmt.Errorf("unknown status %q", filter.Status)
+	}
+	if filter.List != "" {
+		if _, err := svc.list.FindByName(filter.List); err != nil {
+			return errors.New(mailbus.ErrorMessage(err))
+		}
+	}
+
+	switch args[0] {
+	case "list":
+		if len(emails) != 0 {
+			return errors.New(subscribersUsage)
+		}
+		subscribers, err := svc.subscription.Find(filter)
+		if err != nil {
+			return err
+		}
+		return writeSubscribers(*format, subscribers)
+	case "show":
+		if len(emails) != 1 {
+			return errors.New(subscribersUsage)
+		}
+		return showSubscriber(svc.subscription, *format, filter.List, emails[0])
+	case "add":
+		if len(emails) == 0 {
+			return errors.New(subscribersUsage)
+		}
+		return addSubscribers(config, svc, listOrDefault(filter.List), filter.Status, serverURL(config, *url), emails, *dryRun)
+	case "remove":
+		return changeSubscribers(svc, filter, emails, *dryRun, subscriberChange{
+			verb: "remove",
+			done: "removed",
+			apply: func(s *mailbus.Subscriber) error {
+				if err := svc.subscription.Delete(s.List, s.Email); err != nil {
+					return err
+				}
+				audit(svc, mailbus.AuditActionSubscriberDelete, s.Email, s.List)
+				return nil
+			},
+		})
+	case "unsubscribe":
+		return changeSubscribers(svc, filter, emails, *dryRun, subscriberChange{
+			verb: "unsubscribe",
+			done: "unsubscribed",
+			check: func(s *mailbus.Subscriber) error {
+				if s.Status == mailbus.StatusUnsubscribed {
+					return errors.New("already unsubscribed")
+				}
+				return nil
+			},
+			apply: func(s *mailbus.Subscriber) error {
+				if err := svc.subscription.Unsubscribe(s.List, s.Email); err != nil {
+					return err
+				}
+				audit(svc, mailbus.AuditActionSubscriberUpdate, s.Email, s.List+": "+s.Status+" -> "+mailbus.StatusUnsubscribed)
+				return nil
+			},
+		})
+	case "resend-confirmation":
+		confirmer, err := newConfirmer(config, svc)
+		if err != nil {
+			return err
+		}
+		link := serverURL(config, *url)
+		if len(emails) == 0 && filter.Status == "" {
+			filter.Status = mailbus.StatusPendingConfirmation
+		}
+		return changeSubscribers(svc, filter, emails, *dryRun, subscriberChange{
+			verb: "resend the confirmation to",
+			done: "resent the confirmation to",
+			check: func(s *mailbus.Subscriber) error {
+				if s.Status != mailbus.StatusPendingConfirmation {
+					return fmt.Errorf("%s, not %s", s.Status, mailbus.StatusPendingConfirmation)
+				}
+				return nil
+			},
+			apply: func(s *mailbus.Subscriber) error {
+				subscription, err := newConfirmation(confirmer, s.List, s.Email, link)
+				if err != nil {
+					return err
+				}
+				if err := svc.subscription.Update(subscription); err != nil {
+					return err
+				}
+				audit(svc, mailbus.AuditActionSubscriberUpdate, s.Email, s.List+": "+s.Status+" -> "+mailbus.StatusPendingConfirmation)
+				return nil
+			},
+		})
+	default:
+		return errors.New(subscribersUsage)
+	}
+}
+
+func writeSubscribers(format string, subscribers []mailbus.Subscriber) error {
+	if subscribers == nil {
+		subscribers = []mailbus.Subscriber{}
+	}
+	return writeOutput(os.Stdout, format, subscribers, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, "EMAIL\tLIST\tSTATUS\tSUBSCRIBED\tCONFIRMATION SENT")
+		for _, s := range subscribers {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", s.Email, s.List, s.Status, formatTime(s.SubscribedAt), formatTime(s.ConfirmationSentAt))
+		}
+	})
+}
+
+// showSubscriber shows the subscriptions of an address, to every list or to one
+func showSubscriber(ss mailbus.SubscriptionService, format, list, email string) error {
+	found, err := ss.Find(mailbus.SubscriberFilter{List: list, Query: email})
+	if err != nil {
+		return err
+	}
+
+	// the query matches the addresses containing email, only the address itself is shown
+	var subscribers []mailbus.Subscriber
+	for _, s := range found {
+		if strings.EqualFold(s.Email, email) {
+			subscribers = append(subscribers, s)
+		}
+	}
+	if len(subscribers) == 0 {
+		return fmt.Errorf("%s is not subscribed to any list", email)
+	}
+
+	return writeSubscribers(format, subscribers)
+}
+
+// addSubscribers adds addresses to a list, going on with the next address when one cannot be added
+func addSubscribers(config *mailbus.Config, svc *services, list, status, link string, emails []string, dryRun bool) error {
+	if status == "" {
+		status = mailbus.StatusActive
+	}
+	if status != mailbus.StatusActive && status != mailbus.StatusPendingConfirmation {
+		return fmt.Errorf("subscribers can be added %s or %s", mailbus.StatusActive, mailbus.StatusPendingConfirmation)
+	}
+	confirmer, err := newConfirmer(config, svc)
+	if err != nil {
+		return err
+	}
+
+	var added, skipped, invalid int
+	for _, email := range emails {
+		email = strings.TrimSpace(email)
+		if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+			fmt.Fprintf(os.Stderr, "invalid email address %s\n", email)
+			invalid++
+			continue
+		}
+		email = mailbus.NormalizeEmail(email)
+		_, err := svc.subscription.FindByEmail(list, email)
+		if err == nil {
+			fmt.Fprintf(os.Stderr, "skipped %s: already subscribed to %s\n", email, list)
+			skipped++
+			continue
+		}
+		if mailbus.ErrorCode(err) != mailbus.ErrNotFound {
+			return err
+		}
+
+		if dryRun {
+			fmt.Printf("would add %s to %s as %s\n", email, list, status)
+			added++
+			continue
+		}
+
+		subscription := mailbus.NewSubscription(list, email, mailbus.StatusActive, "")
+		if status == mailbus.StatusPendingConfirmation {
+			if subscription, err = newConfirmation(confirmer, list, email, link); err != nil {
+				return err
+			}
+		}
+		if err := svc.subscription.Insert(subscription); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to add %s: %s\n", email, mailbus.ErrorMessage(err))
+			invalid++
+			continue
+		}
+		audit(svc, mailbus.AuditActionSubscriberCreate, email, list)
+		fmt.Printf("added %s to %s as %s\n", email, list, status)
+		added++
+	}
+
+	return summarize("added", "are invalid or could not be added", added, skipped, invalid, dryRun)
+}
+
+// changeSubscribers applies a change to the given addresses of the list of the filter,
+// or to the subscribers matching the filter when no address is given
+func changeSubscribers(svc *services, filter mailbus.SubscriberFilter, emails []string, dryRun bool, change subscriberChange) error {
+	var subscribers []mailbus.Subscriber
+	var changed, skipped, invalid int
+	if len(emails) == 0 {
+		if filter.List == "" && filter.Status == "" && filter.Query == "" && filter.Since.IsZero() && filter.Until.IsZero() {
+			return errors.New("give the addresses of the subscribers, or filters selecting them")
+		}
+		var err error
+		if subscribers, err = svc.subscription.Find(filter); err != nil {
+			return err
+		}
+	} else {
+		list := listOrDefault(filter.List)
+		for _, email := range emails {
+			s, err := svc.subscription.FindByEmail(list, email)
+			if mailbus.ErrorCode(err) == mailbus.ErrNotFound {
+				fmt.Fprintf(os.Stderr, "%s is not subscribed to %s\n", email, list)
+				invalid++
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			subscribers = append(subscribers, *s)
+		}
+	}
+
+	for i := range subscribers {
+		s := &subscribers[i]
+		if change.check != nil {
+			if err := change.check(s); err != nil {
+				fmt.Fprintf(os.Stderr, "skipped %s (%s): %v\n", s.Email, s.List, err)
+				skipped++
+				continue
+			}
+		}
+
+		if dryRun {
+			fmt.Printf("would %s %s (%s)\n", change.verb, s.Email, s.List)
+			changed++
+			continue
+		}
+		if err := change.apply(s); err != nil {
+			return fmt.Errorf("failed to %s %s (%s): %s", change.verb, s.Email, s.List, mailbus.ErrorMessage(err))
+		}
+		fmt.Printf("%s %s (%s)\n", change.done, s.Email, s.List)
+		changed++
+	}
+
+	return summarize("changed", "are not subscribed", changed, skipped, invalid, dryRun)
+}
+
+// summarize prints how many subscribers a command changed and skipped, as they already were as asked.
+// The command fails when some of the addresses given were invalid, so that scripts notice: failed tells
+// what was wrong with them, in the words of the command.
+func summarize(done, failed string, changed, skipped, invalid int, dryRun bool) error {
+	if dryRun {
+		fmt.Printf("dry run: %d would be %s, %d skipped\n", changed, done, skipped)
+	} else {
+		fmt.Printf("%d %s, %d skipped\n", changed, done, skipped)
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d of the addresses given %s", invalid, failed)
+	}
+	return nil
+}
+
+// newConfirmer returns the confirmer issuing the tokens of the confirmation emails, as the server does
+func newConfirmer(config *mailbus.Config, svc *services) (*mailbus.Confirmer, error) {
+	signer, err := newTokenSigner(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mailbus.Confirmer{
+		SubscriptionService: svc.subscription,
+		Policy:              newConfirmationPolicy(config),
+		Signer:              signer,
+		NewToken: func() string {
+			return uuid.NewV4().String()
+		},
+	}, nil
+}
+
+// newConfirmation returns a subscription pending confirmation, along with its confirmation email
+func newConfirmation(confirmer *mailbus.Confirmer, list, email, link string) (*mailbus.Subscription, error) {
+	subscription, err := confirmer.NewSubscription(list, email)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription.Message = mailbus.NewConfirmationMessage(email, link, subscription.Token)
+	return subscription, nil
+}
+
+// serverURL returns the URL the confirmation links lead to: url if it is set, or the server as it listens on this machine
+func serverURL(config *mailbus.Config, url string) string {
+	if url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+
+	_, port, err := net.SplitHostPort(config.HTTP.Addr)
+	if err != nil || port == "" || port == "80" {
+		return "http://localhost"
+	}
+	return "http://localhost:" + port
+}
+
+func listOrDefault(name string) string {
+	if name == "" {
+		return mailbus.DefaultList
+	}
+	return name
+}
+
+func validStatus(status string) bool {
+	switch status {
+	case mailbus.StatusPendingConfirmation, mailbus.StatusActive, mailbus.StatusUnsubscribed, mailbus.StatusBounced:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/quantonganh/mailbus"
+)
+
+const suppressionsUsage = `usage: mailbus suppressions <command> [arguments]
+
+commands:
+  list                          list suppressed addresses and domains
+  add [-reason R] VALUE...      suppress addresses or domains
+  remove VALUE...               remove addresses or domains from the suppression list
+  import [-reason R] FILE       suppress one address or domain per line of FILE, or stdin if FILE is -`
+
+// suppressionsCommand manages the suppression list
+func suppressionsCommand(_ *mailbus.Config, svc *services, args []string) error {
+	if len(args) == 0 {
+		return errors.New(suppressionsUsage)
+	}
+
+	fs := flag.NewFlagSet("suppressions "+args[0], flag.ContinueOnError)
+	reason := fs.String("reason", "", "why the entries are suppressed")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		return listSuppressions(svc.suppression, os.Stdout)
+	case "add":
+		for _, value := range fs.Args() {
+			if err := addSuppression(svc.suppression, value, mailbus.SuppressionSourceManual, *reason); err != nil {
+				return err
+			}
+			audit(svc, mailbus.AuditActionSuppressionCreate, value, *reason)
+			fmt.Printf("suppressed %s\n", value)
+		}
+		return nil
+	case "remove":
+		for _, value := range fs.Args() {
+			value = strings.TrimPrefix(strings.ToLower(value), "@")
+			if err := svc.suppression.Delete(value); err != nil {
+				return err
+			}
+			audit(svc, mailbus.AuditActionSuppressionDelete, value, "")
+			fmt.Printf("removed %s\n", value)
+		}
+		return nil
+	case "import":
+		if fs.NArg() != 1 {
+			return errors.New(suppressionsUsage)
+		}
+		return importSuppressions(svc.suppression, fs.Arg(0), *reason)
+	default:
+		return errors.New(suppressionsUsage)
+	}
+}
+
+func listSuppressions(ss mailbus.SuppressionService, w io.Writer) error {
+	suppressions, err := ss.FindAll()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VALUE\tSOURCE\tREASON\tCREATED")
+	for _, s := range suppressions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.Value, s.Source, s.Reason, s.CreatedAt.Format("2006-01-02 15:04"))
+	}
+	return tw.Flush()
+}
+
+func addSuppression(ss mailbus.SuppressionService, value, source, reason string) error {
+	s, err := mailbus.NewSuppression(value, source, reason)
+	if err != nil {
+		return err
+	}
+	return ss.Create(s)
+}
+
+// importSuppressions suppresses every address or domain of a file, skipping blank lines,
+// comments starting with # and entries that are already suppressed
+func importSuppressions(ss mailbus.SuppressionService, path, reason string) error {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var added, existing, invalid int
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		value := strings.TrimSpace(scanner.Text())
+		if value == "" || strings.HasPrefix(value, "#") {
+			continue
+		}
+
+		err := addSuppression(ss, value, mailbus.SuppressionSourceImport, reason)
+		switch mailbus.ErrorCode(err) {
+		case "":
+			added++
+		case mailbus.ErrConflict:
+			existing++
+		case mailbus.ErrInvalid:
+			invalid++
+			fmt.Fprintf(os.Stderr, "line %d: %s\n", line, mailbus.ErrorMessage(err))
+		default:
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	fmt.Printf("%d added, %d already suppressed, %d invalid\n", added, existing, invalid)
+	return nil
+}
@@ -1,5 +1,7 @@
 package mailbus
 
+import "time"
+
 // Config represents the main config
 type Config struct {
 	DB struct {
@@ -11,11 +13,60 @@ type Config struct {
 		Addr string
 	}
 
+	Admin struct {
+		SessionTTL time.Duration // how long admins stay logged in to the admin UI
+	}
+
 	SMTP struct {
 		Host     string
 		Port     int
 		Username string
 		Password string
+		Pool     struct {
+			Size int // number of connections kept open between sends
+		}
+		Retry struct {
+			MaxAttempts     int
+			InitialInterval time.Duration
+			MaxInterval     time.Duration
+		}
+	}
+
+	// RateLimit throttles newsletters, zero values mean no limit
+	RateLimit struct {
+		Rate    float64 // messages per second
+		Burst   int
+		Hourly  int // messages in any rolling hour
+		Daily   int // messages in any rolling 24 hours
+		Domains []struct {
+			Names  []string // recipient domains sharing the quota, e.g. gmail.com and googlemail.com
+			Rate   float64
+			Burst  int
+			Hourly int
+			Daily  int
+		}
+	}
+
+	Transport struct {
+		Driver   string // "smtp" (default), "sendmail", "file" or "http"
+		Sendmail struct {
+			Path string
+		}
+		File struct {
+			Dir     string
+			Maildir bool // write a Maildir instead of .eml files
+		}
+		HTTP struct {
+			URL     string
+			Headers map[string]string
+			Timeout time.Duration
+		}
+	}
+
+	DKIM struct {
+		Domain     string
+		Selector   string
+		PrivateKey string // path to the PEM encoded RSA or Ed25519 private key
 	}
 
 	Newsletter struct {
@@ -28,7 +79,66 @@ type Config struct {
 			Name string
 		}
 		HMAC struct {
+			// Secret signed links before keys had IDs: it keeps verifying the links without a key ID,
+			// and signs if there are no keys
 			Secret string
+			// Keys sign links, newest first: the first key that is not retired signs, the others verify
+			Keys []struct {
+				ID      string
+				Secret  string // at least 32 bytes
+				Retired bool   // neither signs nor verifies anymore
+			}
+			LinkTTL time.Duration // unsubscribe links expire after that long, 0 for never
+		}
+		Dispatcher struct {
+			Interval    time.Duration
+			Concurrency int // number of messages sent at the same time, keep it at or below smtp.pool.size
+		}
+		Outbox struct {
+			Interval time.Duration
+		}
+		Confirmation struct {
+			TokenTTL    time.Duration // how long a confirmation link can be followed, 0 for ever
+			Cooldown    time.Duration // how long before a pending subscriber can be sent another confirmation email
+			RemindAfter time.Duration // how long a subscription is pending before a reminder is sent, 0 for none
+			PurgeAfter  time.Duration // how long a subscription is pending before it is deleted, 0 for never
+			Interval    time.Duration
+			Tokens      string // stored random tokens, or signed ones that are verified without a lookup
+			// Keys sign and verify signed tokens, the first one signs
+			Keys []struct {
+				ID         string
+				Algorithm  string // hmac-sha256 or ed25519
+				Secret     string // secret of an HMAC key, at least 32 bytes
+				PrivateKey string // path to the PEM encoded private key of an Ed25519 key
+			}
+		}
+		Lists []struct {
+			Name        string
+			Title       string
+			Description string
+			TrackOpens  bool // add a tracking pixel to the newsletters of the list
+			TrackClicks bool // redirect the links of the newsletters of the list through the server
+		}
+	}
+
+	Tracking struct {
+		Opens struct {
+			MinDelay        time.Duration // opens sooner than this after delivery are made by machines
+			ProxyUserAgents []string      // exact user agents of privacy proxies
+			UserAgents      []string      // substrings of the user agents of machines
+			Networks        []string      // CIDR ranges of machines
+		}
+	}
+
+	Bounce struct {
+		HardThreshold int
+		SoftThreshold int
+		Window        time.Duration
+		Interval      time.Duration
+		Maildir       string
+		Mbox          string
+		SMTP          struct {
+			Addr string
 		}
 	}
 
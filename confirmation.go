@@ -0,0 +1,97 @@
+package mailbus
+
+import (
+	"time"
+
+	"github.com/quantonganh/mailbus/pkg/token"
+)
+
+// Confirmation token mode
+const (
+	TokensStored = "stored"
+	TokensSigned = "signed"
+)
+
+// Confirmer issues the tokens of confirmation links and confirms the subscriptions they were issued for.
+// Tokens are random ones stored with the subscription or, if Signer is set, signed ones that carry the subscription
+// and are verified without a lookup. Stored tokens issued before switching to signed ones can still be used.
+type Confirmer struct {
+	SubscriptionService SubscriptionService
+	Policy              ConfirmationPolicy
+	Signer              *token.Signer
+	// NewToken returns a random token
+	NewToken func() string
+}
+
+// NewSubscription returns a subscription pending confirmation, with a new token
+func (c *Confirmer) NewSubscription(list, email string) (*Subscription, error) {
+	s := NewSubscription(list, email, StatusPendingConfirmation, "")
+	s.ExpiresAt = c.Policy.Expiry(s.IssuedAt)
+	if c.Signer == nil {
+		s.Token = c.NewToken()
+		return s, nil
+	}
+
+	t, err := c.Signer.Sign(token.Claims{Email: email, List: list, IssuedAt: s.IssuedAt})
+	if err != nil {
+		return nil, err
+	}
+	s.Token = t
+	s.Signed = true
+	return s, nil
+}
+
+// Verify checks the signature and the expiry of a signed token, stored tokens are only checked when they are used
+func (c *Confirmer) Verify(t string) error {
+	if !token.IsSigned(t) {
+		return nil
+	}
+
+	_, err := c.verify(t)
+	return err
+}
+
+// Confirm activates the subscription a token was issued for.
+// A signed token can only be used once: the subscription has to be pending since before the token was issued.
+func (c *Confirmer) Confirm(t string) (*Subscriber, error) {
+	const op = "Confirmer.Confirm"
+
+	if !token.IsSigned(t) {
+		return c.SubscriptionService.Confirm(t)
+	}
+
+	claims, err := c.verify(t)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := c.SubscriptionService.FindByEmail(claims.List, claims.Email)
+	if err != nil {
+		return nil, err
+	}
+	// the token of a subscription that was confirmed, or left and pending again since, is spent
+	if s.Status != StatusPendingConfirmation || claims.IssuedAt.Before(s.SubscribedAt.Truncate(time.Second)) {
+		return nil, TokenUsedError(op)
+	}
+
+	return c.SubscriptionService.Activate(claims.List, claims.Email)
+}
+
+func (c *Confirmer) verify(t string) (*token.Claims, error) {
+	const op = "Confirmer.verify"
+
+	if c.Signer == nil {
+		return nil, &Error{Code: ErrNotFound, Op: op, Err: token.ErrInvalid}
+	}
+
+	claims, err := c.Signer.Verify(t)
+	if err != nil {
+		return nil, &Error{Code: ErrNotFound, Op: op, Err: err}
+	}
+
+	if expiresAt := c.Policy.Expiry(claims.IssuedAt); !expiresAt.IsZero() && time.Now().After(expiresAt) {
+		return nil, TokenExpiredError(op)
+	}
+
+	return claims, nil
+}
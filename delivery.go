@@ -0,0 +1,145 @@
+package mailbus
+
+import (
+	"errors"
+	"net/textproto"
+	"time"
+)
+
+// Delivery status
+const (
+	DeliveryStatusQueued     = "queued"
+	DeliveryStatusSending    = "sending"
+	DeliveryStatusDeferred   = "deferred"
+	DeliveryStatusSent       = "sent"
+	DeliveryStatusFailed     = "failed"
+	DeliveryStatusSuppressed = "suppressed"
+)
+
+// DeliveryService is the interface that wraps methods related to the delivery log
+type DeliveryService interface {
+	FindByID(id int) (*Delivery, error)
+	Find(filter DeliveryFilter) ([]Delivery, error)
+	FindDue(now time.Time) ([]Delivery, error)
+	Create(d *Delivery) error
+	Update(d *Delivery) error
+}
+
+// Delivery represents the delivery of a campaign to a single subscriber
+type Delivery struct {
+	ID            int       `storm:"id,increment" json:"id"`
+	CampaignID    int       `storm:"index" json:"campaign_id"`
+	SubscriberID  int       `storm:"index" json:"subscriber_id"`
+	Email         string    `storm:"index" json:"email"`
+	Status        string    `storm:"index" json:"status"`
+	SMTPCode      int       `json:"smtp_code"`
+	SMTPMessage   string    `json:"smtp_message"`
+	Attempts      int       `json:"attempts"`
+	QueuedAt      time.Time `json:"queued_at"`
+	LastAttemptAt time.Time `json:"last_attempt_at"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	SentAt        time.Time `json:"sent_at"`
+}
+
+// DeliveryFilter represents the criteria used to find deliveries
+type DeliveryFilter struct {
+	CampaignID   int
+	SubscriberID int
+	Email        string // matched regardless of case
+	Status       string
+	// SentSince bounds when the deliveries were sent, zero for any time
+	SentSince time.Time
+}
+
+// SMTPReply represents the reply of the mail server to a delivery attempt
+type SMTPReply struct {
+	Code    int
+	Message string
+}
+
+// NewDelivery returns a queued delivery of a campaign to a subscriber
+func NewDelivery(campaignID int, s Subscriber) *Delivery {
+	return &Delivery{
+		CampaignID:   campaignID,
+		SubscriberID: s.ID,
+		Email:        s.Email,
+		Status:       DeliveryStatusQueued,
+		QueuedAt:     time.Now(),
+	}
+}
+
+// Start marks the delivery as being attempted, it is saved before the message is handed over to the server.
+// A delivery still sending after a restart may or may not have gone out, see Interrupt.
+func (d *Delivery) Start() {
+	d.Status = DeliveryStatusSending
+}
+
+// Interrupt fails a delivery whose attempt was cut short by a restart. The server may have accepted the message
+// before the outcome was recorded, so it is not attempted again: a campaign is delivered at most once.
+func (d *Delivery) Interrupt() {
+	d.Status = DeliveryStatusFailed
+	d.NextAttemptAt = time.Time{}
+	d.SMTPMessage = "interrupted while sending, the message may have been delivered"
+}
+
+// Record records the outcome of a delivery attempt. Temporary failures are deferred
+// to a later attempt as long as the policy allows it, permanent ones fail right away.
+func (d *Delivery) Record(reply *SMTPReply, err error, policy RetryPolicy) {
+	now := time.Now()
+	d.Attempts++
+	d.LastAttemptAt = now
+	d.NextAttemptAt = time.Time{}
+
+	if reply != nil {
+		d.SMTPCode = reply.Code
+		d.SMTPMessage = reply.Message
+	}
+
+	if err == nil {
+		d.Status = DeliveryStatusSent
+		d.SentAt = now
+		return
+	}
+
+	if reply == nil || reply.Message == "" {
+		d.SMTPMessage = err.Error()
+	}
+
+	// the message never left, so there is nothing to retry
+	if ErrorCode(err) == ErrSuppressed {
+		d.Status = DeliveryStatusSuppressed
+		d.SMTPMessage = ErrorMessage(err)
+		return
+	}
+
+	if (reply == nil || reply.Temporary()) && policy.Retryable(d.Attempts) {
+		d.Status = DeliveryStatusDeferred
+		d.NextAttemptAt = now.Add(policy.Backoff(d.Attempts))
+		return
+	}
+
+	d.Status = DeliveryStatusFailed
+}
+
+// NewSMTPReply extracts the SMTP reply from the error returned by a mail server,
+// a nil error means the message was accepted
+func NewSMTPReply(err error) *SMTPReply {
+	if err == nil {
+		return &SMTPReply{
+			Code:    250,
+			Message: "OK",
+		}
+	}
+
+	var e *textproto.Error
+	if errors.As(err, &e) {
+		return &SMTPReply{
+			Code:    e.Code,
+			Message: e.Msg,
+		}
+	}
+
+	return &SMTPReply{
+		Message: err.Error(),
+	}
+}
@@ -0,0 +1,94 @@
+package dkim
+
+import (
+	"bytes"
+	"strings"
+)
+
+// field is a header field as it appears in the message, continuation lines included
+type field struct {
+	name string
+	raw  string
+}
+
+// splitMessage splits a message into its header and its body, and normalizes line endings to CRLF
+func splitMessage(msg []byte) (header, body []byte) {
+	msg = bytes.ReplaceAll(msg, []byte("\r\n"), []byte("\n"))
+	msg = bytes.ReplaceAll(msg, []byte("\n"), []byte("\r\n"))
+
+	if i := bytes.Index(msg, []byte("\r\n\r\n")); i >= 0 {
+		return msg[:i+2], msg[i+4:]
+	}
+	return msg, nil
+}
+
+// parseHeader returns the fields of a header in order
+func parseHeader(header []byte) []field {
+	var fields []field
+	for _, line := range strings.SplitAfter(string(header), "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1].raw += line
+			continue
+		}
+
+		name := line
+		if i := strings.Index(line, ":"); i >= 0 {
+			name = line[:i]
+		}
+		fields = append(fields, field{name: strings.TrimSpace(name), raw: line})
+	}
+
+	return fields
+}
+
+// canonicalHeader applies the relaxed header canonicalization of RFC 6376 section 3.4.2
+func canonicalHeader(raw string) string {
+	name, value := raw, ""
+	if i := strings.Index(raw, ":"); i >= 0 {
+		name, value = raw[:i], raw[i+1:]
+	}
+
+	value = strings.ReplaceAll(value, "\r\n", "")
+	value = strings.Join(strings.FieldsFunc(value, isWSP), " ")
+
+	return strings.ToLower(strings.TrimSpace(name)) + ":" + value + "\r\n"
+}
+
+// canonicalBody applies the relaxed body canonicalization of RFC 6376 section 3.4.4
+func canonicalBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	for i, line := range lines {
+		line = strings.TrimRightFunc(line, isWSP)
+		var buf strings.Builder
+		space := false
+		for _, r := range line {
+			if isWSP(r) {
+				space = true
+				continue
+			}
+			if space {
+				buf.WriteByte(' ')
+				space = false
+			}
+			buf.WriteRune(r)
+		}
+		lines[i] = buf.String()
+	}
+
+	// ignore all empty lines at the end of the body
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+func isWSP(r rune) bool {
+	return r == ' ' || r == '\t'
+}
@@ -0,0 +1,217 @@
+// Package dkim signs outgoing messages with DKIM (RFC 6376), using RSA-SHA256 or Ed25519-SHA256 (RFC 8463)
+package dkim
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Algorithm
+const (
+	AlgorithmRSA     = "rsa"
+	AlgorithmEd25519 = "ed25519"
+)
+
+// DefaultHeaders are the header fields that are signed when they are present in a message
+var DefaultHeaders = []string{
+	"From", "To", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type", "Content-Transfer-Encoding",
+	"List-Id", "List-Unsubscribe", "List-Unsubscribe-Post",
+}
+
+// Signer adds a DKIM-Signature header field to messages
+type Signer struct {
+	Domain   string
+	Selector string
+	Key      crypto.Signer
+	Headers  []string
+
+	// now returns the signature timestamp, time.Now if nil
+	now func() time.Time
+}
+
+// NewSigner returns a signer for a domain and a selector, with the PEM encoded private key stored at keyPath
+func NewSigner(domain, selector, keyPath string) (*Signer, error) {
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read DKIM private key")
+	}
+
+	key, err := ParsePrivateKey(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Signer{
+		Domain:   domain,
+		Selector: selector,
+		Key:      key,
+		Headers:  DefaultHeaders,
+	}, nil
+}
+
+// Sign returns the message, with CRLF line endings, prefixed with its DKIM-Signature header field
+func (s *Signer) Sign(msg []byte) ([]byte, error) {
+	var algorithm string
+	switch s.Key.(type) {
+	case *rsa.PrivateKey:
+		algorithm = "rsa-sha256"
+	case ed25519.PrivateKey:
+		algorithm = "ed25519-sha256"
+	default:
+		return nil, errors.Errorf("unsupported DKIM key type %T", s.Key)
+	}
+
+	header, body := splitMessage(msg)
+	fields := parseHeader(header)
+
+	bodyHash := sha256.Sum256(canonicalBody(body))
+
+	// sign the last occurrence of every field that is present, as verifiers look them up from the bottom
+	var (
+		names  []string
+		signed bytes.Buffer
+		used   = make(map[int]bool)
+	)
+	for _, name := range s.Headers {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if used[i] || !strings.EqualFold(fields[i].name, name) {
+				continue
+			}
+			used[i] = true
+			names = append(names, strings.ToLower(name))
+			signed.WriteString(canonicalHeader(fields[i].raw))
+			break
+		}
+	}
+
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	signature := fmt.Sprintf("DKIM-Signature: v=1; a=%s; c=relaxed/relaxed; d=%s; s=%s;\r\n"+
+		" t=%d; h=%s;\r\n bh=%s;\r\n b=",
+		algorithm, s.Domain, s.Selector, now().Unix(), strings.Join(names, ":"),
+		base64.StdEncoding.EncodeToString(bodyHash[:]))
+
+	// the signature covers its own header field, with an empty b= tag and without the trailing CRLF
+	signed.WriteString(strings.TrimSuffix(canonicalHeader(signature), "\r\n"))
+	digest := sha256.Sum256(signed.Bytes())
+
+	var (
+		b   []byte
+		err error
+	)
+	if algorithm == "ed25519-sha256" {
+		b, err = s.Key.Sign(rand.Reader, digest[:], crypto.Hash(0))
+	} else {
+		b, err = s.Key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign message")
+	}
+
+	var out bytes.Buffer
+	out.WriteString(signature)
+	out.WriteString(fold(base64.StdEncoding.EncodeToString(b)))
+	out.WriteString("\r\n")
+	out.Write(header)
+	out.WriteString("\r\n")
+	out.Write(body)
+
+	return out.Bytes(), nil
+}
+
+// fold splits a base64 value over several lines, white space is ignored in the b= tag
+func fold(v string) string {
+	const width = 72
+
+	var buf strings.Builder
+	for len(v) > width {
+		buf.WriteString(v[:width])
+		buf.WriteString("\r\n ")
+		v = v[width:]
+	}
+	buf.WriteString(v)
+	return buf.String()
+}
+
+// GenerateKey generates a new private key, bits only applies to RSA keys
+func GenerateKey(algorithm string, bits int) (crypto.Signer, error) {
+	switch algorithm {
+	case AlgorithmRSA:
+		if bits < 1024 {
+			return nil, errors.Errorf("RSA keys must be at least 1024 bits, got %d", bits)
+		}
+		return rsa.GenerateKey(rand.Reader, bits)
+	case AlgorithmEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	default:
+		return nil, errors.Errorf("unsupported algorithm %q, use %s or %s", algorithm, AlgorithmRSA, AlgorithmEd25519)
+	}
+}
+
+// ParsePrivateKey parses a PEM encoded PKCS #8 RSA or Ed25519 private key, or a PKCS #1 RSA private key
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found in DKIM private key")
+	}
+
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse DKIM private key")
+	}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return key, nil
+	case ed25519.PrivateKey:
+		return key, nil
+	default:
+		return nil, errors.Errorf("unsupported DKIM key type %T", key)
+	}
+}
+
+// MarshalPrivateKey encodes a private key as PEM encoded PKCS #8
+func MarshalPrivateKey(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal private key")
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// TXTRecord returns the DKIM key record to publish as a TXT record at <selector>._domainkey.<domain>
+func TXTRecord(key crypto.Signer) (string, error) {
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to marshal public key")
+		}
+		return "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(der), nil
+	case ed25519.PublicKey:
+		return "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(pub), nil
+	default:
+		return "", errors.Errorf("unsupported DKIM key type %T", pub)
+	}
+}
@@ -0,0 +1,105 @@
+package dkim
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the example message of RFC 8463 appendix A
+const message = "From: Joe SixPack <joe@football.example.com>\r\n" +
+	"To: Suzie Q <suzie@shopping.example.net>\r\n" +
+	"Subject: Is dinner ready?\r\n" +
+	"Date: Fri, 11 Jul 2003 21:00:37 -0700 (PDT)\r\n" +
+	"Message-ID: <20030712040037.46341.5F8J@football.example.com>\r\n" +
+	"\r\n" +
+	"Hi.\r\n" +
+	"\r\n" +
+	"We lost the game.  Are you hungry yet?\r\n" +
+	"\r\n" +
+	"Joe.\r\n"
+
+func TestCanonicalBody(t *testing.T) {
+	_, body := splitMessage([]byte(message))
+	bodyHash := sha256.Sum256(canonicalBody(body))
+	assert.Equal(t, "2jUSOH9NhtVGCQWNr9BrIAPreKQjO6Sn7XIkfJVOzv8=", base64.StdEncoding.EncodeToString(bodyHash[:]))
+
+	assert.Nil(t, canonicalBody([]byte("\r\n\r\n")))
+	assert.Equal(t, " a b\r\n", string(canonicalBody([]byte(" \ta  \t b \r\n\r\n"))))
+}
+
+func TestCanonicalHeader(t *testing.T) {
+	assert.Equal(t, "subject:Is dinner ready?\r\n", canonicalHeader("SUBJECT :  Is dinner\r\n \tready? \r\n"))
+}
+
+func TestSign(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmRSA, AlgorithmEd25519} {
+		t.Run(algorithm, func(t *testing.T) {
+			key, err := GenerateKey(algorithm, 2048)
+			require.NoError(t, err)
+
+			pemKey, err := MarshalPrivateKey(key)
+			require.NoError(t, err)
+			key, err = ParsePrivateKey(pemKey)
+			require.NoError(t, err)
+
+			s := &Signer{Domain: "football.example.com", Selector: "brisbane", Key: key, Headers: DefaultHeaders}
+			signed, err := s.Sign([]byte(strings.ReplaceAll(message, "\r\n", "\n")))
+			require.NoError(t, err)
+			require.True(t, strings.HasPrefix(string(signed), "DKIM-Signature: v=1; a="+algorithm+"-sha256;"))
+			assert.True(t, strings.HasSuffix(string(signed), "Joe.\r\n"))
+
+			verify(t, signed, key.Public())
+
+			record, err := TXTRecord(key)
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(record, "v=DKIM1; k="+algorithm+"; p="))
+		})
+	}
+}
+
+// verify checks the DKIM-Signature of a message the way a receiver would
+func verify(t *testing.T, msg []byte, pub crypto.PublicKey) {
+	header, body := splitMessage(msg)
+	fields := parseHeader(header)
+	require.Equal(t, "DKIM-Signature", fields[0].name)
+
+	tags := make(map[string]string)
+	value := strings.SplitN(strings.ReplaceAll(fields[0].raw, "\r\n", ""), ":", 2)[1]
+	for _, tag := range strings.Split(value, ";") {
+		kv := strings.SplitN(strings.TrimSpace(tag), "=", 2)
+		tags[kv[0]] = strings.Join(strings.Fields(kv[1]), "")
+	}
+
+	bodyHash := sha256.Sum256(canonicalBody(body))
+	require.Equal(t, base64.StdEncoding.EncodeToString(bodyHash[:]), tags["bh"])
+
+	var data strings.Builder
+	for _, name := range strings.Split(tags["h"], ":") {
+		for i := len(fields) - 1; i > 0; i-- {
+			if strings.EqualFold(fields[i].name, name) {
+				data.WriteString(canonicalHeader(fields[i].raw))
+				break
+			}
+		}
+	}
+	unsigned := fields[0].raw[:strings.LastIndex(fields[0].raw, " b=")+3]
+	data.WriteString(strings.TrimSuffix(canonicalHeader(unsigned), "\r\n"))
+	digest := sha256.Sum256([]byte(data.String()))
+
+	sig, err := base64.StdEncoding.DecodeString(tags["b"])
+	require.NoError(t, err)
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		assert.NoError(t, rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig))
+	case ed25519.PublicKey:
+		assert.True(t, ed25519.Verify(pub, digest[:], sig))
+	}
+}
@@ -13,6 +13,7 @@ const (
 	ErrNotFound     = "not_found"
 	ErrConflict     = "conflict"
 	ErrInternal     = "internal"
+	ErrSuppressed   = "suppressed"
 )
 
 type Error struct {
@@ -28,7 +29,7 @@ func ErrorCode(err error) string {
 		return ""
 	} else if errors.As(err, &e) && e.Code != "" {
 		return e.Code
-	} else if e.Err != nil {
+	} else if e != nil && e.Err != nil {
 		return ErrorCode(e.Err)
 	}
 
@@ -41,7 +42,7 @@ func ErrorMessage(err error) string {
 		return ""
 	} else if errors.As(err, &e) && e.Message != "" {
 		return e.Message
-	} else if e.Err != nil {
+	} else if e != nil && e.Err != nil {
 		return ErrorMessage(e.Err)
 	}
 
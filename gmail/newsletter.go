@@ -1,32 +1,57 @@
 package gmail
 
 import (
+	"bytes"
 	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
 
-	"github.com/getsentry/sentry-go"
 	"github.com/matcornic/hermes/v2"
 	"github.com/pkg/errors"
 	uuid "github.com/satori/go.uuid"
+	xhtml "golang.org/x/net/html"
 	"gopkg.in/gomail.v2"
 
 	"github.com/quantonganh/mailbus"
+	"github.com/quantonganh/mailbus/dkim"
+	"github.com/quantonganh/mailbus/pkg/hash"
 )
 
 type newsletterService struct {
 	ServerURL string
 	*mailbus.Config
+	ListService        mailbus.ListService
+	SuppressionService mailbus.SuppressionService
+	Signer             *dkim.Signer
+	Keyring            *hash.Keyring
+	Transport          mailbus.Transport
 }
 
-// NewNewsletterService returns new newsletter service
-func NewNewsletterService(config *mailbus.Config, serverURL string) mailbus.NewsletterService {
+// NewNewsletterService returns new newsletter service sending through transport. Messages to suppressed
+// addresses are skipped and, if signer is not nil, every message is DKIM signed. Links are signed with keyring,
+// and opens and clicks are tracked as the lists of listService are set up to.
+func NewNewsletterService(config *mailbus.Config, serverURL string, listService mailbus.ListService, suppressionService mailbus.SuppressionService, signer *dkim.Signer, keyring *hash.Keyring, transport mailbus.Transport) mailbus.NewsletterService {
 	return &newsletterService{
-		Config:    config,
-		ServerURL: serverURL,
+		Config:             config,
+		ServerURL:          serverURL,
+		ListService:        listService,
+		SuppressionService: suppressionService,
+		Signer:             signer,
+		Keyring:            keyring,
+		Transport:          transport,
 	}
 }
 
 // SendConfirmationEmail sends a confirmation email
 func (ns *newsletterService) SendConfirmationEmail(to, url, token string) error {
+	if err := ns.checkSuppressed(to); err != nil {
+		return skipSuppressed(err)
+	}
+
 	h := hermes.Hermes{
 		Product: hermes.Product{
 			Name: ns.Config.Newsletter.Product.Name,
@@ -58,11 +83,15 @@ func (ns *newsletterService) SendConfirmationEmail(to, url, token string) error
 		return errors.Errorf("failed to generate HTML email: %v", err)
 	}
 
-	return ns.sendEmail(to, "Confirm subscription", emailBody)
+	return ns.sendEmail(to, "Confirm subscription", emailBody, nil)
 }
 
 // SendThankYouEmail sends a "thank you" email
 func (ns *newsletterService) SendThankYouEmail(to string) error {
+	if err := ns.checkSuppressed(to); err != nil {
+		return skipSuppressed(err)
+	}
+
 	h := hermes.Hermes{
 		Product: hermes.Product{
 			Name: ns.Config.Newsletter.Product.Name,
@@ -89,37 +118,203 @@ func (ns *newsletterService) SendThankYouEmail(to string) error {
 		return errors.Errorf("failed to generate HTML email: %v", err)
 	}
 
-	return ns.sendEmail(to, "Thank you for subscribing", emailBody)
+	return ns.sendEmail(to, "Thank you for subscribing", emailBody, nil)
 }
 
-// SendNewsletter sends newsletter
-func (ns *newsletterService) SendNewsletter(subscribers []mailbus.Subscriber, subject, body string) {
-	for _, s := range subscribers {
-		if err := ns.sendEmail(s.Email, subject, body); err != nil {
-			sentry.CaptureException(err)
+// SendNewsletter sends a campaign to a subscriber and returns the reply of the mail server
+func (ns *newsletterService) SendNewsletter(c *mailbus.Campaign, to mailbus.Subscriber) (*mailbus.SMTPReply, error) {
+	if err := ns.checkSuppressed(to.Email); err != nil {
+		if mailbus.ErrorCode(err) == mailbus.ErrSuppressed {
+			log.Printf("skipping campaign %d to %s: %s", c.ID, to.Email, mailbus.ErrorMessage(err))
+		}
+		return nil, err
+	}
+
+	var expiresAt time.Time
+	if ttl := ns.Config.Newsletter.HMAC.LinkTTL; ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+	unsubscribeURL, err := mailbus.UnsubscribeURL(ns.ServerURL, ns.Keyring, c.List, to.Email, c.ID, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// RFC 8058 one-click unsubscribe: mailbox providers POST "List-Unsubscribe=One-Click" to the link
+	headers := map[string]string{
+		"List-Id":               ns.listID(c.List),
+		"List-Unsubscribe":      "<" + unsubscribeURL + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+
+	// opens and clicks are tracked as the list is set up to
+	l, err := ns.ListService.FindByName(c.List)
+	if err != nil {
+		return nil, err
+	}
+	body := c.Body
+	if l.TrackClicks {
+		body, err = ns.trackLinks(body, c.ID, to.ID)
+		if err != nil {
+			return nil, err
 		}
 	}
+	if l.TrackOpens {
+		openURL, err := mailbus.OpenURL(ns.ServerURL, ns.Keyring, c.ID, to.ID)
+		if err != nil {
+			return nil, err
+		}
+		body = addPixel(body, openURL)
+	}
+
+	err = ns.sendEmail(to.Email, c.Subject, body, headers)
+	return mailbus.NewSMTPReply(err), err
 }
 
-func (ns *newsletterService) sendEmail(to string, subject, body string) error {
+// trackLinks replaces the web links of an HTML body with signed links redirecting the subscriber through the server.
+// Links to the server itself, such as the unsubscribe one, are left alone.
+func (ns *newsletterService) trackLinks(body string, campaignID, subscriberID int) (string, error) {
+	var (
+		buf bytes.Buffer
+		z   = xhtml.NewTokenizer(strings.NewReader(body))
+	)
+	for {
+		tt := z.Next()
+		if tt == xhtml.ErrorToken {
+			if z.Err() == io.EOF {
+				return buf.String(), nil
+			}
+			return "", errors.Wrap(z.Err(), "failed to parse newsletter body")
+		}
+
+		// Token lowercases the tag name in place, the raw bytes have to be copied first
+		raw := append([]byte(nil), z.Raw()...)
+		if tt != xhtml.StartTagToken && tt != xhtml.SelfClosingTagToken {
+			buf.Write(raw)
+			continue
+		}
+
+		token := z.Token()
+		rewritten := false
+		if token.Data == "a" {
+			for i, attr := range token.Attr {
+				if attr.Key != "href" || !mailbus.Trackable(attr.Val) || ns.ServerURL != "" && strings.HasPrefix(attr.Val, ns.ServerURL) {
+					continue
+				}
+
+				clickURL, err := mailbus.ClickURL(ns.ServerURL, ns.Keyring, campaignID, subscriberID, attr.Val)
+				if err != nil {
+					return "", err
+				}
+				token.Attr[i].Val = clickURL
+				rewritten = true
+			}
+		}
+
+		if rewritten {
+			buf.WriteString(token.String())
+		} else {
+			buf.Write(raw)
+		}
+	}
+}
+
+// addPixel adds a tracking pixel at the end of an HTML body
+func addPixel(body, src string) string {
+	img := fmt.Sprintf(`<img src="%s" width="1" height="1" alt="" style="display:block;border:0;width:1px;height:1px">`,
+		html.EscapeString(src))
+
+	if i := strings.LastIndex(strings.ToLower(body), "</body>"); i >= 0 {
+		return body[:i] + img + body[i:]
+	}
+	return body + img
+}
+
+// checkSuppressed returns a suppressed error if an address is on the suppression list
+func (ns *newsletterService) checkSuppressed(to string) error {
+	if ns.SuppressionService == nil {
+		return nil
+	}
+
+	s, err := ns.SuppressionService.Match(to)
+	if err != nil {
+		return err
+	}
+	if s != nil {
+		return mailbus.SuppressedError(to, s)
+	}
+
+	return nil
+}
+
+// skipSuppressed logs why a transactional email is not sent to a suppressed address,
+// without telling the caller that the address is suppressed
+func skipSuppressed(err error) error {
+	if mailbus.ErrorCode(err) != mailbus.ErrSuppressed {
+		return err
+	}
+
+	log.Printf("skipping email: %s", mailbus.ErrorMessage(err))
+	return nil
+}
+
+// listID returns the RFC 2919 List-Id of a list, scoped to the sender domain
+func (ns *newsletterService) listID(list string) string {
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(ns.Config.Newsletter.From); err == nil {
+		domain = domainOf(addr.Address)
+	}
+	return fmt.Sprintf("<%s.%s>", list, domain)
+}
+
+func domainOf(address string) string {
+	if i := strings.LastIndex(address, "@"); i >= 0 {
+		return address[i+1:]
+	}
+	return "localhost"
+}
+
+func (ns *newsletterService) sendEmail(to string, subject, body string, headers map[string]string) error {
+	from, err := mail.ParseAddress(ns.Config.Newsletter.From)
+	if err != nil {
+		return errors.Errorf("invalid from address %q: %v", ns.Config.Newsletter.From, err)
+	}
+
 	m := gomail.NewMessage()
 	m.SetHeader("From", ns.Config.Newsletter.From)
 	m.SetHeader("To", to)
 	m.SetHeader("Subject", subject)
+	m.SetHeader("Message-ID", fmt.Sprintf("<%s@%s>", ns.GenerateNewUUID(), domainOf(from.Address)))
+	for k, v := range headers {
+		m.SetHeader(k, v)
+	}
 	m.SetBody("text/html", body)
-	d := gomail.NewDialer(ns.Config.SMTP.Host, ns.Config.SMTP.Port, ns.Config.SMTP.Username, ns.Config.SMTP.Password)
-	if err := d.DialAndSend(m); err != nil {
-		return errors.Errorf("failed to send mail to %s: %v", fmt.Sprintf("%+v\n", to), err)
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		return errors.Wrap(err, "failed to write message")
+	}
+	raw := buf.Bytes()
+	if ns.Signer != nil {
+		if raw, err = ns.Signer.Sign(raw); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return ns.Transport.Send(&mailbus.Message{
+		From:    from.Address,
+		To:      []string{to},
+		Subject: subject,
+		HTML:    body,
+		Headers: headers,
+		Raw:     raw,
+	})
 }
 
 func (ns *newsletterService) GenerateNewUUID() string {
 	return uuid.NewV4().String()
 }
 
-// GetHMACSecret gets HMAC secret from config
-func (ns *newsletterService) GetHMACSecret() string {
-	return ns.Config.Newsletter.HMAC.Secret
+// GetKeyring returns the keyring links are signed with
+func (ns *newsletterService) GetKeyring() *hash.Keyring {
+	return ns.Keyring
 }
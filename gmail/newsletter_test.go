@@ -0,0 +1,340 @@
+package gmail
+
+import (
+	"bufio"
+	"html"
+	"io"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	testifymock "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/mailbus"
+	"github.com/quantonganh/mailbus/dkim"
+	"github.com/quantonganh/mailbus/mock"
+	"github.com/quantonganh/mailbus/pkg/hash"
+	"github.com/quantonganh/mailbus/transport"
+)
+
+// fakeSMTPServer is a minimal SMTP server that answers RCPT TO with a fixed reply
+type fakeSMTPServer struct {
+	ln        net.Listener
+	rcptReply string
+	received  chan string
+}
+
+func newFakeSMTPServer(t *testing.T, rcptReply string) *fakeSMTPServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &fakeSMTPServer{
+		ln:        ln,
+		rcptReply: rcptReply,
+		received:  make(chan string, 10),
+	}
+	go s.serve()
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+
+	return s
+}
+
+func (s *fakeSMTPServer) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTPServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		_, _ = conn.Write([]byte(line + "\r\n"))
+	}
+
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 fake")
+		case strings.HasPrefix(cmd, "RCPT"):
+			reply(s.rcptReply)
+		case strings.HasPrefix(cmd, "DATA"):
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.received <- data.String()
+			reply("250 2.0.0 queued")
+		case strings.HasPrefix(cmd, "QUIT"):
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func newTestNewsletterService(port int) *newsletterService {
+	config := &mailbus.Config{}
+	config.SMTP.Host = "127.0.0.1"
+	config.SMTP.Port = port
+	config.Newsletter.From = "Mailbus <newsletter@example.com>"
+
+	keyring, err := hash.NewKeyring(hash.Key{ID: "1", Secret: strings.Repeat("s", hash.MinSecretLength)})
+	if err != nil {
+		panic(err)
+	}
+
+	// the lists track nothing unless a test says otherwise
+	listService := new(mock.ListService)
+	listService.On("FindByName", testifymock.Anything).Return(func(name string) *mailbus.List {
+		return &mailbus.List{Name: name}
+	}, nil)
+
+	return &newsletterService{
+		Config:      config,
+		ServerURL:   "http://localhost",
+		ListService: listService,
+		Keyring:     keyring,
+		Transport:   transport.NewSMTPTransport(config.SMTP.Host, config.SMTP.Port, "", "", 1),
+	}
+}
+
+func TestSendNewsletterRetry(t *testing.T) {
+	policy := mailbus.RetryPolicy{
+		MaxAttempts:     3,
+		InitialInterval: time.Minute,
+		MaxInterval:     time.Hour,
+	}
+	campaign := &mailbus.Campaign{ID: 1, List: mailbus.DefaultList, Subject: "Issue #1", Body: "<p>Hello</p>"}
+	subscriber := mailbus.Subscriber{ID: 1, Email: "alice@example.com", List: mailbus.DefaultList}
+
+	tests := []struct {
+		name       string
+		rcptReply  string
+		attempts   int
+		wantStatus string
+		wantCode   int
+		bounced    bool
+	}{
+		{"accepted", "250 2.1.5 OK", 0, mailbus.DeliveryStatusSent, 250, false},
+		{"transient failure is deferred", "451 4.7.1 try again later", 0, mailbus.DeliveryStatusDeferred, 451, false},
+		{"transient failure gives up after max attempts", "421 4.4.2 too busy", 2, mailbus.DeliveryStatusFailed, 421, true},
+		{"permanent failure fails right away", "550 5.1.1 user unknown", 0, mailbus.DeliveryStatusFailed, 550, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeSMTPServer(t, tt.rcptReply)
+			ns := newTestNewsletterService(server.port())
+
+			delivery := mailbus.NewDelivery(campaign.ID, subscriber)
+			delivery.Attempts = tt.attempts
+
+			reply, err := ns.SendNewsletter(campaign, subscriber)
+			delivery.Record(reply, err, policy)
+
+			assert.Equal(t, tt.wantStatus, delivery.Status)
+			assert.Equal(t, tt.wantCode, delivery.SMTPCode)
+			assert.Equal(t, tt.bounced, delivery.Bounce() != nil)
+			if tt.wantStatus == mailbus.DeliveryStatusDeferred {
+				assert.True(t, delivery.NextAttemptAt.After(time.Now().Add(policy.InitialInterval/2-time.Second)))
+			}
+		})
+	}
+}
+
+func TestSendNewsletterNetworkError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := ln.Addr().(*net.TCPAddr).Port
+	require.NoError(t, ln.Close())
+
+	ns := newTestNewsletterService(port)
+	reply, err := ns.SendNewsletter(&mailbus.Campaign{List: mailbus.DefaultList}, mailbus.Subscriber{Email: "alice@example.com"})
+	require.Error(t, err)
+	assert.True(t, reply.Temporary())
+}
+
+func TestSendNewsletterSuppressed(t *testing.T) {
+	server := newFakeSMTPServer(t, "250 2.1.5 OK")
+	suppressionService := mock.NewSuppressionService(t)
+	suppressionService.On("Match", "alice@example.com").
+		Return(&mailbus.Suppression{Value: "example.com", Source: mailbus.SuppressionSourceManual}, nil)
+	ns := newTestNewsletterService(server.port())
+	ns.SuppressionService = suppressionService
+
+	campaign := &mailbus.Campaign{ID: 1, List: mailbus.DefaultList}
+	subscriber := mailbus.Subscriber{ID: 1, Email: "alice@example.com"}
+	delivery := mailbus.NewDelivery(campaign.ID, subscriber)
+	reply, err := ns.SendNewsletter(campaign, subscriber)
+	delivery.Record(reply, err, mailbus.RetryPolicy{MaxAttempts: 3})
+
+	assert.Equal(t, mailbus.DeliveryStatusSuppressed, delivery.Status)
+	assert.Contains(t, delivery.SMTPMessage, "example.com")
+	assert.Nil(t, delivery.Bounce())
+
+	require.NoError(t, ns.SendThankYouEmail("alice@example.com"))
+	select {
+	case msg := <-server.received:
+		t.Fatalf("expected no message, got %q", msg)
+	default:
+	}
+}
+
+func TestSendNewsletterListUnsubscribe(t *testing.T) {
+	server := newFakeSMTPServer(t, "250 2.1.5 OK")
+	ns := newTestNewsletterService(server.port())
+
+	_, err := ns.SendNewsletter(&mailbus.Campaign{ID: 1, List: "go", Subject: "Issue #1"}, mailbus.Subscriber{Email: "alice@example.com"})
+	require.NoError(t, err)
+
+	msg, err := mail.ReadMessage(strings.NewReader(<-server.received))
+	require.NoError(t, err)
+	assert.Equal(t, "List-Unsubscribe=One-Click", msg.Header.Get("List-Unsubscribe-Post"))
+
+	link := strings.Trim(msg.Header.Get("List-Unsubscribe"), "<>")
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+	assert.Equal(t, "/unsubscribe", u.Path)
+	query := u.Query()
+	assert.Equal(t, "go", query.Get("list"))
+	assert.Equal(t, "1", query.Get("campaign"))
+	assert.Equal(t, "1", query.Get("kid"))
+	assert.Empty(t, query.Get("expires"))
+	sig, err := mailbus.ParseLinkSignature(query)
+	require.NoError(t, err)
+	assert.True(t, mailbus.VerifyUnsubscribeSignature(ns.Keyring, "go", "alice@example.com", 1, sig))
+	assert.False(t, mailbus.VerifyUnsubscribeSignature(ns.Keyring, "python", "alice@example.com", 1, sig))
+	assert.False(t, mailbus.VerifyUnsubscribeSignature(ns.Keyring, "go", "alice@example.com", 2, sig))
+
+	// with a TTL, the expiry is signed along with the link
+	ns.Config.Newsletter.HMAC.LinkTTL = time.Hour
+	_, err = ns.SendNewsletter(&mailbus.Campaign{ID: 1, List: "go", Subject: "Issue #1"}, mailbus.Subscriber{Email: "alice@example.com"})
+	require.NoError(t, err)
+	msg, err = mail.ReadMessage(strings.NewReader(<-server.received))
+	require.NoError(t, err)
+	u, err = url.Parse(strings.Trim(msg.Header.Get("List-Unsubscribe"), "<>"))
+	require.NoError(t, err)
+	sig, err = mailbus.ParseLinkSignature(u.Query())
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), sig.ExpiresAt, time.Minute)
+	assert.True(t, mailbus.VerifyUnsubscribeSignature(ns.Keyring, "go", "alice@example.com", 1, sig))
+	sig.ExpiresAt = sig.ExpiresAt.Add(time.Hour)
+	assert.False(t, mailbus.VerifyUnsubscribeSignature(ns.Keyring, "go", "alice@example.com", 1, sig))
+}
+
+func TestSendNewsletterOpenTracking(t *testing.T) {
+	server := newFakeSMTPServer(t, "250 2.1.5 OK")
+	ns := newTestNewsletterService(server.port())
+	listService := new(mock.ListService)
+	listService.On("FindByName", "go").Return(&mailbus.List{Name: "go", TrackOpens: true}, nil)
+	listService.On("FindByName", "python").Return(&mailbus.List{Name: "python"}, nil)
+	ns.ListService = listService
+
+	body := func(list string) string {
+		c := &mailbus.Campaign{ID: 1, List: list, Subject: "Issue #1", Body: "<html><body><p>Hello</p></body></html>"}
+		_, err := ns.SendNewsletter(c, mailbus.Subscriber{ID: 2, Email: "alice@example.com"})
+		require.NoError(t, err)
+
+		msg, err := mail.ReadMessage(strings.NewReader(<-server.received))
+		require.NoError(t, err)
+		data, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	tracked := body("go")
+	start := strings.Index(tracked, `<img src="`)
+	require.True(t, start >= 0, tracked)
+	assert.Contains(t, tracked, `px"></body></html>`, "the pixel goes at the end of the body")
+
+	src := html.UnescapeString(tracked[start+len(`<img src="`) : strings.Index(tracked[start:], `" width`)+start])
+	u, err := url.Parse(src)
+	require.NoError(t, err)
+	assert.Equal(t, "/tracking/open", u.Path)
+	assert.Equal(t, "1", u.Query().Get("campaign"))
+	assert.Equal(t, "2", u.Query().Get("subscriber"))
+	sig, err := mailbus.ParseLinkSignature(u.Query())
+	require.NoError(t, err)
+	assert.True(t, mailbus.VerifyTrackingSignature(ns.Keyring, mailbus.TrackingEventOpen, 1, 2, "", sig))
+
+	// lists that do not track opens get the body as it is
+	assert.NotContains(t, body("python"), "<img")
+}
+
+func TestTrackLinks(t *testing.T) {
+	ns := newTestNewsletterService(0)
+	ns.ServerURL = "https://mailbus.example.com"
+
+	body := `<html><body><p>Read <A class="post" HREF="https://example.com/posts?id=1&amp;ref=mail">the post</A>,` +
+		` <a href="mailto:alice@example.com">reply</a>, <a href="#top">go up</a>` +
+		` or <a href="https://mailbus.example.com/unsubscribe">unsubscribe</a>.</p></body></html>`
+	tracked, err := ns.trackLinks(body, 1, 2)
+	require.NoError(t, err)
+
+	start := strings.Index(tracked, `href="`) + len(`href="`)
+	u, err := url.Parse(html.UnescapeString(tracked[start : strings.Index(tracked[start:], `"`)+start]))
+	require.NoError(t, err)
+	assert.Equal(t, "mailbus.example.com", u.Host)
+	assert.Equal(t, "/tracking/click", u.Path)
+	assert.Equal(t, "https://example.com/posts?id=1&ref=mail", u.Query().Get("url"))
+	sig, err := mailbus.ParseLinkSignature(u.Query())
+	require.NoError(t, err)
+	assert.True(t, mailbus.VerifyTrackingSignature(ns.Keyring, mailbus.TrackingEventClick, 1, 2, u.Query().Get("url"), sig))
+
+	// the rest of the body is left as it was
+	assert.Contains(t, tracked, `class="post"`)
+	assert.Contains(t, tracked, `<a href="mailto:alice@example.com">reply</a>, <a href="#top">go up</a>`)
+	assert.Contains(t, tracked, `<a href="https://mailbus.example.com/unsubscribe">unsubscribe</a>.</p></body></html>`)
+}
+
+func TestSendEmailDKIM(t *testing.T) {
+	server := newFakeSMTPServer(t, "250 2.1.5 OK")
+	ns := newTestNewsletterService(server.port())
+
+	key, err := dkim.GenerateKey(dkim.AlgorithmEd25519, 0)
+	require.NoError(t, err)
+	ns.Signer = &dkim.Signer{Domain: "example.com", Selector: "mailbus", Key: key, Headers: dkim.DefaultHeaders}
+
+	require.NoError(t, ns.SendThankYouEmail("alice@example.com"))
+
+	msg, err := mail.ReadMessage(strings.NewReader(<-server.received))
+	require.NoError(t, err)
+	signature := msg.Header.Get("DKIM-Signature")
+	assert.Contains(t, signature, "a=ed25519-sha256;")
+	assert.Contains(t, signature, "d=example.com; s=mailbus;")
+	assert.Contains(t, signature, "h=from:to:subject:date:message-id:mime-version:content-type")
+	assert.NotEmpty(t, msg.Header.Get("Message-ID"))
+}
@@ -0,0 +1,389 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/quantonganh/mailbus"
+)
+
+const (
+	sessionCookieName = "mailbus_session"
+	flashCookieName   = "mailbus_flash"
+
+	// defaultSessionTTL is how long admins stay logged in when the server does not say
+	defaultSessionTTL = 12 * time.Hour
+
+	// adminPageSize is how many rows the tables of the admin UI show at once
+	adminPageSize = 50
+)
+
+// adminContentSecurityPolicy lets the admin UI load its own scripts only. Images and inline styles are allowed
+// because the preview of a campaign shows its body, which is rendered in a sandboxed frame that runs no script.
+const adminContentSecurityPolicy = "default-src 'self'; img-src * data:; style-src 'self' 'unsafe-inline'; " +
+	"script-src 'self'; frame-src 'self'; frame-ancestors 'none'; form-action 'self'; base-uri 'none'"
+
+// dummyPasswordHash is compared with the passwords of unknown usernames,
+// so that logging in takes as long whether or not the username exists
+var dummyPasswordHash = func() string {
+	a, err := mailbus.NewAdmin("dummy", "dummy password", mailbus.RoleViewer)
+	if err != nil {
+		panic(err)
+	}
+	return a.PasswordHash
+}()
+
+//go:embed static
+var staticFS embed.FS
+
+var adminFuncs = template.FuncMap{
+	"datetime": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.UTC().Format("2006-01-02 15:04 UTC")
+	},
+	// datetimeLocal formats a time as the value of a datetime-local input, in UTC
+	"datetimeLocal": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format("2006-01-02T15:04")
+	},
+	"percent": func(f float64) string {
+		return fmt.Sprintf("%.1f%%", f*100)
+	},
+}
+
+// adminPages are the pages of the admin UI, each one rendered inside the admin layout
+var adminPages = map[string]*template.Template{
+	"login":       parseAdminPage("login.html"),
+	"error":       parseAdminPage("error.html"),
+	"subscribers": parseAdminPage("subscribers.html"),
+	"lists":       parseAdminPage("lists.html"),
+	"list":        parseAdminPage("list.html"),
+	"campaigns":   parseAdminPage("campaigns.html"),
+	"campaign":    parseAdminPage("campaign.html"),
+	"report":      parseAdminPage("report.html"),
+	"account":     parseAdminPage("account.html"),
+	"admins":      parseAdminPage("admins.html"),
+	"audit":       parseAdminPage("audit.html"),
+}
+
+func parseAdminPage(name string) *template.Template {
+	return template.Must(template.New("layout.html").Funcs(adminFuncs).
+		ParseFS(templateFS, "templates/admin/layout.html", "templates/admin/"+name))
+}
+
+// adminPage holds the data of a page of the admin UI
+type adminPage struct {
+	Title     string
+	Section   string
+	Admin     *mailbus.Admin
+	CSRFToken string
+	Notice    string
+	Error     string
+	// Data holds what the page itself shows
+	Data interface{}
+}
+
+// Can reports whether the role of the admin viewing the page grants a scope, to hide what they cannot do
+func (p adminPage) Can(scope string) bool {
+	return p.Admin != nil && p.Admin.Allows(scope)
+}
+
+// adminStatic serves the stylesheet and the script of the admin UI
+func adminStatic() http.Handler {
+	static, err := fs.Sub(staticFS, "static")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix("/admin/static/", http.FileServer(http.FS(static)))
+}
+
+// adminHandler wraps a handler of the admin UI: errors are rendered as pages of the admin UI rather than as JSON
+func (s *Server) adminHandler(fn appHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := fn(w, r)
+		if err == nil {
+			return
+		}
+
+		hlog.FromRequest(r).Error().Msg(err.Error())
+
+		status, message := http.StatusInternalServerError, "Something went wrong, please try again."
+		if e, ok := FromError(err).(*Error); ok {
+			status, message = e.Status, e.Message
+		}
+		if err := s.renderAdmin(w, r, status, "error", adminPage{Title: http.StatusText(status), Error: message}); err != nil {
+			hlog.FromRequest(r).Error().Err(err).Msg("failed to render error page")
+		}
+	}
+}
+
+// requireAdmin lets the requests of the admin UI through if they come from a logged in admin,
+// and if their forms were submitted from the admin UI. Others are sent to the login page.
+func (s *Server) requireAdmin(next http.Handler) http.Handler {
+	return s.adminHandler(func(w http.ResponseWriter, r *http.Request) error {
+		a, err := s.findAdmin(r)
+		if err != nil {
+			if mailbus.ErrorCode(err) != mailbus.ErrUnauthorized {
+				return err
+			}
+
+			target := "/admin/login"
+			if r.Method == http.MethodGet {
+				target += "?next=" + url.QueryEscape(r.URL.RequestURI())
+			}
+			http.Redirect(w, r, target, http.StatusSeeOther)
+			return nil
+		}
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			if err := verifyCSRF(r); err != nil {
+				return err
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), adminContextKey, a)))
+		return nil
+	})
+}
+
+// can wraps a handler of the admin UI so that only the admins whose role grants a scope can use it
+func (s *Server) can(scope string, fn appHandler) appHandler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if !allowed(r, scope) {
+			return NewError(nil, http.StatusForbidden, "Your role does not allow this.")
+		}
+		return fn(w, r)
+	}
+}
+
+// ownerOnly wraps a handler of the admin UI so that only owners can use it
+func (s *Server) ownerOnly(fn appHandler) appHandler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if a, ok := r.Context().Value(adminContextKey).(*mailbus.Admin); !ok || !a.IsOwner() {
+			return NewError(nil, http.StatusForbidden, "Only owners can manage admins.")
+		}
+		return fn(w, r)
+	}
+}
+
+// findAdmin returns the admin logged in with the session cookie of a request
+func (s *Server) findAdmin(r *http.Request) (*mailbus.Admin, error) {
+	const op = "Server.findAdmin"
+
+	c, err := r.Cookie(sessionCookieName)
+	if err != nil || c.Value == "" {
+		return nil, &mailbus.Error{Code: mailbus.ErrUnauthorized, Op: op}
+	}
+
+	session, err := s.SessionService.FindByID(mailbus.SessionID(c.Value))
+	if err != nil {
+		if mailbus.ErrorCode(err) == mailbus.ErrNotFound {
+			return nil, &mailbus.Error{Code: mailbus.ErrUnauthorized, Op: op}
+		}
+		return nil, err
+	}
+	if session.Expired(time.Now()) {
+		return nil, &mailbus.Error{Code: mailbus.ErrUnauthorized, Op: op}
+	}
+
+	a, err := s.AdminService.FindByID(session.AdminID)
+	if err != nil {
+		if mailbus.ErrorCode(err) == mailbus.ErrNotFound {
+			return nil, &mailbus.Error{Code: mailbus.ErrUnauthorized, Op: op}
+		}
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (s *Server) loginPageHandler(w http.ResponseWriter, r *http.Request) error {
+	return s.renderAdmin(w, r, http.StatusOK, "login", adminPage{
+		Title: "Log in",
+		Data:  loginForm{Next: r.URL.Query().Get("next")},
+	})
+}
+
+// loginForm holds the fields of the login form
+type loginForm struct {
+	Username string
+	Next     string
+}
+
+// loginHandler opens a session for the admin whose username and password were submitted,
+// along with the code of their authenticator app if they enabled two-factor authentication
+func (s *Server) loginHandler(w http.ResponseWriter, r *http.Request) error {
+	if err := verifyCSRF(r); err != nil {
+		return err
+	}
+
+	form := loginForm{
+		Username: strings.TrimSpace(r.PostFormValue("username")),
+		Next:     r.PostFormValue("next"),
+	}
+	password := r.PostFormValue("password")
+
+	a, err := s.AdminService.FindByUsername(form.Username)
+	if err != nil && mailbus.ErrorCode(err) != mailbus.ErrNotFound {
+		return err
+	}
+	if a == nil {
+		(&mailbus.Admin{PasswordHash: dummyPasswordHash}).CheckPassword(password)
+	}
+	// which of the three was wrong is not told, so that passwords cannot be guessed without the device
+	if a == nil || !a.CheckPassword(password) || (a.TwoFactor() && !a.CheckCode(r.PostFormValue("code"), time.Now())) {
+		hlog.FromRequest(r).Info().Str("username", form.Username).Msg("failed login")
+		s.audit(r, mailbus.NewAuditEvent("", mailbus.AuditActionAdminLoginFailed, form.Username, ""))
+		return s.renderAdmin(w, r, http.StatusUnauthorized, "login", adminPage{
+			Title: "Log in",
+			Error: "Invalid username, password or code.",
+			Data:  form,
+		})
+	}
+	if a.TwoFactor() {
+		// the code cannot be used again
+		if err := s.AdminService.Update(a); err != nil {
+			return err
+		}
+	}
+	s.audit(r, mailbus.NewAuditEvent("admin:"+a.Username, mailbus.AuditActionAdminLogin, a.Username, ""))
+
+	// a good time to forget the sessions nobody will use anymore
+	if err := s.SessionService.DeleteExpired(time.Now()); err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("failed to delete expired sessions")
+	}
+
+	ttl := s.SessionTTL
+	if ttl <= 0 {
+		ttl = defaultSessionTTL
+	}
+	session, token, err := mailbus.NewSession(a.ID, ttl)
+	if err != nil {
+		return err
+	}
+	if err := s.SessionService.Create(session); err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/admin",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   s.UseTLS(),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, safeNext(form.Next), http.StatusSeeOther)
+	return nil
+}
+
+// logoutHandler closes the session of the admin
+func (s *Server) logoutHandler(w http.ResponseWriter, r *http.Request) error {
+	if c, err := r.Cookie(sessionCookieName); err == nil {
+		if err := s.SessionService.Delete(mailbus.SessionID(c.Value)); err != nil {
+			return err
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Path:     "/admin",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   s.UseTLS(),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+	return nil
+}
+
+// safeNext returns where to go after logging in: a page of the admin UI, never another site
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/admin/") || strings.HasPrefix(next, "//") || strings.Contains(next, `\`) {
+		return "/admin/subscribers"
+	}
+	return next
+}
+
+// renderAdmin writes a page of the admin UI
+func (s *Server) renderAdmin(w http.ResponseWriter, r *http.Request, status int, name string, data adminPage) error {
+	var err error
+	if data.CSRFToken, err = s.csrfToken(w, r); err != nil {
+		return err
+	}
+	if a, ok := r.Context().Value(adminContextKey).(*mailbus.Admin); ok {
+		data.Admin = a
+	}
+	if data.Notice == "" {
+		data.Notice = popFlash(w, r)
+	}
+
+	var buf bytes.Buffer
+	if err := adminPages[name].ExecuteTemplate(&buf, "layout.html", data); err != nil {
+		return err
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("Cache-Control", "no-store")
+	h.Set("Content-Security-Policy", adminContentSecurityPolicy)
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Referrer-Policy", "same-origin")
+	w.WriteHeader(status)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// redirectAdmin sends the admin to another page of the admin UI, which shows notice once
+func redirectAdmin(w http.ResponseWriter, r *http.Request, target, notice string) {
+	if notice != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     flashCookieName,
+			Value:    base64.RawURLEncoding.EncodeToString([]byte(notice)),
+			Path:     "/admin",
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// popFlash returns the notice left by redirectAdmin, and forgets it
+func popFlash(w http.ResponseWriter, r *http.Request) string {
+	c, err := r.Cookie(flashCookieName)
+	if err != nil {
+		return ""
+	}
+	http.SetCookie(w, &http.Cookie{Name: flashCookieName, Path: "/admin", MaxAge: -1})
+
+	notice, err := base64.RawURLEncoding.DecodeString(c.Value)
+	if err != nil {
+		return ""
+	}
+	return string(notice)
+}
+
+// pageNumber returns the page of a table of the admin UI asked for, starting at 1
+func pageNumber(r *http.Request) int {
+	var n int
+	if _, err := fmt.Sscan(r.URL.Query().Get("page"), &n); err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
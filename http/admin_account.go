@@ -0,0 +1,109 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/quantonganh/mailbus"
+	"github.com/quantonganh/mailbus/pkg/totp"
+)
+
+// accountPage holds the data of the page where admins change their password and two-factor authentication
+type accountPage struct {
+	// Secret is offered to the admin who has not enabled two-factor authentication, URL imports it
+	Secret string
+	URL    string
+}
+
+// adminAccountHandler shows the forms changing the password and the two-factor authentication of the admin
+func (s *Server) adminAccountHandler(w http.ResponseWriter, r *http.Request) error {
+	return s.renderAccount(w, r, http.StatusOK, "", "")
+}
+
+// adminPasswordHandler changes the password of the admin, who types their current one again
+func (s *Server) adminPasswordHandler(w http.ResponseWriter, r *http.Request) error {
+	a := r.Context().Value(adminContextKey).(*mailbus.Admin)
+
+	if !a.CheckPassword(r.PostFormValue("current")) {
+		return s.renderAccount(w, r, http.StatusBadRequest, "", "Current password is wrong.")
+	}
+	password := r.PostFormValue("password")
+	if password != r.PostFormValue("confirm") {
+		return s.renderAccount(w, r, http.StatusBadRequest, "", "New passwords do not match.")
+	}
+	if err := a.SetPassword(password); err != nil {
+		return s.renderAccount(w, r, http.StatusBadRequest, "", mailbus.ErrorMessage(err))
+	}
+
+	if err := s.AdminService.Update(a); err != nil {
+		return err
+	}
+	s.audit(r, mailbus.NewAuditEvent(actor(r), mailbus.AuditActionAdminPassword, a.Username, ""))
+
+	redirectAdmin(w, r, "/admin/account", "Your password was changed.")
+	return nil
+}
+
+// adminEnableTwoFactorHandler turns two-factor authentication on with the secret offered by the account page,
+// once the code typed shows that the authenticator app of the admin has it
+func (s *Server) adminEnableTwoFactorHandler(w http.ResponseWriter, r *http.Request) error {
+	a := r.Context().Value(adminContextKey).(*mailbus.Admin)
+	if a.TwoFactor() {
+		return NewError(nil, http.StatusConflict, "Two-factor authentication is already enabled.")
+	}
+
+	secret := r.PostFormValue("secret")
+	if err := a.EnableTwoFactor(secret, r.PostFormValue("code"), time.Now()); err != nil {
+		return s.renderAccount(w, r, http.StatusBadRequest, secret, mailbus.ErrorMessage(err))
+	}
+
+	if err := s.AdminService.Update(a); err != nil {
+		return err
+	}
+	s.audit(r, mailbus.NewAuditEvent(actor(r), mailbus.AuditActionTwoFactorEnable, a.Username, ""))
+
+	redirectAdmin(w, r, "/admin/account", "Two-factor authentication is enabled, you will be asked for a code when you log in.")
+	return nil
+}
+
+// adminDisableTwoFactorHandler turns two-factor authentication off, the admin types their password again
+func (s *Server) adminDisableTwoFactorHandler(w http.ResponseWriter, r *http.Request) error {
+	a := r.Context().Value(adminContextKey).(*mailbus.Admin)
+
+	if !a.CheckPassword(r.PostFormValue("current")) {
+		return s.renderAccount(w, r, http.StatusBadRequest, "", "Current password is wrong.")
+	}
+
+	a.DisableTwoFactor()
+	if err := s.AdminService.Update(a); err != nil {
+		return err
+	}
+	s.audit(r, mailbus.NewAuditEvent(actor(r), mailbus.AuditActionTwoFactorDisable, a.Username, ""))
+
+	redirectAdmin(w, r, "/admin/account", "Two-factor authentication is disabled.")
+	return nil
+}
+
+// renderAccount shows the account page, offering secret to set up two-factor authentication, or a new one
+func (s *Server) renderAccount(w http.ResponseWriter, r *http.Request, status int, secret, message string) error {
+	a := r.Context().Value(adminContextKey).(*mailbus.Admin)
+
+	var data accountPage
+	if !a.TwoFactor() {
+		if secret == "" {
+			var err error
+			if secret, err = totp.NewSecret(); err != nil {
+				return err
+			}
+		}
+		data.Secret = secret
+		data.URL = a.TOTPURL(secret)
+	}
+
+	return s.renderAdmin(w, r, status, "account", adminPage{
+		Title:   "Account",
+		Section: "account",
+		Error:   message,
+		Data:    data,
+	})
+}
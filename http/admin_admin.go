@@ -0,0 +1,145 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/quantonganh/mailbus"
+)
+
+// adminsPage holds the data of the page where owners manage the admins
+type adminsPage struct {
+	Admins []mailbus.Admin
+	Roles  []string
+}
+
+// adminAdminsHandler shows the admins, along with the form adding one
+func (s *Server) adminAdminsHandler(w http.ResponseWriter, r *http.Request) error {
+	admins, err := s.AdminService.FindAll()
+	if err != nil {
+		return err
+	}
+
+	return s.renderAdmin(w, r, http.StatusOK, "admins", adminPage{
+		Title:   "Admins",
+		Section: "admins",
+		Data: adminsPage{
+			Admins: admins,
+			Roles:  mailbus.Roles,
+		},
+	})
+}
+
+// adminCreateAdminHandler adds an admin with a role, who can log in with the password chosen for them
+func (s *Server) adminCreateAdminHandler(w http.ResponseWriter, r *http.Request) error {
+	a, err := mailbus.NewAdmin(r.PostFormValue("username"), r.PostFormValue("password"), r.PostFormValue("role"))
+	if err != nil {
+		return FromError(err)
+	}
+
+	if err := s.AdminService.Create(a); err != nil {
+		return FromError(err)
+	}
+	s.audit(r, mailbus.NewAuditEvent(actor(r), mailbus.AuditActionAdminCreate, a.Username, "role="+a.Role))
+
+	redirectAdmin(w, r, "/admin/admins", a.Username+" was added as "+a.Role+".")
+	return nil
+}
+
+// adminUpdateAdminHandler changes the role of an admin
+func (s *Server) adminUpdateAdminHandler(w http.ResponseWriter, r *http.Request) error {
+	a, err := s.findAdminByID(r)
+	if err != nil {
+		return err
+	}
+
+	role := r.PostFormValue("role")
+	if role != mailbus.RoleOwner {
+		if err := s.keepOwner(a); err != nil {
+			return err
+		}
+	}
+	if err := a.SetRole(role); err != nil {
+		return FromError(err)
+	}
+
+	if err := s.AdminService.Update(a); err != nil {
+		return err
+	}
+	s.audit(r, mailbus.NewAuditEvent(actor(r), mailbus.AuditActionAdminUpdate, a.Username, "role="+a.Role))
+
+	redirectAdmin(w, r, "/admin/admins", a.Username+" is now "+a.Role+".")
+	return nil
+}
+
+// adminResetTwoFactorHandler turns the two-factor authentication of an admin off, for those who lost their device
+func (s *Server) adminResetTwoFactorHandler(w http.ResponseWriter, r *http.Request) error {
+	a, err := s.findAdminByID(r)
+	if err != nil {
+		return err
+	}
+
+	a.DisableTwoFactor()
+	if err := s.AdminService.Update(a); err != nil {
+		return err
+	}
+	s.audit(r, mailbus.NewAuditEvent(actor(r), mailbus.AuditActionTwoFactorDisable, a.Username, ""))
+
+	redirectAdmin(w, r, "/admin/admins", "Two-factor authentication of "+a.Username+" was reset.")
+	return nil
+}
+
+// adminDeleteAdminHandler removes an admin, logging them out
+func (s *Server) adminDeleteAdminHandler(w http.ResponseWriter, r *http.Request) error {
+	a, err := s.findAdminByID(r)
+	if err != nil {
+		return err
+	}
+	if err := s.keepOwner(a); err != nil {
+		return err
+	}
+
+	if err := s.AdminService.Delete(a.ID); err != nil {
+		return FromError(err)
+	}
+	s.audit(r, mailbus.NewAuditEvent(actor(r), mailbus.AuditActionAdminDelete, a.Username, ""))
+
+	redirectAdmin(w, r, "/admin/admins", a.Username+" was removed.")
+	return nil
+}
+
+// findAdminByID returns the admin of the path
+func (s *Server) findAdminByID(r *http.Request) (*mailbus.Admin, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, NewError(err, http.StatusNotFound, "Admin not found.")
+	}
+
+	a, err := s.AdminService.FindByID(id)
+	if mailbus.ErrorCode(err) == mailbus.ErrNotFound {
+		return nil, NewError(err, http.StatusNotFound, "Admin not found.")
+	}
+
+	return a, err
+}
+
+// keepOwner refuses to demote or remove the last owner, without whom nobody could manage the admins anymore
+func (s *Server) keepOwner(a *mailbus.Admin) error {
+	if !a.IsOwner() {
+		return nil
+	}
+
+	admins, err := s.AdminService.FindAll()
+	if err != nil {
+		return err
+	}
+	for _, other := range admins {
+		if other.ID != a.ID && other.IsOwner() {
+			return nil
+		}
+	}
+
+	return NewError(nil, http.StatusConflict, a.Username+" is the last owner, make someone else owner first.")
+}
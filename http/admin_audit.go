@@ -0,0 +1,43 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/quantonganh/mailbus"
+)
+
+// auditPage holds the data of the audit trail
+type auditPage struct {
+	Events  []mailbus.AuditEvent
+	Actions []string
+	Filter  mailbus.AuditFilter
+	// Since and Until are the dates of the form, as they were typed
+	Since string
+	Until string
+}
+
+// adminAuditHandler shows the latest events of the audit trail, searched by actor, action, target and dates
+func (s *Server) adminAuditHandler(w http.ResponseWriter, r *http.Request) error {
+	filter, err := auditFilter(r)
+	if err != nil {
+		return err
+	}
+
+	events, err := s.AuditService.Find(filter)
+	if err != nil {
+		return err
+	}
+
+	query := r.URL.Query()
+	return s.renderAdmin(w, r, http.StatusOK, "audit", adminPage{
+		Title:   "Audit log",
+		Section: "audit",
+		Data: auditPage{
+			Events:  events,
+			Actions: mailbus.AuditActions,
+			Filter:  filter,
+			Since:   query.Get("since"),
+			Until:   query.Get("until"),
+		},
+	})
+}
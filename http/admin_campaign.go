@@ -0,0 +1,268 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/quantonganh/mailbus"
+)
+
+// campaignStatuses are the statuses campaigns can be filtered by
+var campaignStatuses = []string{
+	mailbus.CampaignStatusDraft,
+	mailbus.CampaignStatusScheduled,
+	mailbus.CampaignStatusSending,
+	mailbus.CampaignStatusSent,
+	mailbus.CampaignStatusFailed,
+	mailbus.CampaignStatusCancelled,
+}
+
+// campaignsPage holds the data of the campaign table
+type campaignsPage struct {
+	Campaigns []mailbus.Campaign
+	Lists     []mailbus.List
+	Statuses  []string
+	Filter    mailbus.CampaignFilter
+}
+
+// campaignPage holds the data of the page composing a campaign, or showing one that was sent
+type campaignPage struct {
+	Campaign *mailbus.Campaign
+	Lists    []mailbus.List
+}
+
+// reportPage holds the data of the report of a campaign
+type reportPage struct {
+	Campaign *mailbus.Campaign
+	Stats    *mailbus.CampaignStats
+	Links    []mailbus.LinkClicks
+	Failed   []mailbus.Delivery
+}
+
+// adminCampaignsHandler shows the campaigns, filtered by list and status
+func (s *Server) adminCampaignsHandler(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+	filter := mailbus.CampaignFilter{
+		List:   query.Get("list"),
+		Status: query.Get("status"),
+	}
+
+	campaigns, err := s.CampaignService.Find(filter)
+	if err != nil {
+		return err
+	}
+	lists, err := s.ListService.FindAll()
+	if err != nil {
+		return err
+	}
+
+	return s.renderAdmin(w, r, http.StatusOK, "campaigns", adminPage{
+		Title:   "Campaigns",
+		Section: "campaigns",
+		Data: campaignsPage{
+			Campaigns: campaigns,
+			Lists:     lists,
+			Statuses:  campaignStatuses,
+			Filter:    filter,
+		},
+	})
+}
+
+// adminNewCampaignHandler shows the form composing a new campaign
+func (s *Server) adminNewCampaignHandler(w http.ResponseWriter, r *http.Request) error {
+	return s.renderCampaign(w, r, http.StatusOK, mailbus.NewCampaign(mailbus.DefaultList, "", ""), "")
+}
+
+// adminCreateCampaignHandler saves a new campaign as a draft
+func (s *Server) adminCreateCampaignHandler(w http.ResponseWriter, r *http.Request) error {
+	c := mailbus.NewCampaign(mailbus.DefaultList, "", "")
+	if message := s.campaignFromForm(r, c); message != "" {
+		return s.renderCampaign(w, r, http.StatusBadRequest, c, message)
+	}
+
+	if err := s.CampaignService.Create(c); err != nil {
+		return FromError(err)
+	}
+
+	redirectAdmin(w, r, fmt.Sprintf("/admin/campaigns/%d", c.ID), "Campaign was saved as a draft.")
+	return nil
+}
+
+// adminCampaignHandler shows the form editing a campaign, or the campaign if it can no longer be edited
+func (s *Server) adminCampaignHandler(w http.ResponseWriter, r *http.Request) error {
+	c, err := s.findCampaign(r)
+	if err != nil {
+		return err
+	}
+
+	return s.renderCampaign(w, r, http.StatusOK, c, "")
+}
+
+// adminUpdateCampaignHandler saves the changes made to a campaign that has not been sent yet
+func (s *Server) adminUpdateCampaignHandler(w http.ResponseWriter, r *http.Request) error {
+	c, err := s.findCampaign(r)
+	if err != nil {
+		return err
+	}
+	if !c.Editable() {
+		return NewError(nil, http.StatusConflict, "Campaign can no longer be edited.")
+	}
+
+	if message := s.campaignFromForm(r, c); message != "" {
+		return s.renderCampaign(w, r, http.StatusBadRequest, c, message)
+	}
+
+	if err := s.CampaignService.Update(c); err != nil {
+		return FromError(err)
+	}
+
+	redirectAdmin(w, r, fmt.Sprintf("/admin/campaigns/%d", c.ID), "Campaign was saved.")
+	return nil
+}
+
+// adminScheduleCampaignHandler schedules a campaign to be sent now, or at the time of the form
+func (s *Server) adminScheduleCampaignHandler(w http.ResponseWriter, r *http.Request) error {
+	c, err := s.findCampaign(r)
+	if err != nil {
+		return err
+	}
+
+	at := time.Now()
+	if r.PostFormValue("when") == "later" {
+		if at, err = parseScheduledAt(r.PostFormValue("scheduled_at")); err != nil {
+			return NewError(err, http.StatusBadRequest, "Invalid date and time.")
+		}
+	}
+
+	if err := c.Schedule(at); err != nil {
+		return FromError(err)
+	}
+	if err := s.CampaignService.Update(c); err != nil {
+		return err
+	}
+	s.auditCampaign(r, mailbus.AuditActionCampaignSchedule, c)
+
+	redirectAdmin(w, r, fmt.Sprintf("/admin/campaigns/%d", c.ID),
+		"Campaign is scheduled to be sent at "+c.ScheduledAt.UTC().Format("2006-01-02 15:04 UTC")+".")
+	return nil
+}
+
+// adminCancelCampaignHandler cancels a campaign that has not been sent yet
+func (s *Server) adminCancelCampaignHandler(w http.ResponseWriter, r *http.Request) error {
+	c, err := s.findCampaign(r)
+	if err != nil {
+		return err
+	}
+
+	if err := c.Cancel(); err != nil {
+		return FromError(err)
+	}
+	if err := s.CampaignService.Update(c); err != nil {
+		return err
+	}
+	s.auditCampaign(r, mailbus.AuditActionCampaignCancel, c)
+
+	redirectAdmin(w, r, fmt.Sprintf("/admin/campaigns/%d", c.ID), "Campaign was cancelled.")
+	return nil
+}
+
+// adminDeleteCampaignHandler deletes a draft or cancelled campaign
+func (s *Server) adminDeleteCampaignHandler(w http.ResponseWriter, r *http.Request) error {
+	c, err := s.findCampaign(r)
+	if err != nil {
+		return err
+	}
+	if c.Status != mailbus.CampaignStatusDraft && c.Status != mailbus.CampaignStatusCancelled {
+		return NewError(nil, http.StatusConflict, "Only draft or cancelled campaigns can be deleted.")
+	}
+
+	if err := s.CampaignService.Delete(c.ID); err != nil {
+		return err
+	}
+	s.auditCampaign(r, mailbus.AuditActionCampaignDelete, c)
+
+	redirectAdmin(w, r, "/admin/campaigns", "Campaign was deleted.")
+	return nil
+}
+
+// adminReportHandler shows the deliveries of a campaign and how subscribers engaged with it
+func (s *Server) adminReportHandler(w http.ResponseWriter, r *http.Request) error {
+	c, err := s.findCampaign(r)
+	if err != nil {
+		return err
+	}
+
+	stats, err := s.ReportService.CampaignStats(c.ID)
+	if err != nil {
+		return err
+	}
+	events, err := s.TrackingService.Find(mailbus.TrackingFilter{CampaignID: c.ID, Type: mailbus.TrackingEventClick})
+	if err != nil {
+		return err
+	}
+	failed, err := s.DeliveryService.Find(mailbus.DeliveryFilter{CampaignID: c.ID, Status: mailbus.DeliveryStatusFailed})
+	if err != nil {
+		return err
+	}
+
+	return s.renderAdmin(w, r, http.StatusOK, "report", adminPage{
+		Title:   "Report: " + c.Subject,
+		Section: "campaigns",
+		Data: reportPage{
+			Campaign: c,
+			Stats:    stats,
+			Links:    mailbus.ClicksByLink(events),
+			Failed:   failed,
+		},
+	})
+}
+
+// campaignFromForm copies the submitted list, subject and body into a campaign,
+// and returns what is wrong with them, if anything
+func (s *Server) campaignFromForm(r *http.Request, c *mailbus.Campaign) string {
+	c.Subject = strings.TrimSpace(r.PostFormValue("subject"))
+	c.Body = r.PostFormValue("body")
+
+	list, err := s.ListService.FindByName(r.PostFormValue("list"))
+	if err != nil {
+		return "Please choose a list."
+	}
+	c.List = list.Name
+
+	if c.Subject == "" {
+		return "Subject is required."
+	}
+	return ""
+}
+
+func (s *Server) renderCampaign(w http.ResponseWriter, r *http.Request, status int, c *mailbus.Campaign, message string) error {
+	lists, err := s.ListService.FindAll()
+	if err != nil {
+		return err
+	}
+
+	title := c.Subject
+	if c.ID == 0 {
+		title = "New campaign"
+	}
+	return s.renderAdmin(w, r, status, "campaign", adminPage{
+		Title:   title,
+		Section: "campaigns",
+		Error:   message,
+		Data: campaignPage{
+			Campaign: c,
+			Lists:    lists,
+		},
+	})
+}
+
+// parseScheduledAt parses the time a campaign is scheduled at: the script of the admin UI converts
+// the local time of the browser to RFC 3339, without it the time of the form is taken as UTC
+func parseScheduledAt(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02T15:04", v, time.UTC)
+}
@@ -0,0 +1,93 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/quantonganh/mailbus"
+)
+
+// adminListsHandler shows the lists, along with the form creating one
+func (s *Server) adminListsHandler(w http.ResponseWriter, r *http.Request) error {
+	lists, err := s.ListService.FindAll()
+	if err != nil {
+		return err
+	}
+
+	return s.renderAdmin(w, r, http.StatusOK, "lists", adminPage{
+		Title:   "Lists",
+		Section: "lists",
+		Data:    lists,
+	})
+}
+
+// adminCreateListHandler creates a list
+func (s *Server) adminCreateListHandler(w http.ResponseWriter, r *http.Request) error {
+	l, err := mailbus.NewList(strings.TrimSpace(r.PostFormValue("name")), r.PostFormValue("title"), r.PostFormValue("description"))
+	if err != nil {
+		return FromError(err)
+	}
+	l.TrackOpens = r.PostFormValue("track_opens") != ""
+	l.TrackClicks = r.PostFormValue("track_clicks") != ""
+
+	if err := s.ListService.Create(l); err != nil {
+		return FromError(err)
+	}
+	s.audit(r, mailbus.NewAuditEvent(actor(r), mailbus.AuditActionListCreate, l.Name, l.Title))
+
+	redirectAdmin(w, r, "/admin/lists", "List "+l.Name+" was created.")
+	return nil
+}
+
+// adminListHandler shows the form editing a list
+func (s *Server) adminListHandler(w http.ResponseWriter, r *http.Request) error {
+	l, err := s.findList(r, "")
+	if err != nil {
+		return err
+	}
+
+	return s.renderAdmin(w, r, http.StatusOK, "list", adminPage{
+		Title:   l.Name,
+		Section: "lists",
+		Data:    l,
+	})
+}
+
+// adminUpdateListHandler edits the title, the description and the tracking settings of a list
+func (s *Server) adminUpdateListHandler(w http.ResponseWriter, r *http.Request) error {
+	l, err := s.findList(r, "")
+	if err != nil {
+		return err
+	}
+
+	l.Title = r.PostFormValue("title")
+	l.Description = r.PostFormValue("description")
+	l.TrackOpens = r.PostFormValue("track_opens") != ""
+	l.TrackClicks = r.PostFormValue("track_clicks") != ""
+	if err := s.ListService.Update(l); err != nil {
+		return FromError(err)
+	}
+	s.audit(r, mailbus.NewAuditEvent(actor(r), mailbus.AuditActionListUpdate, l.Name, l.Title))
+
+	redirectAdmin(w, r, "/admin/lists", "List "+l.Name+" was saved.")
+	return nil
+}
+
+// adminDeleteListHandler deletes a list along with its subscribers, the default list is kept
+func (s *Server) adminDeleteListHandler(w http.ResponseWriter, r *http.Request) error {
+	l, err := s.findList(r, "")
+	if err != nil {
+		return err
+	}
+	if l.Name == mailbus.DefaultList {
+		return NewError(nil, http.StatusConflict, "The default list cannot be deleted.")
+	}
+
+	if err := s.ListService.Delete(l.Name); err != nil {
+		return err
+	}
+	s.audit(r, mailbus.NewAuditEvent(actor(r), mailbus.AuditActionListDelete, l.Name, ""))
+
+	redirectAdmin(w, r, "/admin/lists", "List "+l.Name+" was deleted.")
+	return nil
+}
@@ -0,0 +1,167 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/quantonganh/mailbus"
+)
+
+// subscriberStatuses are the statuses subscribers can be filtered by
+var subscriberStatuses = []string{
+	mailbus.StatusActive,
+	mailbus.StatusPendingConfirmation,
+	mailbus.StatusUnsubscribed,
+	mailbus.StatusBounced,
+}
+
+// subscribersPage holds the data of the subscriber table
+type subscribersPage struct {
+	Subscribers []mailbus.Subscriber
+	Lists       []mailbus.List
+	Statuses    []string
+	Filter      mailbus.SubscriberFilter
+	Page        int
+	PrevURL     string
+	NextURL     string
+	// ExportURL downloads every subscriber found with the same filters
+	ExportURL string
+}
+
+// adminSubscribersHandler shows the subscribers of every list, a page at a time, searched by address, list and status
+func (s *Server) adminSubscribersHandler(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+	n := pageNumber(r)
+	filter := mailbus.SubscriberFilter{
+		List:   query.Get("list"),
+		Status: query.Get("status"),
+		Query:  query.Get("q"),
+		// one more than shown, to know whether there is a next page
+		Limit:  adminPageSize + 1,
+		Offset: (n - 1) * adminPageSize,
+	}
+	if filter.Status != "" && !validStatus(filter.Status) {
+		filter.Status = ""
+	}
+
+	subscribers, err := s.SubscriptionService.Find(filter)
+	if err != nil {
+		return err
+	}
+	lists, err := s.ListService.FindAll()
+	if err != nil {
+		return err
+	}
+
+	data := subscribersPage{
+		Subscribers: subscribers,
+		Lists:       lists,
+		Statuses:    subscriberStatuses,
+		Filter:      filter,
+		Page:        n,
+		ExportURL:   exportURL(r),
+	}
+	if n > 1 {
+		data.PrevURL = pageURL(r, n-1)
+	}
+	if len(subscribers) > adminPageSize {
+		data.Subscribers = subscribers[:adminPageSize]
+		data.NextURL = pageURL(r, n+1)
+	}
+
+	return s.renderAdmin(w, r, http.StatusOK, "subscribers", adminPage{
+		Title:   "Subscribers",
+		Section: "subscribers",
+		Data:    data,
+	})
+}
+
+// adminExportSubscribersHandler downloads the subscribers found with the filters of the subscriber table as CSV
+func (s *Server) adminExportSubscribersHandler(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+	filter := mailbus.SubscriberFilter{
+		List:   query.Get("list"),
+		Status: query.Get("status"),
+		Query:  query.Get("q"),
+	}
+	if filter.Status != "" && !validStatus(filter.Status) {
+		filter.Status = ""
+	}
+
+	return s.exportSubscribers(w, r, filter)
+}
+
+// adminCreateSubscriberHandler adds a subscriber to a list
+func (s *Server) adminCreateSubscriberHandler(w http.ResponseWriter, r *http.Request) error {
+	list, err := s.findList(r, r.PostFormValue("list"))
+	if err != nil {
+		return err
+	}
+
+	subscriber, err := s.addSubscriber(r, list.Name, mailbus.SubscriberRequest{
+		Email:  r.PostFormValue("email"),
+		Status: r.PostFormValue("status"),
+	})
+	if err != nil {
+		return err
+	}
+
+	redirectAdmin(w, r, "/admin/subscribers?q="+url.QueryEscape(subscriber.Email),
+		subscriber.Email+" was added to "+list.Name+".")
+	return nil
+}
+
+// adminUpdateSubscriberHandler changes the status of a subscriber
+func (s *Server) adminUpdateSubscriberHandler(w http.ResponseWriter, r *http.Request) error {
+	subscriber, err := s.findSubscriber(r)
+	if err != nil {
+		return err
+	}
+
+	subscriber, err = s.setSubscriberStatus(r, subscriber, mailbus.SubscriberRequest{Status: r.PostFormValue("status")})
+	if err != nil {
+		return err
+	}
+
+	redirectAdmin(w, r, backTo(r, "/admin/subscribers"),
+		subscriber.Email+" is now "+subscriber.Status+" in "+subscriber.List+".")
+	return nil
+}
+
+// adminDeleteSubscriberHandler removes a subscriber from a list
+func (s *Server) adminDeleteSubscriberHandler(w http.ResponseWriter, r *http.Request) error {
+	subscriber, err := s.findSubscriber(r)
+	if err != nil {
+		return err
+	}
+
+	if err := s.deleteSubscriber(r, subscriber); err != nil {
+		return err
+	}
+
+	redirectAdmin(w, r, backTo(r, "/admin/subscribers"), subscriber.Email+" was removed from "+subscriber.List+".")
+	return nil
+}
+
+// pageURL returns the URL of another page of the table of a request, with the same filters
+func pageURL(r *http.Request, n int) string {
+	query := r.URL.Query()
+	query.Set("page", strconv.Itoa(n))
+	return r.URL.Path + "?" + query.Encode()
+}
+
+// exportURL returns the URL exporting the subscribers found with the filters of a request
+func exportURL(r *http.Request) string {
+	query := r.URL.Query()
+	query.Del("page")
+	return "/admin/subscribers/export?" + query.Encode()
+}
+
+// backTo returns the page of the admin UI a form was submitted from, so that its filters are kept
+func backTo(r *http.Request, fallback string) string {
+	if back := r.PostFormValue("back"); back != "" {
+		return safeNext(back)
+	}
+	return fallback
+}
@@ -0,0 +1,352 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/quantonganh/mailbus"
+)
+
+type contextKey int
+
+const (
+	// apiKeyContextKey is the key of the API key a request of the admin API was authenticated with
+	apiKeyContextKey contextKey = iota
+	// adminContextKey is the key of the admin a request of the admin UI was made by
+	adminContextKey
+)
+
+// defaultSubscribersLimit is how many subscribers are returned when a search does not say
+const defaultSubscribersLimit = 100
+
+// authenticate lets the requests of the admin API through if they carry a valid API key as a bearer token
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return s.Error(func(w http.ResponseWriter, r *http.Request) error {
+		k, err := s.findAPIKey(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="mailbus"`)
+			return err
+		}
+
+		ctx := context.WithValue(r.Context(), apiKeyContextKey, k)
+		if k.AdminID != 0 {
+			// the key can do no more than the admin it was issued to, and nothing once they are removed
+			a, err := s.AdminService.FindByID(k.AdminID)
+			if err != nil {
+				if mailbus.ErrorCode(err) == mailbus.ErrNotFound {
+					w.Header().Set("WWW-Authenticate", `Bearer realm="mailbus"`)
+					return NewError(fmt.Errorf("admin of API key %s was removed", k.Prefix), http.StatusUnauthorized, "Invalid API key.")
+				}
+				return err
+			}
+			ctx = context.WithValue(ctx, adminContextKey, a)
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+		return nil
+	})
+}
+
+// findAPIKey returns the API key of a request. Unknown, revoked and wrong keys are told apart in the logs only.
+func (s *Server) findAPIKey(r *http.Request) (*mailbus.APIKey, error) {
+	key, ok := bearerToken(r)
+	if !ok {
+		return nil, NewError(nil, http.StatusUnauthorized, "API key is required.")
+	}
+
+	prefix, ok := mailbus.ParseAPIKey(key)
+	if !ok {
+		return nil, NewError(nil, http.StatusUnauthorized, "Invalid API key.")
+	}
+
+	k, err := s.APIKeyService.FindByPrefix(prefix)
+	if err != nil {
+		if mailbus.ErrorCode(err) == mailbus.ErrNotFound {
+			return nil, NewError(err, http.StatusUnauthorized, "Invalid API key.")
+		}
+		return nil, err
+	}
+	if !k.Verify(key) {
+		return nil, NewError(fmt.Errorf("wrong secret for API key %s", k.Prefix), http.StatusUnauthorized, "Invalid API key.")
+	}
+	if k.Revoked() {
+		return nil, NewError(fmt.Errorf("API key %s is revoked", k.Prefix), http.StatusUnauthorized, "Invalid API key.")
+	}
+
+	return k, nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// scope wraps a handler of the admin API so that only the keys granted a scope can call it
+func (s *Server) scope(scope string, fn appHandler) http.HandlerFunc {
+	return s.Error(func(w http.ResponseWriter, r *http.Request) error {
+		if !allowed(r, scope) {
+			return NewError(nil, http.StatusForbidden, fmt.Sprintf("API key is not granted the %s scope.", scope))
+		}
+		return fn(w, r)
+	})
+}
+
+// allowed reports whether who made a request may do what a scope grants: the API key must be granted
+// the scope, and the admin, who logged in or whom the key was issued to, must have a role that grants it
+func allowed(r *http.Request, scope string) bool {
+	k, isKey := r.Context().Value(apiKeyContextKey).(*mailbus.APIKey)
+	a, isAdmin := r.Context().Value(adminContextKey).(*mailbus.Admin)
+	switch {
+	case !isKey && !isAdmin:
+		return false
+	case isKey && !k.Allows(scope):
+		return false
+	case isAdmin && !a.Allows(scope):
+		return false
+	}
+	return true
+}
+
+// actor returns who made a request of the admin API or of the admin UI, for the audit log
+func actor(r *http.Request) string {
+	if k, ok := r.Context().Value(apiKeyContextKey).(*mailbus.APIKey); ok {
+		return "api-key:" + k.Prefix
+	}
+	if a, ok := r.Context().Value(adminContextKey).(*mailbus.Admin); ok {
+		return "admin:" + a.Username
+	}
+	return ""
+}
+
+// subscribersHandler searches the subscribers of every list, or of the list of the path
+func (s *Server) subscribersHandler(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+	filter := mailbus.SubscriberFilter{
+		List:   query.Get("list"),
+		Status: query.Get("status"),
+		Query:  query.Get("q"),
+		Limit:  defaultSubscribersLimit,
+	}
+
+	if _, ok := mux.Vars(r)["list"]; ok {
+		list, err := s.findList(r, "")
+		if err != nil {
+			return err
+		}
+		filter.List = list.Name
+	}
+
+	if filter.Status != "" && !validStatus(filter.Status) {
+		return NewError(nil, http.StatusBadRequest, fmt.Sprintf("Unknown status %q.", filter.Status))
+	}
+
+	for name, n := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if v := query.Get(name); v != "" {
+			i, err := strconv.Atoi(v)
+			if err != nil || i < 0 {
+				return NewError(err, http.StatusBadRequest, fmt.Sprintf("Invalid %s.", name))
+			}
+			*n = i
+		}
+	}
+
+	subscribers, err := s.SubscriptionService.Find(filter)
+	if err != nil {
+		return err
+	}
+	if subscribers == nil {
+		subscribers = []mailbus.Subscriber{}
+	}
+
+	return writeJSON(w, http.StatusOK, subscribers)
+}
+
+func (s *Server) subscriberHandler(w http.ResponseWriter, r *http.Request) error {
+	subscriber, err := s.findSubscriber(r)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, subscriber)
+}
+
+// createSubscriberHandler adds a subscriber to a list, active by default. Subscribers added
+// pending confirmation are sent a confirmation email, like the ones who sign up by themselves.
+func (s *Server) createSubscriberHandler(w http.ResponseWriter, r *http.Request) error {
+	var req mailbus.SubscriberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return NewError(err, http.StatusBadRequest, "Invalid JSON body.")
+	}
+
+	list, err := s.findList(r, "")
+	if err != nil {
+		return err
+	}
+
+	subscriber, err := s.addSubscriber(r, list.Name, req)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusCreated, subscriber)
+}
+
+// updateSubscriberHandler changes the status of a subscriber: back to pending confirmation,
+// with a new confirmation email, active or unsubscribed
+func (s *Server) updateSubscriberHandler(w http.ResponseWriter, r *http.Request) error {
+	subscriber, err := s.findSubscriber(r)
+	if err != nil {
+		return err
+	}
+
+	var req mailbus.SubscriberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return NewError(err, http.StatusBadRequest, "Invalid JSON body.")
+	}
+
+	subscriber, err = s.setSubscriberStatus(r, subscriber, req)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, subscriber)
+}
+
+// deleteSubscriberHandler removes a subscriber from a list, as if they had never subscribed
+func (s *Server) deleteSubscriberHandler(w http.ResponseWriter, r *http.Request) error {
+	subscriber, err := s.findSubscriber(r)
+	if err != nil {
+		return err
+	}
+
+	if err := s.deleteSubscriber(r, subscriber); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// addSubscriber adds a subscriber to a list on behalf of the admin API or the admin UI
+func (s *Server) addSubscriber(r *http.Request, list string, req mailbus.SubscriberRequest) (*mailbus.Subscriber, error) {
+	email := strings.TrimSpace(req.Email)
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return nil, NewError(err, http.StatusBadRequest, "Invalid email address.")
+	}
+	email = mailbus.NormalizeEmail(email)
+
+	_, err := s.SubscriptionService.FindByEmail(list, email)
+	if err == nil {
+		return nil, NewError(nil, http.StatusConflict, fmt.Sprintf("%s is already subscribed to %s.", email, list))
+	}
+	if mailbus.ErrorCode(err) != mailbus.ErrNotFound {
+		return nil, err
+	}
+
+	var subscription *mailbus.Subscription
+	switch req.Status {
+	case "", mailbus.StatusActive:
+		subscription = mailbus.NewSubscription(list, email, mailbus.StatusActive, "")
+	case mailbus.StatusPendingConfirmation:
+		if subscription, err = s.newConfirmation(list, email, req.URL); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, NewError(nil, http.StatusBadRequest, fmt.Sprintf("Subscribers can be added %s or %s.",
+			mailbus.StatusActive, mailbus.StatusPendingConfirmation))
+	}
+
+	if err := s.SubscriptionService.Insert(subscription); err != nil {
+		return nil, FromError(err)
+	}
+	s.audit(r, mailbus.NewAuditEvent(actor(r), mailbus.AuditActionSubscriberCreate, email, list))
+
+	return s.SubscriptionService.FindByEmail(list, email)
+}
+
+// setSubscriberStatus changes the status of a subscriber on behalf of the admin API or the admin UI
+func (s *Server) setSubscriberStatus(r *http.Request, subscriber *mailbus.Subscriber, req mailbus.SubscriberRequest) (*mailbus.Subscriber, error) {
+	var err error
+	switch req.Status {
+	case mailbus.StatusActive:
+		_, err = s.SubscriptionService.Activate(subscriber.List, subscriber.Email)
+	case mailbus.StatusUnsubscribed:
+		err = s.SubscriptionService.Unsubscribe(subscriber.List, subscriber.Email)
+	case mailbus.StatusPendingConfirmation:
+		var subscription *mailbus.Subscription
+		if subscription, err = s.newConfirmation(subscriber.List, subscriber.Email, req.URL); err != nil {
+			return nil, err
+		}
+		err = s.SubscriptionService.Update(subscription)
+	default:
+		return nil, NewError(nil, http.StatusBadRequest, fmt.Sprintf("Status can be set to %s, %s or %s.",
+			mailbus.StatusActive, mailbus.StatusUnsubscribed, mailbus.StatusPendingConfirmation))
+	}
+	if err != nil {
+		return nil, FromError(err)
+	}
+	s.audit(r, mailbus.NewAuditEvent(actor(r), mailbus.AuditActionSubscriberUpdate, subscriber.Email,
+		subscriber.List+": "+subscriber.Status+" -> "+req.Status))
+
+	return s.SubscriptionService.FindByEmail(subscriber.List, subscriber.Email)
+}
+
+// deleteSubscriber removes a subscriber from a list on behalf of the admin API or the admin UI
+func (s *Server) deleteSubscriber(r *http.Request, subscriber *mailbus.Subscriber) error {
+	if err := s.SubscriptionService.Delete(subscriber.List, subscriber.Email); err != nil {
+		return FromError(err)
+	}
+	s.audit(r, mailbus.NewAuditEvent(actor(r), mailbus.AuditActionSubscriberDelete, subscriber.Email, subscriber.List))
+
+	return nil
+}
+
+// newConfirmation returns a subscription pending confirmation, along with its confirmation email.
+// The link of the email leads to url, or to the server if it is empty.
+func (s *Server) newConfirmation(list, email, url string) (*mailbus.Subscription, error) {
+	subscription, err := s.confirmer().NewSubscription(list, email)
+	if err != nil {
+		return nil, err
+	}
+
+	if url == "" {
+		url = s.URL()
+	}
+	subscription.Message = mailbus.NewConfirmationMessage(email, url, subscription.Token)
+	return subscription, nil
+}
+
+// findSubscriber returns the subscriber of the path
+func (s *Server) findSubscriber(r *http.Request) (*mailbus.Subscriber, error) {
+	list, err := s.findList(r, "")
+	if err != nil {
+		return nil, err
+	}
+
+	email := mux.Vars(r)["email"]
+	subscriber, err := s.SubscriptionService.FindByEmail(list.Name, email)
+	if mailbus.ErrorCode(err) == mailbus.ErrNotFound {
+		return nil, NewError(err, http.StatusNotFound, "Subscriber not found.")
+	}
+
+	return subscriber, err
+}
+
+func validStatus(status string) bool {
+	switch status {
+	case mailbus.StatusPendingConfirmation, mailbus.StatusActive, mailbus.StatusUnsubscribed, mailbus.StatusBounced:
+		return true
+	}
+	return false
+}
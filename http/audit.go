@@ -0,0 +1,109 @@
+package http
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/quantonganh/mailbus"
+)
+
+// audit records a change in the audit trail along with where the request came from.
+// The change has already been made, so a failure to record it is reported but does not fail the request.
+func (s *Server) audit(r *http.Request, e *mailbus.AuditEvent) {
+	e.IP = remoteIP(r)
+	e.UserAgent = r.UserAgent()
+
+	if err := s.AuditService.Record(e); err != nil {
+		hlog.FromRequest(r).Error().Err(err).Str("action", e.Action).Msg("failed to record audit event")
+		sentry.CaptureException(err)
+	}
+}
+
+// auditCampaign records who scheduled, cancelled or deleted a campaign, which is the target by ID
+func (s *Server) auditCampaign(r *http.Request, action string, c *mailbus.Campaign) {
+	details := fmt.Sprintf("%q to %s", c.Subject, c.List)
+	if action == mailbus.AuditActionCampaignSchedule {
+		details += " at " + c.ScheduledAt.UTC().Format(time.RFC3339)
+	}
+	s.audit(r, mailbus.NewAuditEvent(actor(r), action, strconv.Itoa(c.ID), details))
+}
+
+const (
+	// defaultAuditLimit is how many audit events are returned when a search does not say
+	defaultAuditLimit = 100
+	// maxAuditLimit is the most audit events returned at once
+	maxAuditLimit = 1000
+)
+
+// auditHandler searches the audit trail, newest events first
+func (s *Server) auditHandler(w http.ResponseWriter, r *http.Request) error {
+	filter, err := auditFilter(r)
+	if err != nil {
+		return err
+	}
+
+	events, err := s.AuditService.Find(filter)
+	if err != nil {
+		return err
+	}
+	if events == nil {
+		events = []mailbus.AuditEvent{}
+	}
+
+	return writeJSON(w, http.StatusOK, events)
+}
+
+// auditFilter reads the criteria of a search of the audit trail from the query of a request. Since and until
+// are RFC 3339 times or dates, a date of until includes that day.
+func auditFilter(r *http.Request) (mailbus.AuditFilter, error) {
+	query := r.URL.Query()
+	filter := mailbus.AuditFilter{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		Target: query.Get("target"),
+		Limit:  defaultAuditLimit,
+	}
+
+	for name, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		v := query.Get(name)
+		if v == "" {
+			continue
+		}
+		if parsed, err := time.Parse(time.RFC3339, v); err == nil {
+			*t = parsed
+			continue
+		}
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return filter, NewError(err, http.StatusBadRequest, fmt.Sprintf("Invalid %s, use a date or an RFC 3339 time.", name))
+		}
+		if name == "until" {
+			parsed = parsed.AddDate(0, 0, 1)
+		}
+		*t = parsed
+	}
+
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAuditLimit {
+			return filter, NewError(err, http.StatusBadRequest, fmt.Sprintf("Invalid limit, it must be between 1 and %d.", maxAuditLimit))
+		}
+		filter.Limit = n
+	}
+
+	return filter, nil
+}
+
+// remoteIP returns the address a request came from, without the port
+func remoteIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
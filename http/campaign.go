@@ -0,0 +1,212 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/quantonganh/mailbus"
+)
+
+func (s *Server) campaignsHandler(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+	campaigns, err := s.CampaignService.Find(mailbus.CampaignFilter{
+		List:   query.Get("list"),
+		Status: query.Get("status"),
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, campaigns)
+}
+
+func (s *Server) createCampaignHandler(w http.ResponseWriter, r *http.Request) error {
+	var req mailbus.CampaignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return NewError(err, http.StatusBadRequest, "Invalid JSON body.")
+	}
+	if req.Subject == "" {
+		return NewError(nil, http.StatusBadRequest, "Subject is required.")
+	}
+
+	list, err := s.findList(r, req.List)
+	if err != nil {
+		return err
+	}
+
+	c := mailbus.NewCampaign(list.Name, req.Subject, req.Body)
+	if !req.ScheduledAt.IsZero() {
+		if err := c.Schedule(req.ScheduledAt); err != nil {
+			return FromError(err)
+		}
+	}
+
+	if err := s.CampaignService.Create(c); err != nil {
+		return FromError(err)
+	}
+
+	return writeJSON(w, http.StatusCreated, c)
+}
+
+func (s *Server) campaignHandler(w http.ResponseWriter, r *http.Request) error {
+	c, err := s.findCampaign(r)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, c)
+}
+
+func (s *Server) updateCampaignHandler(w http.ResponseWriter, r *http.Request) error {
+	c, err := s.findCampaign(r)
+	if err != nil {
+		return err
+	}
+	if !c.Editable() {
+		return NewError(nil, http.StatusConflict, "Campaign can no longer be edited.")
+	}
+
+	var req mailbus.CampaignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return NewError(err, http.StatusBadRequest, "Invalid JSON body.")
+	}
+
+	if req.List != "" {
+		list, err := s.findList(r, req.List)
+		if err != nil {
+			return err
+		}
+		c.List = list.Name
+	}
+	if req.Subject != "" {
+		c.Subject = req.Subject
+	}
+	if req.Body != "" {
+		c.Body = req.Body
+	}
+	if !req.ScheduledAt.IsZero() && c.Status == mailbus.CampaignStatusScheduled {
+		c.ScheduledAt = req.ScheduledAt
+	}
+
+	if err := s.CampaignService.Update(c); err != nil {
+		return FromError(err)
+	}
+
+	return writeJSON(w, http.StatusOK, c)
+}
+
+func (s *Server) deleteCampaignHandler(w http.ResponseWriter, r *http.Request) error {
+	c, err := s.findCampaign(r)
+	if err != nil {
+		return err
+	}
+	if c.Status != mailbus.CampaignStatusDraft && c.Status != mailbus.CampaignStatusCancelled {
+		return NewError(nil, http.StatusConflict, "Only draft or cancelled campaigns can be deleted.")
+	}
+
+	if err := s.CampaignService.Delete(c.ID); err != nil {
+		return err
+	}
+	s.auditCampaign(r, mailbus.AuditActionCampaignDelete, c)
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *Server) scheduleCampaignHandler(w http.ResponseWriter, r *http.Request) error {
+	c, err := s.findCampaign(r)
+	if err != nil {
+		return err
+	}
+
+	var req mailbus.CampaignRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return NewError(err, http.StatusBadRequest, "Invalid JSON body.")
+		}
+	}
+
+	at := req.ScheduledAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+	if err := c.Schedule(at); err != nil {
+		return FromError(err)
+	}
+
+	if err := s.CampaignService.Update(c); err != nil {
+		return err
+	}
+	s.auditCampaign(r, mailbus.AuditActionCampaignSchedule, c)
+
+	return writeJSON(w, http.StatusOK, c)
+}
+
+func (s *Server) cancelCampaignHandler(w http.ResponseWriter, r *http.Request) error {
+	c, err := s.findCampaign(r)
+	if err != nil {
+		return err
+	}
+
+	if err := c.Cancel(); err != nil {
+		return FromError(err)
+	}
+
+	if err := s.CampaignService.Update(c); err != nil {
+		return err
+	}
+	s.auditCampaign(r, mailbus.AuditActionCampaignCancel, c)
+
+	return writeJSON(w, http.StatusOK, c)
+}
+
+// archiveHandler lists the issues already sent to a list
+func (s *Server) archiveHandler(w http.ResponseWriter, r *http.Request) error {
+	list, err := s.findList(r, "")
+	if err != nil {
+		return err
+	}
+
+	campaigns, err := s.CampaignService.Find(mailbus.CampaignFilter{
+		List:   list.Name,
+		Status: mailbus.CampaignStatusSent,
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, campaigns)
+}
+
+// campaignStatsHandler reports the deliveries of a campaign and how subscribers engaged with it
+func (s *Server) campaignStatsHandler(w http.ResponseWriter, r *http.Request) error {
+	c, err := s.findCampaign(r)
+	if err != nil {
+		return err
+	}
+
+	stats, err := s.ReportService.CampaignStats(c.ID)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, stats)
+}
+
+func (s *Server) findCampaign(r *http.Request) (*mailbus.Campaign, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, NewError(err, http.StatusBadRequest, "Invalid campaign ID.")
+	}
+
+	c, err := s.CampaignService.FindByID(id)
+	if err != nil {
+		return nil, FromError(err)
+	}
+
+	return c, nil
+}
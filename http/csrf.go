@@ -0,0 +1,53 @@
+package http
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+)
+
+const (
+	csrfCookieName = "mailbus_csrf"
+	csrfFieldName  = "csrf_token"
+)
+
+// csrfToken returns the CSRF token of the browser, issuing one in a cookie if it has none yet.
+// Forms carry the token in a hidden field, see verifyCSRF.
+func (s *Server) csrfToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	if c, err := r.Cookie(csrfCookieName); err == nil && c.Value != "" {
+		return c.Value, nil
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   s.UseTLS(),
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	return token, nil
+}
+
+// verifyCSRF checks that a form was submitted from one of our pages:
+// the token of the form must match the one in the cookie (double submit)
+func verifyCSRF(r *http.Request) error {
+	c, err := r.Cookie(csrfCookieName)
+	if err != nil || c.Value == "" {
+		return NewError(err, http.StatusForbidden, "Missing CSRF cookie.")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(c.Value), []byte(r.PostFormValue(csrfFieldName))) != 1 {
+		return NewError(nil, http.StatusForbidden, "Invalid CSRF token.")
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/quantonganh/mailbus"
+)
+
+// deliveriesHandler searches the delivery log by campaign, email and status
+func (s *Server) deliveriesHandler(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+	filter := mailbus.DeliveryFilter{
+		Email:  query.Get("email"),
+		Status: query.Get("status"),
+	}
+
+	campaignID := query.Get("campaign_id")
+	if v, ok := mux.Vars(r)["id"]; ok {
+		campaignID = v
+	}
+	if campaignID != "" {
+		id, err := strconv.Atoi(campaignID)
+		if err != nil {
+			return NewError(err, http.StatusBadRequest, "Invalid campaign ID.")
+		}
+		filter.CampaignID = id
+	}
+
+	deliveries, err := s.DeliveryService.Find(filter)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, deliveries)
+}
+
+func (s *Server) deliveryHandler(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return NewError(err, http.StatusBadRequest, "Invalid delivery ID.")
+	}
+
+	d, err := s.DeliveryService.FindByID(id)
+	if err != nil {
+		return FromError(err)
+	}
+
+	return writeJSON(w, http.StatusOK, d)
+}
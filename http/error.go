@@ -99,3 +99,20 @@ func NewError(err error, status int, message string) error {
 		Status:  status,
 	}
 }
+
+var errorStatuses = map[string]int{
+	mailbus.ErrInvalid:      http.StatusBadRequest,
+	mailbus.ErrUnauthorized: http.StatusUnauthorized,
+	mailbus.ErrForbidden:    http.StatusForbidden,
+	mailbus.ErrNotFound:     http.StatusNotFound,
+	mailbus.ErrConflict:     http.StatusConflict,
+}
+
+// FromError converts a domain error into a client error, internal errors are returned as is
+func FromError(err error) error {
+	status, ok := errorStatuses[mailbus.ErrorCode(err)]
+	if !ok {
+		return err
+	}
+	return NewError(err, status, mailbus.ErrorMessage(err))
+}
@@ -0,0 +1,93 @@
+package http
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/quantonganh/mailbus"
+)
+
+// exportBatchSize is how many subscribers are read at once while they are exported
+const exportBatchSize = 1000
+
+// exportSubscribersHandler exports the subscribers of every list, or of the list of the query, as CSV
+func (s *Server) exportSubscribersHandler(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+	filter := mailbus.SubscriberFilter{
+		List:   query.Get("list"),
+		Status: query.Get("status"),
+		Query:  query.Get("q"),
+	}
+	if filter.Status != "" && !validStatus(filter.Status) {
+		return NewError(nil, http.StatusBadRequest, fmt.Sprintf("Unknown status %q.", filter.Status))
+	}
+	if filter.List != "" {
+		if _, err := s.findList(r, filter.List); err != nil {
+			return err
+		}
+	}
+
+	return s.exportSubscribers(w, r, filter)
+}
+
+// exportSubscribers writes the subscribers matching filter as CSV on behalf of the admin API or the admin UI.
+// They are read and written a batch at a time, so that large lists are streamed rather than held in memory.
+func (s *Server) exportSubscribers(w http.ResponseWriter, r *http.Request, filter mailbus.SubscriberFilter) error {
+	filter.Limit = exportBatchSize
+	filter.Offset = 0
+
+	// the first batch is read before anything is written, so that an error can still be reported
+	subscribers, err := s.SubscriptionService.Find(filter)
+	if err != nil {
+		return err
+	}
+
+	criteria := url.Values{}
+	for name, v := range map[string]string{"list": filter.List, "status": filter.Status, "q": filter.Query} {
+		if v != "" {
+			criteria.Set(name, v)
+		}
+	}
+	s.audit(r, mailbus.NewAuditEvent(actor(r), mailbus.AuditActionSubscribersExport, filter.List, criteria.Encode()))
+
+	h := w.Header()
+	h.Set("Content-Type", "text/csv; charset=utf-8")
+	h.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="subscribers-%s.csv"`, time.Now().UTC().Format("20060102")))
+	h.Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"email", "list", "status", "subscribed_at"})
+	for {
+		for _, subscriber := range subscribers {
+			var subscribedAt string
+			if !subscriber.SubscribedAt.IsZero() {
+				subscribedAt = subscriber.SubscribedAt.UTC().Format(time.RFC3339)
+			}
+			_ = cw.Write([]string{subscriber.Email, subscriber.List, subscriber.Status, subscribedAt})
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			// the client went away
+			return nil
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+
+		if len(subscribers) < exportBatchSize {
+			return nil
+		}
+		filter.Offset += exportBatchSize
+		if subscribers, err = s.SubscriptionService.Find(filter); err != nil {
+			// the response has started, all that is left is to cut it short
+			hlog.FromRequest(r).Error().Err(err).Msg("failed to export subscribers")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package http
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/rs/zerolog/hlog"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// pages are the HTML pages served to subscribers, each one rendered inside the layout
+var pages = map[string]*template.Template{
+	"confirm":     parsePage("confirm.html"),
+	"unsubscribe": parsePage("unsubscribe.html"),
+	"message":     parsePage("message.html"),
+}
+
+func parsePage(name string) *template.Template {
+	return template.Must(template.ParseFS(templateFS, "templates/layout.html", "templates/"+name))
+}
+
+// page holds the data of an HTML page
+type page struct {
+	Title     string
+	Message   string
+	Action    string
+	Token     string
+	List      string
+	Email     string
+	CSRFToken string
+}
+
+// render writes an HTML page
+func (s *Server) render(w http.ResponseWriter, status int, name string, data page) error {
+	var buf bytes.Buffer
+	if err := pages[name].ExecuteTemplate(&buf, "layout.html", data); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// pageHandler wraps a handler of the pages that subscribers open in their browser: errors are rendered
+// as HTML pages rather than as JSON, and the errors that are not the client's answer 500
+func (s *Server) pageHandler(fn appHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := fn(w, r)
+		if err == nil {
+			return
+		}
+
+		hlog.FromRequest(r).Error().Msg(err.Error())
+
+		status, message := http.StatusInternalServerError, "Something went wrong, please try again later."
+		if e, ok := FromError(err).(*Error); ok {
+			status, message = e.Status, e.Message
+		} else {
+			sentry.CaptureException(err)
+		}
+		if err := s.render(w, status, "message", page{Title: http.StatusText(status), Message: message}); err != nil {
+			hlog.FromRequest(r).Error().Err(err).Msg("failed to render error page")
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package http
+
+import (
+	"mime"
+	"net/http"
+
+	"github.com/quantonganh/mailbus"
+)
+
+// importFormats are the formats of the content types imports are sent as
+var importFormats = map[string]string{
+	"text/csv":             mailbus.ImportFormatCSV,
+	"application/x-ndjson": mailbus.ImportFormatJSONL,
+	"application/jsonl":    mailbus.ImportFormatJSONL,
+}
+
+// importSubscribersHandler adds the subscribers of a CSV or JSONL body, imported as it is read,
+// and responds with the report of the import
+func (s *Server) importSubscribersHandler(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+
+	format := query.Get("format")
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if format = importFormats[mediaType]; format == "" {
+			return NewError(nil, http.StatusUnsupportedMediaType, "Send CSV as text/csv, or JSON lines as application/x-ndjson.")
+		}
+	}
+	mapping, err := mailbus.ParseImportMapping(query.Get("map"))
+	if err != nil {
+		return FromError(err)
+	}
+	if list := query.Get("list"); list != "" {
+		if _, err := s.findList(r, list); err != nil {
+			return err
+		}
+	}
+
+	opts := mailbus.ImportOptions{
+		Format:  format,
+		Mapping: mapping,
+		List:    query.Get("list"),
+		Consent: query.Get("consent"),
+		URL:     query.Get("url"),
+		DryRun:  query.Get("dry_run") == "true",
+	}
+	if opts.URL == "" {
+		opts.URL = s.URL()
+	}
+
+	report, err := s.importer().Import(r.Body, opts)
+	if report != nil && !opts.DryRun {
+		s.audit(r, mailbus.NewAuditEvent(actor(r), mailbus.AuditActionSubscribersImport, opts.List,
+			"consent="+opts.Consent+" "+report.Summary()))
+	}
+	if err != nil {
+		return FromError(err)
+	}
+
+	return writeJSON(w, http.StatusOK, report)
+}
+
+func (s *Server) importer() *mailbus.Importer {
+	return &mailbus.Importer{
+		ListService:         s.ListService,
+		SubscriptionService: s.SubscriptionService,
+		SuppressionService:  s.SuppressionService,
+		Confirmer:           s.confirmer(),
+	}
+}
@@ -0,0 +1,124 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/quantonganh/mailbus"
+)
+
+func (s *Server) listsHandler(w http.ResponseWriter, r *http.Request) error {
+	lists, err := s.ListService.FindAll()
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, lists)
+}
+
+func (s *Server) listHandler(w http.ResponseWriter, r *http.Request) error {
+	l, err := s.findList(r, "")
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, l)
+}
+
+func (s *Server) createListHandler(w http.ResponseWriter, r *http.Request) error {
+	var req mailbus.ListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return NewError(err, http.StatusBadRequest, "Invalid JSON body.")
+	}
+
+	l, err := mailbus.NewList(req.Name, req.Title, req.Description)
+	if err != nil {
+		return FromError(err)
+	}
+	setTracking(l, req)
+
+	if err := s.ListService.Create(l); err != nil {
+		return FromError(err)
+	}
+	s.audit(r, mailbus.NewAuditEvent(actor(r), mailbus.AuditActionListCreate, l.Name, l.Title))
+
+	return writeJSON(w, http.StatusCreated, l)
+}
+
+// updateListHandler edits the title, the description and the tracking settings of a list, the fields left empty are kept
+func (s *Server) updateListHandler(w http.ResponseWriter, r *http.Request) error {
+	l, err := s.findList(r, "")
+	if err != nil {
+		return err
+	}
+
+	var req mailbus.ListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return NewError(err, http.StatusBadRequest, "Invalid JSON body.")
+	}
+	if req.Name != "" && req.Name != l.Name {
+		return NewError(nil, http.StatusBadRequest, "Lists cannot be renamed.")
+	}
+	if req.Title != "" {
+		l.Title = req.Title
+	}
+	if req.Description != "" {
+		l.Description = req.Description
+	}
+	setTracking(l, req)
+
+	if err := s.ListService.Update(l); err != nil {
+		return FromError(err)
+	}
+	s.audit(r, mailbus.NewAuditEvent(actor(r), mailbus.AuditActionListUpdate, l.Name, l.Title))
+
+	return writeJSON(w, http.StatusOK, l)
+}
+
+// setTracking applies the tracking settings of a request to a list
+func setTracking(l *mailbus.List, req mailbus.ListRequest) {
+	if req.TrackOpens != nil {
+		l.TrackOpens = *req.TrackOpens
+	}
+	if req.TrackClicks != nil {
+		l.TrackClicks = *req.TrackClicks
+	}
+}
+
+// deleteListHandler deletes a list along with its subscribers, the default list is kept
+func (s *Server) deleteListHandler(w http.ResponseWriter, r *http.Request) error {
+	l, err := s.findList(r, "")
+	if err != nil {
+		return err
+	}
+	if l.Name == mailbus.DefaultList {
+		return NewError(nil, http.StatusConflict, "The default list cannot be deleted.")
+	}
+
+	if err := s.ListService.Delete(l.Name); err != nil {
+		return err
+	}
+	s.audit(r, mailbus.NewAuditEvent(actor(r), mailbus.AuditActionListDelete, l.Name, ""))
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// findList returns the list a request is scoped to, falling back to the default list
+func (s *Server) findList(r *http.Request, name string) (*mailbus.List, error) {
+	if v, ok := mux.Vars(r)["list"]; ok {
+		name = v
+	}
+	if name == "" {
+		name = mailbus.DefaultList
+	}
+
+	l, err := s.ListService.FindByName(name)
+	if mailbus.ErrorCode(err) == mailbus.ErrNotFound {
+		return nil, NewError(err, http.StatusNotFound, "List not found.")
+	}
+
+	return l, err
+}
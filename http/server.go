@@ -11,6 +11,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/getsentry/sentry-go"
 	sentryhttp "github.com/getsentry/sentry-go/http"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
@@ -19,6 +20,7 @@ import (
 
 	"github.com/quantonganh/httperror"
 	"github.com/quantonganh/mailbus"
+	"github.com/quantonganh/mailbus/pkg/token"
 )
 
 const (
@@ -34,9 +36,28 @@ type Server struct {
 	Addr   string
 	Domain string
 
+	ListService         mailbus.ListService
 	SubscriptionService mailbus.SubscriptionService
+	CampaignService     mailbus.CampaignService
+	DeliveryService     mailbus.DeliveryService
+	SuppressionService  mailbus.SuppressionService
+	AuditService        mailbus.AuditService
+	TrackingService     mailbus.TrackingService
+	ReportService       mailbus.ReportService
+	APIKeyService       mailbus.APIKeyService
+	AdminService        mailbus.AdminService
+	SessionService      mailbus.SessionService
 	NewsletterService   mailbus.NewsletterService
 	QueueService        mailbus.QueueService
+
+	// OpenHeuristics flags the opens made by machines
+	OpenHeuristics mailbus.OpenHeuristics
+	// ConfirmationPolicy decides how long confirmation tokens last and how often they are resent
+	ConfirmationPolicy mailbus.ConfirmationPolicy
+	// TokenSigner, if not nil, signs confirmation tokens instead of storing random ones
+	TokenSigner *token.Signer
+	// SessionTTL is how long admins stay logged in to the admin UI
+	SessionTTL time.Duration
 }
 
 // NewServer create new HTTP server
@@ -72,8 +93,97 @@ func NewServer() (*Server, error) {
 	s.router.HandleFunc("/health", s.healthCheckHandler)
 	s.router.HandleFunc("/subscriptions", s.Error(s.subscriptionsHandler)).Methods(http.MethodPost)
 	subRouter := s.router.PathPrefix("/subscriptions").Subrouter()
-	subRouter.HandleFunc("/confirm", s.Error(s.confirmHandler))
-	s.router.HandleFunc("/unsubscribe", s.Error(s.unsubscribeHandler))
+	subRouter.HandleFunc("/confirm", s.pageHandler(s.confirmHandler)).Methods(http.MethodGet)
+	subRouter.HandleFunc("/confirm", s.pageHandler(s.confirmSubscriptionHandler)).Methods(http.MethodPost)
+	s.router.HandleFunc("/unsubscribe", s.pageHandler(s.unsubscribeHandler)).Methods(http.MethodGet)
+	s.router.HandleFunc("/unsubscribe", s.pageHandler(s.unsubscribeFormHandler)).Methods(http.MethodPost)
+
+	s.router.HandleFunc("/lists", s.Error(s.listsHandler)).Methods(http.MethodGet)
+	listRouter := s.router.PathPrefix("/lists/{list}").Subrouter()
+	listRouter.HandleFunc("/subscriptions", s.Error(s.subscriptionsHandler)).Methods(http.MethodPost)
+	listRouter.HandleFunc("/unsubscribe", s.pageHandler(s.unsubscribeHandler)).Methods(http.MethodGet)
+	listRouter.HandleFunc("/unsubscribe", s.pageHandler(s.unsubscribeFormHandler)).Methods(http.MethodPost)
+	listRouter.HandleFunc("/archive", s.Error(s.archiveHandler)).Methods(http.MethodGet)
+
+	// the admin API, authenticated with API keys
+	v1Router := s.router.PathPrefix("/api/v1").Subrouter()
+	v1Router.Use(s.authenticate)
+	v1Router.HandleFunc("/subscribers", s.scope(mailbus.ScopeSubscribersRead, s.subscribersHandler)).Methods(http.MethodGet)
+	v1Router.HandleFunc("/subscribers/export", s.scope(mailbus.ScopeSubscribersExport, s.exportSubscribersHandler)).Methods(http.MethodGet)
+	v1Router.HandleFunc("/subscribers/import", s.scope(mailbus.ScopeSubscribersWrite, s.importSubscribersHandler)).Methods(http.MethodPost)
+	v1Router.HandleFunc("/lists", s.scope(mailbus.ScopeListsRead, s.listsHandler)).Methods(http.MethodGet)
+	v1Router.HandleFunc("/lists", s.scope(mailbus.ScopeListsWrite, s.createListHandler)).Methods(http.MethodPost)
+	v1ListRouter := v1Router.PathPrefix("/lists/{list}").Subrouter()
+	v1ListRouter.HandleFunc("", s.scope(mailbus.ScopeListsRead, s.listHandler)).Methods(http.MethodGet)
+	v1ListRouter.HandleFunc("", s.scope(mailbus.ScopeListsWrite, s.updateListHandler)).Methods(http.MethodPatch)
+	v1ListRouter.HandleFunc("", s.scope(mailbus.ScopeListsWrite, s.deleteListHandler)).Methods(http.MethodDelete)
+	v1ListRouter.HandleFunc("/subscribers", s.scope(mailbus.ScopeSubscribersRead, s.subscribersHandler)).Methods(http.MethodGet)
+	v1ListRouter.HandleFunc("/subscribers", s.scope(mailbus.ScopeSubscribersWrite, s.createSubscriberHandler)).Methods(http.MethodPost)
+	v1ListRouter.HandleFunc("/subscribers/{email}", s.scope(mailbus.ScopeSubscribersRead, s.subscriberHandler)).Methods(http.MethodGet)
+	v1ListRouter.HandleFunc("/subscribers/{email}", s.scope(mailbus.ScopeSubscribersWrite, s.updateSubscriberHandler)).Methods(http.MethodPatch)
+	v1ListRouter.HandleFunc("/subscribers/{email}", s.scope(mailbus.ScopeSubscribersWrite, s.deleteSubscriberHandler)).Methods(http.MethodDelete)
+	v1Router.HandleFunc("/campaigns", s.scope(mailbus.ScopeCampaignsRead, s.campaignsHandler)).Methods(http.MethodGet)
+	v1Router.HandleFunc("/campaigns", s.scope(mailbus.ScopeCampaignsWrite, s.createCampaignHandler)).Methods(http.MethodPost)
+	v1CampaignRouter := v1Router.PathPrefix("/campaigns/{id:[0-9]+}").Subrouter()
+	v1CampaignRouter.HandleFunc("", s.scope(mailbus.ScopeCampaignsRead, s.campaignHandler)).Methods(http.MethodGet)
+	v1CampaignRouter.HandleFunc("", s.scope(mailbus.ScopeCampaignsWrite, s.updateCampaignHandler)).Methods(http.MethodPut)
+	v1CampaignRouter.HandleFunc("", s.scope(mailbus.ScopeCampaignsWrite, s.deleteCampaignHandler)).Methods(http.MethodDelete)
+	v1CampaignRouter.HandleFunc("/schedule", s.scope(mailbus.ScopeCampaignsSend, s.scheduleCampaignHandler)).Methods(http.MethodPost)
+	v1CampaignRouter.HandleFunc("/cancel", s.scope(mailbus.ScopeCampaignsSend, s.cancelCampaignHandler)).Methods(http.MethodPost)
+	v1CampaignRouter.HandleFunc("/deliveries", s.scope(mailbus.ScopeCampaignsRead, s.deliveriesHandler)).Methods(http.MethodGet)
+	v1CampaignRouter.HandleFunc("/links", s.scope(mailbus.ScopeCampaignsRead, s.linksHandler)).Methods(http.MethodGet)
+	v1CampaignRouter.HandleFunc("/stats", s.scope(mailbus.ScopeCampaignsRead, s.campaignStatsHandler)).Methods(http.MethodGet)
+	v1Router.HandleFunc("/deliveries", s.scope(mailbus.ScopeCampaignsRead, s.deliveriesHandler)).Methods(http.MethodGet)
+	v1Router.HandleFunc("/deliveries/{id:[0-9]+}", s.scope(mailbus.ScopeCampaignsRead, s.deliveryHandler)).Methods(http.MethodGet)
+	v1Router.HandleFunc("/suppressions", s.scope(mailbus.ScopeSuppressionsRead, s.suppressionsHandler)).Methods(http.MethodGet)
+	v1Router.HandleFunc("/suppressions", s.scope(mailbus.ScopeSuppressionsWrite, s.createSuppressionHandler)).Methods(http.MethodPost)
+	v1Router.HandleFunc("/suppressions/{value}", s.scope(mailbus.ScopeSuppressionsWrite, s.deleteSuppressionHandler)).Methods(http.MethodDelete)
+	v1Router.HandleFunc("/audit", s.scope(mailbus.ScopeAuditRead, s.auditHandler)).Methods(http.MethodGet)
+
+	// the admin UI, for the people who log in with a username and a password
+	adminRouter := s.router.PathPrefix("/admin").Subrouter()
+	adminRouter.PathPrefix("/static/").Handler(adminStatic()).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/login", s.adminHandler(s.loginPageHandler)).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/login", s.adminHandler(s.loginHandler)).Methods(http.MethodPost)
+	uiRouter := adminRouter.NewRoute().Subrouter()
+	uiRouter.Use(s.requireAdmin)
+	uiRouter.Handle("", http.RedirectHandler("/admin/subscribers", http.StatusFound)).Methods(http.MethodGet)
+	uiRouter.HandleFunc("/logout", s.adminHandler(s.logoutHandler)).Methods(http.MethodPost)
+	uiRouter.HandleFunc("/account", s.adminHandler(s.adminAccountHandler)).Methods(http.MethodGet)
+	uiRouter.HandleFunc("/account/password", s.adminHandler(s.adminPasswordHandler)).Methods(http.MethodPost)
+	uiRouter.HandleFunc("/account/2fa", s.adminHandler(s.adminEnableTwoFactorHandler)).Methods(http.MethodPost)
+	uiRouter.HandleFunc("/account/2fa/disable", s.adminHandler(s.adminDisableTwoFactorHandler)).Methods(http.MethodPost)
+	uiRouter.HandleFunc("/subscribers", s.adminHandler(s.can(mailbus.ScopeSubscribersRead, s.adminSubscribersHandler))).Methods(http.MethodGet)
+	uiRouter.HandleFunc("/subscribers", s.adminHandler(s.can(mailbus.ScopeSubscribersWrite, s.adminCreateSubscriberHandler))).Methods(http.MethodPost)
+	uiRouter.HandleFunc("/subscribers/export", s.adminHandler(s.can(mailbus.ScopeSubscribersExport, s.adminExportSubscribersHandler))).Methods(http.MethodGet)
+	uiRouter.HandleFunc("/lists", s.adminHandler(s.can(mailbus.ScopeListsRead, s.adminListsHandler))).Methods(http.MethodGet)
+	uiRouter.HandleFunc("/lists", s.adminHandler(s.can(mailbus.ScopeListsWrite, s.adminCreateListHandler))).Methods(http.MethodPost)
+	uiListRouter := uiRouter.PathPrefix("/lists/{list}").Subrouter()
+	uiListRouter.HandleFunc("", s.adminHandler(s.can(mailbus.ScopeListsRead, s.adminListHandler))).Methods(http.MethodGet)
+	uiListRouter.HandleFunc("", s.adminHandler(s.can(mailbus.ScopeListsWrite, s.adminUpdateListHandler))).Methods(http.MethodPost)
+	uiListRouter.HandleFunc("/delete", s.adminHandler(s.can(mailbus.ScopeListsWrite, s.adminDeleteListHandler))).Methods(http.MethodPost)
+	uiListRouter.HandleFunc("/subscribers/{email}", s.adminHandler(s.can(mailbus.ScopeSubscribersWrite, s.adminUpdateSubscriberHandler))).Methods(http.MethodPost)
+	uiListRouter.HandleFunc("/subscribers/{email}/delete", s.adminHandler(s.can(mailbus.ScopeSubscribersWrite, s.adminDeleteSubscriberHandler))).Methods(http.MethodPost)
+	uiRouter.HandleFunc("/campaigns", s.adminHandler(s.can(mailbus.ScopeCampaignsRead, s.adminCampaignsHandler))).Methods(http.MethodGet)
+	uiRouter.HandleFunc("/campaigns", s.adminHandler(s.can(mailbus.ScopeCampaignsWrite, s.adminCreateCampaignHandler))).Methods(http.MethodPost)
+	uiRouter.HandleFunc("/campaigns/new", s.adminHandler(s.can(mailbus.ScopeCampaignsWrite, s.adminNewCampaignHandler))).Methods(http.MethodGet)
+	uiCampaignRouter := uiRouter.PathPrefix("/campaigns/{id:[0-9]+}").Subrouter()
+	uiCampaignRouter.HandleFunc("", s.adminHandler(s.can(mailbus.ScopeCampaignsRead, s.adminCampaignHandler))).Methods(http.MethodGet)
+	uiCampaignRouter.HandleFunc("", s.adminHandler(s.can(mailbus.ScopeCampaignsWrite, s.adminUpdateCampaignHandler))).Methods(http.MethodPost)
+	uiCampaignRouter.HandleFunc("/schedule", s.adminHandler(s.can(mailbus.ScopeCampaignsSend, s.adminScheduleCampaignHandler))).Methods(http.MethodPost)
+	uiCampaignRouter.HandleFunc("/cancel", s.adminHandler(s.can(mailbus.ScopeCampaignsSend, s.adminCancelCampaignHandler))).Methods(http.MethodPost)
+	uiCampaignRouter.HandleFunc("/delete", s.adminHandler(s.can(mailbus.ScopeCampaignsWrite, s.adminDeleteCampaignHandler))).Methods(http.MethodPost)
+	uiCampaignRouter.HandleFunc("/report", s.adminHandler(s.can(mailbus.ScopeCampaignsRead, s.adminReportHandler))).Methods(http.MethodGet)
+	uiRouter.HandleFunc("/audit", s.adminHandler(s.can(mailbus.ScopeAuditRead, s.adminAuditHandler))).Methods(http.MethodGet)
+	uiRouter.HandleFunc("/admins", s.adminHandler(s.ownerOnly(s.adminAdminsHandler))).Methods(http.MethodGet)
+	uiRouter.HandleFunc("/admins", s.adminHandler(s.ownerOnly(s.adminCreateAdminHandler))).Methods(http.MethodPost)
+	uiAdminRouter := uiRouter.PathPrefix("/admins/{id:[0-9]+}").Subrouter()
+	uiAdminRouter.HandleFunc("", s.adminHandler(s.ownerOnly(s.adminUpdateAdminHandler))).Methods(http.MethodPost)
+	uiAdminRouter.HandleFunc("/2fa/reset", s.adminHandler(s.ownerOnly(s.adminResetTwoFactorHandler))).Methods(http.MethodPost)
+	uiAdminRouter.HandleFunc("/delete", s.adminHandler(s.ownerOnly(s.adminDeleteAdminHandler))).Methods(http.MethodPost)
+
+	s.router.HandleFunc("/tracking/open", s.Error(s.openHandler)).Methods(http.MethodGet)
+	s.router.HandleFunc("/tracking/click", s.Error(s.clickHandler)).Methods(http.MethodGet)
 
 	return s, nil
 }
@@ -132,27 +242,47 @@ func (s *Server) Open() (err error) {
 	return nil
 }
 
-func (s *Server) ConsumeAndSendNewsletter(ctx context.Context) error {
+// ConsumeNewsletterRequests consumes newsletter requests from the queue
+// and turns each of them into a campaign scheduled at the time returned by scheduleAt.
+// A request that cannot be turned into a campaign is reported and skipped.
+func (s *Server) ConsumeNewsletterRequests(ctx context.Context, scheduleAt func(now time.Time) time.Time) error {
 	messages, err := s.QueueService.Consume(ctx, "added-posts")
 	if err != nil {
 		return err
 	}
 
-	activeSubscribers, err := s.SubscriptionService.FindByStatus(mailbus.StatusActive)
-	if err != nil {
+	for msg := range messages {
+		if err := s.createCampaign(msg, scheduleAt); err != nil {
+			sentry.CaptureException(errors.Wrap(err, "failed to create campaign from newsletter request"))
+		}
+	}
+
+	return nil
+}
+
+// createCampaign schedules a campaign for a newsletter request read from the queue
+func (s *Server) createCampaign(msg []byte, scheduleAt func(now time.Time) time.Time) error {
+	var req *mailbus.EmailNewsletterRequest
+	if err := json.NewDecoder(bytes.NewReader(msg)).Decode(&req); err != nil {
 		return err
 	}
 
-	for msg := range messages {
-		var req *mailbus.EmailNewsletterRequest
-		if err := json.NewDecoder(bytes.NewReader(msg)).Decode(&req); err != nil {
-			return err
-		}
+	list := req.List
+	if list == "" {
+		list = mailbus.DefaultList
+	}
 
-		s.NewsletterService.SendNewsletter(activeSubscribers, req.Subject, req.Body)
+	c := mailbus.NewCampaign(list, req.Subject, req.Body)
+	if err := c.Schedule(scheduleAt(time.Now())); err != nil {
+		return err
 	}
+	return s.CampaignService.Create(c)
+}
 
-	return nil
+func writeJSON(w http.ResponseWriter, status int, v interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
 }
 
 // Close shutdowns HTTP server
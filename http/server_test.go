@@ -2,28 +2,39 @@ package http
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/asdine/storm/v3"
 	uuid "github.com/satori/go.uuid"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	testifymock "github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
 	"github.com/quantonganh/mailbus"
 	"github.com/quantonganh/mailbus/mock"
 	"github.com/quantonganh/mailbus/pkg/hash"
+	"github.com/quantonganh/mailbus/pkg/token"
+	"github.com/quantonganh/mailbus/pkg/totp"
 )
 
 var (
-	cfg *mailbus.Config
-	s   *Server
+	cfg     *mailbus.Config
+	s       *Server
+	keyring *hash.Keyring
 )
 
 func TestMain(m *testing.M) {
@@ -35,6 +46,9 @@ templates:
 newsletter:
   hmac:
     secret: da02e221bc331c9875c5e1299fa8d765
+    keys:
+      - id: "2"
+        secret: 6f1ed002ab5595859014ebf0951522d9
 `)
 	if err := viper.ReadConfig(bytes.NewBuffer(yamlConfig)); err != nil {
 		log.Fatal(err)
@@ -44,6 +58,13 @@ newsletter:
 	}
 
 	var err error
+	// the secret only verifies links signed before keys had IDs
+	hmac := cfg.Newsletter.HMAC
+	keyring, err = hash.NewKeyring(hash.Key{ID: hmac.Keys[0].ID, Secret: hmac.Keys[0].Secret}, hash.Key{Secret: hmac.Secret})
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	s, err = NewServer()
 	if err != nil {
 		log.Fatal(err)
@@ -58,15 +79,19 @@ func TestSubscriptionsHandler(t *testing.T) {
 	email := "foo@gmail.com"
 	token := uuid.NewV4().String()
 
+	listService := new(mock.ListService)
+	listService.On("FindByName", mailbus.DefaultList).Return(&mailbus.List{Name: mailbus.DefaultList}, nil)
+
 	subscribe := &mailbus.Subscriber{}
 	subscribeService := new(mock.SubscriptionService)
-	subscribeService.On("FindByEmail", email).Return(subscribe, storm.ErrNotFound)
-	subscribeService.On("Insert", mailbus.NewSubscription(email, token, mailbus.StatusPendingConfirmation)).Return(nil)
+	subscribeService.On("FindByEmail", mailbus.DefaultList, email).Return(subscribe, storm.ErrNotFound)
+	subscribeService.On("Insert", mailbus.NewSubscription(mailbus.DefaultList, email, mailbus.StatusPendingConfirmation, token)).Return(nil)
 
 	smtpService := new(mock.NewsletterService)
 	smtpService.On("SendConfirmationEmail", email, token).Return(nil)
 	smtpService.On("GenerateNewUUID").Return(token)
 
+	s.ListService = listService
 	s.SubscriptionService = subscribeService
 	s.NewsletterService = smtpService
 
@@ -82,24 +107,116 @@ func TestSubscriptionsHandler(t *testing.T) {
 	w := httptest.NewRecorder()
 	s.router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusNotFound, w.Code)
+	// only a subscriber that is not found is signed up, other store errors are internal ones
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestSubscriptionsHandlerOutbox(t *testing.T) {
+	email := "bar@gmail.com"
+	token := uuid.NewV4().String()
+
+	listService := new(mock.ListService)
+	listService.On("FindByName", mailbus.DefaultList).Return(&mailbus.List{Name: mailbus.DefaultList}, nil)
+
+	subscribeService := new(mock.SubscriptionService)
+	subscribeService.On("FindByEmail", mailbus.DefaultList, email).Return(nil, &mailbus.Error{Code: mailbus.ErrNotFound})
+	subscribeService.On("Insert", testifymock.MatchedBy(func(s *mailbus.Subscription) bool {
+		return s.Email == email && s.Token == token && s.Message != nil &&
+			s.Message.Kind == mailbus.OutboxKindConfirmation && s.Message.Recipient == email &&
+			strings.Contains(s.Message.Payload, token)
+	})).Return(nil)
+
+	smtpService := new(mock.NewsletterService)
+	smtpService.On("GenerateNewUUID").Return(token)
+
+	s.ListService = listService
+	s.SubscriptionService = subscribeService
+	s.NewsletterService = smtpService
+
+	// the address is stored the way it is looked up, whatever the case it is typed in
+	data, err := json.Marshal(&mailbus.SubscriptionRequest{Email: " Bar@Gmail.com", URL: "https://example.com"})
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, "/subscriptions", bytes.NewReader(data))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	// the confirmation email is written to the outbox with the subscription, not sent by the handler
+	assert.Equal(t, http.StatusOK, w.Code)
+	subscribeService.AssertExpectations(t)
+	smtpService.AssertNotCalled(t, "SendConfirmationEmail", testifymock.Anything, testifymock.Anything, testifymock.Anything)
+}
+
+func TestSubscriptionsHandlerPending(t *testing.T) {
+	email := "baz@gmail.com"
+	token := uuid.NewV4().String()
+
+	listService := new(mock.ListService)
+	listService.On("FindByName", mailbus.DefaultList).Return(&mailbus.List{Name: mailbus.DefaultList}, nil)
+
+	pending := &mailbus.Subscriber{Email: email, List: mailbus.DefaultList, Status: mailbus.StatusPendingConfirmation,
+		ConfirmationSentAt: time.Now().Add(-time.Minute)}
+	subscribeService := new(mock.SubscriptionService)
+	subscribeService.On("FindByEmail", mailbus.DefaultList, email).Return(pending, nil)
+	subscribeService.On("Update", testifymock.MatchedBy(func(s *mailbus.Subscription) bool {
+		return s.Token == token && s.ExpiresAt.Equal(s.IssuedAt.Add(time.Hour)) && s.Message != nil &&
+			strings.Contains(s.Message.Payload, token)
+	})).Return(nil)
+
+	smtpService := new(mock.NewsletterService)
+	smtpService.On("GenerateNewUUID").Return(token)
+
+	s.ListService = listService
+	s.SubscriptionService = subscribeService
+	s.NewsletterService = smtpService
+	s.ConfirmationPolicy = mailbus.ConfirmationPolicy{TokenTTL: time.Hour, Cooldown: 10 * time.Minute}
+	defer func() {
+		s.ConfirmationPolicy = mailbus.ConfirmationPolicy{}
+	}()
+
+	subscribe := func() *httptest.ResponseRecorder {
+		data, err := json.Marshal(&mailbus.SubscriptionRequest{Email: email, URL: "https://example.com"})
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, "/subscriptions", bytes.NewReader(data))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// the last confirmation email was sent a minute ago
+	assert.Equal(t, http.StatusTooManyRequests, subscribe().Code)
+	subscribeService.AssertNotCalled(t, "Update", testifymock.Anything)
+
+	// once the cooldown is over, a fresh token is issued and sent
+	pending.ConfirmationSentAt = time.Now().Add(-time.Hour)
+	assert.Equal(t, http.StatusOK, subscribe().Code)
+	subscribeService.AssertExpectations(t)
 }
 
 func TestConfirmHandler(t *testing.T) {
 	email := "foo@gmail.com"
 	token := uuid.NewV4().String()
 
-	subscribe := mailbus.NewSubscription(email, token, mailbus.StatusPendingConfirmation)
+	subscriber := &mailbus.Subscriber{Email: email, List: mailbus.DefaultList, Status: mailbus.StatusActive}
 	subscribeService := new(mock.SubscriptionService)
-	subscribeService.On("Subscribe", token).Return(nil)
-	subscribeService.On("FindByToken", token).Return(subscribe, nil)
+	subscribeService.On("Confirm", token).Return(subscriber, nil)
 
 	smtpService := new(mock.NewsletterService)
 	smtpService.On("SendThankYouEmail", email).Return(nil)
 
+	auditService := new(mock.AuditService)
+	auditService.On("Record", testifymock.MatchedBy(func(e *mailbus.AuditEvent) bool {
+		return e.Actor == email && e.Action == mailbus.AuditActionSubscriptionConfirm
+	})).Return(nil)
+
 	s.SubscriptionService = subscribeService
 	s.NewsletterService = smtpService
+	s.AuditService = auditService
 
+	// following the link only renders the confirmation page
 	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/subscriptions/confirm?token=%s", token), nil)
 	assert.NoError(t, err)
 
@@ -107,28 +224,1202 @@ func TestConfirmHandler(t *testing.T) {
 	s.router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), token)
+	subscribeService.AssertNotCalled(t, "Confirm", token)
+
+	cookie := csrfCookie(t, w)
+
+	// a POST without the CSRF token is refused
+	w = postForm(t, "/subscriptions/confirm", url.Values{"token": {token}}, cookie)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	subscribeService.AssertNotCalled(t, "Confirm", token)
+
+	w = postForm(t, "/subscriptions/confirm", url.Values{"token": {token}, csrfFieldName: {cookie.Value}}, cookie)
+	assert.Equal(t, http.StatusOK, w.Code)
+	subscribeService.AssertExpectations(t)
+	smtpService.AssertExpectations(t)
+	auditService.AssertExpectations(t)
+}
+
+func TestConfirmHandlerExpiredToken(t *testing.T) {
+	token := uuid.NewV4().String()
+
+	subscribeService := new(mock.SubscriptionService)
+	subscribeService.On("Confirm", token).Return(nil, mailbus.TokenExpiredError("subscriptionService.Confirm"))
+	s.SubscriptionService = subscribeService
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/subscriptions/confirm?token=%s", token), nil)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	cookie := csrfCookie(t, w)
+
+	w = postForm(t, "/subscriptions/confirm", url.Values{"token": {token}, csrfFieldName: {cookie.Value}}, cookie)
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "Token has expired")
+}
+
+func TestConfirmHandlerStoreError(t *testing.T) {
+	token := uuid.NewV4().String()
+
+	subscribeService := new(mock.SubscriptionService)
+	subscribeService.On("Confirm", token).Return(nil, errors.New("database is locked"))
+	s.SubscriptionService = subscribeService
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/subscriptions/confirm?token=%s", token), nil)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	cookie := csrfCookie(t, w)
+
+	// the browser gets a page, and the details of the error stay in the logs
+	w = postForm(t, "/subscriptions/confirm", url.Values{"token": {token}, csrfFieldName: {cookie.Value}}, cookie)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "Something went wrong")
+	assert.NotContains(t, w.Body.String(), "database is locked")
+}
+
+func TestConfirmHandlerSignedToken(t *testing.T) {
+	email := "qux@gmail.com"
+	signer, err := token.NewSigner(token.Key{ID: "1", Algorithm: token.AlgorithmHMAC, Secret: []byte(strings.Repeat("k", 32))})
+	require.NoError(t, err)
+
+	listService := new(mock.ListService)
+	listService.On("FindByName", mailbus.DefaultList).Return(&mailbus.List{Name: mailbus.DefaultList}, nil)
+
+	var issued *mailbus.Subscription
+	subscriber := &mailbus.Subscriber{Email: email, List: mailbus.DefaultList, Status: mailbus.StatusPendingConfirmation}
+	subscribeService := new(mock.SubscriptionService)
+	subscribeService.On("FindByEmail", mailbus.DefaultList, email).Return(nil, &mailbus.Error{Code: mailbus.ErrNotFound}).Once()
+	subscribeService.On("Insert", testifymock.AnythingOfType("*mailbus.Subscription")).Run(func(args testifymock.Arguments) {
+		issued = args.Get(0).(*mailbus.Subscription)
+		subscriber.SubscribedAt = issued.IssuedAt
+	}).Return(nil)
+	subscribeService.On("FindByEmail", mailbus.DefaultList, email).Return(subscriber, nil)
+	subscribeService.On("Activate", mailbus.DefaultList, email).Run(func(testifymock.Arguments) {
+		subscriber.Status = mailbus.StatusActive
+	}).Return(subscriber, nil)
+
+	smtpService := new(mock.NewsletterService)
+	smtpService.On("SendThankYouEmail", email).Return(nil)
+
+	auditService := new(mock.AuditService)
+	auditService.On("Record", testifymock.AnythingOfType("*mailbus.AuditEvent")).Return(nil)
+
+	s.ListService = listService
+	s.SubscriptionService = subscribeService
+	s.NewsletterService = smtpService
+	s.AuditService = auditService
+	s.TokenSigner = signer
+	defer func() {
+		s.TokenSigner = nil
+	}()
+
+	data, err := json.Marshal(&mailbus.SubscriptionRequest{Email: email, URL: "https://example.com"})
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, "/subscriptions", bytes.NewReader(data))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotNil(t, issued)
+	assert.True(t, issued.Signed)
+	assert.True(t, token.IsSigned(issued.Token))
+	smtpService.AssertNotCalled(t, "GenerateNewUUID")
+
+	confirmPage := func(t string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, "/subscriptions/confirm?token="+url.QueryEscape(t), nil)
+		require.NoError(nil, err)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// a tampered token is refused before the page is rendered, without any lookup
+	assert.Equal(t, http.StatusNotFound, confirmPage(issued.Token[:len(issued.Token)-2]+"AA").Code)
+
+	w = confirmPage(issued.Token)
+	require.Equal(t, http.StatusOK, w.Code)
+	cookie := csrfCookie(t, w)
+	form := url.Values{"token": {issued.Token}, csrfFieldName: {cookie.Value}}
+	assert.Equal(t, http.StatusOK, postForm(t, "/subscriptions/confirm", form, cookie).Code)
+	subscribeService.AssertCalled(t, "Activate", mailbus.DefaultList, email)
+
+	// the token cannot be used again
+	assert.Equal(t, http.StatusConflict, postForm(t, "/subscriptions/confirm", form, cookie).Code)
+	subscribeService.AssertNumberOfCalls(t, "Activate", 1)
 }
 
 func TestUnsubscribeHandler(t *testing.T) {
 	email := "foo@gmail.com"
-	secret := cfg.Newsletter.HMAC.Secret
-	hashValue, err := hash.ComputeHmac256(email, secret)
+	// a link signed over the address alone, with the secret used before keys
+	hashValue, err := hash.ComputeHmac256(email, cfg.Newsletter.HMAC.Secret)
 	require.NoError(t, err)
 
+	listService := new(mock.ListService)
+	listService.On("FindByName", mailbus.DefaultList).Return(&mailbus.List{Name: mailbus.DefaultList}, nil)
+	s.ListService = listService
+
 	subscriptionService := new(mock.SubscriptionService)
-	subscriptionService.On("Unsubscribe", email).Return(nil)
+	subscriptionService.On("Unsubscribe", mailbus.DefaultList, email).Return(nil)
 
 	s.SubscriptionService = subscriptionService
 
 	newsletterService := new(mock.NewsletterService)
-	newsletterService.On("GetHMACSecret").Return(secret)
+	newsletterService.On("GetKeyring").Return(keyring)
 	s.NewsletterService = newsletterService
 
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/unsubscribe?email=%s&hash=%s", email, hashValue), nil)
+	auditService := new(mock.AuditService)
+	auditService.On("Record", testifymock.AnythingOfType("*mailbus.AuditEvent")).Return(nil)
+	s.AuditService = auditService
+
+	link := "/unsubscribe?" + url.Values{"email": {email}, "hash": {hashValue}}.Encode()
+	req, err := http.NewRequest(http.MethodGet, link, nil)
 	assert.NoError(t, err)
 
 	w := httptest.NewRecorder()
 	s.router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
+	subscriptionService.AssertNotCalled(t, "Unsubscribe", mailbus.DefaultList, email)
+
+	cookie := csrfCookie(t, w)
+	w = postForm(t, link, url.Values{csrfFieldName: {cookie.Value}}, cookie)
+	assert.Equal(t, http.StatusOK, w.Code)
+	subscriptionService.AssertExpectations(t)
+	auditService.AssertExpectations(t)
+
+	req, err = http.NewRequest(http.MethodGet, "/unsubscribe?"+url.Values{"email": {email}, "hash": {"forged"}}.Encode(), nil)
+	require.NoError(t, err)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "Invalid unsubscribe link.")
+}
+
+// csrfCookie returns the CSRF cookie set by a page
+func csrfCookie(t *testing.T, w *httptest.ResponseRecorder) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == csrfCookieName {
+			return c
+		}
+	}
+	t.Fatal("no CSRF cookie")
+	return nil
+}
+
+func postForm(t *testing.T, target string, form url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(cookie)
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestOneClickUnsubscribeHandler(t *testing.T) {
+	email := "foo@gmail.com"
+	unsubscribeURL, err := mailbus.UnsubscribeURL("", keyring, "go", email, 3, time.Time{})
+	require.NoError(t, err)
+
+	listService := new(mock.ListService)
+	listService.On("FindByName", "go").Return(&mailbus.List{Name: "go"}, nil)
+	s.ListService = listService
+
+	subscriptionService := new(mock.SubscriptionService)
+	subscriptionService.On("Unsubscribe", "go", email).Return(nil)
+	s.SubscriptionService = subscriptionService
+
+	newsletterService := new(mock.NewsletterService)
+	newsletterService.On("GetKeyring").Return(keyring)
+	s.NewsletterService = newsletterService
+
+	auditService := new(mock.AuditService)
+	auditService.On("Record", testifymock.MatchedBy(func(e *mailbus.AuditEvent) bool {
+		return e.Action == mailbus.AuditActionUnsubscribe && e.Details == "one-click"
+	})).Return(nil)
+	s.AuditService = auditService
+
+	deliveryService := new(mock.DeliveryService)
+	deliveryService.On("Find", mailbus.DeliveryFilter{CampaignID: 3, Email: email}).
+		Return([]mailbus.Delivery{{ID: 7, CampaignID: 3, SubscriberID: 5, Email: email}}, nil)
+	s.DeliveryService = deliveryService
+
+	trackingService := new(mock.TrackingService)
+	trackingService.On("Record", testifymock.MatchedBy(func(e *mailbus.TrackingEvent) bool {
+		return e.Type == mailbus.TrackingEventUnsubscribe && e.DeliveryID == 7 && e.CampaignID == 3
+	})).Return(nil)
+	s.TrackingService = trackingService
+
+	post := func(body string) int {
+		req, err := http.NewRequest(http.MethodPost, unsubscribeURL, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// without the one-click body, the request has to come from the unsubscribe page
+	assert.Equal(t, http.StatusForbidden, post(""))
+	subscriptionService.AssertNotCalled(t, "Unsubscribe", "go", email)
+
+	assert.Equal(t, http.StatusOK, post("List-Unsubscribe=One-Click"))
+	subscriptionService.AssertExpectations(t)
+	// the unsubscribe is attributed to the campaign the link was sent in
+	trackingService.AssertExpectations(t)
+}
+
+func TestUnsubscribeHandlerKeyRotation(t *testing.T) {
+	email := "bar@gmail.com"
+	previous, err := hash.NewKeyring(hash.Key{ID: "1", Secret: strings.Repeat("1", hash.MinSecretLength)})
+	require.NoError(t, err)
+	rotated, err := hash.NewKeyring(hash.Key{ID: "2", Secret: strings.Repeat("2", hash.MinSecretLength)},
+		hash.Key{ID: "1", Secret: strings.Repeat("1", hash.MinSecretLength)})
+	require.NoError(t, err)
+
+	listService := new(mock.ListService)
+	listService.On("FindByName", "go").Return(&mailbus.List{Name: "go"}, nil)
+	s.ListService = listService
+
+	newsletterService := new(mock.NewsletterService)
+	s.NewsletterService = newsletterService
+
+	get := func(keyring *hash.Keyring, link string) int {
+		newsletterService.ExpectedCalls = nil
+		newsletterService.On("GetKeyring").Return(keyring)
+
+		req, err := http.NewRequest(http.MethodGet, link, nil)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// links signed with the previous key keep working until it is retired
+	link, err := mailbus.UnsubscribeURL("", previous, "go", email, 0, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, get(rotated, link))
+	assert.Equal(t, http.StatusBadRequest, get(keyring, link))
+
+	expired, err := mailbus.UnsubscribeURL("", rotated, "go", email, 0, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusGone, get(rotated, expired))
+
+	// the expiry is signed: pushing it back breaks the signature
+	u, err := url.Parse(expired)
+	require.NoError(t, err)
+	query := u.Query()
+	query.Set("expires", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+	assert.Equal(t, http.StatusBadRequest, get(rotated, "/unsubscribe?"+query.Encode()))
+}
+
+// apiKey issues an API key granted the scopes, that the server knows of
+func apiKey(t *testing.T, scopes ...string) string {
+	k, key, err := mailbus.NewAPIKey(t.Name(), scopes)
+	require.NoError(t, err)
+	apiKeyService := new(mock.APIKeyService)
+	apiKeyService.On("FindByPrefix", k.Prefix).Return(k, nil)
+	s.APIKeyService = apiKeyService
+	return key
+}
+
+// apiRequest sends a request to the admin API, authenticated with the key
+func apiRequest(t *testing.T, method, target, key string, body io.Reader) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, target, body)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+key)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCreateCampaignHandler(t *testing.T) {
+	listService := new(mock.ListService)
+	listService.On("FindByName", "go").Return(&mailbus.List{Name: "go"}, nil)
+	s.ListService = listService
+
+	campaignService := new(mock.CampaignService)
+	campaignService.On("Create", testifymock.AnythingOfType("*mailbus.Campaign")).Return(nil)
+	s.CampaignService = campaignService
+
+	data, err := json.Marshal(&mailbus.CampaignRequest{
+		List:    "go",
+		Subject: "Issue #1",
+		Body:    "<p>Hello</p>",
+	})
+	require.NoError(t, err)
+	w := apiRequest(t, http.MethodPost, "/api/v1/campaigns", apiKey(t, mailbus.ScopeCampaignsWrite), bytes.NewReader(data))
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var c mailbus.Campaign
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&c))
+	assert.Equal(t, "go", c.List)
+	assert.Equal(t, mailbus.CampaignStatusDraft, c.Status)
+	campaignService.AssertExpectations(t)
+}
+
+// queueService is a queue holding the messages it was given
+type queueService [][]byte
+
+func (q queueService) Consume(ctx context.Context, topic string) (<-chan []byte, error) {
+	messages := make(chan []byte, len(q))
+	for _, msg := range q {
+		messages <- msg
+	}
+	close(messages)
+	return messages, nil
+}
+
+func TestConsumeNewsletterRequests(t *testing.T) {
+	campaignService := new(mock.CampaignService)
+	campaignService.On("Create", testifymock.MatchedBy(func(c *mailbus.Campaign) bool {
+		return c.Subject == "Down"
+	})).Return(errors.New("database is locked")).Once()
+	campaignService.On("Create", testifymock.MatchedBy(func(c *mailbus.Campaign) bool {
+		return c.Subject == "Up" && c.List == mailbus.DefaultList && c.Status == mailbus.CampaignStatusScheduled
+	})).Return(nil).Once()
+	s.CampaignService = campaignService
+	s.QueueService = queueService{
+		[]byte(`not json`),
+		[]byte(`{"subject": "Down", "body": "<p>Hello</p>"}`),
+		[]byte(`{"subject": "Up", "body": "<p>Hello</p>"}`),
+	}
+
+	// a request that fails does not stop the ones after it
+	scheduleAt := func(now time.Time) time.Time { return now.Add(time.Hour) }
+	require.NoError(t, s.ConsumeNewsletterRequests(context.Background(), scheduleAt))
+	campaignService.AssertExpectations(t)
+}
+
+func TestUpdateListTracking(t *testing.T) {
+	listService := new(mock.ListService)
+	listService.On("FindByName", "go").Return(&mailbus.List{Name: "go", Title: "Go", TrackClicks: true}, nil)
+	listService.On("Update", &mailbus.List{Name: "go", Title: "Go", TrackOpens: true, TrackClicks: true}).Return(nil).Once()
+	s.ListService = listService
+
+	auditService := new(mock.AuditService)
+	auditService.On("Record", testifymock.AnythingOfType("*mailbus.AuditEvent")).Return(nil)
+	s.AuditService = auditService
+
+	// the tracking left out of the request is kept
+	w := apiRequest(t, http.MethodPatch, "/api/v1/lists/go", apiKey(t, mailbus.ScopeListsWrite), strings.NewReader(`{"track_opens": true}`))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var l mailbus.List
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &l))
+	assert.True(t, l.TrackOpens)
+	assert.True(t, l.TrackClicks)
+	listService.AssertExpectations(t)
+}
+
+func TestCancelCampaignHandler(t *testing.T) {
+	campaignService := new(mock.CampaignService)
+	campaignService.On("FindByID", 1).Return(&mailbus.Campaign{ID: 1, Status: mailbus.CampaignStatusScheduled}, nil)
+	campaignService.On("FindByID", 2).Return(&mailbus.Campaign{ID: 2, Status: mailbus.CampaignStatusSent}, nil)
+	campaignService.On("Update", testifymock.AnythingOfType("*mailbus.Campaign")).Return(nil)
+	s.CampaignService = campaignService
+
+	auditService := new(mock.AuditService)
+	auditService.On("Record", testifymock.MatchedBy(func(e *mailbus.AuditEvent) bool {
+		return e.Action == mailbus.AuditActionCampaignCancel && e.Target == "1"
+	})).Return(nil).Once()
+	s.AuditService = auditService
+
+	key := apiKey(t, mailbus.ScopeCampaignsSend)
+	assert.Equal(t, http.StatusOK, apiRequest(t, http.MethodPost, "/api/v1/campaigns/1/cancel", key, nil).Code)
+	assert.Equal(t, http.StatusConflict, apiRequest(t, http.MethodPost, "/api/v1/campaigns/2/cancel", key, nil).Code)
+	auditService.AssertExpectations(t)
+
+	// the campaigns are not managed outside of the admin API
+	req, err := http.NewRequest(http.MethodPost, "/campaigns/1/cancel", nil)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDeliveriesHandler(t *testing.T) {
+	email := "alice@example.com"
+	deliveryService := new(mock.DeliveryService)
+	deliveryService.On("Find", mailbus.DeliveryFilter{CampaignID: 3, Email: email}).Return([]mailbus.Delivery{
+		{ID: 1, CampaignID: 3, Email: email, Status: mailbus.DeliveryStatusSent, SMTPCode: 250, Attempts: 1},
+	}, nil)
+	s.DeliveryService = deliveryService
+
+	target := fmt.Sprintf("/api/v1/campaigns/3/deliveries?email=%s", email)
+	w := apiRequest(t, http.MethodGet, target, "", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = apiRequest(t, http.MethodGet, target, apiKey(t, mailbus.ScopeCampaignsRead), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var deliveries []mailbus.Delivery
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&deliveries))
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, mailbus.DeliveryStatusSent, deliveries[0].Status)
+}
+
+func TestCreateSuppressionHandler(t *testing.T) {
+	suppressionService := new(mock.SuppressionService)
+	suppressionService.On("Create", testifymock.AnythingOfType("*mailbus.Suppression")).Return(nil)
+	s.SuppressionService = suppressionService
+
+	auditService := new(mock.AuditService)
+	auditService.On("Record", testifymock.MatchedBy(func(e *mailbus.AuditEvent) bool {
+		return e.Action == mailbus.AuditActionSuppressionCreate && strings.HasPrefix(e.Actor, "api-key:")
+	})).Return(nil).Twice()
+	s.AuditService = auditService
+
+	key := apiKey(t, mailbus.ScopeSuppressionsWrite)
+	data, err := json.Marshal(&mailbus.SuppressionRequest{Value: "@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, apiRequest(t, http.MethodPost, "/api/v1/suppressions", "", bytes.NewReader(data)).Code)
+
+	for _, tt := range []struct {
+		value      string
+		wantStatus int
+	}{
+		{"@Example.com", http.StatusCreated},
+		{"Alice@Example.com", http.StatusCreated},
+		{"not a domain", http.StatusBadRequest},
+	} {
+		data, err := json.Marshal(&mailbus.SuppressionRequest{Value: tt.value, Reason: "requested by the owner"})
+		require.NoError(t, err)
+		w := apiRequest(t, http.MethodPost, "/api/v1/suppressions", key, bytes.NewReader(data))
+		assert.Equal(t, tt.wantStatus, w.Code, tt.value)
+	}
+
+	suppressionService.AssertCalled(t, "Create", testifymock.MatchedBy(func(s *mailbus.Suppression) bool {
+		return s.Value == "example.com" && s.IsDomain() && s.Source == mailbus.SuppressionSourceManual
+	}))
+	suppressionService.AssertNumberOfCalls(t, "Create", 2)
+	auditService.AssertExpectations(t)
+}
+
+func TestOpenHandler(t *testing.T) {
+	smtpService := new(mock.NewsletterService)
+	smtpService.On("GetKeyring").Return(keyring)
+
+	delivery := mailbus.Delivery{ID: 7, CampaignID: 3, SubscriberID: 5, SentAt: time.Now().Add(-time.Hour)}
+	deliveryService := new(mock.DeliveryService)
+	deliveryService.On("Find", mailbus.DeliveryFilter{CampaignID: 3, SubscriberID: 5}).Return([]mailbus.Delivery{delivery}, nil)
+
+	var events []*mailbus.TrackingEvent
+	trackingService := new(mock.TrackingService)
+	trackingService.On("Record", testifymock.AnythingOfType("*mailbus.TrackingEvent")).Run(func(args testifymock.Arguments) {
+		events = append(events, args.Get(0).(*mailbus.TrackingEvent))
+	}).Return(nil)
+
+	s.NewsletterService = smtpService
+	s.DeliveryService = deliveryService
+	s.TrackingService = trackingService
+	s.OpenHeuristics = mailbus.OpenHeuristics{
+		MinDelay:        10 * time.Second,
+		ProxyUserAgents: []string{"Mozilla/5.0"},
+		UserAgents:      []string{"Barracuda"},
+	}
+
+	link, err := mailbus.OpenURL("", keyring, 3, 5)
+	require.NoError(t, err)
+
+	open := func(target, userAgent string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, target, nil)
+		require.NoError(t, err)
+		req.Header.Set("User-Agent", userAgent)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := open(link, "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/gif", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Cache-Control"), "no-store")
+	assert.Equal(t, pixel, w.Body.Bytes())
+
+	open(link, "Mozilla/5.0")
+	open(link, "Barracuda Sentinel (EE)")
+
+	require.Len(t, events, 3)
+	for i, want := range []string{"", "privacy proxy", "machine user agent"} {
+		assert.Equal(t, mailbus.TrackingEventOpen, events[i].Type)
+		assert.Equal(t, 7, events[i].DeliveryID)
+		assert.Equal(t, want != "", events[i].Machine)
+		assert.Equal(t, want, events[i].Reason)
+	}
+
+	// an open right after delivery is made by a machine, whatever its user agent
+	delivery.SentAt = time.Now()
+	deliveryService.ExpectedCalls = nil
+	deliveryService.On("Find", mailbus.DeliveryFilter{CampaignID: 3, SubscriberID: 5}).Return([]mailbus.Delivery{delivery}, nil)
+	open(link, "Mozilla/5.0 (Windows NT 10.0; Win64; x64)")
+	require.Len(t, events, 4)
+	assert.Equal(t, "too soon after delivery", events[3].Reason)
+
+	// a link signed for another subscriber is refused
+	w = open(strings.Replace(link, "subscriber=5", "subscriber=6", 1), "Mozilla/5.0")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Len(t, events, 4)
+}
+
+func TestClickHandler(t *testing.T) {
+	smtpService := new(mock.NewsletterService)
+	smtpService.On("GetKeyring").Return(keyring)
+
+	deliveryService := new(mock.DeliveryService)
+	deliveryService.On("Find", mailbus.DeliveryFilter{CampaignID: 3, SubscriberID: 5}).
+		Return([]mailbus.Delivery{{ID: 7, CampaignID: 3, SubscriberID: 5}}, nil)
+
+	var events []*mailbus.TrackingEvent
+	trackingService := new(mock.TrackingService)
+	trackingService.On("Record", testifymock.AnythingOfType("*mailbus.TrackingEvent")).Run(func(args testifymock.Arguments) {
+		events = append(events, args.Get(0).(*mailbus.TrackingEvent))
+	}).Return(nil)
+
+	s.NewsletterService = smtpService
+	s.DeliveryService = deliveryService
+	s.TrackingService = trackingService
+
+	link, err := mailbus.ClickURL("", keyring, 3, 5, "https://example.com/posts/1")
+	require.NoError(t, err)
+
+	click := func(target string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, target, nil)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := click(link)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://example.com/posts/1", w.Header().Get("Location"))
+	require.Len(t, events, 1)
+	assert.Equal(t, mailbus.TrackingEventClick, events[0].Type)
+	assert.Equal(t, 7, events[0].DeliveryID)
+	assert.Equal(t, "https://example.com/posts/1", events[0].URL)
+
+	// the target is signed: the link cannot be used to redirect anywhere else
+	tampered := strings.Replace(link, url.QueryEscape("https://example.com/posts/1"), url.QueryEscape("https://evil.example.com"), 1)
+	require.NotEqual(t, link, tampered)
+	w = click(tampered)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+
+	// nor to a target that is not a web page, even a signed one
+	link, err = mailbus.ClickURL("", keyring, 3, 5, "javascript:alert(1)")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, click(link).Code)
+	assert.Len(t, events, 1)
+}
+
+func TestLinksHandler(t *testing.T) {
+	campaignService := new(mock.CampaignService)
+	campaignService.On("FindByID", 3).Return(&mailbus.Campaign{ID: 3}, nil)
+
+	trackingService := new(mock.TrackingService)
+	trackingService.On("Find", mailbus.TrackingFilter{CampaignID: 3, Type: mailbus.TrackingEventClick}).Return([]mailbus.TrackingEvent{
+		{Type: mailbus.TrackingEventClick, SubscriberID: 1, URL: "https://example.com/a"},
+		{Type: mailbus.TrackingEventClick, SubscriberID: 2, URL: "https://example.com/b"},
+		{Type: mailbus.TrackingEventClick, SubscriberID: 2, URL: "https://example.com/b"},
+		{Type: mailbus.TrackingEventClick, SubscriberID: 1, URL: "https://example.com/b"},
+	}, nil)
+
+	s.CampaignService = campaignService
+	s.TrackingService = trackingService
+
+	w := apiRequest(t, http.MethodGet, "/api/v1/campaigns/3/links", apiKey(t, mailbus.ScopeCampaignsRead), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var links []mailbus.LinkClicks
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&links))
+	assert.Equal(t, []mailbus.LinkClicks{
+		{URL: "https://example.com/b", Total: 3, Unique: 2},
+		{URL: "https://example.com/a", Total: 1, Unique: 1},
+	}, links)
+}
+
+func TestCampaignStatsHandler(t *testing.T) {
+	campaignService := new(mock.CampaignService)
+	campaignService.On("FindByID", 3).Return(&mailbus.Campaign{ID: 3}, nil)
+	campaignService.On("FindByID", 4).Return(nil, &mailbus.Error{Code: mailbus.ErrNotFound, Message: "Campaign not found."})
+
+	stats := mailbus.NewCampaignStats(3)
+	stats.CountDeliveries(mailbus.DeliveryStatusSent, 4, 0)
+	stats.CountEvents(mailbus.TrackingEventOpen, false, 3, 2)
+	stats.CountHourly(mailbus.TrackingEventOpen, time.Date(2026, 10, 1, 8, 30, 0, 0, time.UTC), 3)
+	stats.ComputeRates()
+	reportService := new(mock.ReportService)
+	reportService.On("CampaignStats", 3).Return(stats, nil)
+
+	s.CampaignService = campaignService
+	s.ReportService = reportService
+
+	key := apiKey(t, mailbus.ScopeCampaignsRead)
+	get := func(target string) *httptest.ResponseRecorder {
+		return apiRequest(t, http.MethodGet, target, key, nil)
+	}
+
+	w := get("/api/v1/campaigns/3/stats")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var got mailbus.CampaignStats
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	assert.Equal(t, 4, got.Delivered)
+	assert.Equal(t, 2, got.Opened)
+	assert.Equal(t, 0.5, got.Rates.Open)
+	require.Len(t, got.Hourly, 1)
+	assert.Equal(t, time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC), got.Hourly[0].Hour)
+	assert.Equal(t, 3, got.Hourly[0].Opens)
+
+	assert.Equal(t, http.StatusNotFound, get("/api/v1/campaigns/4/stats").Code)
+	assert.Equal(t, http.StatusUnauthorized, apiRequest(t, http.MethodGet, "/api/v1/campaigns/3/stats", "", nil).Code)
+}
+
+func TestAdminAPIAuthentication(t *testing.T) {
+	reader, readerKey, err := mailbus.NewAPIKey("reader", []string{mailbus.ScopeCampaignsRead})
+	require.NoError(t, err)
+	revoked, revokedKey, err := mailbus.NewAPIKey("revoked", mailbus.Scopes)
+	require.NoError(t, err)
+	revoked.RevokedAt = time.Now()
+
+	apiKeyService := new(mock.APIKeyService)
+	apiKeyService.On("FindByPrefix", reader.Prefix).Return(reader, nil)
+	apiKeyService.On("FindByPrefix", revoked.Prefix).Return(revoked, nil)
+	apiKeyService.On("FindByPrefix", testifymock.Anything).Return(nil, &mailbus.Error{Code: mailbus.ErrNotFound})
+	s.APIKeyService = apiKeyService
+
+	campaignService := new(mock.CampaignService)
+	campaignService.On("Find", mailbus.CampaignFilter{}).Return([]mailbus.Campaign{{ID: 3}}, nil)
+	s.CampaignService = campaignService
+
+	request := func(method, target, key string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, target, nil)
+		require.NoError(t, err)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request(http.MethodGet, "/api/v1/campaigns", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Bearer realm="mailbus"`, w.Header().Get("WWW-Authenticate"))
+
+	for _, key := range []string{"not-a-key", "mb_bbbbbbbbbbbb_secret", readerKey[:len(readerKey)-2] + "xx", revokedKey} {
+		assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/api/v1/campaigns", key).Code, key)
+	}
+
+	w = request(http.MethodGet, "/api/v1/campaigns", readerKey)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// reading campaigns does not allow sending them
+	w = request(http.MethodPost, "/api/v1/campaigns/3/schedule", readerKey)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	campaignService.AssertNotCalled(t, "FindByID", 3)
+}
+
+func TestAdminAPISubscribers(t *testing.T) {
+	admin, key, err := mailbus.NewAPIKey("admin", []string{mailbus.ScopeSubscribersRead, mailbus.ScopeSubscribersWrite})
+	require.NoError(t, err)
+	apiKeyService := new(mock.APIKeyService)
+	apiKeyService.On("FindByPrefix", admin.Prefix).Return(admin, nil)
+	s.APIKeyService = apiKeyService
+
+	listService := new(mock.ListService)
+	listService.On("FindByName", "go").Return(&mailbus.List{Name: "go"}, nil)
+	s.ListService = listService
+
+	email := "carol@example.com"
+	subscriptionService := new(mock.SubscriptionService)
+	subscriptionService.On("Find", mailbus.SubscriberFilter{List: "go", Status: mailbus.StatusActive, Query: "example", Limit: 10}).
+		Return([]mailbus.Subscriber{{ID: 1, Email: "alice@example.com", List: "go", Status: mailbus.StatusActive}}, nil)
+	subscriptionService.On("FindByEmail", "go", email).Return(nil, &mailbus.Error{Code: mailbus.ErrNotFound}).Once()
+	subscriptionService.On("Insert", testifymock.MatchedBy(func(sub *mailbus.Subscription) bool {
+		return sub.List == "go" && sub.Email == email && sub.Status == mailbus.StatusActive && sub.Token == "" && sub.Message == nil
+	})).Return(nil)
+	subscriptionService.On("FindByEmail", "go", email).Return(&mailbus.Subscriber{ID: 2, Email: email, List: "go", Status: mailbus.StatusActive}, nil)
+	subscriptionService.On("Delete", "go", email).Return(nil)
+	s.SubscriptionService = subscriptionService
+
+	auditService := new(mock.AuditService)
+	auditService.On("Record", testifymock.MatchedBy(func(e *mailbus.AuditEvent) bool {
+		return e.Actor == "api-key:"+admin.Prefix && e.Target == email && e.Details == "go"
+	})).Return(nil)
+	s.AuditService = auditService
+
+	request := func(method, target, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, target, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request(http.MethodGet, "/api/v1/lists/go/subscribers?status=active&q=example&limit=10", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var subscribers []mailbus.Subscriber
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&subscribers))
+	require.Len(t, subscribers, 1)
+	assert.Equal(t, "alice@example.com", subscribers[0].Email)
+
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodGet, "/api/v1/subscribers?status=gone", "").Code)
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodPost, "/api/v1/lists/go/subscribers", `{"email": "not an address"}`).Code)
+
+	w = request(http.MethodPost, "/api/v1/lists/go/subscribers", `{"email": "`+email+`"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	subscriptionService.AssertCalled(t, "Insert", testifymock.Anything)
+
+	// adding the same address again is a conflict
+	assert.Equal(t, http.StatusConflict, request(http.MethodPost, "/api/v1/lists/go/subscribers", `{"email": "`+email+`"}`).Code)
+
+	assert.Equal(t, http.StatusNoContent, request(http.MethodDelete, "/api/v1/lists/go/subscribers/"+email, "").Code)
+	subscriptionService.AssertCalled(t, "Delete", "go", email)
+	auditService.AssertNumberOfCalls(t, "Record", 2)
+}
+
+func TestAdminLogin(t *testing.T) {
+	admin, err := mailbus.NewAdmin("alice", "correct horse battery", mailbus.RoleOwner)
+	require.NoError(t, err)
+	admin.ID = 1
+
+	adminService := new(mock.AdminService)
+	adminService.On("FindByUsername", "alice").Return(admin, nil)
+	adminService.On("FindByUsername", testifymock.Anything).Return(nil, &mailbus.Error{Code: mailbus.ErrNotFound})
+	adminService.On("FindByID", admin.ID).Return(admin, nil)
+	s.AdminService = adminService
+
+	var session *mailbus.Session
+	sessionService := new(mock.SessionService)
+	sessionService.On("DeleteExpired", testifymock.Anything).Return(nil)
+	sessionService.On("Create", testifymock.Anything).Run(func(args testifymock.Arguments) {
+		session = args.Get(0).(*mailbus.Session)
+	}).Return(nil)
+	s.SessionService = sessionService
+
+	auditService := new(mock.AuditService)
+	auditService.On("Record", testifymock.Anything).Return(nil)
+	s.AuditService = auditService
+
+	subscriptionService := new(mock.SubscriptionService)
+	subscriptionService.On("Find", testifymock.Anything).
+		Return([]mailbus.Subscriber{{ID: 1, Email: "bob@example.com", List: "go", Status: mailbus.StatusActive}}, nil)
+	s.SubscriptionService = subscriptionService
+	listService := new(mock.ListService)
+	listService.On("FindAll").Return([]mailbus.List{{Name: "go"}}, nil)
+	s.ListService = listService
+
+	request := func(method, target string, form url.Values, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, target, strings.NewReader(form.Encode()))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request(http.MethodGet, "/admin/subscribers?q=bob", nil)
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/admin/login?next=%2Fadmin%2Fsubscribers%3Fq%3Dbob", w.Header().Get("Location"))
+
+	w = request(http.MethodGet, "/admin/login", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	csrf := csrfCookie(t, w)
+
+	form := url.Values{"username": {"alice"}, "password": {"correct horse battery"}, "next": {"/admin/campaigns"}}
+	assert.Equal(t, http.StatusForbidden, request(http.MethodPost, "/admin/login", form, csrf).Code)
+
+	form.Set(csrfFieldName, csrf.Value)
+	for _, credentials := range [][2]string{{"alice", "wrong password"}, {"mallory", "correct horse battery"}} {
+		wrong := url.Values{"username": {credentials[0]}, "password": {credentials[1]}, csrfFieldName: {csrf.Value}}
+		w = request(http.MethodPost, "/admin/login", wrong, csrf)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid username, password or code.")
+	}
+	auditService.AssertCalled(t, "Record", testifymock.MatchedBy(func(e *mailbus.AuditEvent) bool {
+		return e.Action == mailbus.AuditActionAdminLoginFailed && e.Target == "mallory"
+	}))
+
+	w = request(http.MethodPost, "/admin/login", form, csrf)
+	require.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/admin/campaigns", w.Header().Get("Location"))
+	require.NotNil(t, session)
+	assert.Equal(t, admin.ID, session.AdminID)
+
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == sessionCookieName {
+			cookie = c
+		}
+	}
+	require.NotNil(t, cookie)
+	assert.True(t, cookie.HttpOnly)
+	// only the hash of the token of the session is stored
+	assert.Equal(t, mailbus.SessionID(cookie.Value), session.ID)
+	sessionService.On("FindByID", session.ID).Return(session, nil)
+
+	w = request(http.MethodGet, "/admin/subscribers?q=bob", nil, cookie)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "bob@example.com")
+	assert.NotEmpty(t, w.Header().Get("Content-Security-Policy"))
+
+	// forms of the admin UI must carry the CSRF token
+	add := url.Values{"email": {"carol@example.com"}, "list": {"go"}}
+	assert.Equal(t, http.StatusForbidden, request(http.MethodPost, "/admin/subscribers", add, cookie, csrf).Code)
+	subscriptionService.AssertNotCalled(t, "Insert", testifymock.Anything)
+
+	// logging in does not send anywhere but to the admin UI
+	form.Set("next", "//evil.example.com/admin/")
+	w = request(http.MethodPost, "/admin/login", form, csrf)
+	assert.Equal(t, "/admin/subscribers", w.Header().Get("Location"))
+}
+
+func TestAdminSessionExpired(t *testing.T) {
+	session, token, err := mailbus.NewSession(1, -time.Minute)
+	require.NoError(t, err)
+
+	sessionService := new(mock.SessionService)
+	sessionService.On("FindByID", session.ID).Return(session, nil)
+	s.SessionService = sessionService
+
+	req, err := http.NewRequest(http.MethodGet, "/admin/campaigns", nil)
+	require.NoError(t, err)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: token})
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Location"), "/admin/login"))
+}
+
+func TestAdminLoginTwoFactor(t *testing.T) {
+	admin, err := mailbus.NewAdmin("alice", "correct horse battery", mailbus.RoleOwner)
+	require.NoError(t, err)
+	admin.ID = 1
+	admin.TOTPSecret, err = totp.NewSecret()
+	require.NoError(t, err)
+
+	adminService := new(mock.AdminService)
+	adminService.On("FindByUsername", "alice").Return(admin, nil)
+	adminService.On("Update", admin).Return(nil)
+	s.AdminService = adminService
+
+	sessionService := new(mock.SessionService)
+	sessionService.On("DeleteExpired", testifymock.Anything).Return(nil)
+	sessionService.On("Create", testifymock.Anything).Return(nil)
+	s.SessionService = sessionService
+
+	auditService := new(mock.AuditService)
+	auditService.On("Record", testifymock.Anything).Return(nil)
+	s.AuditService = auditService
+
+	req, err := http.NewRequest(http.MethodGet, "/admin/login", nil)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	csrf := csrfCookie(t, w)
+
+	code, err := totp.Code(admin.TOTPSecret, time.Now())
+	require.NoError(t, err)
+	form := url.Values{"username": {"alice"}, "password": {"correct horse battery"}, csrfFieldName: {csrf.Value}}
+
+	// the password alone is not enough
+	assert.Equal(t, http.StatusUnauthorized, postForm(t, "/admin/login", form, csrf).Code)
+	adminService.AssertNotCalled(t, "Update", testifymock.Anything)
+
+	form.Set("code", code)
+	w = postForm(t, "/admin/login", form, csrf)
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	adminService.AssertCalled(t, "Update", admin)
+
+	// a code is only accepted once
+	w = postForm(t, "/admin/login", form, csrf)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid username, password or code.")
+}
+
+func TestAdminRoles(t *testing.T) {
+	viewer, err := mailbus.NewAdmin("bob", "correct horse battery", mailbus.RoleViewer)
+	require.NoError(t, err)
+	viewer.ID = 2
+	editor, err := mailbus.NewAdmin("carol", "correct horse battery", mailbus.RoleEditor)
+	require.NoError(t, err)
+	editor.ID = 3
+
+	adminService := new(mock.AdminService)
+	adminService.On("FindByID", viewer.ID).Return(viewer, nil)
+	adminService.On("FindByID", editor.ID).Return(editor, nil)
+	s.AdminService = adminService
+
+	sessionService := new(mock.SessionService)
+	s.SessionService = sessionService
+	login := func(a *mailbus.Admin) *http.Cookie {
+		session, token, err := mailbus.NewSession(a.ID, time.Hour)
+		require.NoError(t, err)
+		sessionService.On("FindByID", session.ID).Return(session, nil)
+		return &http.Cookie{Name: sessionCookieName, Value: token}
+	}
+
+	listService := new(mock.ListService)
+	listService.On("FindAll").Return([]mailbus.List{{Name: "go"}}, nil)
+	s.ListService = listService
+
+	campaignService := new(mock.CampaignService)
+	campaignService.On("FindByID", 1).Return(&mailbus.Campaign{ID: 1, List: "go", Status: mailbus.CampaignStatusScheduled}, nil)
+	campaignService.On("Update", testifymock.AnythingOfType("*mailbus.Campaign")).Return(nil)
+	s.CampaignService = campaignService
+
+	auditService := new(mock.AuditService)
+	auditService.On("Record", testifymock.MatchedBy(func(e *mailbus.AuditEvent) bool {
+		return e.Actor == "admin:carol" && e.Action == mailbus.AuditActionCampaignCancel && e.Target == "1"
+	})).Return(nil).Once()
+	s.AuditService = auditService
+
+	request := func(method, target string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, target, nil)
+		require.NoError(t, err)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	bob := login(viewer)
+	w := request(http.MethodGet, "/admin/lists", bob)
+	require.Equal(t, http.StatusOK, w.Code)
+	csrf := csrfCookie(t, w)
+	// viewers are not shown what they cannot do
+	assert.NotContains(t, w.Body.String(), "Create a list")
+	assert.NotContains(t, w.Body.String(), "/admin/admins")
+
+	form := url.Values{csrfFieldName: {csrf.Value}}
+	for _, target := range []string{"/admin/lists", "/admin/campaigns/1/cancel"} {
+		req, err := http.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(bob)
+		req.AddCookie(csrf)
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code, target)
+	}
+	for _, target := range []string{"/admin/subscribers/export", "/admin/audit", "/admin/admins"} {
+		assert.Equal(t, http.StatusForbidden, request(http.MethodGet, target, bob).Code, target)
+	}
+	campaignService.AssertNotCalled(t, "Update", testifymock.Anything)
+
+	// editors send campaigns, and who did it is recorded
+	req, err := http.NewRequest(http.MethodPost, "/admin/campaigns/1/cancel", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(login(editor))
+	req.AddCookie(csrf)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	auditService.AssertExpectations(t)
+}
+
+func TestAuditHandler(t *testing.T) {
+	owner, err := mailbus.NewAdmin("alice", "correct horse battery", mailbus.RoleOwner)
+	require.NoError(t, err)
+	owner.ID = 1
+	viewer, err := mailbus.NewAdmin("bob", "correct horse battery", mailbus.RoleViewer)
+	require.NoError(t, err)
+	viewer.ID = 2
+	adminService := new(mock.AdminService)
+	adminService.On("FindByID", owner.ID).Return(owner, nil)
+	adminService.On("FindByID", viewer.ID).Return(viewer, nil)
+	adminService.On("FindByID", 3).Return(nil, &mailbus.Error{Code: mailbus.ErrNotFound})
+	s.AdminService = adminService
+
+	keys := make(map[int]string)
+	apiKeyService := new(mock.APIKeyService)
+	for _, adminID := range []int{owner.ID, viewer.ID, 3} {
+		k, key, err := mailbus.NewAPIKey("audit", []string{mailbus.ScopeAuditRead})
+		require.NoError(t, err)
+		k.AdminID = adminID
+		apiKeyService.On("FindByPrefix", k.Prefix).Return(k, nil)
+		keys[adminID] = key
+	}
+	s.APIKeyService = apiKeyService
+
+	auditService := new(mock.AuditService)
+	auditService.On("Find", mailbus.AuditFilter{
+		Action: mailbus.AuditActionCampaignSchedule,
+		Since:  time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Until:  time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+		Limit:  5,
+	}).Return([]mailbus.AuditEvent{{ID: 7, Actor: "admin:carol", Action: mailbus.AuditActionCampaignSchedule, Target: "1"}}, nil)
+	s.AuditService = auditService
+
+	request := func(target, key string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, target, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("/api/v1/audit?action=campaign.schedule&since=2026-01-01&until=2026-01-31&limit=5", keys[owner.ID])
+	require.Equal(t, http.StatusOK, w.Code)
+	var events []mailbus.AuditEvent
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&events))
+	require.Len(t, events, 1)
+	assert.Equal(t, "admin:carol", events[0].Actor)
+
+	assert.Equal(t, http.StatusBadRequest, request("/api/v1/audit?limit=5000", keys[owner.ID]).Code)
+	assert.Equal(t, http.StatusBadRequest, request("/api/v1/audit?since=yesterday", keys[owner.ID]).Code)
+	// keys can do no more than the role of their admin, and nothing once the admin is removed
+	assert.Equal(t, http.StatusForbidden, request("/api/v1/audit", keys[viewer.ID]).Code)
+	assert.Equal(t, http.StatusUnauthorized, request("/api/v1/audit", keys[3]).Code)
+}
+
+func TestExportSubscribersHandler(t *testing.T) {
+	k, key, err := mailbus.NewAPIKey("export", []string{mailbus.ScopeSubscribersExport})
+	require.NoError(t, err)
+	apiKeyService := new(mock.APIKeyService)
+	apiKeyService.On("FindByPrefix", k.Prefix).Return(k, nil)
+	s.APIKeyService = apiKeyService
+
+	listService := new(mock.ListService)
+	listService.On("FindByName", "go").Return(&mailbus.List{Name: "go"}, nil)
+	s.ListService = listService
+
+	subscribedAt := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	subscriptionService := new(mock.SubscriptionService)
+	subscriptionService.On("Find", mailbus.SubscriberFilter{List: "go", Status: mailbus.StatusActive, Limit: exportBatchSize}).
+		Return([]mailbus.Subscriber{{Email: "alice@example.com", List: "go", Status: mailbus.StatusActive, SubscribedAt: subscribedAt}}, nil)
+	s.SubscriptionService = subscriptionService
+
+	auditService := new(mock.AuditService)
+	auditService.On("Record", testifymock.MatchedBy(func(e *mailbus.AuditEvent) bool {
+		return e.Actor == "api-key:"+k.Prefix && e.Action == mailbus.AuditActionSubscribersExport && e.Target == "go"
+	})).Return(nil).Once()
+	s.AuditService = auditService
+
+	req, err := http.NewRequest(http.MethodGet, "/api/v1/subscribers/export?list=go&status=active", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+key)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "email,list,status,subscribed_at\nalice@example.com,go,active,2026-03-01T08:00:00Z\n", w.Body.String())
+	auditService.AssertExpectations(t)
+}
+
+func TestImportSubscribersHandler(t *testing.T) {
+	k, key, err := mailbus.NewAPIKey("import", []string{mailbus.ScopeSubscribersWrite})
+	require.NoError(t, err)
+	apiKeyService := new(mock.APIKeyService)
+	apiKeyService.On("FindByPrefix", k.Prefix).Return(k, nil)
+	s.APIKeyService = apiKeyService
+
+	listService := new(mock.ListService)
+	listService.On("FindByName", "go").Return(&mailbus.List{Name: "go"}, nil)
+	listService.On("FindByName", "rust").Return(nil, &mailbus.Error{Code: mailbus.ErrNotFound})
+	s.ListService = listService
+
+	suppressionService := new(mock.SuppressionService)
+	suppressionService.On("Match", "carol@spam.com").Return(&mailbus.Suppression{Value: "spam.com"}, nil)
+	suppressionService.On("Match", testifymock.Anything).Return(nil, nil)
+	s.SuppressionService = suppressionService
+
+	subscriptionService := new(mock.SubscriptionService)
+	subscriptionService.On("FindByEmail", "go", "alice@example.com").Return(nil, &mailbus.Error{Code: mailbus.ErrNotFound})
+	subscriptionService.On("FindByEmail", "go", "bob@example.com").
+		Return(&mailbus.Subscriber{Email: "bob@example.com", List: "go", Status: mailbus.StatusActive}, nil)
+	subscriptionService.On("FindByEmail", "go", "dave@example.com").
+		Return(&mailbus.Subscriber{Email: "dave@example.com", List: "go", Status: mailbus.StatusPendingConfirmation}, nil)
+	subscriptionService.On("Insert", testifymock.MatchedBy(func(s *mailbus.Subscription) bool {
+		return s.List == "go" && s.Email == "alice@example.com" && s.Status == mailbus.StatusActive
+	})).Return(nil).Once()
+	subscriptionService.On("Activate", "go", "dave@example.com").Return(&mailbus.Subscriber{}, nil).Once()
+	s.SubscriptionService = subscriptionService
+
+	auditService := new(mock.AuditService)
+	auditService.On("Record", testifymock.MatchedBy(func(e *mailbus.AuditEvent) bool {
+		return e.Action == mailbus.AuditActionSubscribersImport && e.Target == "go" &&
+			e.Details == "consent=confirmed rows=7 accepted=2 skipped=3 invalid=2"
+	})).Return(nil).Once()
+	s.AuditService = auditService
+	s.NewsletterService = new(mock.NewsletterService)
+
+	body := "E-mail Address,Name\n" +
+		"Alice <Alice@Example.com>,Alice\n" +
+		"bob@example.com,Bob\n" +
+		"alice@example.com,Alice again\n" +
+		"carol@spam.com,Carol\n" +
+		"dave@example.com,Dave\n" +
+		"not an address,Eve\n" +
+		",Frank\n"
+	req, err := http.NewRequest(http.MethodPost, "/api/v1/subscribers/import?list=go&consent=confirmed&map=email%3DE-mail+Address",
+		strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+key)
+	req.Header.Set("Content-Type", "text/csv; charset=utf-8")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var report mailbus.ImportReport
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+	assert.Equal(t, 7, report.Rows)
+	assert.Equal(t, 2, report.Accepted)
+	assert.Equal(t, []mailbus.ImportIssue{
+		{Line: 3, Email: "bob@example.com", List: "go", Result: mailbus.ImportSkipped, Reason: "Already active."},
+		{Line: 4, Email: "alice@example.com", List: "go", Result: mailbus.ImportSkipped, Reason: "Duplicate of line 2."},
+		{Line: 5, Email: "carol@spam.com", List: "go", Result: mailbus.ImportSkipped, Reason: "Suppressed by spam.com."},
+		{Line: 7, Email: "not an address", List: "go", Result: mailbus.ImportInvalid, Reason: "Invalid email address."},
+		{Line: 8, List: "go", Result: mailbus.ImportInvalid, Reason: "Missing email address."},
+	}, report.Issues)
+	subscriptionService.AssertExpectations(t)
+	auditService.AssertExpectations(t)
+
+	// JSON lines naming a list that does not exist, checked without importing anything
+	req, err = http.NewRequest(http.MethodPost, "/api/v1/subscribers/import?consent=double_opt_in&dry_run=true",
+		strings.NewReader(`{"email":"alice@example.com","list":"rust"}`+"\n"))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+key)
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	report = mailbus.ImportReport{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 1, report.Invalid)
+	assert.Equal(t, `Unknown list "rust".`, report.Issues[0].Reason)
+
+	for _, tc := range []struct {
+		query, contentType string
+		status             int
+	}{
+		{"consent=confirmed", "application/json", http.StatusUnsupportedMediaType},
+		{"", "text/csv", http.StatusBadRequest},
+		{"consent=confirmed&map=phone%3DPhone", "text/csv", http.StatusBadRequest},
+	} {
+		req, err := http.NewRequest(http.MethodPost, "/api/v1/subscribers/import?"+tc.query, strings.NewReader("email\nalice@example.com\n"))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+key)
+		req.Header.Set("Content-Type", tc.contentType)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		assert.Equal(t, tc.status, w.Code, tc.query)
+	}
 }
@@ -0,0 +1,56 @@
+// Enhancements of the admin UI, which works without them
+(function () {
+    'use strict';
+
+    document.addEventListener('DOMContentLoaded', function () {
+        // live preview of the body of campaigns
+        document.querySelectorAll('textarea[data-preview]').forEach(function (textarea) {
+            var frame = document.getElementById(textarea.dataset.preview);
+            var timer;
+            textarea.addEventListener('input', function () {
+                clearTimeout(timer);
+                timer = setTimeout(function () {
+                    frame.srcdoc = textarea.value;
+                }, 300);
+            });
+        });
+
+        document.querySelectorAll('form[data-confirm]').forEach(function (form) {
+            form.addEventListener('submit', function (event) {
+                if (!window.confirm(form.dataset.confirm)) {
+                    event.preventDefault();
+                }
+            });
+        });
+
+        // come back to the same page, filters included, after submitting a form
+        document.querySelectorAll('input[data-back-field]').forEach(function (input) {
+            input.value = location.pathname + location.search;
+        });
+
+        document.querySelectorAll('a[data-back]').forEach(function (link) {
+            link.addEventListener('click', function (event) {
+                if (history.length > 1) {
+                    event.preventDefault();
+                    history.back();
+                }
+            });
+        });
+
+        // campaigns are scheduled in the time zone of the browser, the server is sent RFC 3339
+        document.querySelectorAll('form[data-schedule]').forEach(function (form) {
+            form.addEventListener('submit', function () {
+                var input = form.querySelector('input[name="scheduled_at"]');
+                if (!input || !input.value) {
+                    return;
+                }
+                var hidden = document.createElement('input');
+                hidden.type = 'hidden';
+                hidden.name = 'scheduled_at';
+                hidden.value = new Date(input.value).toISOString();
+                input.removeAttribute('name');
+                form.appendChild(hidden);
+            });
+        });
+    });
+})();
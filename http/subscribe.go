@@ -1,11 +1,10 @@
 package http
 
 import (
-	"database/sql"
 	"encoding/json"
 	"net/http"
+	"time"
 
-	"github.com/pkg/errors"
 	"github.com/rs/zerolog/hlog"
 
 	"github.com/quantonganh/mailbus"
@@ -16,20 +15,25 @@ func (s *Server) subscriptionsHandler(w http.ResponseWriter, r *http.Request) er
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		return err
 	}
-	email := req.Email
+	email := mailbus.NormalizeEmail(req.Email)
 
-	token := s.NewsletterService.GenerateNewUUID()
-	newSubscription := mailbus.NewSubscription(email, mailbus.StatusPendingConfirmation, token)
+	list, err := s.findList(r, req.List)
+	if err != nil {
+		return err
+	}
 
-	logger := hlog.FromRequest(r)
-	subscribe, err := s.SubscriptionService.FindByEmail(email)
+	newSubscription, err := s.confirmer().NewSubscription(list.Name, email)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			logger.Info().Msg("Sending confirmation email")
-			if err := s.NewsletterService.SendConfirmationEmail(email, req.URL, token); err != nil {
-				return err
-			}
+		return err
+	}
+	// the confirmation email is saved along with the subscription and sent by the outbox relay,
+	// so there is never an email without a subscription, nor a subscription without an email
+	newSubscription.Message = mailbus.NewConfirmationMessage(email, req.URL, newSubscription.Token)
 
+	logger := hlog.FromRequest(r)
+	subscribe, err := s.SubscriptionService.FindByEmail(list.Name, email)
+	if err != nil {
+		if mailbus.ErrorCode(err) == mailbus.ErrNotFound {
 			logger.Info().Msgf("Saving new subscriber %+v into the database", newSubscription)
 			if err := s.SubscriptionService.Insert(newSubscription); err != nil {
 				return err
@@ -37,22 +41,28 @@ func (s *Server) subscriptionsHandler(w http.ResponseWriter, r *http.Request) er
 
 			w.WriteHeader(http.StatusOK)
 		} else {
-			w.WriteHeader(http.StatusNotFound)
+			return err
 		}
 	} else {
 		logger.Info().Msgf("Found subscriber %+v in the database", subscribe)
 		switch subscribe.Status {
 		case mailbus.StatusPendingConfirmation:
-			w.WriteHeader(http.StatusUnauthorized)
-		case mailbus.StatusActive:
-			w.WriteHeader(http.StatusConflict)
-		default:
-			if err := s.NewsletterService.SendConfirmationEmail(email, req.URL, token); err != nil {
+			// the confirmation email may have been lost, or its token may have expired: send a new one, but not too often
+			if s.ConfirmationPolicy.CoolingDown(subscribe, time.Now()) {
+				return NewError(nil, http.StatusTooManyRequests, "A confirmation email was sent recently, please check your inbox.")
+			}
+
+			logger.Info().Msg("Reissuing the confirmation token")
+			if err := s.SubscriptionService.Update(newSubscription); err != nil {
 				return err
 			}
 
+			w.WriteHeader(http.StatusOK)
+		case mailbus.StatusActive:
+			w.WriteHeader(http.StatusConflict)
+		default:
 			logger.Info().Msgf("Updating status to %s", mailbus.StatusPendingConfirmation)
-			if err := s.SubscriptionService.Update(email, token); err != nil {
+			if err := s.SubscriptionService.Update(newSubscription); err != nil {
 				return err
 			}
 
@@ -63,24 +73,76 @@ func (s *Server) subscriptionsHandler(w http.ResponseWriter, r *http.Request) er
 	return nil
 }
 
+// confirmHandler renders the confirmation page. It does not confirm anything by itself, because
+// link scanners and mail prefetchers follow the link in the confirmation email without anyone clicking it.
 func (s *Server) confirmHandler(w http.ResponseWriter, r *http.Request) error {
 	token := r.URL.Query().Get("token")
 	if len(token) == 0 {
-		return errors.New("token is not present")
+		return NewError(nil, http.StatusBadRequest, "Token is not present.")
 	}
 
-	email, err := s.SubscriptionService.Confirm(token)
-	if mailbus.ErrorCode(err) == mailbus.ErrNotFound {
-		return NewError(err, http.StatusNotFound, "Token not found.")
-	} else if err != nil {
+	// signed tokens are checked right away, stored ones when they are used
+	if err := s.confirmer().Verify(token); err != nil {
+		return confirmationError(err)
+	}
+
+	csrfToken, err := s.csrfToken(w, r)
+	if err != nil {
 		return err
 	}
 
-	if err := s.NewsletterService.SendThankYouEmail(email); err != nil {
+	return s.render(w, http.StatusOK, "confirm", page{
+		Title:     "Confirm your subscription",
+		Token:     token,
+		CSRFToken: csrfToken,
+	})
+}
+
+// confirmSubscriptionHandler confirms a subscription when the button of the confirmation page is pressed
+func (s *Server) confirmSubscriptionHandler(w http.ResponseWriter, r *http.Request) error {
+	if err := verifyCSRF(r); err != nil {
 		return err
 	}
 
-	w.WriteHeader(http.StatusOK)
+	token := r.PostFormValue("token")
+	if len(token) == 0 {
+		return NewError(nil, http.StatusBadRequest, "Token is not present.")
+	}
+
+	subscriber, err := s.confirmer().Confirm(token)
+	if err != nil {
+		return confirmationError(err)
+	}
+	s.audit(r, mailbus.NewAuditEvent(subscriber.Email, mailbus.AuditActionSubscriptionConfirm, subscriber.List, ""))
 
-	return nil
+	if err := s.NewsletterService.SendThankYouEmail(subscriber.Email); err != nil {
+		return err
+	}
+
+	return s.render(w, http.StatusOK, "message", page{
+		Title:   "Subscription confirmed",
+		Message: "Thank you, you will receive our next emails.",
+	})
+}
+
+// confirmer returns the confirmer of subscriptions, with signed tokens if the server has a signer
+func (s *Server) confirmer() *mailbus.Confirmer {
+	return &mailbus.Confirmer{
+		SubscriptionService: s.SubscriptionService,
+		Policy:              s.ConfirmationPolicy,
+		Signer:              s.TokenSigner,
+		NewToken:            s.NewsletterService.GenerateNewUUID,
+	}
+}
+
+// confirmationError converts the error of a confirmation token into a client error
+func confirmationError(err error) error {
+	switch mailbus.ErrorCode(err) {
+	case mailbus.ErrNotFound:
+		return NewError(err, http.StatusNotFound, "Token not found.")
+	case mailbus.ErrInvalid:
+		return NewError(err, http.StatusGone, mailbus.ErrorMessage(err))
+	default:
+		return FromError(err)
+	}
 }
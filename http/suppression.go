@@ -0,0 +1,53 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/quantonganh/mailbus"
+)
+
+func (s *Server) suppressionsHandler(w http.ResponseWriter, r *http.Request) error {
+	suppressions, err := s.SuppressionService.FindAll()
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, suppressions)
+}
+
+// createSuppressionHandler adds an address or a domain to the suppression list
+func (s *Server) createSuppressionHandler(w http.ResponseWriter, r *http.Request) error {
+	var req mailbus.SuppressionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return NewError(err, http.StatusBadRequest, "Invalid JSON body.")
+	}
+
+	suppression, err := mailbus.NewSuppression(req.Value, mailbus.SuppressionSourceManual, req.Reason)
+	if err != nil {
+		return FromError(err)
+	}
+
+	if err := s.SuppressionService.Create(suppression); err != nil {
+		return FromError(err)
+	}
+
+	hlog.FromRequest(r).Info().Str("value", suppression.Value).Msg("suppressed")
+	s.audit(r, mailbus.NewAuditEvent(actor(r), mailbus.AuditActionSuppressionCreate, suppression.Value, suppression.Reason))
+	return writeJSON(w, http.StatusCreated, suppression)
+}
+
+func (s *Server) deleteSuppressionHandler(w http.ResponseWriter, r *http.Request) error {
+	value := strings.TrimPrefix(strings.ToLower(mux.Vars(r)["value"]), "@")
+	if err := s.SuppressionService.Delete(value); err != nil {
+		return FromError(err)
+	}
+
+	s.audit(r, mailbus.NewAuditEvent(actor(r), mailbus.AuditActionSuppressionDelete, value, ""))
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
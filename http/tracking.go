@@ -0,0 +1,122 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/quantonganh/mailbus"
+)
+
+// pixel is a transparent 1x1 GIF
+var pixel = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// openHandler serves the tracking pixel of a newsletter and records the open against its delivery.
+// Opens that look like they were made by a machine are recorded too, but flagged so that reports can leave them out.
+func (s *Server) openHandler(w http.ResponseWriter, r *http.Request) error {
+	delivery, err := s.verifyTrackingLink(r, mailbus.TrackingEventOpen)
+	if err != nil {
+		return err
+	}
+
+	e := mailbus.NewTrackingEvent(mailbus.TrackingEventOpen, delivery)
+	e.IP = remoteIP(r)
+	e.UserAgent = r.UserAgent()
+	s.OpenHeuristics.Classify(e, delivery.SentAt)
+	if err := s.TrackingService.Record(e); err != nil {
+		// the reader should not see a broken image because of it
+		hlog.FromRequest(r).Error().Err(err).Int("delivery_id", delivery.ID).Msg("failed to record open")
+		sentry.CaptureException(err)
+	}
+
+	// every open has to reach the server, not a cache
+	w.Header().Set("Content-Type", "image/gif")
+	w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate, private")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(pixel)
+	return err
+}
+
+// clickHandler records a click on a link of a newsletter and redirects the subscriber to its target.
+// The target is part of the signed link, so the handler cannot be used to redirect anywhere else.
+func (s *Server) clickHandler(w http.ResponseWriter, r *http.Request) error {
+	target := r.URL.Query().Get("url")
+	if !mailbus.Trackable(target) {
+		return NewError(nil, http.StatusBadRequest, "Invalid tracking link.")
+	}
+
+	delivery, err := s.verifyTrackingLink(r, mailbus.TrackingEventClick)
+	if err != nil {
+		return err
+	}
+
+	e := mailbus.NewTrackingEvent(mailbus.TrackingEventClick, delivery)
+	e.URL = target
+	e.IP = remoteIP(r)
+	e.UserAgent = r.UserAgent()
+	if err := s.TrackingService.Record(e); err != nil {
+		// the reader should get where they were going anyway
+		hlog.FromRequest(r).Error().Err(err).Int("delivery_id", delivery.ID).Msg("failed to record click")
+		sentry.CaptureException(err)
+	}
+
+	w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate, private")
+	http.Redirect(w, r, target, http.StatusFound)
+	return nil
+}
+
+// linksHandler reports the total and unique clicks on each link of a campaign
+func (s *Server) linksHandler(w http.ResponseWriter, r *http.Request) error {
+	c, err := s.findCampaign(r)
+	if err != nil {
+		return err
+	}
+
+	events, err := s.TrackingService.Find(mailbus.TrackingFilter{CampaignID: c.ID, Type: mailbus.TrackingEventClick})
+	if err != nil {
+		return err
+	}
+
+	links := mailbus.ClicksByLink(events)
+	if links == nil {
+		links = []mailbus.LinkClicks{}
+	}
+	return writeJSON(w, http.StatusOK, links)
+}
+
+// verifyTrackingLink returns the delivery a signed tracking link was issued for
+func (s *Server) verifyTrackingLink(r *http.Request, eventType string) (*mailbus.Delivery, error) {
+	query := r.URL.Query()
+	campaignID, err := strconv.Atoi(query.Get("campaign"))
+	if err != nil {
+		return nil, NewError(err, http.StatusBadRequest, "Invalid tracking link.")
+	}
+	subscriberID, err := strconv.Atoi(query.Get("subscriber"))
+	if err != nil {
+		return nil, NewError(err, http.StatusBadRequest, "Invalid tracking link.")
+	}
+
+	sig, err := mailbus.ParseLinkSignature(query)
+	if err != nil {
+		return nil, NewError(err, http.StatusBadRequest, "Invalid tracking link.")
+	}
+	if !mailbus.VerifyTrackingSignature(s.NewsletterService.GetKeyring(), eventType, campaignID, subscriberID, query.Get("url"), sig) {
+		return nil, NewError(nil, http.StatusBadRequest, "Invalid tracking link.")
+	}
+
+	deliveries, err := s.DeliveryService.Find(mailbus.DeliveryFilter{CampaignID: campaignID, SubscriberID: subscriberID})
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, NewError(nil, http.StatusNotFound, "Delivery not found.")
+	}
+
+	return &deliveries[0], nil
+}
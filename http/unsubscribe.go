@@ -2,28 +2,120 @@ package http
 
 import (
 	"net/http"
+	"strconv"
+	"time"
 
-	"github.com/quantonganh/mailbus/pkg/hash"
+	"github.com/getsentry/sentry-go"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/quantonganh/mailbus"
 )
 
+// unsubscribeHandler renders the page of a signed unsubscribe link. Like the confirmation link,
+// following it does not change anything: the subscriber has to press the button, see unsubscribeFormHandler.
 func (s *Server) unsubscribeHandler(w http.ResponseWriter, r *http.Request) error {
-	query := r.URL.Query()
-	email := query.Get("email")
-	hashValue := query.Get("hash")
-	expectedHash, err := hash.ComputeHmac256(email, s.NewsletterService.GetHMACSecret())
+	list, email, _, err := s.verifyUnsubscribeLink(r)
+	if err != nil {
+		return err
+	}
+
+	csrfToken, err := s.csrfToken(w, r)
 	if err != nil {
 		return err
 	}
 
-	if hashValue == expectedHash {
-		if err := s.SubscriptionService.Unsubscribe(email); err != nil {
+	return s.render(w, http.StatusOK, "unsubscribe", page{
+		Title:     "Unsubscribe",
+		Action:    r.URL.RequestURI(),
+		List:      list.Name,
+		Email:     email,
+		CSRFToken: csrfToken,
+	})
+}
+
+// unsubscribeFormHandler removes a subscriber from a list. The signed link is either posted to
+// by the mailbox provider as an RFC 8058 one-click unsubscribe, or by the form of the unsubscribe page.
+func (s *Server) unsubscribeFormHandler(w http.ResponseWriter, r *http.Request) error {
+	// one-click requests come straight from the mailbox provider and cannot carry a CSRF token,
+	// the signature of the link is what authorizes them
+	oneClick := r.PostFormValue("List-Unsubscribe") == "One-Click"
+	if !oneClick {
+		if err := verifyCSRF(r); err != nil {
 			return err
 		}
+	}
 
+	list, email, campaignID, err := s.verifyUnsubscribeLink(r)
+	if err != nil {
+		return err
+	}
+
+	if err := s.SubscriptionService.Unsubscribe(list.Name, email); err != nil {
+		return FromError(err)
+	}
+	if campaignID != 0 {
+		s.attributeUnsubscribe(r, campaignID, email)
+	}
+
+	via := "unsubscribe page"
+	if oneClick {
+		via = "one-click"
+	}
+	s.audit(r, mailbus.NewAuditEvent(email, mailbus.AuditActionUnsubscribe, list.Name, via))
+
+	if oneClick {
 		w.WriteHeader(http.StatusOK)
-	} else {
-		w.WriteHeader(http.StatusBadRequest)
+		return nil
+	}
+
+	return s.render(w, http.StatusOK, "message", page{
+		Title:   "Unsubscribed",
+		Message: "You will no longer receive these emails.",
+	})
+}
+
+// attributeUnsubscribe records an unsubscribe against the delivery of the campaign it was sent in.
+// The subscriber has left the list by then, so failures are only logged.
+func (s *Server) attributeUnsubscribe(r *http.Request, campaignID int, email string) {
+	deliveries, err := s.DeliveryService.Find(mailbus.DeliveryFilter{CampaignID: campaignID, Email: email})
+	if err == nil && len(deliveries) > 0 {
+		err = s.TrackingService.Record(mailbus.NewTrackingEvent(mailbus.TrackingEventUnsubscribe, &deliveries[0]))
+	}
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Int("campaign_id", campaignID).Msg("failed to attribute unsubscribe")
+		sentry.CaptureException(err)
+	}
+}
+
+// verifyUnsubscribeLink returns the list and the address of a signed unsubscribe link that has not expired,
+// and the campaign it was sent in, 0 if none
+func (s *Server) verifyUnsubscribeLink(r *http.Request) (*mailbus.List, string, int, error) {
+	query := r.URL.Query()
+	email := query.Get("email")
+
+	list, err := s.findList(r, query.Get("list"))
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	var campaignID int
+	if v := query.Get("campaign"); v != "" {
+		campaignID, err = strconv.Atoi(v)
+		if err != nil {
+			return nil, "", 0, NewError(err, http.StatusBadRequest, "Invalid unsubscribe link.")
+		}
+	}
+
+	sig, err := mailbus.ParseLinkSignature(query)
+	if err != nil {
+		return nil, "", 0, NewError(err, http.StatusBadRequest, "Invalid unsubscribe link.")
+	}
+	if !mailbus.VerifyUnsubscribeSignature(s.NewsletterService.GetKeyring(), list.Name, email, campaignID, sig) {
+		return nil, "", 0, NewError(nil, http.StatusBadRequest, "Invalid unsubscribe link.")
+	}
+	if sig.Expired(time.Now()) {
+		return nil, "", 0, NewError(nil, http.StatusGone, "Unsubscribe link has expired, please use the one of a more recent email.")
 	}
 
-	return nil
+	return list, email, campaignID, nil
 }
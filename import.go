@@ -0,0 +1,490 @@
+package mailbus
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"strings"
+)
+
+// Import format
+const (
+	ImportFormatCSV   = "csv"
+	ImportFormatJSONL = "jsonl"
+)
+
+// Import consent mode, which tells how the people imported agreed to receive emails
+const (
+	// ConsentConfirmed imports people who confirmed their subscription elsewhere, as active subscribers
+	ConsentConfirmed = "confirmed"
+	// ConsentDoubleOptIn imports people pending confirmation, and sends them a confirmation email
+	ConsentDoubleOptIn = "double_opt_in"
+)
+
+// Import row result
+const (
+	ImportAccepted = "accepted"
+	ImportSkipped  = "skipped"
+	ImportInvalid  = "invalid"
+)
+
+// MaxImportIssues is how many skipped and invalid rows are listed in an import report, the next ones are only counted
+const MaxImportIssues = 1000
+
+// Import field
+const (
+	ImportFieldEmail = "email"
+	ImportFieldList  = "list"
+)
+
+// ImportFields are the fields read from the rows of an import
+var ImportFields = []string{ImportFieldEmail, ImportFieldList}
+
+// ImportOptions represents how a file is imported
+type ImportOptions struct {
+	Format string
+	// Mapping maps fields to the columns of a CSV file, or to the keys of JSON objects.
+	// Fields that are not mapped are read from the column named after them, if any.
+	Mapping map[string]string
+	// List is the list of the rows without one, the default list if it is empty
+	List    string
+	Consent string
+	// URL is where the links of confirmation emails lead
+	URL string
+	// DryRun checks the rows without changing anything
+	DryRun bool
+}
+
+// ImportReport tells what became of the rows of an import
+type ImportReport struct {
+	Rows     int  `json:"rows"`
+	Accepted int  `json:"accepted"`
+	Skipped  int  `json:"skipped"`
+	Invalid  int  `json:"invalid"`
+	DryRun   bool `json:"dry_run"`
+	// Issues lists the first MaxImportIssues rows that were skipped or invalid, Truncated tells if there were more
+	Issues    []ImportIssue `json:"issues"`
+	Truncated bool          `json:"truncated"`
+}
+
+// ImportIssue is a row that was skipped or invalid, and why
+type ImportIssue struct {
+	Line   int    `json:"line"`
+	Email  string `json:"email,omitempty"`
+	List   string `json:"list,omitempty"`
+	Result string `json:"result"`
+	Reason string `json:"reason"`
+}
+
+// Summary returns the counts of the report, as recorded in the audit trail
+func (r *ImportReport) Summary() string {
+	return fmt.Sprintf("rows=%d accepted=%d skipped=%d invalid=%d", r.Rows, r.Accepted, r.Skipped, r.Invalid)
+}
+
+// ParseImportMapping parses a mapping such as "email=E-mail Address,list=Topic"
+func ParseImportMapping(s string) (map[string]string, error) {
+	const op = "ParseImportMapping"
+
+	mapping := make(map[string]string)
+	if strings.TrimSpace(s) == "" {
+		return mapping, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		field, column, ok := strings.Cut(pair, "=")
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !ok || strings.TrimSpace(column) == "" || !validImportField(field) {
+			return nil, &Error{
+				Code:    ErrInvalid,
+				Message: fmt.Sprintf("Invalid mapping %q, use FIELD=COLUMN with FIELD one of %s.", pair, strings.Join(ImportFields, ", ")),
+				Op:      op,
+			}
+		}
+		mapping[field] = strings.TrimSpace(column)
+	}
+	return mapping, nil
+}
+
+func validImportField(field string) bool {
+	for _, f := range ImportFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Importer adds the subscribers read from CSV or JSONL files.
+// Rows are read and imported one at a time, so that files of any size can be imported.
+type Importer struct {
+	ListService         ListService
+	SubscriptionService SubscriptionService
+	SuppressionService  SuppressionService
+	// Confirmer issues the confirmation emails of double opt-in imports
+	Confirmer *Confirmer
+}
+
+// Import reads the rows of r and adds their subscribers. Rows that are invalid, duplicates, suppressed
+// or already subscribed are reported and skipped, the import goes on with the next one.
+// An error stops the import, the report then tells what was done until then.
+func (im *Importer) Import(r io.Reader, opts ImportOptions) (*ImportReport, error) {
+	const op = "Importer.Import"
+
+	if opts.Consent != ConsentConfirmed && opts.Consent != ConsentDoubleOptIn {
+		return nil, &Error{
+			Code:    ErrInvalid,
+			Message: fmt.Sprintf("Consent must be %s or %s.", ConsentConfirmed, ConsentDoubleOptIn),
+			Op:      op,
+		}
+	}
+	for field := range opts.Mapping {
+		if !validImportField(field) {
+			return nil, &Error{Code: ErrInvalid, Message: fmt.Sprintf("Unknown field %q.", field), Op: op}
+		}
+	}
+	if opts.List == "" {
+		opts.List = DefaultList
+	}
+
+	var rows rowReader
+	var err error
+	switch opts.Format {
+	case ImportFormatCSV:
+		rows, err = newCSVRowReader(r, opts.Mapping)
+	case ImportFormatJSONL:
+		rows = newJSONLRowReader(r, opts.Mapping)
+	default:
+		err = &Error{
+			Code:    ErrInvalid,
+			Message: fmt.Sprintf("Unknown format %q, use %s or %s.", opts.Format, ImportFormatCSV, ImportFormatJSONL),
+			Op:      op,
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	run := &importRun{
+		Importer: im,
+		opts:     opts,
+		report:   &ImportReport{DryRun: opts.DryRun, Issues: []ImportIssue{}},
+		lists:    make(map[string]bool),
+		seen:     make(map[string]int),
+	}
+	for {
+		row, err := rows.next()
+		if errors.Is(err, io.EOF) {
+			return run.report, nil
+		}
+		if err != nil {
+			return run.report, fmt.Errorf("failed to read the file: %w", err)
+		}
+		if err := run.importRow(row); err != nil {
+			return run.report, fmt.Errorf("failed to import line %d: %w", row.line, err)
+		}
+	}
+}
+
+// importRun holds the state of an import
+type importRun struct {
+	*Importer
+	opts   ImportOptions
+	report *ImportReport
+	// lists tells which of the lists named so far exist, seen the line where each subscription was first read
+	lists map[string]bool
+	seen  map[string]int
+}
+
+// importRow imports a row, errors are those of the services
+func (run *importRun) importRow(row importRow) error {
+	run.report.Rows++
+	if row.err != nil {
+		run.issue(row, ImportInvalid, row.err.Error())
+		return nil
+	}
+
+	row.list = strings.ToLower(strings.TrimSpace(row.list))
+	if row.list == "" {
+		row.list = run.opts.List
+	}
+	email, reason := normalizeEmail(row.email)
+	if reason != "" {
+		run.issue(row, ImportInvalid, reason)
+		return nil
+	}
+	row.email = email
+
+	exists, ok := run.lists[row.list]
+	if !ok {
+		_, err := run.ListService.FindByName(row.list)
+		if err != nil && ErrorCode(err) != ErrNotFound {
+			return err
+		}
+		exists = err == nil
+		run.lists[row.list] = exists
+	}
+	if !exists {
+		run.issue(row, ImportInvalid, fmt.Sprintf("Unknown list %q.", row.list))
+		return nil
+	}
+
+	key := row.list + " " + row.email
+	if line, ok := run.seen[key]; ok {
+		run.issue(row, ImportSkipped, fmt.Sprintf("Duplicate of line %d.", line))
+		return nil
+	}
+	run.seen[key] = row.line
+
+	suppression, err := run.SuppressionService.Match(row.email)
+	if err != nil {
+		return err
+	}
+	if suppression != nil {
+		run.issue(row, ImportSkipped, fmt.Sprintf("Suppressed by %s.", suppression.Value))
+		return nil
+	}
+
+	subscriber, err := run.SubscriptionService.FindByEmail(row.list, row.email)
+	switch {
+	case err == nil:
+		return run.merge(row, subscriber)
+	case ErrorCode(err) != ErrNotFound:
+		return err
+	}
+
+	if run.opts.DryRun {
+		run.report.Accepted++
+		return nil
+	}
+
+	subscription := NewSubscription(row.list, row.email, StatusActive, "")
+	if run.opts.Consent == ConsentDoubleOptIn {
+		if subscription, err = run.Confirmer.NewSubscription(row.list, row.email); err != nil {
+			return err
+		}
+		subscription.Message = NewConfirmationMessage(row.email, run.opts.URL, subscription.Token)
+	}
+	if err := run.SubscriptionService.Insert(subscription); err != nil {
+		return err
+	}
+
+	run.report.Accepted++
+	return nil
+}
+
+// merge imports a row of someone who is already subscribed: confirmed imports activate pending subscribers,
+// the others are left as they are, people who unsubscribed or bounced in particular
+func (run *importRun) merge(row importRow, subscriber *Subscriber) error {
+	if subscriber.Status != StatusPendingConfirmation || run.opts.Consent != ConsentConfirmed {
+		run.issue(row, ImportSkipped, fmt.Sprintf("Already %s.", strings.ReplaceAll(subscriber.Status, "_", " ")))
+		return nil
+	}
+
+	if !run.opts.DryRun {
+		if _, err := run.SubscriptionService.Activate(row.list, row.email); err != nil {
+			return err
+		}
+	}
+	run.report.Accepted++
+	return nil
+}
+
+func (run *importRun) issue(row importRow, result, reason string) {
+	if result == ImportInvalid {
+		run.report.Invalid++
+	} else {
+		run.report.Skipped++
+	}
+
+	if len(run.report.Issues) == MaxImportIssues {
+		run.report.Truncated = true
+		return
+	}
+	run.report.Issues = append(run.report.Issues, ImportIssue{
+		Line:   row.line,
+		Email:  row.email,
+		List:   row.list,
+		Result: result,
+		Reason: reason,
+	})
+}
+
+// normalizeEmail returns the address of a field, lower-cased and without a display name,
+// or why it is not a valid address
+func normalizeEmail(v string) (string, string) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return "", "Missing email address."
+	}
+
+	addr, err := mail.ParseAddress(v)
+	if err != nil || !strings.Contains(addr.Address[strings.LastIndex(addr.Address, "@")+1:], ".") {
+		return "", "Invalid email address."
+	}
+	return NormalizeEmail(addr.Address), ""
+}
+
+// importRow is a row read from an import file, err tells why its fields could not be read
+type importRow struct {
+	line  int
+	email string
+	list  string
+	err   error
+}
+
+// rowReader reads the rows of an import file, until io.EOF
+type rowReader interface {
+	next() (importRow, error)
+}
+
+// csvRowReader reads CSV files, whose first line names the columns
+type csvRowReader struct {
+	r *csv.Reader
+	// columns are the indexes of the columns of the fields
+	columns map[string]int
+}
+
+func newCSVRowReader(r io.Reader, mapping map[string]string) (*csvRowReader, error) {
+	const op = "newCSVRowReader"
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, &Error{Code: ErrInvalid, Message: "The file is empty.", Op: op}
+	}
+	if err != nil {
+		return nil, &Error{Code: ErrInvalid, Message: fmt.Sprintf("Invalid CSV header: %v.", err), Op: op, Err: err}
+	}
+
+	names := make(map[string]int, len(header))
+	for i, name := range header {
+		if i == 0 {
+			// spreadsheets start the files they export with a byte order mark
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		names[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	columns := make(map[string]int)
+	for _, field := range ImportFields {
+		name, mapped := mapping[field]
+		if !mapped {
+			name = field
+		}
+		i, ok := names[strings.ToLower(name)]
+		if !ok {
+			if mapped || field == ImportFieldEmail {
+				return nil, &Error{
+					Code:    ErrInvalid,
+					Message: fmt.Sprintf("No %q column for the %s field.", name, field),
+					Op:      op,
+				}
+			}
+			continue
+		}
+		columns[field] = i
+	}
+
+	return &csvRowReader{r: cr, columns: columns}, nil
+}
+
+func (cr *csvRowReader) next() (importRow, error) {
+	record, err := cr.r.Read()
+	var perr *csv.ParseError
+	if errors.As(err, &perr) {
+		return importRow{line: perr.StartLine, err: fmt.Errorf("Invalid CSV: %v.", perr.Err)}, nil
+	}
+	if err != nil {
+		return importRow{}, err
+	}
+
+	line, _ := cr.r.FieldPos(0)
+	return importRow{
+		line:  line,
+		email: cr.field(record, ImportFieldEmail),
+		list:  cr.field(record, ImportFieldList),
+	}, nil
+}
+
+func (cr *csvRowReader) field(record []string, field string) string {
+	i, ok := cr.columns[field]
+	if !ok || i >= len(record) {
+		return ""
+	}
+	return record[i]
+}
+
+// jsonlRowReader reads files holding a JSON object per line, blank lines are ignored
+type jsonlRowReader struct {
+	r    *bufio.Reader
+	keys map[string]string
+	line int
+}
+
+func newJSONLRowReader(r io.Reader, mapping map[string]string) *jsonlRowReader {
+	keys := make(map[string]string, len(ImportFields))
+	for _, field := range ImportFields {
+		keys[field] = field
+		if key, ok := mapping[field]; ok {
+			keys[field] = key
+		}
+	}
+	return &jsonlRowReader{r: bufio.NewReader(r), keys: keys}
+}
+
+func (jr *jsonlRowReader) next() (importRow, error) {
+	for {
+		b, err := jr.r.ReadBytes('\n')
+		if len(b) > 0 {
+			jr.line++
+		}
+		if err != nil && (!errors.Is(err, io.EOF) || len(b) == 0) {
+			return importRow{line: jr.line}, err
+		}
+		b = bytes.TrimSpace(b)
+		if len(b) == 0 {
+			continue
+		}
+
+		row := importRow{line: jr.line}
+		var object map[string]interface{}
+		if err := json.Unmarshal(b, &object); err != nil {
+			row.err = errors.New("Invalid JSON object.")
+			return row, nil
+		}
+		row.email = jr.field(object, ImportFieldEmail)
+		row.list = jr.field(object, ImportFieldList)
+		return row, nil
+	}
+}
+
+// field returns the value of the key of a field, whose case does not matter
+func (jr *jsonlRowReader) field(object map[string]interface{}, field string) string {
+	key := jr.keys[field]
+	v, ok := object[key]
+	if !ok {
+		for k, value := range object {
+			if strings.EqualFold(k, key) {
+				v = value
+				break
+			}
+		}
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,77 @@
+package mailbus
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseImportMapping(t *testing.T) {
+	mapping, err := ParseImportMapping("email = E-mail Address, List=Topic")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"email": "E-mail Address", "list": "Topic"}, mapping)
+
+	for _, s := range []string{"email", "phone=Phone", "email="} {
+		_, err := ParseImportMapping(s)
+		assert.Equal(t, ErrInvalid, ErrorCode(err), s)
+	}
+}
+
+func TestRowReaders(t *testing.T) {
+	csvRows, err := newCSVRowReader(strings.NewReader("\ufeffName,E-Mail\n"+
+		"Alice,alice@example.com\n"+
+		"\"Bob, Jr.\",\"bob@example.com\"\n"+
+		"Carol\n"), map[string]string{"email": "e-mail"})
+	require.NoError(t, err)
+	assert.Equal(t, []importRow{
+		{line: 2, email: "alice@example.com"},
+		{line: 3, email: "bob@example.com"},
+		{line: 4},
+	}, readRows(t, csvRows))
+
+	_, err = newCSVRowReader(strings.NewReader("name,mail\n"), nil)
+	assert.Equal(t, ErrInvalid, ErrorCode(err))
+
+	jsonlRows := newJSONLRowReader(strings.NewReader(`{"Email":"alice@example.com","list":"go"}`+"\n\n"+
+		"{oops\n"+
+		`{"email":"bob@example.com"}`), nil)
+	rows := readRows(t, jsonlRows)
+	require.Len(t, rows, 3)
+	assert.Equal(t, importRow{line: 1, email: "alice@example.com", list: "go"}, rows[0])
+	assert.Equal(t, 3, rows[1].line)
+	assert.Error(t, rows[1].err)
+	assert.Equal(t, importRow{line: 4, email: "bob@example.com"}, rows[2])
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	for v, want := range map[string]string{
+		" Alice@Example.COM ":            "alice@example.com",
+		"Bob <bob@example.com>":          "bob@example.com",
+		`"Carol C." <carol@example.com>`: "carol@example.com",
+	} {
+		email, reason := normalizeEmail(v)
+		assert.Empty(t, reason, v)
+		assert.Equal(t, want, email)
+	}
+
+	for _, v := range []string{"", "alice", "alice@localhost", "alice@example.com, bob@example.com"} {
+		_, reason := normalizeEmail(v)
+		assert.NotEmpty(t, reason, v)
+	}
+}
+
+func readRows(t *testing.T, rows rowReader) []importRow {
+	var all []importRow
+	for {
+		row, err := rows.next()
+		if errors.Is(err, io.EOF) {
+			return all
+		}
+		require.NoError(t, err)
+		all = append(all, row)
+	}
+}
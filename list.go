@@ -0,0 +1,61 @@
+package mailbus
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// DefaultList is the name of the list used when a request does not specify one
+const DefaultList = "default"
+
+// ListService is the interface that wraps methods related to mailing lists
+type ListService interface {
+	FindAll() ([]List, error)
+	FindByName(name string) (*List, error)
+	Create(l *List) error
+	Update(l *List) error
+	Delete(name string) error
+}
+
+// List represents a named mailing list (topic) that people can subscribe to
+type List struct {
+	ID          int       `storm:"id,increment" json:"id"`
+	Name        string    `storm:"unique" json:"name"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	TrackOpens  bool      `json:"track_opens"`  // add a tracking pixel to the newsletters of the list
+	TrackClicks bool      `json:"track_clicks"` // redirect the links of the newsletters of the list through the server
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// listName is the pattern of list names, which appear in URLs
+var listName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// NewList validates the name of a list and returns the list
+func NewList(name, title, description string) (*List, error) {
+	if !listName.MatchString(name) {
+		return nil, &Error{
+			Code:    ErrInvalid,
+			Message: fmt.Sprintf("Invalid list name %q, use lowercase letters, digits, - and _.", name),
+			Op:      "NewList",
+		}
+	}
+
+	return &List{
+		Name:        name,
+		Title:       title,
+		Description: description,
+		CreatedAt:   time.Now(),
+	}, nil
+}
+
+// ListRequest represents a request of the admin API to create or edit a list,
+// the tracking settings left out are off for a new list and kept for an edited one
+type ListRequest struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	TrackOpens  *bool  `json:"track_opens"`
+	TrackClicks *bool  `json:"track_clicks"`
+}
@@ -4,6 +4,7 @@ package mock
 
 import (
 	mailbus "github.com/quantonganh/mailbus"
+	hash "github.com/quantonganh/mailbus/pkg/hash"
 	mock "github.com/stretchr/testify/mock"
 )
 
@@ -26,15 +27,17 @@ func (_m *NewsletterService) GenerateNewUUID() string {
 	return r0
 }
 
-// GetHMACSecret provides a mock function with given fields:
-func (_m *NewsletterService) GetHMACSecret() string {
+// GetKeyring provides a mock function with given fields:
+func (_m *NewsletterService) GetKeyring() *hash.Keyring {
 	ret := _m.Called()
 
-	var r0 string
-	if rf, ok := ret.Get(0).(func() string); ok {
+	var r0 *hash.Keyring
+	if rf, ok := ret.Get(0).(func() *hash.Keyring); ok {
 		r0 = rf()
 	} else {
-		r0 = ret.Get(0).(string)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*hash.Keyring)
+		}
 	}
 
 	return r0
@@ -54,9 +57,30 @@ func (_m *NewsletterService) SendConfirmationEmail(to string, url string, token
 	return r0
 }
 
-// SendNewsletter provides a mock function with given fields: subscribers, subject, body
-func (_m *NewsletterService) SendNewsletter(subscribers []mailbus.Subscriber, subject string, body string) {
-	_m.Called(subscribers, subject, body)
+// SendNewsletter provides a mock function with given fields: c, to
+func (_m *NewsletterService) SendNewsletter(c *mailbus.Campaign, to mailbus.Subscriber) (*mailbus.SMTPReply, error) {
+	ret := _m.Called(c, to)
+
+	var r0 *mailbus.SMTPReply
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*mailbus.Campaign, mailbus.Subscriber) (*mailbus.SMTPReply, error)); ok {
+		return rf(c, to)
+	}
+	if rf, ok := ret.Get(0).(func(*mailbus.Campaign, mailbus.Subscriber) *mailbus.SMTPReply); ok {
+		r0 = rf(c, to)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*mailbus.SMTPReply)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*mailbus.Campaign, mailbus.Subscriber) error); ok {
+		r1 = rf(c, to)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
 // SendThankYouEmail provides a mock function with given fields: to
@@ -5,6 +5,7 @@ package mock
 import (
 	mailbus "github.com/quantonganh/mailbus"
 	mock "github.com/stretchr/testify/mock"
+	time "time"
 )
 
 // SubscriptionService is an autogenerated mock type for the SubscriptionService type
@@ -12,19 +13,47 @@ type SubscriptionService struct {
 	mock.Mock
 }
 
+// Activate provides a mock function with given fields: list, email
+func (_m *SubscriptionService) Activate(list string, email string) (*mailbus.Subscriber, error) {
+	ret := _m.Called(list, email)
+
+	var r0 *mailbus.Subscriber
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string) (*mailbus.Subscriber, error)); ok {
+		return rf(list, email)
+	}
+	if rf, ok := ret.Get(0).(func(string, string) *mailbus.Subscriber); ok {
+		r0 = rf(list, email)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*mailbus.Subscriber)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(list, email)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Confirm provides a mock function with given fields: token
-func (_m *SubscriptionService) Confirm(token string) (string, error) {
+func (_m *SubscriptionService) Confirm(token string) (*mailbus.Subscriber, error) {
 	ret := _m.Called(token)
 
-	var r0 string
+	var r0 *mailbus.Subscriber
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (string, error)); ok {
+	if rf, ok := ret.Get(0).(func(string) (*mailbus.Subscriber, error)); ok {
 		return rf(token)
 	}
-	if rf, ok := ret.Get(0).(func(string) string); ok {
+	if rf, ok := ret.Get(0).(func(string) *mailbus.Subscriber); ok {
 		r0 = rf(token)
 	} else {
-		r0 = ret.Get(0).(string)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*mailbus.Subscriber)
+		}
 	}
 
 	if rf, ok := ret.Get(1).(func(string) error); ok {
@@ -36,25 +65,65 @@ func (_m *SubscriptionService) Confirm(token string) (string, error) {
 	return r0, r1
 }
 
-// FindByEmail provides a mock function with given fields: email
-func (_m *SubscriptionService) FindByEmail(email string) (*mailbus.Subscriber, error) {
-	ret := _m.Called(email)
+// Delete provides a mock function with given fields: list, email
+func (_m *SubscriptionService) Delete(list string, email string) error {
+	ret := _m.Called(list, email)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(list, email)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Find provides a mock function with given fields: filter
+func (_m *SubscriptionService) Find(filter mailbus.SubscriberFilter) ([]mailbus.Subscriber, error) {
+	ret := _m.Called(filter)
+
+	var r0 []mailbus.Subscriber
+	var r1 error
+	if rf, ok := ret.Get(0).(func(mailbus.SubscriberFilter) ([]mailbus.Subscriber, error)); ok {
+		return rf(filter)
+	}
+	if rf, ok := ret.Get(0).(func(mailbus.SubscriberFilter) []mailbus.Subscriber); ok {
+		r0 = rf(filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]mailbus.Subscriber)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(mailbus.SubscriberFilter) error); ok {
+		r1 = rf(filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindByEmail provides a mock function with given fields: list, email
+func (_m *SubscriptionService) FindByEmail(list string, email string) (*mailbus.Subscriber, error) {
+	ret := _m.Called(list, email)
 
 	var r0 *mailbus.Subscriber
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (*mailbus.Subscriber, error)); ok {
-		return rf(email)
+	if rf, ok := ret.Get(0).(func(string, string) (*mailbus.Subscriber, error)); ok {
+		return rf(list, email)
 	}
-	if rf, ok := ret.Get(0).(func(string) *mailbus.Subscriber); ok {
-		r0 = rf(email)
+	if rf, ok := ret.Get(0).(func(string, string) *mailbus.Subscriber); ok {
+		r0 = rf(list, email)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*mailbus.Subscriber)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(email)
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(list, email)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -62,25 +131,51 @@ func (_m *SubscriptionService) FindByEmail(email string) (*mailbus.Subscriber, e
 	return r0, r1
 }
 
-// FindByStatus provides a mock function with given fields: status
-func (_m *SubscriptionService) FindByStatus(status string) ([]mailbus.Subscriber, error) {
-	ret := _m.Called(status)
+// FindByStatus provides a mock function with given fields: list, status
+func (_m *SubscriptionService) FindByStatus(list string, status string) ([]mailbus.Subscriber, error) {
+	ret := _m.Called(list, status)
 
 	var r0 []mailbus.Subscriber
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) ([]mailbus.Subscriber, error)); ok {
-		return rf(status)
+	if rf, ok := ret.Get(0).(func(string, string) ([]mailbus.Subscriber, error)); ok {
+		return rf(list, status)
 	}
-	if rf, ok := ret.Get(0).(func(string) []mailbus.Subscriber); ok {
-		r0 = rf(status)
+	if rf, ok := ret.Get(0).(func(string, string) []mailbus.Subscriber); ok {
+		r0 = rf(list, status)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]mailbus.Subscriber)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(status)
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(list, status)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindPending provides a mock function with given fields: since
+func (_m *SubscriptionService) FindPending(since time.Time) ([]mailbus.Subscriber, error) {
+	ret := _m.Called(since)
+
+	var r0 []mailbus.Subscriber
+	var r1 error
+	if rf, ok := ret.Get(0).(func(time.Time) ([]mailbus.Subscriber, error)); ok {
+		return rf(since)
+	}
+	if rf, ok := ret.Get(0).(func(time.Time) []mailbus.Subscriber); ok {
+		r0 = rf(since)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]mailbus.Subscriber)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(time.Time) error); ok {
+		r1 = rf(since)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -102,8 +197,8 @@ func (_m *SubscriptionService) Insert(s *mailbus.Subscription) error {
 	return r0
 }
 
-// Unsubscribe provides a mock function with given fields: email
-func (_m *SubscriptionService) Unsubscribe(email string) error {
+// MarkBounced provides a mock function with given fields: email
+func (_m *SubscriptionService) MarkBounced(email string) error {
 	ret := _m.Called(email)
 
 	var r0 error
@@ -116,13 +211,51 @@ func (_m *SubscriptionService) Unsubscribe(email string) error {
 	return r0
 }
 
-// Update provides a mock function with given fields: email, token
-func (_m *SubscriptionService) Update(email string, token string) error {
-	ret := _m.Called(email, token)
+// Purge provides a mock function with given fields: since
+func (_m *SubscriptionService) Purge(since time.Time) (int, error) {
+	ret := _m.Called(since)
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(time.Time) (int, error)); ok {
+		return rf(since)
+	}
+	if rf, ok := ret.Get(0).(func(time.Time) int); ok {
+		r0 = rf(since)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(time.Time) error); ok {
+		r1 = rf(since)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Unsubscribe provides a mock function with given fields: list, email
+func (_m *SubscriptionService) Unsubscribe(list string, email string) error {
+	ret := _m.Called(list, email)
 
 	var r0 error
 	if rf, ok := ret.Get(0).(func(string, string) error); ok {
-		r0 = rf(email, token)
+		r0 = rf(list, email)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Update provides a mock function with given fields: s
+func (_m *SubscriptionService) Update(s *mailbus.Subscription) error {
+	ret := _m.Called(s)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*mailbus.Subscription) error); ok {
+		r0 = rf(s)
 	} else {
 		r0 = ret.Error(0)
 	}
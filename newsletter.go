@@ -1,15 +1,18 @@
 package mailbus
 
+import "github.com/quantonganh/mailbus/pkg/hash"
+
 // NewsletterService is the interface that wraps methods related to SMTP
 type NewsletterService interface {
 	SendConfirmationEmail(to, url, token string) error
 	SendThankYouEmail(to string) error
-	SendNewsletter(subscribers []Subscriber, subject, body string)
+	SendNewsletter(c *Campaign, to Subscriber) (*SMTPReply, error)
 	GenerateNewUUID() string
-	GetHMACSecret() string
+	GetKeyring() *hash.Keyring
 }
 
 type EmailNewsletterRequest struct {
+	List    string `json:"list"`
 	Subject string `json:"subject"`
 	Body    string `json:"body"`
 }
@@ -0,0 +1,84 @@
+package mailbus
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Outbox message kind
+const (
+	OutboxKindConfirmation = "confirmation"
+)
+
+// Outbox message status
+const (
+	OutboxStatusPending = "pending"
+	OutboxStatusSent    = "sent"
+	OutboxStatusFailed  = "failed"
+)
+
+// OutboxService is the interface that wraps methods related to the outbox.
+// Messages are written to the outbox in the same transaction as the change they are about,
+// and relayed to the mail server afterwards.
+type OutboxService interface {
+	FindDue(now time.Time) ([]OutboxMessage, error)
+	Update(m *OutboxMessage) error
+}
+
+// OutboxMessage represents an email waiting to be sent
+type OutboxMessage struct {
+	ID            int       `storm:"id,increment" json:"id"`
+	Kind          string    `json:"kind"`
+	Recipient     string    `json:"recipient"`
+	Payload       string    `json:"payload"`
+	Status        string    `storm:"index" json:"status"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"last_error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	NextAttemptAt time.Time `storm:"index" json:"next_attempt_at"`
+	SentAt        time.Time `json:"sent_at"`
+}
+
+// ConfirmationPayload is the payload of a confirmation email
+type ConfirmationPayload struct {
+	URL   string `json:"url"`
+	Token string `json:"token"`
+}
+
+// NewConfirmationMessage returns the confirmation email of a subscription, to be sent right away
+func NewConfirmationMessage(to, url, token string) *OutboxMessage {
+	// marshalling a struct of strings cannot fail
+	payload, _ := json.Marshal(ConfirmationPayload{URL: url, Token: token})
+
+	now := time.Now()
+	return &OutboxMessage{
+		Kind:          OutboxKindConfirmation,
+		Recipient:     to,
+		Payload:       string(payload),
+		Status:        OutboxStatusPending,
+		CreatedAt:     now,
+		NextAttemptAt: now,
+	}
+}
+
+// Record records the outcome of an attempt to send the message. Temporary failures are retried
+// as long as the policy allows it, invalid messages fail right away.
+func (m *OutboxMessage) Record(err error, policy RetryPolicy) {
+	now := time.Now()
+	m.Attempts++
+
+	if err == nil {
+		m.Status = OutboxStatusSent
+		m.SentAt = now
+		m.LastError = ""
+		return
+	}
+
+	m.LastError = err.Error()
+	if ErrorCode(err) != ErrInvalid && NewSMTPReply(err).Temporary() && policy.Retryable(m.Attempts) {
+		m.NextAttemptAt = now.Add(policy.Backoff(m.Attempts))
+		return
+	}
+
+	m.Status = OutboxStatusFailed
+}
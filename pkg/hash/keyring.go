@@ -0,0 +1,65 @@
+package hash
+
+import (
+	"crypto/hmac"
+
+	"github.com/pkg/errors"
+)
+
+// MinSecretLength is the minimum length of the secrets of keys with an ID, as long as the hash
+const MinSecretLength = 32
+
+// Key is an HMAC-SHA256 secret, named by the ID that goes along with the hashes it computes.
+// The key with an empty ID is the one hashes were computed with before keys had IDs.
+type Key struct {
+	ID     string
+	Secret string
+}
+
+// Keyring computes hashes with its first key, and verifies them with any of its keys, so that a secret can be
+// rotated: the new key goes first, the previous one stays until the hashes it computed no longer matter.
+type Keyring struct {
+	keys []Key
+}
+
+// NewKeyring returns a keyring of keys, the first one computes hashes
+func NewKeyring(keys ...Key) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no HMAC key")
+	}
+
+	ids := make(map[string]bool)
+	for _, k := range keys {
+		if ids[k.ID] {
+			return nil, errors.Errorf("duplicate HMAC key ID %q", k.ID)
+		}
+		ids[k.ID] = true
+
+		if k.ID != "" && len(k.Secret) < MinSecretLength {
+			return nil, errors.Errorf("secret of HMAC key %q must be at least %d bytes", k.ID, MinSecretLength)
+		}
+	}
+
+	return &Keyring{keys: keys}, nil
+}
+
+// Sign returns the HMAC-SHA256 of a message computed with the first key, and the ID of that key
+func (k *Keyring) Sign(message string) (keyID, hashValue string, err error) {
+	key := k.keys[0]
+	hashValue, err = ComputeHmac256(message, key.Secret)
+	return key.ID, hashValue, err
+}
+
+// Verify reports whether a hash is the HMAC-SHA256 of a message computed with the key of an ID.
+// The hashes are compared in constant time.
+func (k *Keyring) Verify(keyID, message, hashValue string) bool {
+	for _, key := range k.keys {
+		if key.ID != keyID {
+			continue
+		}
+
+		expected, err := ComputeHmac256(message, key.Secret)
+		return err == nil && hmac.Equal([]byte(hashValue), []byte(expected))
+	}
+	return false
+}
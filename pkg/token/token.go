@@ -0,0 +1,202 @@
+// Package token issues stateless tokens: the claims are carried in the token itself, signed with HMAC-SHA256
+// or Ed25519, so that a token can be verified without looking it up. Every token names the key it was signed with,
+// so that keys can be rotated: the first key of a signer signs, all of them verify.
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Algorithm
+const (
+	AlgorithmHMAC    = "hmac-sha256"
+	AlgorithmEd25519 = "ed25519"
+)
+
+// prefix tells signed tokens from other ones, and leaves room for another format
+const prefix = "v1."
+
+// minSecretLength is the minimum length of HMAC secrets, as long as the hash
+const minSecretLength = sha256.Size
+
+// ErrInvalid is returned for tokens that are malformed, signed with an unknown key, or whose signature does not match
+var ErrInvalid = errors.New("invalid token")
+
+// Claims represents what a token says
+type Claims struct {
+	Email    string
+	List     string
+	IssuedAt time.Time
+	// KeyID is the ID of the key the token was signed with
+	KeyID string
+}
+
+// payload is the encoding of claims in a token
+type payload struct {
+	Email    string `json:"email"`
+	List     string `json:"list"`
+	IssuedAt int64  `json:"iat"`
+	KeyID    string `json:"kid"`
+}
+
+// Key represents a signing key: Secret is used with HMAC-SHA256, PrivateKey with Ed25519
+type Key struct {
+	ID         string
+	Algorithm  string
+	Secret     []byte
+	PrivateKey ed25519.PrivateKey
+}
+
+// Signer signs tokens with its first key, and verifies them with any of its keys
+type Signer struct {
+	keys []Key
+}
+
+// NewSigner returns a signer of keys, the first one signs
+func NewSigner(keys ...Key) (*Signer, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no signing key")
+	}
+
+	ids := make(map[string]bool)
+	for _, k := range keys {
+		if k.ID == "" || strings.Contains(k.ID, ".") {
+			return nil, errors.Errorf("invalid key ID %q", k.ID)
+		}
+		if ids[k.ID] {
+			return nil, errors.Errorf("duplicate key ID %q", k.ID)
+		}
+		ids[k.ID] = true
+
+		switch k.Algorithm {
+		case AlgorithmHMAC:
+			if len(k.Secret) < minSecretLength {
+				return nil, errors.Errorf("secret of key %q must be at least %d bytes", k.ID, minSecretLength)
+			}
+		case AlgorithmEd25519:
+			if len(k.PrivateKey) != ed25519.PrivateKeySize {
+				return nil, errors.Errorf("key %q has no Ed25519 private key", k.ID)
+			}
+		default:
+			return nil, errors.Errorf("unsupported algorithm %q of key %q, use %s or %s", k.Algorithm, k.ID,
+				AlgorithmHMAC, AlgorithmEd25519)
+		}
+	}
+
+	return &Signer{keys: keys}, nil
+}
+
+// Sign returns a token of the claims, signed with the first key. The issue time is kept to the second.
+func (s *Signer) Sign(c Claims) (string, error) {
+	k := s.keys[0]
+	data, err := json.Marshal(payload{
+		Email:    c.Email,
+		List:     c.List,
+		IssuedAt: c.IssuedAt.Unix(),
+		KeyID:    k.ID,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode claims")
+	}
+
+	signed := prefix + base64.RawURLEncoding.EncodeToString(data)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign(k, []byte(signed))), nil
+}
+
+// Verify returns the claims of a token if it was signed with one of the keys, ErrInvalid otherwise.
+// It does not check how old the token is: the issue time is in the claims.
+func (s *Signer) Verify(token string) (*Claims, error) {
+	if !IsSigned(token) {
+		return nil, ErrInvalid
+	}
+
+	i := strings.LastIndex(token, ".")
+	signed, signature := token[:i], token[i+1:]
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	data, err := base64.RawURLEncoding.DecodeString(signed[len(prefix):])
+	if err != nil {
+		return nil, ErrInvalid
+	}
+
+	var p payload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, ErrInvalid
+	}
+
+	k, ok := s.key(p.KeyID)
+	if !ok || !verify(k, []byte(signed), sig) {
+		return nil, ErrInvalid
+	}
+
+	return &Claims{
+		Email:    p.Email,
+		List:     p.List,
+		IssuedAt: time.Unix(p.IssuedAt, 0),
+		KeyID:    p.KeyID,
+	}, nil
+}
+
+func (s *Signer) key(id string) (Key, bool) {
+	for _, k := range s.keys {
+		if k.ID == id {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+func sign(k Key, message []byte) []byte {
+	if k.Algorithm == AlgorithmEd25519 {
+		return ed25519.Sign(k.PrivateKey, message)
+	}
+
+	h := hmac.New(sha256.New, k.Secret)
+	h.Write(message)
+	return h.Sum(nil)
+}
+
+func verify(k Key, message, sig []byte) bool {
+	if k.Algorithm == AlgorithmEd25519 {
+		return ed25519.Verify(k.PrivateKey.Public().(ed25519.PublicKey), message, sig)
+	}
+
+	// the comparison takes the same time wherever the signatures differ
+	return hmac.Equal(sign(k, message), sig)
+}
+
+// IsSigned reports whether a token looks like a signed one, as opposed to a random one
+func IsSigned(token string) bool {
+	return strings.HasPrefix(token, prefix) && strings.Count(token, ".") == 2
+}
+
+// ParseEd25519Key parses a PEM encoded PKCS #8 Ed25519 private key
+func ParseEd25519Key(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found in private key")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse private key")
+	}
+
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.Errorf("unsupported key type %T, want an Ed25519 key", key)
+	}
+	return private, nil
+}
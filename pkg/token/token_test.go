@@ -0,0 +1,101 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hmacKey(id string) Key {
+	return Key{ID: id, Algorithm: AlgorithmHMAC, Secret: []byte(strings.Repeat(id, 32))}
+}
+
+func TestSignVerify(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	issuedAt := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	for _, k := range []Key{hmacKey("a"), {ID: "b", Algorithm: AlgorithmEd25519, PrivateKey: private}} {
+		s, err := NewSigner(k)
+		require.NoError(t, err)
+
+		token, err := s.Sign(Claims{Email: "alice@example.com", List: "go", IssuedAt: issuedAt})
+		require.NoError(t, err)
+		assert.True(t, IsSigned(token))
+
+		claims, err := s.Verify(token)
+		require.NoError(t, err, k.Algorithm)
+		assert.Equal(t, "alice@example.com", claims.Email)
+		assert.Equal(t, "go", claims.List)
+		assert.True(t, issuedAt.Equal(claims.IssuedAt))
+		assert.Equal(t, k.ID, claims.KeyID)
+
+		// the claims cannot be changed without the key
+		parts := strings.Split(token, ".")
+		other, err := s.Sign(Claims{Email: "mallory@example.com", List: "go", IssuedAt: issuedAt})
+		require.NoError(t, err)
+		forged := parts[0] + "." + strings.Split(other, ".")[1] + "." + parts[2]
+		_, err = s.Verify(forged)
+		assert.ErrorIs(t, err, ErrInvalid, k.Algorithm)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	old, err := NewSigner(hmacKey("a"))
+	require.NoError(t, err)
+	token, err := old.Sign(Claims{Email: "alice@example.com", List: "go", IssuedAt: time.Now()})
+	require.NoError(t, err)
+
+	// tokens signed with a previous key are still accepted until the key is removed
+	rotated, err := NewSigner(hmacKey("b"), hmacKey("a"))
+	require.NoError(t, err)
+	_, err = rotated.Verify(token)
+	assert.NoError(t, err)
+
+	removed, err := NewSigner(hmacKey("b"))
+	require.NoError(t, err)
+	_, err = removed.Verify(token)
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	s, err := NewSigner(hmacKey("a"))
+	require.NoError(t, err)
+
+	for _, token := range []string{"", "6ba7b810-9dad-11d1-80b4-00c04fd430c8", "v1.", "v1..", "v1.e30.!!!", "v1.!!!.e30"} {
+		_, err := s.Verify(token)
+		assert.ErrorIs(t, err, ErrInvalid, token)
+	}
+}
+
+func TestNewSigner(t *testing.T) {
+	_, err := NewSigner()
+	assert.Error(t, err)
+	_, err = NewSigner(Key{ID: "a", Algorithm: AlgorithmHMAC, Secret: []byte("short")})
+	assert.Error(t, err)
+	_, err = NewSigner(hmacKey("a"), hmacKey("a"))
+	assert.Error(t, err)
+	_, err = NewSigner(Key{ID: "a", Algorithm: "rsa"})
+	assert.Error(t, err)
+}
+
+func TestParseEd25519Key(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+
+	parsed, err := ParseEd25519Key(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	require.NoError(t, err)
+	assert.Equal(t, private, parsed)
+
+	_, err = ParseEd25519Key([]byte("not a key"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,113 @@
+// Package totp implements the time-based one-time passwords of RFC 6238, as shown by authenticator apps:
+// 6 digits derived with HMAC-SHA1 from a shared secret and the current 30 seconds step.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Period is how long a code is valid
+	Period = 30 * time.Second
+	// Digits is the length of codes
+	Digits = 6
+	// modulo truncates codes to Digits digits
+	modulo = 1000000
+	// skew is how many steps before and after the current one are accepted, for clocks that drift
+	skew = 1
+	// secretLength is the length of generated secrets, as long as the output of SHA-1
+	secretLength = 20
+)
+
+// ErrInvalidSecret is returned for secrets that are not base32 encoded
+var ErrInvalidSecret = errors.New("invalid TOTP secret")
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns a random secret, base32 encoded as authenticator apps expect it
+func NewSecret() (string, error) {
+	b := make([]byte, secretLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// URL returns the otpauth URL of a secret, which authenticator apps import, usually from a QR code
+func URL(issuer, account, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("digits", fmt.Sprint(Digits))
+	query.Set("period", fmt.Sprint(int(Period.Seconds())))
+	return "otpauth://totp/" + url.PathEscape(issuer+":"+account) + "?" + query.Encode()
+}
+
+// Code returns the code of a secret at t
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decode(secret)
+	if err != nil {
+		return "", err
+	}
+	return code(key, step(t)), nil
+}
+
+// Validate checks a code against the steps around t, and returns the step it matched,
+// so that callers can refuse a code that was already used: steps only go forward.
+func Validate(secret, passcode string, t time.Time) (int64, bool) {
+	key, err := decode(secret)
+	if err != nil {
+		return 0, false
+	}
+
+	passcode = strings.TrimSpace(passcode)
+	if len(passcode) != Digits {
+		return 0, false
+	}
+
+	current := step(t)
+	for s := current - skew; s <= current+skew; s++ {
+		if subtle.ConstantTimeCompare([]byte(code(key, s)), []byte(passcode)) == 1 {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+func step(t time.Time) int64 {
+	return t.Unix() / int64(Period.Seconds())
+}
+
+// code computes the code of a step, see RFC 4226 section 5.3
+func code(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	n := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, n%modulo)
+}
+
+func decode(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(strings.TrimRight(secret, "="), " ", ""))
+	key, err := encoding.DecodeString(secret)
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return key, nil
+}
@@ -0,0 +1,64 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA-1 secret of the test vectors of RFC 6238 appendix B
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	// the last 6 digits of the 8 digits codes of the RFC
+	for unix, want := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		got, err := Code(rfcSecret, time.Unix(unix, 0))
+		require.NoError(t, err)
+		assert.Equal(t, want, got, unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := NewSecret()
+	require.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	code, err := Code(secret, now)
+	require.NoError(t, err)
+
+	step, ok := Validate(secret, code, now)
+	assert.True(t, ok)
+	assert.Equal(t, now.Unix()/30, step)
+
+	// clocks drift by a step at most
+	_, ok = Validate(secret, code, now.Add(Period))
+	assert.True(t, ok)
+	_, ok = Validate(secret, code, now.Add(3*Period))
+	assert.False(t, ok)
+
+	// the secret is typed by hand sometimes
+	_, ok = Validate(strings.ToLower(secret), " "+code, now)
+	assert.True(t, ok)
+
+	for _, wrong := range []string{"", "12345", "abcdef"} {
+		_, ok = Validate(secret, wrong, now)
+		assert.False(t, ok, wrong)
+	}
+	_, ok = Validate("not base32!", code, now)
+	assert.False(t, ok)
+}
+
+func TestURL(t *testing.T) {
+	assert.Equal(t, "otpauth://totp/mailbus:alice?digits=6&issuer=mailbus&period=30&secret=ABC",
+		URL("mailbus", "alice", "ABC"))
+}
@@ -0,0 +1,207 @@
+// Package ratelimit throttles outgoing messages, globally and per recipient domain
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Quota represents the limits of a sender, zero values mean no limit
+type Quota struct {
+	Rate   float64 // messages per second
+	Burst  int     // messages sent at once before Rate applies, at least 1
+	Hourly int     // messages in any rolling hour
+	Daily  int     // messages in any rolling 24 hours
+}
+
+// Limiter throttles messages with a token bucket per quota, and pauses them once a rolling cap is reached
+// until the window has room again. Counts are kept in memory, the messages sent before a restart are
+// carried over with Record.
+type Limiter struct {
+	global  *quota
+	domains map[string]*quota
+
+	mu sync.Mutex
+	// now returns the current time, time.Now if nil
+	now func() time.Time
+}
+
+// quota is the state of a Quota
+type quota struct {
+	name    string
+	bucket  *rate.Limiter
+	windows []*window
+}
+
+// New returns a limiter that applies global to all messages
+func New(global Quota) *Limiter {
+	return &Limiter{
+		global:  newQuota("all domains", global),
+		domains: make(map[string]*quota),
+	}
+}
+
+// Limit applies a quota to the messages to some recipient domains, on top of the global one.
+// The domains share the quota: messages to any of them count against the same limits.
+func (l *Limiter) Limit(q Quota, domains ...string) {
+	s := newQuota(strings.ToLower(strings.Join(domains, ", ")), q)
+	for _, domain := range domains {
+		l.domains[strings.ToLower(domain)] = s
+	}
+}
+
+func newQuota(name string, q Quota) *quota {
+	s := &quota{name: name}
+	if q.Rate > 0 {
+		burst := q.Burst
+		if burst < 1 {
+			burst = int(math.Max(1, math.Ceil(q.Rate)))
+		}
+		s.bucket = rate.NewLimiter(rate.Limit(q.Rate), burst)
+	}
+	if q.Hourly > 0 {
+		s.windows = append(s.windows, &window{period: time.Hour, max: q.Hourly})
+	}
+	if q.Daily > 0 {
+		s.windows = append(s.windows, &window{period: 24 * time.Hour, max: q.Daily})
+	}
+
+	return s
+}
+
+// Record counts a message to email sent at some time in the rolling caps, without waiting.
+// It carries over the messages sent before the limiter was created: they are recorded in the order
+// they were sent, before any message is waited for.
+func (l *Limiter) Record(email string, sentAt time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	quotas := []*quota{l.global}
+	if q, ok := l.domains[domainOf(email)]; ok {
+		quotas = append(quotas, q)
+	}
+	for _, q := range quotas {
+		for _, w := range q.windows {
+			w.add(sentAt)
+		}
+	}
+}
+
+// Wait blocks until a message to email can be sent without exceeding any quota, or ctx is done.
+// A nil limiter never blocks.
+func (l *Limiter) Wait(ctx context.Context, email string) error {
+	if l == nil {
+		return nil
+	}
+
+	quotas := []*quota{l.global}
+	if q, ok := l.domains[domainOf(email)]; ok {
+		quotas = append(quotas, q)
+	}
+
+	for {
+		delay, name := l.reserve(quotas)
+		if delay == 0 {
+			break
+		}
+
+		// the cap is rolling: the message goes out as soon as the oldest one counted leaves the window
+		if delay >= time.Minute {
+			log.Printf("sending cap reached for %s, sending to %s paused for %s", name, email, delay.Round(time.Second))
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+
+	for _, q := range quotas {
+		if q.bucket == nil {
+			continue
+		}
+		if err := q.bucket.Wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reserve counts a message in the windows of all quotas if they all have room for it, otherwise
+// it returns how long to wait before trying again and the name of the quota that is exhausted
+func (l *Limiter) reserve(quotas []*quota) (time.Duration, string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if l.now != nil {
+		now = l.now()
+	}
+
+	var (
+		delay time.Duration
+		name  string
+	)
+	for _, q := range quotas {
+		for _, w := range q.windows {
+			if d := w.delay(now); d > delay {
+				delay, name = d, q.name
+			}
+		}
+	}
+	if delay > 0 {
+		return delay, name
+	}
+
+	for _, q := range quotas {
+		for _, w := range q.windows {
+			w.add(now)
+		}
+	}
+	return 0, ""
+}
+
+// window counts the messages sent in a rolling period
+type window struct {
+	period time.Duration
+	max    int
+
+	// sent holds the send times of the last max messages, next is the index of the oldest one once it is full
+	sent []time.Time
+	next int
+}
+
+// delay returns how long to wait before another message fits in the window
+func (w *window) delay(now time.Time) time.Duration {
+	if len(w.sent) < w.max {
+		return 0
+	}
+
+	if d := w.sent[w.next].Add(w.period).Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
+
+func (w *window) add(now time.Time) {
+	if len(w.sent) < w.max {
+		w.sent = append(w.sent, now)
+		return
+	}
+
+	w.sent[w.next] = now
+	w.next = (w.next + 1) % w.max
+}
+
+func domainOf(email string) string {
+	return strings.ToLower(email[strings.LastIndex(email, "@")+1:])
+}
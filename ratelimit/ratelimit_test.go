@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clock is a fake clock that only moves when told to
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func waitFor(l *Limiter, email string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	return l.Wait(ctx, email)
+}
+
+func TestDailyCap(t *testing.T) {
+	c := &clock{t: time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)}
+	l := New(Quota{Daily: 2})
+	l.now = c.now
+
+	require.NoError(t, waitFor(l, "alice@example.com"))
+	c.t = c.t.Add(12 * time.Hour)
+	require.NoError(t, waitFor(l, "bob@example.com"))
+
+	// the cap is reached: sending pauses instead of failing
+	assert.ErrorIs(t, waitFor(l, "carol@example.com"), context.DeadlineExceeded)
+
+	// and resumes once the first message is more than a day old
+	c.t = c.t.Add(12 * time.Hour)
+	require.NoError(t, waitFor(l, "carol@example.com"))
+	assert.ErrorIs(t, waitFor(l, "dave@example.com"), context.DeadlineExceeded)
+}
+
+func TestDomainQuota(t *testing.T) {
+	c := &clock{t: time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)}
+	l := New(Quota{Hourly: 3})
+	l.Limit(Quota{Hourly: 1}, "Gmail.com", "googlemail.com")
+	l.now = c.now
+
+	require.NoError(t, waitFor(l, "alice@gmail.com"))
+	assert.ErrorIs(t, waitFor(l, "bob@GMAIL.com"), context.DeadlineExceeded)
+	assert.ErrorIs(t, waitFor(l, "bob@googlemail.com"), context.DeadlineExceeded)
+
+	// other domains are only bound by the global quota
+	require.NoError(t, waitFor(l, "carol@example.com"))
+	require.NoError(t, waitFor(l, "dave@example.com"))
+	assert.ErrorIs(t, waitFor(l, "erin@example.com"), context.DeadlineExceeded)
+
+	c.t = c.t.Add(time.Hour)
+	require.NoError(t, waitFor(l, "bob@gmail.com"))
+}
+
+func TestRecord(t *testing.T) {
+	c := &clock{t: time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)}
+	l := New(Quota{Daily: 3})
+	l.Limit(Quota{Hourly: 1}, "gmail.com")
+	l.now = c.now
+
+	// the messages sent before a restart count against the caps
+	l.Record("alice@example.com", c.t.Add(-23*time.Hour))
+	l.Record("bob@gmail.com", c.t.Add(-30*time.Minute))
+	assert.ErrorIs(t, waitFor(l, "carol@gmail.com"), context.DeadlineExceeded)
+	require.NoError(t, waitFor(l, "carol@example.com"))
+	assert.ErrorIs(t, waitFor(l, "dave@example.com"), context.DeadlineExceeded)
+
+	c.t = c.t.Add(time.Hour)
+	require.NoError(t, waitFor(l, "dave@example.com"))
+}
+
+func TestRate(t *testing.T) {
+	l := New(Quota{Rate: 100, Burst: 1})
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, l.Wait(context.Background(), "alice@example.com"))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 35*time.Millisecond)
+}
+
+func TestNilLimiter(t *testing.T) {
+	var l *Limiter
+	assert.NoError(t, l.Wait(context.Background(), "alice@example.com"))
+}
@@ -0,0 +1,140 @@
+package mailbus
+
+import (
+	"sort"
+	"time"
+)
+
+// ReportService is the interface that wraps methods related to campaign reports
+type ReportService interface {
+	CampaignStats(campaignID int) (*CampaignStats, error)
+}
+
+// CampaignStats represents what happened to the deliveries of a campaign, and how subscribers engaged with it.
+// Opens flagged as made by a machine are counted apart and left out of the open rate and the time series.
+type CampaignStats struct {
+	CampaignID int `json:"campaign_id"`
+	Recipients int `json:"recipients"`
+	Delivered  int `json:"delivered"`
+	// Pending deliveries are queued, being sent or deferred to a later attempt
+	Pending    int `json:"pending"`
+	Failed     int `json:"failed"`
+	Suppressed int `json:"suppressed"`
+	// Bounced deliveries are the failed ones the mail server rejected, see Delivery.Bounce,
+	// and the sent ones a delivery status notification reported as failed
+	Bounced int `json:"bounced"`
+
+	// Opened, Clicked and Unsubscribed count subscribers, Opens and Clicks count events
+	Opened       int `json:"opened"`
+	Opens        int `json:"opens"`
+	MachineOpens int `json:"machine_opens"`
+	Clicked      int `json:"clicked"`
+	Clicks       int `json:"clicks"`
+	Unsubscribed int `json:"unsubscribed"`
+
+	Rates  CampaignRates `json:"rates"`
+	Hourly []HourlyStats `json:"hourly"`
+}
+
+// CampaignRates represents the rates of a campaign, between 0 and 1. Delivery and bounce rates are
+// relative to the recipients, click to open rate to the subscribers who opened, the others to the delivered messages.
+type CampaignRates struct {
+	Delivery    float64 `json:"delivery"`
+	Bounce      float64 `json:"bounce"`
+	Open        float64 `json:"open"`
+	Click       float64 `json:"click"`
+	ClickToOpen float64 `json:"click_to_open"`
+	Unsubscribe float64 `json:"unsubscribe"`
+}
+
+// HourlyStats represents the opens and clicks of a campaign in the hour starting at Hour, in UTC
+type HourlyStats struct {
+	Hour   time.Time `json:"hour"`
+	Opens  int       `json:"opens"`
+	Clicks int       `json:"clicks"`
+}
+
+// NewCampaignStats returns empty stats of a campaign
+func NewCampaignStats(campaignID int) *CampaignStats {
+	return &CampaignStats{
+		CampaignID: campaignID,
+		Hourly:     []HourlyStats{},
+	}
+}
+
+// CountDeliveries adds n deliveries in a status, bounced of which were rejected by the mail server.
+// The sent deliveries that bounced later, as a delivery status notification reported, count as failed.
+func (s *CampaignStats) CountDeliveries(status string, n, bounced int) {
+	s.Recipients += n
+	switch status {
+	case DeliveryStatusSent:
+		s.Delivered += n - bounced
+		s.Failed += bounced
+		s.Bounced += bounced
+	case DeliveryStatusFailed:
+		s.Failed += n
+		s.Bounced += bounced
+	case DeliveryStatusSuppressed:
+		s.Suppressed += n
+	default:
+		s.Pending += n
+	}
+}
+
+// CountEvents adds total tracking events of a type, made by unique subscribers
+func (s *CampaignStats) CountEvents(eventType string, machine bool, total, unique int) {
+	switch {
+	case eventType == TrackingEventOpen && machine:
+		s.MachineOpens += total
+	case eventType == TrackingEventOpen:
+		s.Opens += total
+		s.Opened += unique
+	case eventType == TrackingEventClick:
+		s.Clicks += total
+		s.Clicked += unique
+	case eventType == TrackingEventUnsubscribe:
+		s.Unsubscribed += unique
+	}
+}
+
+// CountHourly adds opens or clicks made by readers in the hour of t
+func (s *CampaignStats) CountHourly(eventType string, t time.Time, n int) {
+	if eventType != TrackingEventOpen && eventType != TrackingEventClick {
+		return
+	}
+
+	hour := t.UTC().Truncate(time.Hour)
+	i := sort.Search(len(s.Hourly), func(i int) bool {
+		return !s.Hourly[i].Hour.Before(hour)
+	})
+	if i == len(s.Hourly) || !s.Hourly[i].Hour.Equal(hour) {
+		s.Hourly = append(s.Hourly, HourlyStats{})
+		copy(s.Hourly[i+1:], s.Hourly[i:])
+		s.Hourly[i] = HourlyStats{Hour: hour}
+	}
+
+	if eventType == TrackingEventOpen {
+		s.Hourly[i].Opens += n
+	} else {
+		s.Hourly[i].Clicks += n
+	}
+}
+
+// ComputeRates computes the rates from the counts
+func (s *CampaignStats) ComputeRates() {
+	s.Rates = CampaignRates{
+		Delivery:    ratio(s.Delivered, s.Recipients),
+		Bounce:      ratio(s.Bounced, s.Recipients),
+		Open:        ratio(s.Opened, s.Delivered),
+		Click:       ratio(s.Clicked, s.Delivered),
+		ClickToOpen: ratio(s.Clicked, s.Opened),
+		Unsubscribe: ratio(s.Unsubscribed, s.Delivered),
+	}
+}
+
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
@@ -0,0 +1,47 @@
+package mailbus
+
+import (
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy decides whether and when a failed delivery is attempted again
+type RetryPolicy struct {
+	MaxAttempts     int
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+}
+
+// Backoff returns how long to wait after the given number of failed attempts:
+// the interval doubles after every attempt up to MaxInterval,
+// and half of it is randomized so that retries of a campaign do not all hit the server at once
+func (p RetryPolicy) Backoff(attempts int) time.Duration {
+	d := p.InitialInterval
+	for i := 1; i < attempts && d < p.MaxInterval; i++ {
+		d *= 2
+	}
+	if p.MaxInterval > 0 && d > p.MaxInterval {
+		d = p.MaxInterval
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// Retryable reports whether another attempt is allowed after the given number of attempts
+func (p RetryPolicy) Retryable(attempts int) bool {
+	return attempts < p.MaxAttempts
+}
+
+// Temporary reports whether the server asked to try again later (4xx), or could not be reached at all
+func (r *SMTPReply) Temporary() bool {
+	return r.Code == 0 || (r.Code >= 400 && r.Code < 500)
+}
+
+// Permanent reports whether the server rejected the message for good (5xx)
+func (r *SMTPReply) Permanent() bool {
+	return r.Code >= 500
+}
@@ -0,0 +1,22 @@
+package mailbus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts:     10,
+		InitialInterval: time.Minute,
+		MaxInterval:     10 * time.Minute,
+	}
+
+	for attempts, max := range map[int]time.Duration{1: time.Minute, 2: 2 * time.Minute, 3: 4 * time.Minute, 8: 10 * time.Minute} {
+		d := policy.Backoff(attempts)
+		assert.GreaterOrEqual(t, d, max/2)
+		assert.LessOrEqual(t, d, max)
+	}
+}
@@ -0,0 +1,78 @@
+package mailbus
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/quantonganh/mailbus/pkg/hash"
+)
+
+// LinkSignature represents the signature of a link sent in an email: the hash, the ID of the key that computed it,
+// empty for links signed before keys had IDs, and when the link expires, zero if it does not
+type LinkSignature struct {
+	KeyID     string
+	Hash      string
+	ExpiresAt time.Time
+}
+
+// signLink signs the message of a link with the current key of a keyring, until expiresAt if it is not zero
+func signLink(keyring *hash.Keyring, message string, expiresAt time.Time) (LinkSignature, error) {
+	var sig LinkSignature
+	if !expiresAt.IsZero() {
+		// links carry the expiry to the second
+		sig.ExpiresAt = time.Unix(expiresAt.Unix(), 0)
+	}
+
+	var err error
+	sig.KeyID, sig.Hash, err = keyring.Sign(sig.message(message))
+	return sig, err
+}
+
+// verify reports whether the signature was computed over the message of a link by one of the keys of a keyring.
+// It does not check the expiry, see Expired.
+func (sig LinkSignature) verify(keyring *hash.Keyring, message string) bool {
+	return keyring.Verify(sig.KeyID, sig.message(message), sig.Hash)
+}
+
+// message returns what is signed: the expiry cannot be changed without the key either
+func (sig LinkSignature) message(message string) string {
+	if sig.ExpiresAt.IsZero() {
+		return message
+	}
+	return message + ":" + strconv.FormatInt(sig.ExpiresAt.Unix(), 10)
+}
+
+// Expired reports whether the link has expired at t
+func (sig LinkSignature) Expired(t time.Time) bool {
+	return !sig.ExpiresAt.IsZero() && t.After(sig.ExpiresAt)
+}
+
+// encode adds the signature to the query of a link
+func (sig LinkSignature) encode(query url.Values) {
+	if sig.KeyID != "" {
+		query.Set("kid", sig.KeyID)
+	}
+	if !sig.ExpiresAt.IsZero() {
+		query.Set("expires", strconv.FormatInt(sig.ExpiresAt.Unix(), 10))
+	}
+	query.Set("hash", sig.Hash)
+}
+
+// ParseLinkSignature returns the signature carried by the query of a link
+func ParseLinkSignature(query url.Values) (LinkSignature, error) {
+	const op = "ParseLinkSignature"
+
+	sig := LinkSignature{
+		KeyID: query.Get("kid"),
+		Hash:  query.Get("hash"),
+	}
+	if v := query.Get("expires"); v != "" {
+		expires, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return LinkSignature{}, &Error{Code: ErrInvalid, Message: "Invalid link expiry.", Op: op, Err: err}
+		}
+		sig.ExpiresAt = time.Unix(expires, 0)
+	}
+	return sig, nil
+}
@@ -0,0 +1,246 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/quantonganh/mailbus"
+)
+
+type adminService struct {
+	db *DB
+}
+
+func NewAdminService(db *DB) mailbus.AdminService {
+	return &adminService{
+		db: db,
+	}
+}
+
+const adminColumns = "id, username, role, password_hash, totp_secret, totp_step, created_at, updated_at"
+
+// FindAll returns every admin, by username
+func (as *adminService) FindAll() ([]mailbus.Admin, error) {
+	rows, err := as.db.sqlDB.Query("SELECT " + adminColumns + " FROM admins ORDER BY username")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find admins: %w", err)
+	}
+	defer rows.Close()
+
+	var admins []mailbus.Admin
+	for rows.Next() {
+		a, err := scanAdmin(rows)
+		if err != nil {
+			return nil, &mailbus.Error{
+				Code: mailbus.ErrInternal,
+				Op:   "adminService.FindAll",
+				Err:  err,
+			}
+		}
+		admins = append(admins, *a)
+	}
+
+	return admins, rows.Err()
+}
+
+// FindByID finds an admin by ID
+func (as *adminService) FindByID(id int) (*mailbus.Admin, error) {
+	return as.findOne("adminService.FindByID", "id = ?", id)
+}
+
+// FindByUsername finds an admin by username
+func (as *adminService) FindByUsername(username string) (*mailbus.Admin, error) {
+	return as.findOne("adminService.FindByUsername", "username = ?", username)
+}
+
+func (as *adminService) findOne(op, where string, args ...interface{}) (*mailbus.Admin, error) {
+	a, err := scanAdmin(as.db.sqlDB.QueryRow("SELECT "+adminColumns+" FROM admins WHERE "+where, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &mailbus.Error{
+				Code:    mailbus.ErrNotFound,
+				Message: "Admin not found.",
+				Op:      op,
+			}
+		}
+		return nil, &mailbus.Error{
+			Code: mailbus.ErrInternal,
+			Op:   op,
+			Err:  err,
+		}
+	}
+
+	return a, nil
+}
+
+// Create saves a new admin
+func (as *adminService) Create(a *mailbus.Admin) error {
+	now := time.Now()
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = now
+	}
+	a.UpdatedAt = now
+
+	result, err := as.db.sqlDB.Exec(`INSERT INTO admins (username, role, password_hash, totp_secret, totp_step, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		a.Username, a.Role, a.PasswordHash, a.TOTPSecret, a.TOTPStep, a.CreatedAt.UTC(), a.UpdatedAt.UTC())
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return &mailbus.Error{
+				Code:    mailbus.ErrConflict,
+				Message: fmt.Sprintf("Admin %q already exists.", a.Username),
+				Op:      "adminService.Create",
+			}
+		}
+		return fmt.Errorf("failed to insert into admins table: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	a.ID = int(id)
+
+	return nil
+}
+
+// Update saves the role, the password and the two-factor authentication of an admin
+func (as *adminService) Update(a *mailbus.Admin) error {
+	a.UpdatedAt = time.Now()
+
+	result, err := as.db.sqlDB.Exec("UPDATE admins SET role = ?, password_hash = ?, totp_secret = ?, totp_step = ?, updated_at = ? WHERE id = ?",
+		a.Role, a.PasswordHash, a.TOTPSecret, a.TOTPStep, a.UpdatedAt.UTC(), a.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update admin: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if n == 0 {
+		return &mailbus.Error{
+			Code:    mailbus.ErrNotFound,
+			Message: "Admin not found.",
+			Op:      "adminService.Update",
+		}
+	}
+
+	return nil
+}
+
+// Delete deletes an admin, logging them out
+func (as *adminService) Delete(id int) (err error) {
+	tx, err := as.db.sqlDB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start a transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		_ = tx.Commit()
+	}()
+
+	if _, err = tx.Exec("DELETE FROM sessions WHERE admin_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete sessions: %w", err)
+	}
+
+	result, err := tx.Exec("DELETE FROM admins WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete admin: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if n == 0 {
+		return &mailbus.Error{
+			Code:    mailbus.ErrNotFound,
+			Message: "Admin not found.",
+			Op:      "adminService.Delete",
+		}
+	}
+
+	return nil
+}
+
+func scanAdmin(row scanner) (*mailbus.Admin, error) {
+	var a mailbus.Admin
+	if err := row.Scan(&a.ID, &a.Username, &a.Role, &a.PasswordHash, &a.TOTPSecret, &a.TOTPStep, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+type sessionService struct {
+	db *DB
+}
+
+func NewSessionService(db *DB) mailbus.SessionService {
+	return &sessionService{
+		db: db,
+	}
+}
+
+// FindByID finds a session by ID, expired sessions included
+func (ss *sessionService) FindByID(id string) (*mailbus.Session, error) {
+	const op = "sessionService.FindByID"
+
+	var s mailbus.Session
+	err := ss.db.sqlDB.QueryRow("SELECT id, admin_id, created_at, expires_at FROM sessions WHERE id = ?", id).
+		Scan(&s.ID, &s.AdminID, &s.CreatedAt, &s.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &mailbus.Error{
+				Code:    mailbus.ErrNotFound,
+				Message: "Session not found.",
+				Op:      op,
+			}
+		}
+		return nil, &mailbus.Error{
+			Code: mailbus.ErrInternal,
+			Op:   op,
+			Err:  err,
+		}
+	}
+
+	return &s, nil
+}
+
+// Create saves a new session
+func (ss *sessionService) Create(s *mailbus.Session) error {
+	_, err := ss.db.sqlDB.Exec("INSERT INTO sessions (id, admin_id, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		s.ID, s.AdminID, s.CreatedAt.UTC(), s.ExpiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to insert into sessions table: %w", err)
+	}
+
+	return nil
+}
+
+// Delete deletes a session, it is not an error if there is none
+func (ss *sessionService) Delete(id string) error {
+	if _, err := ss.db.sqlDB.Exec("DELETE FROM sessions WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpired deletes the sessions that have expired at now
+func (ss *sessionService) DeleteExpired(now time.Time) error {
+	if _, err := ss.db.sqlDB.Exec("DELETE FROM sessions WHERE expires_at <= ?", now.UTC()); err != nil {
+		return fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/quantonganh/mailbus"
+)
+
+type apiKeyService struct {
+	db *DB
+}
+
+func NewAPIKeyService(db *DB) mailbus.APIKeyService {
+	return &apiKeyService{
+		db: db,
+	}
+}
+
+const apiKeyColumns = "id, name, prefix, hash, scopes, admin_id, created_at, revoked_at"
+
+// FindAll returns every API key, revoked ones included, oldest first
+func (ks *apiKeyService) FindAll() ([]mailbus.APIKey, error) {
+	rows, err := ks.db.sqlDB.Query("SELECT " + apiKeyColumns + " FROM api_keys ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []mailbus.APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, &mailbus.Error{
+				Code: mailbus.ErrInternal,
+				Op:   "apiKeyService.FindAll",
+				Err:  err,
+			}
+		}
+		keys = append(keys, *k)
+	}
+
+	return keys, rows.Err()
+}
+
+// FindByPrefix finds an API key by its prefix
+func (ks *apiKeyService) FindByPrefix(prefix string) (*mailbus.APIKey, error) {
+	const op = "apiKeyService.FindByPrefix"
+
+	k, err := scanAPIKey(ks.db.sqlDB.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE prefix = ?", prefix))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &mailbus.Error{
+				Code:    mailbus.ErrNotFound,
+				Message: "API key not found.",
+				Op:      op,
+			}
+		}
+		return nil, &mailbus.Error{
+			Code: mailbus.ErrInternal,
+			Op:   op,
+			Err:  err,
+		}
+	}
+
+	return k, nil
+}
+
+// Create saves a new API key
+func (ks *apiKeyService) Create(k *mailbus.APIKey) error {
+	if k.CreatedAt.IsZero() {
+		k.CreatedAt = time.Now()
+	}
+
+	result, err := ks.db.sqlDB.Exec("INSERT INTO api_keys (name, prefix, hash, scopes, admin_id, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		k.Name, k.Prefix, k.Hash, strings.Join(k.Scopes, " "), k.AdminID, k.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to insert into api_keys table: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	k.ID = int(id)
+
+	return nil
+}
+
+// Revoke revokes an API key, it is kept so that what it did can still be traced to it
+func (ks *apiKeyService) Revoke(id int) error {
+	result, err := ks.db.sqlDB.Exec("UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL",
+		time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if n == 0 {
+		return &mailbus.Error{
+			Code:    mailbus.ErrNotFound,
+			Message: fmt.Sprintf("API key %d not found, or already revoked.", id),
+			Op:      "apiKeyService.Revoke",
+		}
+	}
+
+	return nil
+}
+
+func scanAPIKey(row scanner) (*mailbus.APIKey, error) {
+	var (
+		k         mailbus.APIKey
+		scopes    string
+		revokedAt sql.NullTime
+	)
+	if err := row.Scan(&k.ID, &k.Name, &k.Prefix, &k.Hash, &scopes, &k.AdminID, &k.CreatedAt, &revokedAt); err != nil {
+		return nil, err
+	}
+	k.Scopes = strings.Fields(scopes)
+	k.RevokedAt = revokedAt.Time
+
+	return &k, nil
+}
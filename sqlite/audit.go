@@ -0,0 +1,101 @@
+package sqlite
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/quantonganh/mailbus"
+)
+
+type auditService struct {
+	db *DB
+}
+
+func NewAuditService(db *DB) mailbus.AuditService {
+	return &auditService{
+		db: db,
+	}
+}
+
+// Record appends an event to the audit log
+func (as *auditService) Record(e *mailbus.AuditEvent) error {
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+
+	result, err := as.db.sqlDB.Exec(`
+		INSERT INTO audit_log (actor, action, target, details, ip, user_agent, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		e.Actor, e.Action, e.Target, e.Details, e.IP, e.UserAgent, e.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to insert into audit_log table: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	e.ID = int(id)
+
+	return nil
+}
+
+// Find finds audit events matching the filter, newest first
+func (as *auditService) Find(filter mailbus.AuditFilter) ([]mailbus.AuditEvent, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	if filter.Actor != "" {
+		where = append(where, "actor = ?")
+		args = append(args, filter.Actor)
+	}
+	if filter.Action != "" {
+		where = append(where, "action = ?")
+		args = append(args, filter.Action)
+	}
+	if filter.Target != "" {
+		where = append(where, "target = ?")
+		args = append(args, filter.Target)
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, filter.Until.UTC())
+	}
+
+	query := "SELECT id, actor, action, target, details, ip, user_agent, created_at FROM audit_log"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := as.db.sqlDB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find audit events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []mailbus.AuditEvent
+	for rows.Next() {
+		var e mailbus.AuditEvent
+		if err := rows.Scan(&e.ID, &e.Actor, &e.Action, &e.Target, &e.Details, &e.IP, &e.UserAgent, &e.CreatedAt); err != nil {
+			return nil, &mailbus.Error{
+				Code: mailbus.ErrInternal,
+				Op:   "auditService.Find",
+				Err:  err,
+			}
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
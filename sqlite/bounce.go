@@ -0,0 +1,78 @@
+package sqlite
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/quantonganh/mailbus"
+)
+
+type bounceService struct {
+	db *DB
+}
+
+func NewBounceService(db *DB) mailbus.BounceService {
+	return &bounceService{
+		db: db,
+	}
+}
+
+// Create records a bounce
+func (bs *bounceService) Create(b *mailbus.Bounce) error {
+	if b.CreatedAt.IsZero() {
+		b.CreatedAt = time.Now()
+	}
+
+	result, err := bs.db.sqlDB.Exec(`
+		INSERT INTO bounces (email, delivery_id, type, status, diagnostic, source, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		b.Email, b.DeliveryID, b.Type, b.Status, b.Diagnostic, b.Source, b.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to insert into bounces table: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	b.ID = int(id)
+
+	return nil
+}
+
+// Find finds the bounces of an address, newest first
+func (bs *bounceService) Find(email string) ([]mailbus.Bounce, error) {
+	rows, err := bs.db.sqlDB.Query(`
+		SELECT id, email, delivery_id, type, status, diagnostic, source, created_at
+		FROM bounces WHERE email = ? ORDER BY id DESC`, email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find bounces: %w", err)
+	}
+	defer rows.Close()
+
+	var bounces []mailbus.Bounce
+	for rows.Next() {
+		var b mailbus.Bounce
+		if err := rows.Scan(&b.ID, &b.Email, &b.DeliveryID, &b.Type, &b.Status, &b.Diagnostic, &b.Source, &b.CreatedAt); err != nil {
+			return nil, &mailbus.Error{
+				Code: mailbus.ErrInternal,
+				Op:   "bounceService.Find",
+				Err:  err,
+			}
+		}
+		bounces = append(bounces, b)
+	}
+
+	return bounces, rows.Err()
+}
+
+// Count counts the bounces of the given type of an address since a point in time
+func (bs *bounceService) Count(email, bounceType string, since time.Time) (int, error) {
+	var n int
+	err := bs.db.sqlDB.QueryRow("SELECT COUNT(*) FROM bounces WHERE email = ? AND type = ? AND created_at >= ?",
+		email, bounceType, since.UTC()).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count bounces: %w", err)
+	}
+	return n, nil
+}
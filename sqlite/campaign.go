@@ -0,0 +1,219 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/quantonganh/mailbus"
+)
+
+const campaignColumns = `
+	c.id, l.name, c.subject, c.body, c.status, c.scheduled_at, c.sent_at, c.error, c.created_at, c.updated_at
+	FROM campaigns c
+	JOIN lists l ON c.list_id = l.id`
+
+type campaignService struct {
+	db *DB
+}
+
+func NewCampaignService(db *DB) mailbus.CampaignService {
+	return &campaignService{
+		db: db,
+	}
+}
+
+// FindByID finds a campaign by ID
+func (cs *campaignService) FindByID(id int) (*mailbus.Campaign, error) {
+	const op = "campaignService.FindByID"
+
+	c, err := scanCampaign(cs.db.sqlDB.QueryRow("SELECT "+campaignColumns+" WHERE c.id = ?", id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &mailbus.Error{
+				Code:    mailbus.ErrNotFound,
+				Message: fmt.Sprintf("Campaign %d not found.", id),
+				Op:      op,
+			}
+		}
+		return nil, &mailbus.Error{
+			Code: mailbus.ErrInternal,
+			Op:   op,
+			Err:  err,
+		}
+	}
+
+	return c, nil
+}
+
+// Find finds campaigns matching the filter, newest first
+func (cs *campaignService) Find(filter mailbus.CampaignFilter) ([]mailbus.Campaign, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	if filter.List != "" {
+		where = append(where, "l.name = ?")
+		args = append(args, filter.List)
+	}
+	if filter.Status != "" {
+		where = append(where, "c.status = ?")
+		args = append(args, filter.Status)
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, "c.created_at >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, "c.created_at < ?")
+		args = append(args, filter.Until.UTC())
+	}
+
+	query := "SELECT " + campaignColumns
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY c.id DESC"
+
+	return cs.query(query, args...)
+}
+
+// FindDue finds scheduled campaigns whose time has come
+func (cs *campaignService) FindDue(now time.Time) ([]mailbus.Campaign, error) {
+	return cs.query("SELECT "+campaignColumns+" WHERE c.status = ? AND c.scheduled_at <= ? ORDER BY c.scheduled_at",
+		mailbus.CampaignStatusScheduled, now.UTC())
+}
+
+func (cs *campaignService) query(query string, args ...interface{}) ([]mailbus.Campaign, error) {
+	rows, err := cs.db.sqlDB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find campaigns: %w", err)
+	}
+	defer rows.Close()
+
+	var campaigns []mailbus.Campaign
+	for rows.Next() {
+		c, err := scanCampaign(rows)
+		if err != nil {
+			return nil, &mailbus.Error{
+				Code: mailbus.ErrInternal,
+				Op:   "campaignService.query",
+				Err:  err,
+			}
+		}
+		campaigns = append(campaigns, *c)
+	}
+
+	return campaigns, rows.Err()
+}
+
+// Create inserts a new campaign
+func (cs *campaignService) Create(c *mailbus.Campaign) error {
+	tx, err := cs.db.sqlDB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start a transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		_ = tx.Commit()
+	}()
+
+	var listID int64
+	listID, err = findListID(tx, c.List)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	result, err := tx.Exec(`
+		INSERT INTO campaigns (list_id, subject, body, status, scheduled_at, sent_at, error, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		listID, c.Subject, c.Body, c.Status, nullTime(c.ScheduledAt), nullTime(c.SentAt), c.Error, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to insert into campaigns table: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	c.ID = int(id)
+	c.CreatedAt = now
+	c.UpdatedAt = now
+
+	return nil
+}
+
+// Update saves all fields of an existing campaign
+func (cs *campaignService) Update(c *mailbus.Campaign) error {
+	tx, err := cs.db.sqlDB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start a transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		_ = tx.Commit()
+	}()
+
+	var listID int64
+	listID, err = findListID(tx, c.List)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	_, err = tx.Exec(`
+		UPDATE campaigns
+		SET list_id = ?, subject = ?, body = ?, status = ?, scheduled_at = ?, sent_at = ?, error = ?, updated_at = ?
+		WHERE id = ?`,
+		listID, c.Subject, c.Body, c.Status, nullTime(c.ScheduledAt), nullTime(c.SentAt), c.Error, now, c.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update campaign: %w", err)
+	}
+	c.UpdatedAt = now
+
+	return nil
+}
+
+// Delete deletes a campaign
+func (cs *campaignService) Delete(id int) error {
+	if _, err := cs.db.sqlDB.Exec("DELETE FROM campaigns WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete campaign: %w", err)
+	}
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCampaign(row scanner) (*mailbus.Campaign, error) {
+	var (
+		c                   mailbus.Campaign
+		scheduledAt, sentAt sql.NullTime
+	)
+	if err := row.Scan(&c.ID, &c.List, &c.Subject, &c.Body, &c.Status, &scheduledAt, &sentAt, &c.Error,
+		&c.CreatedAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	c.ScheduledAt = scheduledAt.Time
+	c.SentAt = sentAt.Time
+
+	return &c, nil
+}
+
+// nullTime stores the zero time as NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{
+		Time:  t.UTC(),
+		Valid: !t.IsZero(),
+	}
+}
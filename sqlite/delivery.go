@@ -0,0 +1,176 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/quantonganh/mailbus"
+)
+
+const deliveryColumns = `
+	id, campaign_id, subscriber_id, email, status, smtp_code, smtp_message, attempts,
+	queued_at, last_attempt_at, next_attempt_at, sent_at
+	FROM deliveries`
+
+type deliveryService struct {
+	db *DB
+}
+
+func NewDeliveryService(db *DB) mailbus.DeliveryService {
+	return &deliveryService{
+		db: db,
+	}
+}
+
+// FindByID finds a delivery by ID
+func (ds *deliveryService) FindByID(id int) (*mailbus.Delivery, error) {
+	const op = "deliveryService.FindByID"
+
+	d, err := scanDelivery(ds.db.sqlDB.QueryRow("SELECT "+deliveryColumns+" WHERE id = ?", id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &mailbus.Error{
+				Code:    mailbus.ErrNotFound,
+				Message: fmt.Sprintf("Delivery %d not found.", id),
+				Op:      op,
+			}
+		}
+		return nil, &mailbus.Error{
+			Code: mailbus.ErrInternal,
+			Op:   op,
+			Err:  err,
+		}
+	}
+
+	return d, nil
+}
+
+// Find finds deliveries matching the filter, newest first
+func (ds *deliveryService) Find(filter mailbus.DeliveryFilter) ([]mailbus.Delivery, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	if filter.CampaignID != 0 {
+		where = append(where, "campaign_id = ?")
+		args = append(args, filter.CampaignID)
+	}
+	if filter.SubscriberID != 0 {
+		where = append(where, "subscriber_id = ?")
+		args = append(args, filter.SubscriberID)
+	}
+	if filter.Email != "" {
+		where = append(where, "email = ? COLLATE NOCASE")
+		args = append(args, filter.Email)
+	}
+	if filter.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if !filter.SentSince.IsZero() {
+		where = append(where, "sent_at >= ?")
+		args = append(args, filter.SentSince.UTC())
+	}
+
+	query := "SELECT " + deliveryColumns
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC"
+
+	return ds.query(query, args...)
+}
+
+func (ds *deliveryService) query(query string, args ...interface{}) ([]mailbus.Delivery, error) {
+	rows, err := ds.db.sqlDB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []mailbus.Delivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, &mailbus.Error{
+				Code: mailbus.ErrInternal,
+				Op:   "deliveryService.query",
+				Err:  err,
+			}
+		}
+		deliveries = append(deliveries, *d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// FindDue finds deferred deliveries whose next attempt is due
+func (ds *deliveryService) FindDue(now time.Time) ([]mailbus.Delivery, error) {
+	return ds.query("SELECT "+deliveryColumns+" WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at",
+		mailbus.DeliveryStatusDeferred, now.UTC())
+}
+
+// Create inserts a new delivery, there can only be one per campaign and subscriber
+func (ds *deliveryService) Create(d *mailbus.Delivery) error {
+	result, err := ds.db.sqlDB.Exec(`
+		INSERT INTO deliveries (campaign_id, subscriber_id, email, status, smtp_code, smtp_message, attempts,
+			queued_at, last_attempt_at, next_attempt_at, sent_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		d.CampaignID, d.SubscriberID, d.Email, d.Status, d.SMTPCode, d.SMTPMessage, d.Attempts,
+		d.QueuedAt.UTC(), nullTime(d.LastAttemptAt), nullTime(d.NextAttemptAt), nullTime(d.SentAt))
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return &mailbus.Error{
+				Code:    mailbus.ErrConflict,
+				Message: fmt.Sprintf("Campaign %d has already been delivered to subscriber %d.", d.CampaignID, d.SubscriberID),
+				Op:      "deliveryService.Create",
+			}
+		}
+		return fmt.Errorf("failed to insert into deliveries table: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	d.ID = int(id)
+
+	return nil
+}
+
+// Update saves the state of a delivery
+func (ds *deliveryService) Update(d *mailbus.Delivery) error {
+	_, err := ds.db.sqlDB.Exec(`
+		UPDATE deliveries
+		SET status = ?, smtp_code = ?, smtp_message = ?, attempts = ?, last_attempt_at = ?, next_attempt_at = ?,
+			sent_at = ?
+		WHERE id = ?`,
+		d.Status, d.SMTPCode, d.SMTPMessage, d.Attempts, nullTime(d.LastAttemptAt), nullTime(d.NextAttemptAt),
+		nullTime(d.SentAt), d.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update delivery: %w", err)
+	}
+	return nil
+}
+
+func scanDelivery(row scanner) (*mailbus.Delivery, error) {
+	var (
+		d                                    mailbus.Delivery
+		lastAttemptAt, nextAttemptAt, sentAt sql.NullTime
+	)
+	if err := row.Scan(&d.ID, &d.CampaignID, &d.SubscriberID, &d.Email, &d.Status, &d.SMTPCode, &d.SMTPMessage,
+		&d.Attempts, &d.QueuedAt, &lastAttemptAt, &nextAttemptAt, &sentAt); err != nil {
+		return nil, err
+	}
+	d.LastAttemptAt = lastAttemptAt.Time
+	d.NextAttemptAt = nextAttemptAt.Time
+	d.SentAt = sentAt.Time
+
+	return &d, nil
+}
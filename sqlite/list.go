@@ -0,0 +1,152 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/quantonganh/mailbus"
+)
+
+type listService struct {
+	db *DB
+}
+
+func NewListService(db *DB) mailbus.ListService {
+	return &listService{
+		db: db,
+	}
+}
+
+// FindAll returns all lists ordered by name
+func (ls *listService) FindAll() ([]mailbus.List, error) {
+	rows, err := ls.db.sqlDB.Query("SELECT id, name, title, description, track_opens, track_clicks, created_at FROM lists ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find lists: %w", err)
+	}
+	defer rows.Close()
+
+	var lists []mailbus.List
+	for rows.Next() {
+		var l mailbus.List
+		if err := rows.Scan(&l.ID, &l.Name, &l.Title, &l.Description, &l.TrackOpens, &l.TrackClicks, &l.CreatedAt); err != nil {
+			return nil, &mailbus.Error{
+				Code: mailbus.ErrInternal,
+				Op:   "listService.FindAll",
+				Err:  err,
+			}
+		}
+		lists = append(lists, l)
+	}
+
+	return lists, rows.Err()
+}
+
+// FindByName finds a list by its name
+func (ls *listService) FindByName(name string) (*mailbus.List, error) {
+	const op = "listService.FindByName"
+
+	var l mailbus.List
+	err := ls.db.sqlDB.QueryRow(`
+		SELECT id, name, title, description, track_opens, track_clicks, created_at
+		FROM lists WHERE name = ?`, name).
+		Scan(&l.ID, &l.Name, &l.Title, &l.Description, &l.TrackOpens, &l.TrackClicks, &l.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &mailbus.Error{
+				Code:    mailbus.ErrNotFound,
+				Message: fmt.Sprintf("List %q not found.", name),
+				Op:      op,
+			}
+		}
+		return nil, &mailbus.Error{
+			Code: mailbus.ErrInternal,
+			Op:   op,
+			Err:  err,
+		}
+	}
+
+	return &l, nil
+}
+
+// Create creates a new list
+func (ls *listService) Create(l *mailbus.List) error {
+	result, err := ls.db.sqlDB.Exec("INSERT INTO lists (name, title, description, track_opens, track_clicks) VALUES (?, ?, ?, ?, ?)",
+		l.Name, l.Title, l.Description, l.TrackOpens, l.TrackClicks)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return &mailbus.Error{
+				Code:    mailbus.ErrConflict,
+				Message: fmt.Sprintf("List %q already exists.", l.Name),
+				Op:      "listService.Create",
+			}
+		}
+		return fmt.Errorf("failed to insert into lists table: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	l.ID = int(id)
+
+	return nil
+}
+
+// Update saves the title, the description and the tracking settings of a list, its name cannot change
+func (ls *listService) Update(l *mailbus.List) error {
+	result, err := ls.db.sqlDB.Exec("UPDATE lists SET title = ?, description = ?, track_opens = ?, track_clicks = ? WHERE name = ?",
+		l.Title, l.Description, l.TrackOpens, l.TrackClicks, l.Name)
+	if err != nil {
+		return fmt.Errorf("failed to update list: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if n == 0 {
+		return &mailbus.Error{
+			Code:    mailbus.ErrNotFound,
+			Message: fmt.Sprintf("List %q not found.", l.Name),
+			Op:      "listService.Update",
+		}
+	}
+
+	return nil
+}
+
+// Delete deletes a list and all of its memberships
+func (ls *listService) Delete(name string) error {
+	tx, err := ls.db.sqlDB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start a transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		_ = tx.Commit()
+	}()
+
+	_, err = tx.Exec("DELETE FROM list_subscriptions WHERE list_id = (SELECT id FROM lists WHERE name = ?)", name)
+	if err != nil {
+		return fmt.Errorf("failed to delete list subscriptions: %w", err)
+	}
+
+	_, err = tx.Exec("DELETE FROM subscription_tokens WHERE list_id = (SELECT id FROM lists WHERE name = ?)", name)
+	if err != nil {
+		return fmt.Errorf("failed to delete subscription tokens: %w", err)
+	}
+
+	_, err = tx.Exec("DELETE FROM lists WHERE name = ?", name)
+	if err != nil {
+		return fmt.Errorf("failed to delete list: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+CREATE TABLE subscriptions_old (
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    email         TEXT NOT NULL UNIQUE,
+    status        TEXT NOT NULL,
+    subscribed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO subscriptions_old (id, email, status, subscribed_at)
+SELECT s.id, s.email, COALESCE(ls.status, 'unsubscribed'), s.created_at
+FROM subscriptions s
+LEFT JOIN list_subscriptions ls
+    ON ls.subscriber_id = s.id AND ls.list_id = (SELECT id FROM lists WHERE name = 'default');
+
+DROP TABLE list_subscriptions;
+DROP TABLE subscriptions;
+ALTER TABLE subscriptions_old RENAME TO subscriptions;
+
+CREATE TABLE subscription_tokens_old (
+    subscription_token TEXT NOT NULL,
+    subscriber_id      INTEGER NOT NULL REFERENCES subscriptions (id),
+
+    PRIMARY KEY (subscription_token)
+);
+
+INSERT INTO subscription_tokens_old (subscription_token, subscriber_id)
+SELECT subscription_token, subscriber_id FROM subscription_tokens;
+
+DROP TABLE subscription_tokens;
+ALTER TABLE subscription_tokens_old RENAME TO subscription_tokens;
+
+DROP TABLE lists;
@@ -0,0 +1,39 @@
+CREATE TABLE lists (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    name        TEXT NOT NULL UNIQUE,
+    title       TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO lists (name, title) VALUES ('default', 'Default');
+
+CREATE TABLE list_subscriptions (
+    list_id       INTEGER NOT NULL REFERENCES lists (id),
+    subscriber_id INTEGER NOT NULL REFERENCES subscriptions (id),
+    status        TEXT NOT NULL,
+    subscribed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (list_id, subscriber_id)
+);
+
+CREATE INDEX list_subscriptions_status_idx ON list_subscriptions (list_id, status);
+
+INSERT INTO list_subscriptions (list_id, subscriber_id, status, subscribed_at)
+SELECT (SELECT id FROM lists WHERE name = 'default'), id, status, subscribed_at
+FROM subscriptions;
+
+CREATE TABLE subscriptions_new (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    email      TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO subscriptions_new (id, email, created_at)
+SELECT id, email, subscribed_at FROM subscriptions;
+
+DROP TABLE subscriptions;
+ALTER TABLE subscriptions_new RENAME TO subscriptions;
+
+ALTER TABLE subscription_tokens ADD COLUMN list_id INTEGER REFERENCES lists (id);
+UPDATE subscription_tokens SET list_id = (SELECT id FROM lists WHERE name = 'default');
@@ -0,0 +1,14 @@
+CREATE TABLE campaigns (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    list_id      INTEGER NOT NULL REFERENCES lists (id),
+    subject      TEXT NOT NULL,
+    body         TEXT NOT NULL,
+    status       TEXT NOT NULL,
+    scheduled_at TIMESTAMP,
+    sent_at      TIMESTAMP,
+    error        TEXT NOT NULL DEFAULT '',
+    created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX campaigns_status_scheduled_at_idx ON campaigns (status, scheduled_at);
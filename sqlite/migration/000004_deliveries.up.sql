@@ -0,0 +1,18 @@
+CREATE TABLE deliveries (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    campaign_id     INTEGER NOT NULL REFERENCES campaigns (id),
+    subscriber_id   INTEGER NOT NULL REFERENCES subscriptions (id),
+    email           TEXT NOT NULL,
+    status          TEXT NOT NULL,
+    smtp_code       INTEGER NOT NULL DEFAULT 0,
+    smtp_message    TEXT NOT NULL DEFAULT '',
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    queued_at       TIMESTAMP NOT NULL,
+    last_attempt_at TIMESTAMP,
+    sent_at         TIMESTAMP,
+
+    UNIQUE (campaign_id, subscriber_id)
+);
+
+CREATE INDEX deliveries_email_idx ON deliveries (email);
+CREATE INDEX deliveries_status_idx ON deliveries (status);
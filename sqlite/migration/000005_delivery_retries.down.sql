@@ -0,0 +1 @@
+DROP INDEX deliveries_next_attempt_at_idx;
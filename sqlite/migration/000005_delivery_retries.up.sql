@@ -0,0 +1,3 @@
+ALTER TABLE deliveries ADD COLUMN next_attempt_at TIMESTAMP;
+
+CREATE INDEX deliveries_next_attempt_at_idx ON deliveries (status, next_attempt_at);
@@ -0,0 +1,11 @@
+CREATE TABLE bounces (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    email      TEXT NOT NULL,
+    type       TEXT NOT NULL,
+    status     TEXT NOT NULL DEFAULT '',
+    diagnostic TEXT NOT NULL DEFAULT '',
+    source     TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX bounces_email_idx ON bounces (email, type, created_at);
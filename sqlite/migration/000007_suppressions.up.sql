@@ -0,0 +1,7 @@
+CREATE TABLE suppressions (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    value      TEXT NOT NULL UNIQUE,
+    source     TEXT NOT NULL,
+    reason     TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL
+);
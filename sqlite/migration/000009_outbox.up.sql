@@ -0,0 +1,14 @@
+CREATE TABLE outbox (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind            TEXT NOT NULL,
+    recipient       TEXT NOT NULL,
+    payload         TEXT NOT NULL DEFAULT '',
+    status          TEXT NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    last_error      TEXT NOT NULL DEFAULT '',
+    created_at      TIMESTAMP NOT NULL,
+    next_attempt_at TIMESTAMP NOT NULL,
+    sent_at         TIMESTAMP
+);
+
+CREATE INDEX outbox_next_attempt_at_idx ON outbox (status, next_attempt_at);
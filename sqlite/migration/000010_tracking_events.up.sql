@@ -0,0 +1,15 @@
+CREATE TABLE tracking_events (
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    type          TEXT NOT NULL,
+    delivery_id   INTEGER NOT NULL REFERENCES deliveries (id),
+    campaign_id   INTEGER NOT NULL REFERENCES campaigns (id),
+    subscriber_id INTEGER NOT NULL,
+    machine       BOOLEAN NOT NULL DEFAULT FALSE,
+    reason        TEXT NOT NULL DEFAULT '',
+    ip            TEXT NOT NULL DEFAULT '',
+    user_agent    TEXT NOT NULL DEFAULT '',
+    created_at    TIMESTAMP NOT NULL
+);
+
+CREATE INDEX tracking_events_campaign_idx ON tracking_events (campaign_id, type, created_at);
+CREATE INDEX tracking_events_delivery_idx ON tracking_events (delivery_id);
@@ -0,0 +1 @@
+DROP INDEX tracking_events_url_idx;
@@ -0,0 +1,3 @@
+ALTER TABLE tracking_events ADD COLUMN url TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX tracking_events_url_idx ON tracking_events (campaign_id, url);
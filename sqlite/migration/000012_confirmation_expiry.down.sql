@@ -0,0 +1 @@
+DROP INDEX subscription_tokens_subscription_idx;
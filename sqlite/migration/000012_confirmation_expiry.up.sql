@@ -0,0 +1,7 @@
+ALTER TABLE subscription_tokens ADD COLUMN issued_at TIMESTAMP;
+ALTER TABLE subscription_tokens ADD COLUMN expires_at TIMESTAMP;
+
+ALTER TABLE list_subscriptions ADD COLUMN confirmation_sent_at TIMESTAMP;
+ALTER TABLE list_subscriptions ADD COLUMN reminded_at TIMESTAMP;
+
+CREATE INDEX subscription_tokens_subscription_idx ON subscription_tokens (list_id, subscriber_id);
@@ -0,0 +1,9 @@
+CREATE TABLE api_keys (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    name       TEXT NOT NULL,
+    prefix     TEXT NOT NULL UNIQUE,
+    hash       TEXT NOT NULL,
+    scopes     TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
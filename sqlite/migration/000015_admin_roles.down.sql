@@ -0,0 +1 @@
+-- the bundled SQLite cannot drop columns, the added ones are left in place
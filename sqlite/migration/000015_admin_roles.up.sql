@@ -0,0 +1,6 @@
+-- admins who existed before roles keep managing everything
+ALTER TABLE admins ADD COLUMN role TEXT NOT NULL DEFAULT 'owner';
+ALTER TABLE admins ADD COLUMN totp_secret TEXT NOT NULL DEFAULT '';
+ALTER TABLE admins ADD COLUMN totp_step INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE api_keys ADD COLUMN admin_id INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- the merged subscribers and the original case of their addresses are not restored
+DROP INDEX subscriptions_email_nocase_idx;
@@ -0,0 +1,30 @@
+-- addresses are compared regardless of case: the subscribers whose addresses differ only by case
+-- are merged into the oldest of them, then every address is stored lower-cased
+CREATE TEMP TABLE subscriber_merges AS
+SELECT s.id AS old_id, (SELECT MIN(o.id) FROM subscriptions o WHERE lower(o.email) = lower(s.email)) AS new_id
+FROM subscriptions s;
+DELETE FROM subscriber_merges WHERE old_id = new_id;
+
+UPDATE OR IGNORE list_subscriptions
+SET subscriber_id = (SELECT new_id FROM subscriber_merges WHERE old_id = subscriber_id)
+WHERE subscriber_id IN (SELECT old_id FROM subscriber_merges);
+DELETE FROM list_subscriptions WHERE subscriber_id IN (SELECT old_id FROM subscriber_merges);
+
+UPDATE subscription_tokens
+SET subscriber_id = (SELECT new_id FROM subscriber_merges WHERE old_id = subscriber_id)
+WHERE subscriber_id IN (SELECT old_id FROM subscriber_merges);
+
+UPDATE OR IGNORE deliveries
+SET subscriber_id = (SELECT new_id FROM subscriber_merges WHERE old_id = subscriber_id)
+WHERE subscriber_id IN (SELECT old_id FROM subscriber_merges);
+
+UPDATE tracking_events
+SET subscriber_id = (SELECT new_id FROM subscriber_merges WHERE old_id = subscriber_id)
+WHERE subscriber_id IN (SELECT old_id FROM subscriber_merges);
+
+DELETE FROM subscriptions WHERE id IN (SELECT old_id FROM subscriber_merges);
+DROP TABLE subscriber_merges;
+
+UPDATE subscriptions SET email = lower(email);
+
+CREATE UNIQUE INDEX subscriptions_email_nocase_idx ON subscriptions (email COLLATE NOCASE);
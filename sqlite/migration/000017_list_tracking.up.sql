@@ -0,0 +1,2 @@
+ALTER TABLE lists ADD COLUMN track_opens BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE lists ADD COLUMN track_clicks BOOLEAN NOT NULL DEFAULT FALSE;
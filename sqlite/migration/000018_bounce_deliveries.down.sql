@@ -0,0 +1,2 @@
+DROP INDEX bounces_delivery_idx;
+-- the bundled SQLite cannot drop columns, the added one is left in place
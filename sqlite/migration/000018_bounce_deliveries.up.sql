@@ -0,0 +1,3 @@
+ALTER TABLE bounces ADD COLUMN delivery_id INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX bounces_delivery_idx ON bounces (delivery_id);
@@ -0,0 +1 @@
+-- the bundled SQLite cannot drop columns, the added one is left in place
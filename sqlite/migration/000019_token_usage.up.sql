@@ -0,0 +1,9 @@
+-- a confirmation token is spent once used, the tokens of the subscriptions that are no longer pending are spent already
+ALTER TABLE subscription_tokens ADD COLUMN used_at TIMESTAMP;
+
+UPDATE subscription_tokens SET used_at = CURRENT_TIMESTAMP
+WHERE NOT EXISTS (
+    SELECT 1 FROM list_subscriptions ls
+    WHERE ls.list_id = subscription_tokens.list_id AND ls.subscriber_id = subscription_tokens.subscriber_id
+    AND ls.status = 'pending_confirmation'
+);
@@ -0,0 +1,83 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/quantonganh/mailbus"
+)
+
+type outboxService struct {
+	db *DB
+}
+
+func NewOutboxService(db *DB) mailbus.OutboxService {
+	return &outboxService{
+		db: db,
+	}
+}
+
+// FindDue finds the pending messages whose next attempt is due, oldest first
+func (o *outboxService) FindDue(now time.Time) ([]mailbus.OutboxMessage, error) {
+	rows, err := o.db.sqlDB.Query(`
+		SELECT id, kind, recipient, payload, status, attempts, last_error, created_at, next_attempt_at, sent_at
+		FROM outbox
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY next_attempt_at`, mailbus.OutboxStatusPending, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to find due outbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []mailbus.OutboxMessage
+	for rows.Next() {
+		var (
+			m      mailbus.OutboxMessage
+			sentAt sql.NullTime
+		)
+		if err := rows.Scan(&m.ID, &m.Kind, &m.Recipient, &m.Payload, &m.Status, &m.Attempts, &m.LastError,
+			&m.CreatedAt, &m.NextAttemptAt, &sentAt); err != nil {
+			return nil, &mailbus.Error{
+				Code: mailbus.ErrInternal,
+				Op:   "outboxService.FindDue",
+				Err:  err,
+			}
+		}
+		m.SentAt = sentAt.Time
+		messages = append(messages, m)
+	}
+
+	return messages, rows.Err()
+}
+
+// Update saves the state of a message
+func (o *outboxService) Update(m *mailbus.OutboxMessage) error {
+	_, err := o.db.sqlDB.Exec(`
+		UPDATE outbox SET status = ?, attempts = ?, last_error = ?, next_attempt_at = ?, sent_at = ?
+		WHERE id = ?`,
+		m.Status, m.Attempts, m.LastError, m.NextAttemptAt.UTC(), nullTime(m.SentAt), m.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update outbox message: %w", err)
+	}
+	return nil
+}
+
+// insertOutboxMessage writes a message to the outbox as part of a transaction
+func insertOutboxMessage(tx *sql.Tx, m *mailbus.OutboxMessage) error {
+	result, err := tx.Exec(`
+		INSERT INTO outbox (kind, recipient, payload, status, attempts, created_at, next_attempt_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		m.Kind, m.Recipient, m.Payload, m.Status, m.Attempts, m.CreatedAt.UTC(), m.NextAttemptAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to insert into outbox table: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	m.ID = int(id)
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package sqlite
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/quantonganh/mailbus"
+)
+
+type reportService struct {
+	db *DB
+}
+
+func NewReportService(db *DB) mailbus.ReportService {
+	return &reportService{
+		db: db,
+	}
+}
+
+// CampaignStats aggregates the deliveries and the tracking events of a campaign
+func (rs *reportService) CampaignStats(campaignID int) (*mailbus.CampaignStats, error) {
+	stats := mailbus.NewCampaignStats(campaignID)
+
+	rows, err := rs.db.sqlDB.Query(`
+		SELECT status, COUNT(*),
+			SUM(CASE WHEN smtp_code >= 400 OR id IN (SELECT delivery_id FROM bounces) THEN 1 ELSE 0 END)
+		FROM deliveries
+		WHERE campaign_id = ?
+		GROUP BY status`, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			status     string
+			n, bounced int
+		)
+		if err := rows.Scan(&status, &n, &bounced); err != nil {
+			return nil, fmt.Errorf("failed to scan delivery counts: %w", err)
+		}
+		stats.CountDeliveries(status, n, bounced)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = rs.db.sqlDB.Query(`
+		SELECT type, machine, COUNT(*), COUNT(DISTINCT subscriber_id)
+		FROM tracking_events
+		WHERE campaign_id = ?
+		GROUP BY type, machine`, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tracking events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			eventType     string
+			machine       bool
+			total, unique int
+		)
+		if err := rows.Scan(&eventType, &machine, &total, &unique); err != nil {
+			return nil, fmt.Errorf("failed to scan tracking event counts: %w", err)
+		}
+		stats.CountEvents(eventType, machine, total, unique)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// times are stored in UTC, so the hours are UTC ones
+	rows, err = rs.db.sqlDB.Query(`
+		SELECT strftime('%Y-%m-%d %H:00:00', created_at) AS hour, type, COUNT(*)
+		FROM tracking_events
+		WHERE campaign_id = ? AND NOT machine AND type IN (?, ?)
+		GROUP BY hour, type`, campaignID, mailbus.TrackingEventOpen, mailbus.TrackingEventClick)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tracking events by hour: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			hour, eventType string
+			n               int
+		)
+		if err := rows.Scan(&hour, &eventType, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan hourly tracking event counts: %w", err)
+		}
+		t, err := time.Parse("2006-01-02 15:04:05", hour)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse hour %q: %w", hour, err)
+		}
+		stats.CountHourly(eventType, t, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	stats.ComputeRates()
+	return stats, nil
+}
@@ -9,6 +9,7 @@ import (
 	"io/fs"
 	"log"
 	"sort"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -46,7 +47,15 @@ func (db *DB) Open() (err error) {
 		return nil
 	}
 
-	if db.sqlDB, err = sql.Open("sqlite3", db.path); err != nil {
+	// campaigns are sent by several workers at once: wait for the write lock instead of failing with SQLITE_BUSY
+	dsn := db.path
+	if strings.Contains(dsn, "?") {
+		dsn += "&_busy_timeout=5000"
+	} else {
+		dsn += "?_busy_timeout=5000"
+	}
+
+	if db.sqlDB, err = sql.Open("sqlite3", dsn); err != nil {
 		return err
 	}
 
@@ -62,7 +71,7 @@ func (db *DB) migrate() error {
 		return fmt.Errorf("cannot create migrations table: %w", err)
 	}
 
-	names, err := fs.Glob(migrationFS, "migration/*.sql")
+	names, err := fs.Glob(migrationFS, "migration/*.up.sql")
 	if err != nil {
 		return err
 	}
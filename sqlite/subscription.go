@@ -4,6 +4,10 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
 
 	"github.com/quantonganh/mailbus"
 )
@@ -18,19 +22,35 @@ func NewSubscriptionService(db *DB) mailbus.SubscriptionService {
 	}
 }
 
-// FindByEmail finds a subscription by email
-func (ss *subscriptionService) FindByEmail(email string) (*mailbus.Subscriber, error) {
-	var s mailbus.Subscriber
-	err := ss.db.sqlDB.QueryRow("SELECT email, status FROM subscriptions WHERE email = ?", email).
-		Scan(&s.Email, &s.Status)
+// FindByEmail finds the subscription of an email to a list
+func (ss *subscriptionService) FindByEmail(list, email string) (*mailbus.Subscriber, error) {
+	const op = "subscriptionService.FindByEmail"
+
+	s, err := scanSubscriber(ss.db.sqlDB.QueryRow(`
+		SELECT `+subscriberColumns+`
+		FROM list_subscriptions ls
+		JOIN subscriptions s ON ls.subscriber_id = s.id
+		JOIN lists l ON ls.list_id = l.id
+		WHERE l.name = ? AND s.email = ? COLLATE NOCASE`, list, email))
 	if err != nil {
-		return nil, err
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &mailbus.Error{
+				Code: mailbus.ErrNotFound,
+				Op:   op,
+				Err:  err,
+			}
+		}
+		return nil, &mailbus.Error{
+			Code: mailbus.ErrInternal,
+			Op:   op,
+			Err:  err,
+		}
 	}
-	return &s, nil
+	return s, nil
 }
 
-// Insert inserts new subscription into stormDB
-func (ss *subscriptionService) Insert(s *mailbus.Subscription) error {
+// Insert inserts new subscription into the database
+func (ss *subscriptionService) Insert(s *mailbus.Subscription) (err error) {
 	tx, err := ss.db.sqlDB.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to start a transaction: %w", err)
@@ -40,87 +60,267 @@ func (ss *subscriptionService) Insert(s *mailbus.Subscription) error {
 			_ = tx.Rollback()
 			return
 		}
-		_ = tx.Commit()
+		err = tx.Commit()
 	}()
 
-	result, err := tx.Exec("INSERT INTO subscriptions (email, status) VALUES (?, ?)",
-		s.Email, s.Status)
+	var listID int64
+	listID, err = findListID(tx, s.List)
 	if err != nil {
-		return fmt.Errorf("failed to insert into subscriptions table: %w", err)
+		return err
 	}
 
-	lastInsertID, err := result.LastInsertId()
+	_, err = tx.Exec("INSERT OR IGNORE INTO subscriptions (email) VALUES (?)", mailbus.NormalizeEmail(s.Email))
 	if err != nil {
-		return fmt.Errorf("failed to get last insert ID: %w", err)
+		return fmt.Errorf("failed to insert into subscriptions table: %w", err)
+	}
+
+	var subscriberID int64
+	if err = tx.QueryRow("SELECT id FROM subscriptions WHERE email = ? COLLATE NOCASE", s.Email).Scan(&subscriberID); err != nil {
+		return fmt.Errorf("failed to find subscriber ID: %w", err)
 	}
 
-	_, err = tx.Exec("INSERT INTO subscription_tokens (subscription_token, subscriber_id) VALUES (?, ?)",
-		s.Token, lastInsertID)
+	_, err = tx.Exec(`
+		INSERT INTO list_subscriptions (list_id, subscriber_id, status, subscribed_at, confirmation_sent_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		listID, subscriberID, s.Status, startedAt(s), nullTime(s.IssuedAt))
 	if err != nil {
-		return fmt.Errorf("failed to insert into subscriptions table: %w", err)
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+			return &mailbus.Error{
+				Code:    mailbus.ErrConflict,
+				Message: fmt.Sprintf("%s is already subscribed to list %q.", s.Email, s.List),
+				Op:      "subscriptionService.Insert",
+			}
+		}
+		return fmt.Errorf("failed to insert into list_subscriptions table: %w", err)
 	}
 
-	return nil
+	if err = insertToken(tx, s, listID, subscriberID); err != nil {
+		return err
+	}
+
+	if s.Message != nil {
+		err = insertOutboxMessage(tx, s.Message)
+	}
+
+	return err
 }
 
-// Update updates subscription status and new token
-func (ss *subscriptionService) Update(email, token string) error {
-	_, err := ss.db.sqlDB.Exec("UPDATE subscriptions SET status = ?, token = ? WHERE email = ?",
-		mailbus.StatusPendingConfirmation, token, email)
+// Update sets a subscription back to pending confirmation with a new token. Subscriptions that were not pending
+// start over, the ones that were keep the time they became pending.
+func (ss *subscriptionService) Update(s *mailbus.Subscription) (err error) {
+	tx, err := ss.db.sqlDB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start a transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	var listID, subscriberID int64
+	err = tx.QueryRow(`
+		SELECT ls.list_id, ls.subscriber_id
+		FROM list_subscriptions ls
+		JOIN subscriptions s ON ls.subscriber_id = s.id
+		JOIN lists l ON ls.list_id = l.id
+		WHERE l.name = ? AND s.email = ? COLLATE NOCASE`, s.List, s.Email).Scan(&listID, &subscriberID)
+	if err != nil {
+		return fmt.Errorf("failed to find subscription: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE list_subscriptions
+		SET subscribed_at = CASE WHEN status = ? THEN subscribed_at ELSE ? END,
+			reminded_at = CASE WHEN status = ? THEN reminded_at END,
+			status = ?, confirmation_sent_at = ?
+		WHERE list_id = ? AND subscriber_id = ?`,
+		mailbus.StatusPendingConfirmation, startedAt(s), mailbus.StatusPendingConfirmation,
+		mailbus.StatusPendingConfirmation, nullTime(s.IssuedAt), listID, subscriberID)
 	if err != nil {
 		return fmt.Errorf("failed to update: %w", err)
 	}
-	return nil
+
+	if s.Reminder {
+		_, err = tx.Exec("UPDATE list_subscriptions SET reminded_at = ? WHERE list_id = ? AND subscriber_id = ?",
+			nullTime(s.IssuedAt), listID, subscriberID)
+		if err != nil {
+			return fmt.Errorf("failed to update: %w", err)
+		}
+	}
+
+	if err = insertToken(tx, s, listID, subscriberID); err != nil {
+		return err
+	}
+
+	if s.Message != nil {
+		err = insertOutboxMessage(tx, s.Message)
+	}
+
+	return err
 }
 
-// FindByStatus finds subscription by status
-func (ss *subscriptionService) FindByStatus(status string) ([]mailbus.Subscriber, error) {
+// FindByStatus finds the subscriptions to a list by status
+func (ss *subscriptionService) FindByStatus(list, status string) ([]mailbus.Subscriber, error) {
 	const op = "subscriptionService.FindByStatus"
 
-	var subscribers []mailbus.Subscriber
-	rows, err := ss.db.sqlDB.Query("SELECT * FROM subscriptions WHERE status = ?", status)
+	rows, err := ss.db.sqlDB.Query(`
+		SELECT `+subscriberColumns+`
+		FROM list_subscriptions ls
+		JOIN subscriptions s ON ls.subscriber_id = s.id
+		JOIN lists l ON ls.list_id = l.id
+		WHERE l.name = ? AND ls.status = ?
+		ORDER BY s.id`, list, status)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find by status: %w", err)
 	}
 	defer rows.Close()
 
-	// Iterate over the rows and populate the subscriptions slice
-	for rows.Next() {
-		var s mailbus.Subscriber
-		err := rows.Scan(&s.ID, &s.Email, &s.Status, &s.SubscribedAt)
+	return scanSubscribers(rows, op)
+}
+
+// Find searches the subscriptions to every list, ordered by address and list
+func (ss *subscriptionService) Find(filter mailbus.SubscriberFilter) ([]mailbus.Subscriber, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	if filter.List != "" {
+		where, args = append(where, "l.name = ?"), append(args, filter.List)
+	}
+	if filter.Status != "" {
+		where, args = append(where, "ls.status = ?"), append(args, filter.Status)
+	}
+	if filter.Query != "" {
+		// LIKE ignores the case of ASCII letters
+		where, args = append(where, `s.email LIKE ? ESCAPE '\'`), append(args, "%"+escapeLike(filter.Query)+"%")
+	}
+	if !filter.Since.IsZero() {
+		where, args = append(where, "ls.subscribed_at >= ?"), append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		where, args = append(where, "ls.subscribed_at < ?"), append(args, filter.Until.UTC())
+	}
+
+	query := `
+		SELECT ` + subscriberColumns + `
+		FROM list_subscriptions ls
+		JOIN subscriptions s ON ls.subscriber_id = s.id
+		JOIN lists l ON ls.list_id = l.id`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY s.email, l.name"
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	} else if filter.Offset > 0 {
+		query += " LIMIT -1 OFFSET ?"
+		args = append(args, filter.Offset)
+	}
+
+	rows, err := ss.db.sqlDB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	return scanSubscribers(rows, "subscriptionService.Find")
+}
+
+// FindPending finds the subscriptions to any list pending confirmation since before since
+func (ss *subscriptionService) FindPending(since time.Time) ([]mailbus.Subscriber, error) {
+	rows, err := ss.db.sqlDB.Query(`
+		SELECT `+subscriberColumns+`
+		FROM list_subscriptions ls
+		JOIN subscriptions s ON ls.subscriber_id = s.id
+		JOIN lists l ON ls.list_id = l.id
+		WHERE ls.status = ? AND ls.subscribed_at < ?
+		ORDER BY ls.subscribed_at`, mailbus.StatusPendingConfirmation, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to find pending subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	return scanSubscribers(rows, "subscriptionService.FindPending")
+}
+
+// Purge deletes the subscriptions pending confirmation since before since, and their tokens
+func (ss *subscriptionService) Purge(since time.Time) (n int, err error) {
+	tx, err := ss.db.sqlDB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start a transaction: %w", err)
+	}
+	defer func() {
 		if err != nil {
-			return nil, &mailbus.Error{
-				Code: mailbus.ErrInternal,
-				Op:   op,
-				Err:  err,
-			}
+			_ = tx.Rollback()
+			return
 		}
-		subscribers = append(subscribers, s)
+		err = tx.Commit()
+	}()
+
+	_, err = tx.Exec(`
+		DELETE FROM subscription_tokens
+		WHERE (list_id, subscriber_id) IN (
+			SELECT list_id, subscriber_id FROM list_subscriptions WHERE status = ? AND subscribed_at < ?
+		)`, mailbus.StatusPendingConfirmation, since.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete tokens: %w", err)
+	}
+
+	result, err := tx.Exec("DELETE FROM list_subscriptions WHERE status = ? AND subscribed_at < ?",
+		mailbus.StatusPendingConfirmation, since.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete pending subscriptions: %w", err)
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count deleted subscriptions: %w", err)
 	}
 
-	return subscribers, nil
+	return int(purged), nil
 }
 
-// Subscribe subscribes to newsletter
-func (ss *subscriptionService) Confirm(token string) (string, error) {
+// Confirm activates the subscription the token was issued for. A token is used once: confirming spends it
+// along with the other tokens of the subscription, and the subscriptions that are no longer pending are left alone.
+func (ss *subscriptionService) Confirm(token string) (_ *mailbus.Subscriber, err error) {
 	const op = "subscriptionService.Confirm"
 
-	row := ss.db.sqlDB.QueryRow(`
-		SELECT s.id, s.email
+	tx, err := ss.db.sqlDB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start a transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	row := tx.QueryRow(`
+		SELECT s.id, s.email, l.id, l.name, ls.status, t.expires_at, t.used_at
 		FROM subscription_tokens t
 		JOIN subscriptions s ON t.subscriber_id = s.id
+		JOIN lists l ON t.list_id = l.id
+		JOIN list_subscriptions ls ON ls.list_id = t.list_id AND ls.subscriber_id = t.subscriber_id
 		WHERE t.subscription_token = ?`, token)
 	var (
-		subscriberID int64
-		email        string
+		subscriberID, listID int64
+		expiresAt, usedAt    sql.NullTime
+		s                    mailbus.Subscriber
 	)
-	if err := row.Scan(&subscriberID, &email); err != nil {
+	if err = row.Scan(&subscriberID, &s.Email, &listID, &s.List, &s.Status, &expiresAt, &usedAt); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return "", &mailbus.Error{
+			return nil, &mailbus.Error{
 				Code: mailbus.ErrNotFound,
 			}
 		} else {
-			return "", &mailbus.Error{
+			return nil, &mailbus.Error{
 				Code: mailbus.ErrInternal,
 				Op:   op,
 				Err:  err,
@@ -128,19 +328,201 @@ func (ss *subscriptionService) Confirm(token string) (string, error) {
 		}
 	}
 
-	_, err := ss.db.sqlDB.Exec("UPDATE subscriptions SET status = ? WHERE id = ?", mailbus.StatusActive, subscriberID)
+	if usedAt.Valid || s.Status != mailbus.StatusPendingConfirmation {
+		return nil, mailbus.TokenUsedError(op)
+	}
+	if expiresAt.Valid && time.Now().After(expiresAt.Time) {
+		return nil, mailbus.TokenExpiredError(op)
+	}
+
+	_, err = tx.Exec("UPDATE list_subscriptions SET status = ? WHERE list_id = ? AND subscriber_id = ?",
+		mailbus.StatusActive, listID, subscriberID)
 	if err != nil {
-		return "", fmt.Errorf("failed to update status: %w", err)
+		return nil, fmt.Errorf("failed to update status: %w", err)
 	}
-	return email, nil
+
+	_, err = tx.Exec("UPDATE subscription_tokens SET used_at = ? WHERE list_id = ? AND subscriber_id = ? AND used_at IS NULL",
+		time.Now().UTC(), listID, subscriberID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to spend tokens: %w", err)
+	}
+
+	s.ID = int(subscriberID)
+	s.Status = mailbus.StatusActive
+	return &s, nil
 }
 
-// Unsubscribe unsubscribes from newsletter
-func (ss *subscriptionService) Unsubscribe(email string) error {
-	_, err := ss.db.sqlDB.Exec("UPDATE subscriptions SET status = ? WHERE email = ?",
-		mailbus.StatusUnsubscribed, email)
+// Unsubscribe unsubscribes an email from a list
+func (ss *subscriptionService) Unsubscribe(list, email string) error {
+	result, err := ss.db.sqlDB.Exec(`
+		UPDATE list_subscriptions SET status = ?
+		WHERE list_id = (SELECT id FROM lists WHERE name = ?)
+		AND subscriber_id = (SELECT id FROM subscriptions WHERE email = ? COLLATE NOCASE)`,
+		mailbus.StatusUnsubscribed, list, email)
 	if err != nil {
 		return fmt.Errorf("failed to update status: %w", err)
 	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if n == 0 {
+		return &mailbus.Error{
+			Code:    mailbus.ErrNotFound,
+			Message: fmt.Sprintf("%s is not subscribed to %s.", email, list),
+			Op:      "subscriptionService.Unsubscribe",
+		}
+	}
+
+	return nil
+}
+
+// MarkBounced marks the subscriptions of an email to every list as bounced
+func (ss *subscriptionService) MarkBounced(email string) error {
+	_, err := ss.db.sqlDB.Exec(`
+		UPDATE list_subscriptions SET status = ?
+		WHERE subscriber_id = (SELECT id FROM subscriptions WHERE email = ? COLLATE NOCASE)
+		AND status IN (?, ?)`,
+		mailbus.StatusBounced, email, mailbus.StatusActive, mailbus.StatusPendingConfirmation)
+	if err != nil {
+		return fmt.Errorf("failed to update status: %w", err)
+	}
+	return nil
+}
+
+// Delete deletes the subscription of an email to a list and its tokens, the address stays in other lists
+func (ss *subscriptionService) Delete(list, email string) (err error) {
+	tx, err := ss.db.sqlDB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start a transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	_, err = tx.Exec(`
+		DELETE FROM subscription_tokens
+		WHERE list_id = (SELECT id FROM lists WHERE name = ?)
+		AND subscriber_id = (SELECT id FROM subscriptions WHERE email = ? COLLATE NOCASE)`, list, email)
+	if err != nil {
+		return fmt.Errorf("failed to delete tokens: %w", err)
+	}
+
+	result, err := tx.Exec(`
+		DELETE FROM list_subscriptions
+		WHERE list_id = (SELECT id FROM lists WHERE name = ?)
+		AND subscriber_id = (SELECT id FROM subscriptions WHERE email = ? COLLATE NOCASE)`, list, email)
+	if err != nil {
+		return fmt.Errorf("failed to delete subscription: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if n == 0 {
+		return &mailbus.Error{
+			Code:    mailbus.ErrNotFound,
+			Message: fmt.Sprintf("%s is not subscribed to %s.", email, list),
+			Op:      "subscriptionService.Delete",
+		}
+	}
+
 	return nil
 }
+
+// Activate activates the subscription of an email to a list
+func (ss *subscriptionService) Activate(list, email string) (*mailbus.Subscriber, error) {
+	_, err := ss.db.sqlDB.Exec(`
+		UPDATE list_subscriptions SET status = ?
+		WHERE list_id = (SELECT id FROM lists WHERE name = ?)
+		AND subscriber_id = (SELECT id FROM subscriptions WHERE email = ? COLLATE NOCASE)`,
+		mailbus.StatusActive, list, email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update status: %w", err)
+	}
+
+	return ss.FindByEmail(list, email)
+}
+
+// startedAt returns when a subscription starts: when its first token is issued
+func startedAt(s *mailbus.Subscription) time.Time {
+	if s.IssuedAt.IsZero() {
+		return time.Now().UTC()
+	}
+	return s.IssuedAt.UTC()
+}
+
+// insertToken saves the confirmation token of a subscription, signed tokens are not stored,
+// nor are the missing tokens of subscriptions that need no confirmation
+func insertToken(tx *sql.Tx, s *mailbus.Subscription, listID, subscriberID int64) error {
+	if s.Signed || s.Token == "" {
+		return nil
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO subscription_tokens (subscription_token, subscriber_id, list_id, issued_at, expires_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		s.Token, subscriberID, listID, nullTime(s.IssuedAt), nullTime(s.ExpiresAt))
+	if err != nil {
+		return fmt.Errorf("failed to insert into subscription_tokens table: %w", err)
+	}
+	return nil
+}
+
+const subscriberColumns = "s.id, s.email, l.name, ls.status, ls.subscribed_at, ls.confirmation_sent_at, ls.reminded_at"
+
+func scanSubscriber(row scanner) (*mailbus.Subscriber, error) {
+	var (
+		s                              mailbus.Subscriber
+		confirmationSentAt, remindedAt sql.NullTime
+	)
+	if err := row.Scan(&s.ID, &s.Email, &s.List, &s.Status, &s.SubscribedAt, &confirmationSentAt, &remindedAt); err != nil {
+		return nil, err
+	}
+	s.ConfirmationSentAt = confirmationSentAt.Time
+	s.RemindedAt = remindedAt.Time
+
+	return &s, nil
+}
+
+func scanSubscribers(rows *sql.Rows, op string) ([]mailbus.Subscriber, error) {
+	var subscribers []mailbus.Subscriber
+	for rows.Next() {
+		s, err := scanSubscriber(rows)
+		if err != nil {
+			return nil, &mailbus.Error{
+				Code: mailbus.ErrInternal,
+				Op:   op,
+				Err:  err,
+			}
+		}
+		subscribers = append(subscribers, *s)
+	}
+
+	return subscribers, rows.Err()
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern, with a backslash
+func escapeLike(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(s)
+}
+
+func findListID(tx *sql.Tx, name string) (int64, error) {
+	var id int64
+	if err := tx.QueryRow("SELECT id FROM lists WHERE name = ?", name).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, &mailbus.Error{
+				Code:    mailbus.ErrNotFound,
+				Message: fmt.Sprintf("List %q not found.", name),
+			}
+		}
+		return 0, fmt.Errorf("failed to find list: %w", err)
+	}
+	return id, nil
+}
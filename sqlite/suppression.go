@@ -0,0 +1,116 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/quantonganh/mailbus"
+)
+
+type suppressionService struct {
+	db *DB
+}
+
+func NewSuppressionService(db *DB) mailbus.SuppressionService {
+	return &suppressionService{
+		db: db,
+	}
+}
+
+// FindAll returns the whole suppression list, newest first
+func (ss *suppressionService) FindAll() ([]mailbus.Suppression, error) {
+	rows, err := ss.db.sqlDB.Query("SELECT id, value, source, reason, created_at FROM suppressions ORDER BY id DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find suppressions: %w", err)
+	}
+	defer rows.Close()
+
+	var suppressions []mailbus.Suppression
+	for rows.Next() {
+		var s mailbus.Suppression
+		if err := rows.Scan(&s.ID, &s.Value, &s.Source, &s.Reason, &s.CreatedAt); err != nil {
+			return nil, &mailbus.Error{
+				Code: mailbus.ErrInternal,
+				Op:   "suppressionService.FindAll",
+				Err:  err,
+			}
+		}
+		suppressions = append(suppressions, s)
+	}
+
+	return suppressions, rows.Err()
+}
+
+// Match returns the entry that suppresses an address, either the address itself or its domain,
+// or nil if the address can be mailed
+func (ss *suppressionService) Match(email string) (*mailbus.Suppression, error) {
+	keys := mailbus.SuppressionKeys(email)
+
+	var s mailbus.Suppression
+	err := ss.db.sqlDB.QueryRow("SELECT id, value, source, reason, created_at FROM suppressions WHERE value IN (?, ?) LIMIT 1",
+		keys[0], keys[len(keys)-1]).
+		Scan(&s.ID, &s.Value, &s.Source, &s.Reason, &s.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to match suppressions: %w", err)
+	}
+
+	return &s, nil
+}
+
+// Create adds an entry to the suppression list
+func (ss *suppressionService) Create(s *mailbus.Suppression) error {
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = time.Now()
+	}
+
+	result, err := ss.db.sqlDB.Exec("INSERT INTO suppressions (value, source, reason, created_at) VALUES (?, ?, ?, ?)",
+		s.Value, s.Source, s.Reason, s.CreatedAt.UTC())
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return &mailbus.Error{
+				Code:    mailbus.ErrConflict,
+				Message: fmt.Sprintf("%s is already suppressed.", s.Value),
+				Op:      "suppressionService.Create",
+			}
+		}
+		return fmt.Errorf("failed to insert into suppressions table: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	s.ID = int(id)
+
+	return nil
+}
+
+// Delete removes an address or a domain from the suppression list
+func (ss *suppressionService) Delete(value string) error {
+	result, err := ss.db.sqlDB.Exec("DELETE FROM suppressions WHERE value = ?", value)
+	if err != nil {
+		return fmt.Errorf("failed to delete suppression: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if n == 0 {
+		return &mailbus.Error{
+			Code:    mailbus.ErrNotFound,
+			Message: fmt.Sprintf("%s is not suppressed.", value),
+			Op:      "suppressionService.Delete",
+		}
+	}
+
+	return nil
+}
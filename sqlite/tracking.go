@@ -0,0 +1,91 @@
+package sqlite
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/quantonganh/mailbus"
+)
+
+type trackingService struct {
+	db *DB
+}
+
+func NewTrackingService(db *DB) mailbus.TrackingService {
+	return &trackingService{
+		db: db,
+	}
+}
+
+// Record saves a tracking event
+func (ts *trackingService) Record(e *mailbus.TrackingEvent) error {
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+
+	result, err := ts.db.sqlDB.Exec(`
+		INSERT INTO tracking_events (type, delivery_id, campaign_id, subscriber_id, url, machine, reason, ip, user_agent, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.Type, e.DeliveryID, e.CampaignID, e.SubscriberID, e.URL, e.Machine, e.Reason, e.IP, e.UserAgent, e.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to insert into tracking_events table: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	e.ID = int(id)
+
+	return nil
+}
+
+// Find finds tracking events matching the filter, oldest first
+func (ts *trackingService) Find(filter mailbus.TrackingFilter) ([]mailbus.TrackingEvent, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	if filter.CampaignID != 0 {
+		where = append(where, "campaign_id = ?")
+		args = append(args, filter.CampaignID)
+	}
+	if filter.DeliveryID != 0 {
+		where = append(where, "delivery_id = ?")
+		args = append(args, filter.DeliveryID)
+	}
+	if filter.Type != "" {
+		where = append(where, "type = ?")
+		args = append(args, filter.Type)
+	}
+
+	query := `SELECT id, type, delivery_id, campaign_id, subscriber_id, url, machine, reason, ip, user_agent, created_at
+		FROM tracking_events`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id"
+
+	rows, err := ts.db.sqlDB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find tracking events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []mailbus.TrackingEvent
+	for rows.Next() {
+		var e mailbus.TrackingEvent
+		if err := rows.Scan(&e.ID, &e.Type, &e.DeliveryID, &e.CampaignID, &e.SubscriberID, &e.URL, &e.Machine, &e.Reason,
+			&e.IP, &e.UserAgent, &e.CreatedAt); err != nil {
+			return nil, &mailbus.Error{
+				Code: mailbus.ErrInternal,
+				Op:   "trackingService.Find",
+				Err:  err,
+			}
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
@@ -1,48 +1,154 @@
 package mailbus
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // Subscribe status
 const (
 	StatusPendingConfirmation = "pending_confirmation"
 	StatusActive              = "active"
 	StatusUnsubscribed        = "unsubscribed"
+	StatusBounced             = "bounced"
 )
 
 // SubscriptionService is the interface that wraps methods related to subscribe function
 type SubscriptionService interface {
-	FindByEmail(email string) (*Subscriber, error)
+	FindByEmail(list, email string) (*Subscriber, error)
 	Insert(s *Subscription) error
-	Update(email, token string) error
-	FindByStatus(status string) ([]Subscriber, error)
-	Confirm(token string) (string, error)
-	Unsubscribe(email string) error
+	Update(s *Subscription) error
+	FindByStatus(list, status string) ([]Subscriber, error)
+	Find(filter SubscriberFilter) ([]Subscriber, error)
+	FindPending(since time.Time) ([]Subscriber, error)
+	Purge(since time.Time) (int, error)
+	Confirm(token string) (*Subscriber, error)
+	Activate(list, email string) (*Subscriber, error)
+	Unsubscribe(list, email string) error
+	MarkBounced(email string) error
+	Delete(list, email string) error
 }
 
-// Subscriber represents a subscriber
+// Subscriber represents the membership of an email address in a list
 type Subscriber struct {
-	ID           int    `storm:"id,increment"`
-	Email        string `storm:"unique"`
-	Status       string `storm:"index"`
-	SubscribedAt time.Time
+	ID           int       `storm:"id,increment" json:"id"`
+	Email        string    `storm:"index" json:"email"`
+	List         string    `storm:"index" json:"list"`
+	Status       string    `storm:"index" json:"status"`
+	SubscribedAt time.Time `json:"subscribed_at"`
+	// ConfirmationSentAt is when the last confirmation token was issued, RemindedAt when the reminder was
+	ConfirmationSentAt time.Time `json:"confirmation_sent_at"`
+	RemindedAt         time.Time `json:"reminded_at"`
+}
+
+// SubscriberFilter represents the criteria used to search subscribers, zero values match everything
+type SubscriberFilter struct {
+	List   string
+	Status string
+	// Query matches the addresses that contain it, regardless of case
+	Query string
+	// Since and Until bound when the subscribers subscribed, Until excluded
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+	Offset int
 }
 
 type Subscription struct {
+	List   string
 	Email  string
 	Status string
 	Token  string
+	// IssuedAt is when the token was issued, it expires at ExpiresAt unless that is zero
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	// Signed tokens carry the subscription they were issued for, they are not stored
+	Signed bool
+	// Reminder tells that the token is reissued to remind a pending subscriber
+	Reminder bool
+
+	// Message, if not nil, is written to the outbox in the same transaction as the subscription
+	Message *OutboxMessage
 }
 
 // NewSubscription returns new subscriber
-func NewSubscription(email, status, token string) *Subscription {
+func NewSubscription(list, email, status, token string) *Subscription {
 	return &Subscription{
-		Email:  email,
-		Status: status,
-		Token:  token,
+		List:     list,
+		Email:    email,
+		Status:   status,
+		Token:    token,
+		IssuedAt: time.Now(),
+	}
+}
+
+// NormalizeEmail returns an address the way it is stored, trimmed and lower-cased,
+// so that an address is subscribed once whatever the case it is typed in
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// ConfirmationPolicy decides how long confirmation tokens last, how often they are sent,
+// and what becomes of the subscriptions that are never confirmed. Zero values disable each rule.
+type ConfirmationPolicy struct {
+	// TokenTTL is how long a confirmation token can be used
+	TokenTTL time.Duration
+	// Cooldown is how long a pending subscriber waits before a confirmation email is sent again
+	Cooldown time.Duration
+	// RemindAfter is how long a subscription is pending before its one reminder is sent
+	RemindAfter time.Duration
+	// PurgeAfter is how long a subscription is pending before it is deleted
+	PurgeAfter time.Duration
+}
+
+// Expiry returns when a token issued at issuedAt expires, the zero time if it does not
+func (p ConfirmationPolicy) Expiry(issuedAt time.Time) time.Time {
+	if p.TokenTTL <= 0 {
+		return time.Time{}
+	}
+	return issuedAt.Add(p.TokenTTL)
+}
+
+// CoolingDown reports whether a confirmation email was sent to a pending subscriber too recently to send another one
+func (p ConfirmationPolicy) CoolingDown(s *Subscriber, now time.Time) bool {
+	return now.Sub(s.ConfirmationSentAt) < p.Cooldown
+}
+
+// Remindable reports whether a pending subscriber is due their reminder
+func (p ConfirmationPolicy) Remindable(s *Subscriber, now time.Time) bool {
+	return p.RemindAfter > 0 && s.RemindedAt.IsZero() && now.Sub(s.SubscribedAt) >= p.RemindAfter &&
+		(p.PurgeAfter <= 0 || now.Sub(s.SubscribedAt) < p.PurgeAfter)
+}
+
+// TokenExpiredError returns the error of a confirmation token used after it expired
+func TokenExpiredError(op string) error {
+	return &Error{
+		Code:    ErrInvalid,
+		Message: "Token has expired, please subscribe again.",
+		Op:      op,
+	}
+}
+
+// TokenUsedError returns the error of a confirmation token used again, or used for a subscription
+// that is no longer pending: it was confirmed, unsubscribed or bounced since
+func TokenUsedError(op string) error {
+	return &Error{
+		Code:    ErrConflict,
+		Message: "Token has already been used.",
+		Op:      op,
 	}
 }
 
 type SubscriptionRequest struct {
 	URL   string `json:"url"`
 	Email string `json:"email"`
+	List  string `json:"list"`
+}
+
+// SubscriberRequest represents a request of the admin API to add a subscriber to a list, or to change its status
+type SubscriberRequest struct {
+	Email  string `json:"email"`
+	Status string `json:"status"`
+	// URL is where the confirmation link leads, for subscribers added pending confirmation
+	URL string `json:"url"`
 }
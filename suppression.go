@@ -0,0 +1,129 @@
+package mailbus
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// Suppression source
+const (
+	SuppressionSourceBounce    = "bounce"
+	SuppressionSourceComplaint = "complaint"
+	SuppressionSourceManual    = "manual"
+	SuppressionSourceImport    = "import"
+)
+
+// SuppressionService is the interface that wraps methods related to the suppression list
+type SuppressionService interface {
+	FindAll() ([]Suppression, error)
+	Match(email string) (*Suppression, error)
+	Create(s *Suppression) error
+	Delete(value string) error
+}
+
+// Suppression represents an address, or a whole domain, that must never be mailed
+type Suppression struct {
+	ID        int       `storm:"id,increment" json:"id"`
+	Value     string    `storm:"unique" json:"value"`
+	Source    string    `storm:"index" json:"source"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SuppressionRequest represents a request to add an entry to the suppression list
+type SuppressionRequest struct {
+	Value  string `json:"value"`
+	Reason string `json:"reason"`
+}
+
+// NewSuppression validates and normalizes an address such as alice@example.com,
+// or a domain such as example.com, and returns a suppression for it
+func NewSuppression(value, source, reason string) (*Suppression, error) {
+	const op = "NewSuppression"
+
+	switch source {
+	case SuppressionSourceBounce, SuppressionSourceComplaint, SuppressionSourceManual, SuppressionSourceImport:
+	default:
+		return nil, &Error{
+			Code:    ErrInvalid,
+			Message: fmt.Sprintf("Unknown suppression source %q.", source),
+			Op:      op,
+		}
+	}
+
+	value = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(value)), "@")
+	if strings.Contains(value, "@") {
+		addr, err := mail.ParseAddress(value)
+		if err != nil || addr.Address != value {
+			return nil, &Error{
+				Code:    ErrInvalid,
+				Message: fmt.Sprintf("Invalid email address %q.", value),
+				Op:      op,
+				Err:     err,
+			}
+		}
+	} else if !validDomain(value) {
+		return nil, &Error{
+			Code:    ErrInvalid,
+			Message: fmt.Sprintf("Invalid domain %q.", value),
+			Op:      op,
+		}
+	}
+
+	return &Suppression{
+		Value:     value,
+		Source:    source,
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// IsDomain reports whether the suppression covers a whole domain
+func (s *Suppression) IsDomain() bool {
+	return !strings.Contains(s.Value, "@")
+}
+
+// SuppressionKeys returns the suppression values that cover an address: the address itself and its domain
+func SuppressionKeys(email string) []string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	keys := []string{email}
+	if i := strings.LastIndex(email, "@"); i >= 0 {
+		keys = append(keys, email[i+1:])
+	}
+	return keys
+}
+
+// SuppressedError returns the error of a message that was not sent because its recipient is suppressed
+func SuppressedError(email string, s *Suppression) error {
+	reason := s.Source
+	if s.Reason != "" {
+		reason += ": " + s.Reason
+	}
+
+	return &Error{
+		Code:    ErrSuppressed,
+		Message: fmt.Sprintf("%s is suppressed by %q (%s)", email, s.Value, reason),
+		Op:      "SuppressedError",
+	}
+}
+
+func validDomain(domain string) bool {
+	if len(domain) < 3 || !strings.Contains(domain, ".") {
+		return false
+	}
+
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+				return false
+			}
+		}
+	}
+
+	return true
+}
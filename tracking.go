@@ -0,0 +1,211 @@
+package mailbus
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/quantonganh/mailbus/pkg/hash"
+)
+
+// Tracking event type
+const (
+	TrackingEventOpen        = "open"
+	TrackingEventClick       = "click"
+	TrackingEventUnsubscribe = "unsubscribe"
+)
+
+// TrackingService is the interface that wraps methods related to engagement tracking
+type TrackingService interface {
+	Record(e *TrackingEvent) error
+	Find(filter TrackingFilter) ([]TrackingEvent, error)
+}
+
+// TrackingEvent represents a subscriber engaging with a delivered campaign
+type TrackingEvent struct {
+	ID           int    `storm:"id,increment" json:"id"`
+	Type         string `storm:"index" json:"type"`
+	DeliveryID   int    `storm:"index" json:"delivery_id"`
+	CampaignID   int    `storm:"index" json:"campaign_id"`
+	SubscriberID int    `json:"subscriber_id"`
+	// URL is the target of a click
+	URL string `json:"url,omitempty"`
+	// Machine flags events that were likely not made by a reader, Reason tells why
+	Machine   bool      `json:"machine"`
+	Reason    string    `json:"reason,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	CreatedAt time.Time `storm:"index" json:"created_at"`
+}
+
+// TrackingFilter represents the criteria used to find tracking events, zero values match everything
+type TrackingFilter struct {
+	CampaignID int
+	DeliveryID int
+	Type       string
+}
+
+// NewTrackingEvent returns an event of a delivery
+func NewTrackingEvent(eventType string, d *Delivery) *TrackingEvent {
+	return &TrackingEvent{
+		Type:         eventType,
+		DeliveryID:   d.ID,
+		CampaignID:   d.CampaignID,
+		SubscriberID: d.SubscriberID,
+		CreatedAt:    time.Now(),
+	}
+}
+
+// OpenHeuristics tells opens made by a reader from the ones made by a machine:
+// privacy proxies that fetch every image on delivery, such as Apple Mail Privacy Protection, and link scanners
+type OpenHeuristics struct {
+	// MinDelay is how long after delivery an open can be made by a reader
+	MinDelay time.Duration
+	// ProxyUserAgents are the exact user agents of privacy proxies, such as the bare "Mozilla/5.0" of Apple's
+	ProxyUserAgents []string
+	// UserAgents are case-insensitive substrings of the user agents of machines
+	UserAgents []string
+	// Networks are the address ranges of machines
+	Networks []*net.IPNet
+}
+
+// Classify flags an event as a machine one if it matches the heuristics
+func (h OpenHeuristics) Classify(e *TrackingEvent, sentAt time.Time) {
+	switch {
+	case h.isProxy(e.UserAgent):
+		e.Machine, e.Reason = true, "privacy proxy"
+	case h.inNetworks(e.IP):
+		e.Machine, e.Reason = true, "machine network"
+	case h.matchesUserAgent(e.UserAgent):
+		e.Machine, e.Reason = true, "machine user agent"
+	case !sentAt.IsZero() && e.CreatedAt.Sub(sentAt) < h.MinDelay:
+		e.Machine, e.Reason = true, "too soon after delivery"
+	}
+}
+
+func (h OpenHeuristics) inNetworks(ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+
+	for _, n := range h.Networks {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h OpenHeuristics) isProxy(userAgent string) bool {
+	for _, ua := range h.ProxyUserAgents {
+		if ua != "" && userAgent == ua {
+			return true
+		}
+	}
+	return false
+}
+
+func (h OpenHeuristics) matchesUserAgent(userAgent string) bool {
+	userAgent = strings.ToLower(userAgent)
+	for _, ua := range h.UserAgents {
+		if ua != "" && strings.Contains(userAgent, strings.ToLower(ua)) {
+			return true
+		}
+	}
+	return false
+}
+
+// TrackingSignature signs an event type, and the target of a click, for the delivery of a campaign to a subscriber,
+// so that tracking links cannot be forged
+func TrackingSignature(keyring *hash.Keyring, eventType string, campaignID, subscriberID int, target string) (LinkSignature, error) {
+	return signLink(keyring, trackingMessage(eventType, campaignID, subscriberID, target), time.Time{})
+}
+
+// VerifyTrackingSignature reports whether a signature was issued for an event type, and the target of a click,
+// of the delivery of a campaign to a subscriber
+func VerifyTrackingSignature(keyring *hash.Keyring, eventType string, campaignID, subscriberID int, target string, sig LinkSignature) bool {
+	return sig.verify(keyring, trackingMessage(eventType, campaignID, subscriberID, target))
+}
+
+func trackingMessage(eventType string, campaignID, subscriberID int, target string) string {
+	message := eventType + ":" + strconv.Itoa(campaignID) + ":" + strconv.Itoa(subscriberID)
+	if target != "" {
+		message += ":" + target
+	}
+	return message
+}
+
+// OpenURL returns the signed URL of the tracking pixel of the delivery of a campaign to a subscriber
+func OpenURL(serverURL string, keyring *hash.Keyring, campaignID, subscriberID int) (string, error) {
+	return trackingURL(serverURL, keyring, TrackingEventOpen, campaignID, subscriberID, "")
+}
+
+// ClickURL returns the signed URL redirecting a subscriber to the target of a link in a campaign
+func ClickURL(serverURL string, keyring *hash.Keyring, campaignID, subscriberID int, target string) (string, error) {
+	return trackingURL(serverURL, keyring, TrackingEventClick, campaignID, subscriberID, target)
+}
+
+func trackingURL(serverURL string, keyring *hash.Keyring, eventType string, campaignID, subscriberID int, target string) (string, error) {
+	sig, err := TrackingSignature(keyring, eventType, campaignID, subscriberID, target)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("campaign", strconv.Itoa(campaignID))
+	query.Set("subscriber", strconv.Itoa(subscriberID))
+	if target != "" {
+		query.Set("url", target)
+	}
+	sig.encode(query)
+	return strings.TrimSuffix(serverURL, "/") + "/tracking/" + eventType + "?" + query.Encode(), nil
+}
+
+// Trackable reports whether a link can be tracked: only web links are, mailto: or anchors are left alone
+func Trackable(target string) bool {
+	u, err := url.Parse(target)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// LinkClicks represents how many times a link of a campaign was clicked, and by how many subscribers
+type LinkClicks struct {
+	URL    string `json:"url"`
+	Total  int    `json:"total"`
+	Unique int    `json:"unique"`
+}
+
+// ClicksByLink counts the click events of a campaign by link, most clicked first
+func ClicksByLink(events []TrackingEvent) []LinkClicks {
+	var (
+		links       []LinkClicks
+		index       = make(map[string]int)
+		subscribers = make(map[string]map[int]bool)
+	)
+	for _, e := range events {
+		if e.Type != TrackingEventClick {
+			continue
+		}
+
+		i, ok := index[e.URL]
+		if !ok {
+			i = len(links)
+			index[e.URL] = i
+			links = append(links, LinkClicks{URL: e.URL})
+			subscribers[e.URL] = make(map[int]bool)
+		}
+		links[i].Total++
+		if !subscribers[e.URL][e.SubscriberID] {
+			subscribers[e.URL][e.SubscriberID] = true
+			links[i].Unique++
+		}
+	}
+
+	sort.SliceStable(links, func(i, j int) bool {
+		return links[i].Total > links[j].Total
+	})
+	return links
+}
@@ -0,0 +1,30 @@
+package mailbus
+
+// Transport driver
+const (
+	TransportSMTP     = "smtp"
+	TransportSendmail = "sendmail"
+	TransportFile     = "file"
+	TransportHTTP     = "http"
+)
+
+// Transport hands messages over to the next hop: a relay, the local MTA, a provider API or a directory
+type Transport interface {
+	Send(msg *Message) error
+	Close() error
+}
+
+// Message represents an email ready to be sent
+type Message struct {
+	// From and To are the envelope sender and recipient addresses
+	From string   `json:"from"`
+	To   []string `json:"to"`
+
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	// Headers are the header fields set on top of From, To and Subject, such as List-Unsubscribe
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Raw is the whole message as it goes over the wire, DKIM signature included
+	Raw []byte `json:"raw"`
+}
@@ -0,0 +1,68 @@
+package transport
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/quantonganh/mailbus"
+)
+
+type fileTransport struct {
+	dir     string
+	maildir bool
+}
+
+// NewFileTransport returns a transport that writes messages to a directory instead of sending them,
+// either as .eml files or, if maildir is true, into the new/ directory of a Maildir
+func NewFileTransport(dir string, maildir bool) (mailbus.Transport, error) {
+	subdirs := []string{""}
+	if maildir {
+		subdirs = []string{"tmp", "new", "cur"}
+	}
+	for _, sub := range subdirs {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, errors.Wrap(err, "failed to create outbox")
+		}
+	}
+
+	return &fileTransport{
+		dir:     dir,
+		maildir: maildir,
+	}, nil
+}
+
+// Send writes a message to a temporary file first, then moves it into place so that readers never see partial messages
+func (t *fileTransport) Send(msg *mailbus.Message) error {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d.%s.mailbus", time.Now().UnixNano(), hex.EncodeToString(b))
+
+	tmp := filepath.Join(t.dir, "."+name)
+	dst := filepath.Join(t.dir, name+".eml")
+	if t.maildir {
+		tmp = filepath.Join(t.dir, "tmp", name)
+		dst = filepath.Join(t.dir, "new", name)
+	}
+
+	if err := os.WriteFile(tmp, msg.Raw, 0644); err != nil {
+		return errors.Wrap(err, "failed to write message")
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		_ = os.Remove(tmp)
+		return errors.Wrap(err, "failed to move message to the outbox")
+	}
+
+	return nil
+}
+
+func (t *fileTransport) Close() error {
+	return nil
+}
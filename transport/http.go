@@ -0,0 +1,93 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/quantonganh/mailbus"
+)
+
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// NewHTTPTransport returns a transport that posts messages as JSON to the API of an email provider.
+// headers are added to every request, typically to authenticate it.
+func NewHTTPTransport(url string, headers map[string]string, timeout time.Duration) mailbus.Transport {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	return &httpTransport{
+		url:     url,
+		headers: headers,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// httpReply is the optional body of a provider response
+type httpReply struct {
+	SMTPCode int    `json:"smtp_code"`
+	Message  string `json:"message"`
+}
+
+// Send posts a message, see mailbus.Message for the JSON fields. A 2xx status means the message was accepted.
+// A rejection is reported with the SMTP code of the response body if there is one,
+// otherwise 429 and 5xx statuses are temporary failures.
+func (t *httpTransport) Send(msg *mailbus.Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode message")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to post message to %s", t.url)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var reply httpReply
+	_ = json.Unmarshal(data, &reply)
+	if reply.Message == "" {
+		reply.Message = string(bytes.TrimSpace(data))
+	}
+
+	if reply.SMTPCode >= 400 && reply.SMTPCode < 600 {
+		return &textproto.Error{Code: reply.SMTPCode, Msg: reply.Message}
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return &textproto.Error{Code: 451, Msg: fmt.Sprintf("4.3.0 %s: %s", resp.Status, reply.Message)}
+	}
+
+	// anything else is most likely a problem on our side, such as a wrong API key: don't bounce the recipient for it
+	return errors.Errorf("%s rejected the message with %s: %s", t.url, resp.Status, reply.Message)
+}
+
+func (t *httpTransport) Close() error {
+	t.client.CloseIdleConnections()
+	return nil
+}
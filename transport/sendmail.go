@@ -0,0 +1,62 @@
+package transport
+
+import (
+	"bytes"
+	"net/textproto"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/quantonganh/mailbus"
+)
+
+// sendmail exit codes, from sysexits.h
+const (
+	exNoUser   = 67
+	exNoHost   = 68
+	exTempFail = 75
+)
+
+type sendmailTransport struct {
+	path string
+}
+
+// NewSendmailTransport returns a transport that pipes messages to the sendmail program of the local MTA
+func NewSendmailTransport(path string) mailbus.Transport {
+	return &sendmailTransport{
+		path: path,
+	}
+}
+
+// Send runs sendmail with the envelope on its command line and the message on its standard input
+func (t *sendmailTransport) Send(msg *mailbus.Message) error {
+	args := append([]string{"-i", "-f", msg.From, "--"}, msg.To...)
+	cmd := exec.Command(t.path, args...)
+	cmd.Stdin = bytes.NewReader(msg.Raw)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err == nil {
+		return nil
+	}
+
+	// report the exit status the way an SMTP server would, so that deliveries are retried or bounced
+	text := strings.TrimSpace(stderr.String())
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		switch exitErr.ExitCode() {
+		case exTempFail:
+			return &textproto.Error{Code: 451, Msg: "4.0.0 sendmail: " + text}
+		case exNoUser, exNoHost:
+			return &textproto.Error{Code: 550, Msg: "5.1.1 sendmail: " + text}
+		}
+	}
+
+	return errors.Wrapf(err, "failed to run %s: %s", t.path, text)
+}
+
+func (t *sendmailTransport) Close() error {
+	return nil
+}
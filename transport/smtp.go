@@ -0,0 +1,121 @@
+package transport
+
+import (
+	"io"
+	"net/textproto"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"gopkg.in/gomail.v2"
+
+	"github.com/quantonganh/mailbus"
+)
+
+type smtpTransport struct {
+	dialer *gomail.Dialer
+
+	mu     sync.Mutex
+	idle   []gomail.SendCloser
+	size   int
+	closed bool
+}
+
+// NewSMTPTransport returns a transport that sends through an SMTP relay.
+// Up to size authenticated connections are kept open between sends and reused.
+func NewSMTPTransport(host string, port int, username, password string, size int) mailbus.Transport {
+	if size < 1 {
+		size = 1
+	}
+
+	return &smtpTransport{
+		dialer: gomail.NewDialer(host, port, username, password),
+		size:   size,
+	}
+}
+
+// Send sends a message over an idle connection, or a new one if none is available.
+// Send is safe for concurrent use, every caller gets a connection of its own.
+func (t *smtpTransport) Send(msg *mailbus.Message) error {
+	s, reused, err := t.get()
+	if err != nil {
+		return errors.Wrapf(err, "failed to dial %s", t.dialer.Host)
+	}
+
+	err = t.send(s, msg)
+	// the server may have dropped an idle connection in the meantime (timeout, restart),
+	// that says nothing about the message: try again once over a new connection
+	var reply *textproto.Error
+	if err != nil && reused && !errors.As(err, &reply) {
+		if s, err = t.dialer.Dial(); err != nil {
+			return errors.Wrapf(err, "failed to dial %s", t.dialer.Host)
+		}
+		err = t.send(s, msg)
+	}
+
+	return err
+}
+
+func (t *smtpTransport) send(s gomail.SendCloser, msg *mailbus.Message) error {
+	// send through the SendCloser directly so that the SMTP reply is kept in the error chain
+	if err := s.Send(msg.From, msg.To, rawMessage(msg.Raw)); err != nil {
+		// the transaction is left half done, don't reuse the connection
+		_ = s.Close()
+		return errors.Wrapf(err, "failed to send mail to %s", strings.Join(msg.To, ", "))
+	}
+
+	t.put(s)
+	return nil
+}
+
+// get returns an idle connection, or dials a new one. reused reports whether the connection was idle.
+func (t *smtpTransport) get() (s gomail.SendCloser, reused bool, err error) {
+	t.mu.Lock()
+	if n := len(t.idle); n > 0 {
+		s := t.idle[n-1]
+		t.idle = t.idle[:n-1]
+		t.mu.Unlock()
+		return s, true, nil
+	}
+	t.mu.Unlock()
+
+	s, err = t.dialer.Dial()
+	return s, false, err
+}
+
+func (t *smtpTransport) put(s gomail.SendCloser) {
+	t.mu.Lock()
+	if !t.closed && len(t.idle) < t.size {
+		t.idle = append(t.idle, s)
+		t.mu.Unlock()
+		return
+	}
+	t.mu.Unlock()
+
+	_ = s.Close()
+}
+
+// Close closes the idle connections
+func (t *smtpTransport) Close() error {
+	t.mu.Lock()
+	idle := t.idle
+	t.idle = nil
+	t.closed = true
+	t.mu.Unlock()
+
+	var err error
+	for _, s := range idle {
+		if cerr := s.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// rawMessage is a message that has already been written out
+type rawMessage []byte
+
+func (m rawMessage) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(m)
+	return int64(n), err
+}
@@ -0,0 +1,247 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quantonganh/mailbus"
+)
+
+func newTestMessage() *mailbus.Message {
+	return &mailbus.Message{
+		From:    "newsletter@example.com",
+		To:      []string{"alice@example.com"},
+		Subject: "Issue #1",
+		HTML:    "<p>Hello</p>",
+		Raw:     []byte("From: newsletter@example.com\r\nTo: alice@example.com\r\nSubject: Issue #1\r\n\r\n<p>Hello</p>\r\n"),
+	}
+}
+
+func TestFileTransport(t *testing.T) {
+	for _, maildir := range []bool{false, true} {
+		dir := t.TempDir()
+		tr, err := NewFileTransport(dir, maildir)
+		require.NoError(t, err)
+		require.NoError(t, tr.Send(newTestMessage()))
+
+		pattern := filepath.Join(dir, "*.eml")
+		if maildir {
+			pattern = filepath.Join(dir, "new", "*")
+		}
+		files, err := filepath.Glob(pattern)
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+
+		data, err := os.ReadFile(files[0])
+		require.NoError(t, err)
+		assert.Equal(t, newTestMessage().Raw, data)
+	}
+}
+
+func TestSendmailTransport(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	script := filepath.Join(dir, "sendmail")
+	require.NoError(t, os.WriteFile(script, []byte(`#!/bin/sh
+echo "$@" > `+out+`
+cat >> `+out+`
+case "$5" in
+  deferred@*) echo "queue full" >&2; exit 75 ;;
+  unknown@*) echo "no such user" >&2; exit 67 ;;
+esac
+`), 0755))
+
+	tr := NewSendmailTransport(script)
+	require.NoError(t, tr.Send(newTestMessage()))
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "-i -f newsletter@example.com -- alice@example.com\n"))
+	assert.Contains(t, string(data), "Subject: Issue #1")
+
+	tests := map[string]int{
+		"deferred@example.com": 451,
+		"unknown@example.com":  550,
+	}
+	for to, code := range tests {
+		msg := newTestMessage()
+		msg.To = []string{to}
+		reply := mailbus.NewSMTPReply(tr.Send(msg))
+		assert.Equal(t, code, reply.Code, to)
+	}
+}
+
+func TestHTTPTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var msg mailbus.Message
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		switch msg.To[0] {
+		case "throttled@example.com":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "unknown@example.com":
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = w.Write([]byte(`{"smtp_code": 550, "message": "5.1.1 user unknown"}`))
+		default:
+			if string(msg.Raw) != string(newTestMessage().Raw) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	defer server.Close()
+
+	tr := NewHTTPTransport(server.URL, map[string]string{"Authorization": "Bearer key"}, 0)
+	require.NoError(t, tr.Send(newTestMessage()))
+
+	for to, code := range map[string]int{"throttled@example.com": 451, "unknown@example.com": 550} {
+		msg := newTestMessage()
+		msg.To = []string{to}
+		assert.Equal(t, code, mailbus.NewSMTPReply(tr.Send(msg)).Code, to)
+	}
+
+	// a wrong API key must not be mistaken for a bounce
+	err := NewHTTPTransport(server.URL, nil, 0).Send(newTestMessage())
+	require.Error(t, err)
+	var protoErr *textproto.Error
+	assert.False(t, errors.As(err, &protoErr))
+}
+
+// startSMTPServer starts a fake SMTP server and returns its port and the number of connections it accepted
+func startSMTPServer(t *testing.T, drop bool) (int, *int32) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+
+	var connections int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&connections, 1)
+			go serveSMTP(conn, drop)
+		}
+	}()
+
+	return ln.Addr().(*net.TCPAddr).Port, &connections
+}
+
+func TestSMTPTransportReusesConnections(t *testing.T) {
+	port, connections := startSMTPServer(t, false)
+	tr := NewSMTPTransport("127.0.0.1", port, "", "", 1)
+	defer tr.Close()
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, tr.Send(newTestMessage()))
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(connections))
+
+	// a rejected transaction closes the connection, the next message goes over a new one
+	msg := newTestMessage()
+	msg.To = []string{"unknown@example.com"}
+	assert.Equal(t, 550, mailbus.NewSMTPReply(tr.Send(msg)).Code)
+	require.NoError(t, tr.Send(newTestMessage()))
+	assert.Equal(t, int32(2), atomic.LoadInt32(connections))
+}
+
+func TestSMTPTransportReconnects(t *testing.T) {
+	port, connections := startSMTPServer(t, true)
+	tr := NewSMTPTransport("127.0.0.1", port, "", "", 1)
+	defer tr.Close()
+
+	// the server hangs up after every message, the idle connection is found dead on the next send
+	for i := 0; i < 3; i++ {
+		require.NoError(t, tr.Send(newTestMessage()))
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(connections))
+}
+
+func TestSMTPTransportConcurrentSends(t *testing.T) {
+	port, connections := startSMTPServer(t, false)
+	tr := NewSMTPTransport("127.0.0.1", port, "", "", 4)
+	defer tr.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				assert.NoError(t, tr.Send(newTestMessage()))
+			}
+		}()
+	}
+	wg.Wait()
+
+	// never more connections than concurrent senders
+	assert.LessOrEqual(t, atomic.LoadInt32(connections), int32(4))
+}
+
+// serveSMTP is a minimal SMTP server that rejects unknown@ recipients, and hangs up after a message if drop is set
+func serveSMTP(conn net.Conn, drop bool) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		_, _ = conn.Write([]byte(line + "\r\n"))
+	}
+
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "RCPT") && strings.Contains(cmd, "UNKNOWN@"):
+			reply("550 5.1.1 user unknown")
+		case strings.HasPrefix(cmd, "DATA"):
+			reply("354 go ahead")
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+			}
+			reply("250 2.0.0 queued")
+			if drop {
+				return
+			}
+		case strings.HasPrefix(cmd, "QUIT"):
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
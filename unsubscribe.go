@@ -0,0 +1,53 @@
+package mailbus
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/quantonganh/mailbus/pkg/hash"
+)
+
+// UnsubscribeSignature signs the address of a subscriber of a list, and the campaign the link was sent in if any,
+// so that unsubscribe links cannot be forged. The link expires at expiresAt, unless it is zero.
+func UnsubscribeSignature(keyring *hash.Keyring, list, email string, campaignID int, expiresAt time.Time) (LinkSignature, error) {
+	return signLink(keyring, unsubscribeMessage(list, email, campaignID), expiresAt)
+}
+
+// VerifyUnsubscribeSignature reports whether a signature was issued for a subscriber of a list, in a campaign
+// if campaignID is not 0. Links signed over the address alone, as they were before lists, are still accepted.
+func VerifyUnsubscribeSignature(keyring *hash.Keyring, list, email string, campaignID int, sig LinkSignature) bool {
+	if sig.verify(keyring, unsubscribeMessage(list, email, campaignID)) {
+		return true
+	}
+
+	legacy := sig.KeyID == "" && sig.ExpiresAt.IsZero() && campaignID == 0
+	return legacy && sig.verify(keyring, email)
+}
+
+func unsubscribeMessage(list, email string, campaignID int) string {
+	message := list + ":" + email
+	if campaignID != 0 {
+		message += ":" + strconv.Itoa(campaignID)
+	}
+	return message
+}
+
+// UnsubscribeURL returns the signed link a subscriber follows, or posts to, to leave a list.
+// Links sent in a campaign carry its ID, so that unsubscribes can be attributed to it.
+func UnsubscribeURL(serverURL string, keyring *hash.Keyring, list, email string, campaignID int, expiresAt time.Time) (string, error) {
+	sig, err := UnsubscribeSignature(keyring, list, email, campaignID, expiresAt)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("list", list)
+	query.Set("email", email)
+	if campaignID != 0 {
+		query.Set("campaign", strconv.Itoa(campaignID))
+	}
+	sig.encode(query)
+	return strings.TrimSuffix(serverURL, "/") + "/unsubscribe?" + query.Encode(), nil
+}